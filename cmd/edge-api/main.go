@@ -7,6 +7,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/apps"
 	"github.com/TeaOSLab/EdgeAPI/internal/configs"
 	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/nodes"
 	"github.com/TeaOSLab/EdgeAPI/internal/setup"
 	_ "github.com/TeaOSLab/EdgeAPI/internal/tasks"
@@ -27,7 +28,7 @@ func main() {
 	var app = apps.NewAppCmd()
 	app.Version(teaconst.Version)
 	app.Product(teaconst.ProductName)
-	app.Usage(teaconst.ProcessName + " [-h|-v|start|stop|restart|setup|upgrade|service|daemon|issues]")
+	app.Usage(teaconst.ProcessName + " [-h|-v|start|stop|restart|setup|upgrade|service|daemon|issues|secrets.keygen|secrets.encrypt]")
 
 	// 短版本号
 	app.On("-V", func() {
@@ -221,6 +222,39 @@ func main() {
 			}
 		}
 	})
+	app.On("secrets.keygen", func() {
+		var path = encrypt.MasterKeyFilePath()
+		version, err := encrypt.GenerateMasterKey(path)
+		if err != nil {
+			fmt.Println("[ERROR]generate master key failed: " + err.Error())
+			return
+		}
+		fmt.Println("generated master key version '" + types.String(version) + "' in '" + path + "'")
+		fmt.Println("please copy the key file to all api nodes, then run '" + teaconst.ProcessName + " secrets.encrypt'")
+	})
+	app.On("secrets.encrypt", func() {
+		var sock = gosock.NewTmpSock(teaconst.ProcessName)
+		reply, err := sock.Send(&gosock.Command{Code: "secrets.encrypt"})
+		if err != nil {
+			fmt.Println("[ERROR]the api node should be running: " + err.Error())
+			return
+		}
+		var resultMap = maps.NewMap(reply.Params)
+		var counts = resultMap.GetMap("result")
+		if counts != nil {
+			fmt.Println("key version: " + counts.GetString("keyVersion"))
+			for _, name := range []string{"sslCerts", "nodeGrants", "dbNodes", "dnsProviders", "acmeUsers"} {
+				if counts.Has(name) {
+					fmt.Println(name + ": " + counts.GetString(name))
+				}
+			}
+		}
+		if !resultMap.GetBool("isOk") {
+			fmt.Println("[ERROR]" + resultMap.GetString("err"))
+			return
+		}
+		fmt.Println("done")
+	})
 
 	app.Run(func() {
 		nodes.NewAPINode().Start()
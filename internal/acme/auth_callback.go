@@ -1,3 +1,9 @@
 package acme
 
 type AuthCallback func(domain, token, keyAuth string)
+
+// TLSALPNAuthCallback TLS-ALPN-01认证证书生成后的回调
+type TLSALPNAuthCallback func(domain string, certData []byte, keyData []byte) error
+
+// TLSALPNCleanUpCallback TLS-ALPN-01认证结束后的回调
+type TLSALPNCleanUpCallback func(domain string) error
@@ -0,0 +1,102 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"net"
+	"strings"
+)
+
+var lookupCNAME = net.LookupCNAME
+
+// DNSDelegationResult CNAME委托检查结果
+type DNSDelegationResult struct {
+	Domain        string `json:"domain"`        // 证书域名
+	ChallengeName string `json:"challengeName"` // 认证记录名，比如 _acme-challenge.example.com
+	Target        string `json:"target"`        // 实际的CNAME目标
+	Expected      string `json:"expected"`      // 设置中期望的CNAME目标，为空表示不限制
+	IsDelegated   bool   `json:"isDelegated"`   // 是否需要委托，域名本身就在DNS主域名下时不需要委托
+	IsOk          bool   `json:"isOk"`
+	Error         string `json:"error"`
+}
+
+// SuggestDNSDelegationTarget 为某个域名建议一个委托目标
+func SuggestDNSDelegationTarget(domain string, dnsDomain string) string {
+	domain = strings.TrimPrefix(normalizeDomain(domain), "*.")
+	return "_acme-challenge." + strings.ReplaceAll(domain, ".", "-") + "." + normalizeDomain(dnsDomain)
+}
+
+// CheckDNSDelegations 检查一组域名的_acme-challenge是否已经通过CNAME委托到DNS主域名下
+// delegations 中为设置的委托目标：域名 => CNAME目标
+func CheckDNSDelegations(dnsDomain string, domains []string, delegations map[string]string) []*DNSDelegationResult {
+	dnsDomain = normalizeDomain(dnsDomain)
+	delegations = normalizeDelegations(delegations)
+
+	var results = []*DNSDelegationResult{}
+	var domainMap = map[string]bool{}
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(normalizeDomain(domain), "*.")
+		if len(domain) == 0 || domainMap[domain] {
+			continue
+		}
+		domainMap[domain] = true
+
+		var result = &DNSDelegationResult{
+			Domain:        domain,
+			ChallengeName: "_acme-challenge." + domain,
+			Expected:      delegations[domain],
+		}
+		results = append(results, result)
+
+		if isSubDomainOf(result.ChallengeName, dnsDomain) {
+			result.IsOk = true
+			continue
+		}
+		result.IsDelegated = true
+
+		target, err := lookupCNAME(result.ChallengeName)
+		if err != nil {
+			result.Error = "lookup CNAME failed: " + err.Error()
+			continue
+		}
+		target = normalizeDomain(target)
+		result.Target = target
+		if target == result.ChallengeName {
+			result.Error = "no CNAME record found for '" + result.ChallengeName + "'"
+			continue
+		}
+		if len(result.Expected) > 0 && target != result.Expected {
+			result.Error = "CNAME target should be '" + result.Expected + "', but got '" + target + "'"
+			continue
+		}
+		if !isSubDomainOf(target, dnsDomain) {
+			result.Error = "CNAME target '" + target + "' is not under '" + dnsDomain + "'"
+			continue
+		}
+		result.IsOk = true
+	}
+	return results
+}
+
+// 统一委托设置中的域名格式
+func normalizeDelegations(delegations map[string]string) map[string]string {
+	var result = map[string]string{}
+	for domain, target := range delegations {
+		domain = strings.TrimPrefix(normalizeDomain(domain), "*.")
+		target = normalizeDomain(target)
+		if len(domain) == 0 || len(target) == 0 {
+			continue
+		}
+		result[domain] = target
+	}
+	return result
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// 判断是否为某个域名的子域名
+func isSubDomainOf(domain string, parent string) bool {
+	return len(parent) > 0 && strings.HasSuffix(domain, "."+parent)
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestCheckDNSDelegations(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var oldLookup = lookupCNAME
+	defer func() {
+		lookupCNAME = oldLookup
+	}()
+	lookupCNAME = func(host string) (string, error) {
+		switch host {
+		case "_acme-challenge.a.com":
+			return "_acme-challenge.a-com.acme.example.com.", nil
+		case "_acme-challenge.b.com":
+			return "_acme-challenge.b.com.", nil
+		case "_acme-challenge.c.com":
+			return "other.example.org.", nil
+		}
+		return "", errors.New("no such host")
+	}
+
+	var results = CheckDNSDelegations("acme.example.com", []string{"a.com", "*.a.com", "b.com", "c.com", "d.com", "www.acme.example.com"}, map[string]string{
+		"*.c.com": "_acme-challenge.c-com.acme.example.com",
+	})
+	a.IsTrue(len(results) == 5)
+
+	a.IsTrue(results[0].Domain == "a.com" && results[0].IsOk && results[0].IsDelegated)
+	a.IsTrue(results[1].Domain == "b.com" && !results[1].IsOk)
+	a.IsTrue(results[2].Domain == "c.com" && !results[2].IsOk && results[2].Expected == "_acme-challenge.c-com.acme.example.com")
+	a.IsTrue(results[3].Domain == "d.com" && !results[3].IsOk)
+	a.IsTrue(results[4].Domain == "www.acme.example.com" && results[4].IsOk && !results[4].IsDelegated)
+	for _, result := range results {
+		t.Log(result.Domain, result.IsOk, result.Error)
+	}
+}
+
+func TestSuggestDNSDelegationTarget(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(SuggestDNSDelegationTarget("*.www.a.com", "acme.example.com.") == "_acme-challenge.www-a-com.acme.example.com")
+}
@@ -17,17 +17,28 @@ type DNSProvider struct {
 	raw       dnsclients.ProviderInterface
 	dnsDomain string
 
+	delegations    map[string]string // domain => target
+	delegatedFQDNs map[string]string // _acme-challenge fqdn => target fqdn
+
 	locker             sync.Mutex
 	deletedRecordNames []string
 }
 
 func NewDNSProvider(raw dnsclients.ProviderInterface, dnsDomain string) *DNSProvider {
 	return &DNSProvider{
-		raw:       raw,
-		dnsDomain: dnsDomain,
+		raw:            raw,
+		dnsDomain:      dnsDomain,
+		delegations:    map[string]string{},
+		delegatedFQDNs: map[string]string{},
 	}
 }
 
+// SetDelegations 设置CNAME委托目标：域名 => CNAME目标
+// 没有设置的域名会通过查询CNAME记录自动检测
+func (this *DNSProvider) SetDelegations(delegations map[string]string) {
+	this.delegations = normalizeDelegations(delegations)
+}
+
 func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	_ = os.Setenv("LEGO_DISABLE_CNAME_SUPPORT", "true")
 	var info = dns01.GetChallengeInfo(domain, keyAuth)
@@ -36,11 +47,10 @@ func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	var value = info.Value
 
 	// 设置记录
-	var index = strings.Index(fqdn, "."+this.dnsDomain)
-	if index < 0 {
-		return errors.New("invalid fqdn value")
+	recordName, err := this.findRecordName(domain, fqdn)
+	if err != nil {
+		return err
 	}
-	var recordName = fqdn[:index]
 
 	// 先删除老的
 	this.locker.Lock()
@@ -64,7 +74,7 @@ func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	}
 
 	// 添加新的
-	err := this.raw.AddRecord(this.dnsDomain, &dnstypes.Record{
+	err = this.raw.AddRecord(this.dnsDomain, &dnstypes.Record{
 		Id:    "",
 		Name:  recordName,
 		Type:  dnstypes.RecordTypeTXT,
@@ -84,5 +94,72 @@ func (this *DNSProvider) Timeout() (timeout, interval time.Duration) {
 }
 
 func (this *DNSProvider) CleanUp(domain, token, keyAuth string) error {
+	// 只清理委托的记录，非委托的记录会在下次申请时删除
+	var info = dns01.GetChallengeInfo(domain, keyAuth)
+	this.locker.Lock()
+	_, isDelegated := this.delegatedFQDNs[info.EffectiveFQDN]
+	this.locker.Unlock()
+	if !isDelegated {
+		return nil
+	}
+
+	recordName, err := this.findRecordName(domain, info.EffectiveFQDN)
+	if err != nil {
+		return err
+	}
+	records, err := this.raw.QueryRecords(this.dnsDomain, recordName, dnstypes.RecordTypeTXT)
+	if err != nil {
+		return fmt.Errorf("query DNS record failed: %w", err)
+	}
+	for _, record := range records {
+		if strings.Trim(record.Value, "\"") != info.Value {
+			continue
+		}
+		err = this.raw.DeleteRecord(this.dnsDomain, record)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// WrapPreCheck 检查记录是否生效时，使用委托的目标代替原有的记录名
+func (this *DNSProvider) WrapPreCheck(domain, fqdn, value string, check dns01.PreCheckFunc) (bool, error) {
+	this.locker.Lock()
+	target, ok := this.delegatedFQDNs[fqdn]
+	this.locker.Unlock()
+	if ok {
+		fqdn = target
+	}
+	return check(fqdn, value)
+}
+
+// 查找需要在DNS主域名下设置的记录名
+func (this *DNSProvider) findRecordName(domain string, fqdn string) (string, error) {
+	var dnsDomain = normalizeDomain(this.dnsDomain)
+	var name = normalizeDomain(fqdn)
+	if isSubDomainOf(name, dnsDomain) {
+		return strings.TrimSuffix(name, "."+dnsDomain), nil
+	}
+
+	// CNAME委托
+	domain = strings.TrimPrefix(normalizeDomain(domain), "*.")
+	target, ok := this.delegations[domain]
+	if !ok {
+		var err error
+		target, err = lookupCNAME(name)
+		if err != nil {
+			return "", fmt.Errorf("lookup CNAME of '%s' failed: %w", name, err)
+		}
+		target = normalizeDomain(target)
+	}
+	if !isSubDomainOf(target, dnsDomain) {
+		return "", errors.New("'" + name + "' should be CNAME to a sub domain of '" + dnsDomain + "', but got '" + target + "'")
+	}
+
+	this.locker.Lock()
+	this.delegatedFQDNs[fqdn] = target + "."
+	this.locker.Unlock()
+
+	return strings.TrimSuffix(target, "."+dnsDomain), nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import "github.com/go-acme/lego/v4/certcrypto"
+
+type KeyType = string
+
+const (
+	KeyTypeRSA2048 KeyType = "RSA2048"
+	KeyTypeRSA3072 KeyType = "RSA3072"
+	KeyTypeRSA4096 KeyType = "RSA4096"
+	KeyTypeEC256   KeyType = "EC256"
+	KeyTypeEC384   KeyType = "EC384"
+)
+
+const DefaultKeyType = KeyTypeRSA2048
+
+type KeyTypeDefinition struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// FindAllKeyTypes 所有支持的证书密钥类型
+func FindAllKeyTypes() []*KeyTypeDefinition {
+	return []*KeyTypeDefinition{
+		{
+			Name: "RSA 2048",
+			Code: KeyTypeRSA2048,
+		},
+		{
+			Name: "RSA 3072",
+			Code: KeyTypeRSA3072,
+		},
+		{
+			Name: "RSA 4096",
+			Code: KeyTypeRSA4096,
+		},
+		{
+			Name: "ECDSA P-256",
+			Code: KeyTypeEC256,
+		},
+		{
+			Name: "ECDSA P-384",
+			Code: KeyTypeEC384,
+		},
+	}
+}
+
+// IsValidKeyType 检查密钥类型是否有效
+func IsValidKeyType(keyType KeyType) bool {
+	for _, def := range FindAllKeyTypes() {
+		if def.Code == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsECDSAKeyType 是否为ECDSA密钥
+func IsECDSAKeyType(keyType KeyType) bool {
+	return keyType == KeyTypeEC256 || keyType == KeyTypeEC384
+}
+
+// 转换为lego中的密钥类型，无效的类型使用默认值
+func legoKeyType(keyType KeyType) certcrypto.KeyType {
+	switch keyType {
+	case KeyTypeRSA3072:
+		return certcrypto.RSA3072
+	case KeyTypeRSA4096:
+		return certcrypto.RSA4096
+	case KeyTypeEC256:
+		return certcrypto.EC256
+	case KeyTypeEC384:
+		return certcrypto.EC384
+	}
+	return certcrypto.RSA2048
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestKeyType(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(IsValidKeyType(KeyTypeEC256))
+	a.IsFalse(IsValidKeyType("RSA1024"))
+	a.IsTrue(IsECDSAKeyType(KeyTypeEC384))
+	a.IsFalse(IsECDSAKeyType(KeyTypeRSA4096))
+	a.IsTrue(legoKeyType("") == certcrypto.RSA2048)
+	a.IsTrue(legoKeyType(KeyTypeEC256) == certcrypto.EC256)
+	a.IsTrue(legoKeyType(KeyTypeRSA3072) == certcrypto.RSA3072)
+}
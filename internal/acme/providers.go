@@ -2,6 +2,8 @@
 
 package acme
 
+import "github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+
 const DefaultProviderCode = "letsencrypt"
 
 type Provider struct {
@@ -12,6 +14,20 @@ type Provider struct {
 	TestAPIURL     string `json:"testAPIURL"`
 	RequireEAB     bool   `json:"requireEAB"`
 	EABDescription string `json:"eabDescription"`
+
+	// 自定义服务商
+	IsCustom bool   `json:"isCustom"` // 是否为管理员自定义
+	CACert   string `json:"caCert"`   // 自定义信任的根证书（PEM），用于私有CA
+}
+
+// FindAllAvailableProviders 所有可用的服务商，包括内置的和管理员自定义的
+func FindAllAvailableProviders() ([]*Provider, error) {
+	var providers = FindAllProviders()
+	customProviders, err := findCustomProviders()
+	if err != nil {
+		return nil, err
+	}
+	return append(providers, customProviders...), nil
 }
 
 func FindProviderWithCode(code string) *Provider {
@@ -20,5 +36,16 @@ func FindProviderWithCode(code string) *Provider {
 			return provider
 		}
 	}
+
+	customProviders, err := findCustomProviders()
+	if err != nil {
+		remotelogs.Error("ACME", "find custom providers failed: "+err.Error())
+		return nil
+	}
+	for _, provider := range customProviders {
+		if provider.Code == code {
+			return provider
+		}
+	}
 	return nil
 }
@@ -0,0 +1,95 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/iwind/TeaGo/dbs"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CustomProviderPrefix 自定义服务商代号前缀
+const CustomProviderPrefix = "custom-"
+
+// CustomProviderDAOInterface 自定义服务商存储接口
+type CustomProviderDAOInterface interface {
+	FindAllEnabledCustomProviders(tx *dbs.Tx) ([]*Provider, error)
+}
+
+// 自定义服务商缓存时间，用来同步其他API节点上的修改
+const customProvidersCacheLife = 1 * time.Minute
+
+var sharedCustomProviderDAO CustomProviderDAOInterface
+
+var customProvidersLocker = &sync.Mutex{}
+var customProviders []*Provider
+var customProvidersExpiresAt time.Time
+
+// SetCustomProviderDAO 设置自定义服务商存储接口
+func SetCustomProviderDAO(dao CustomProviderDAOInterface) {
+	sharedCustomProviderDAO = dao
+	ResetCustomProviders()
+}
+
+// ResetCustomProviders 清除自定义服务商缓存，在服务商修改后调用
+func ResetCustomProviders() {
+	customProvidersLocker.Lock()
+	customProviders = nil
+	customProvidersExpiresAt = time.Time{}
+	customProvidersLocker.Unlock()
+}
+
+// 从缓存中获取自定义服务商
+func findCustomProviders() ([]*Provider, error) {
+	if sharedCustomProviderDAO == nil {
+		return nil, nil
+	}
+
+	customProvidersLocker.Lock()
+	defer customProvidersLocker.Unlock()
+
+	if time.Now().Before(customProvidersExpiresAt) {
+		return customProviders, nil
+	}
+
+	providers, err := sharedCustomProviderDAO.FindAllEnabledCustomProviders(nil)
+	if err != nil {
+		return nil, err
+	}
+	customProviders = providers
+	customProvidersExpiresAt = time.Now().Add(customProvidersCacheLife)
+	return providers, nil
+}
+
+// ParseCACertPool 解析自定义根证书
+func ParseCACertPool(caCert string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(caCert)) {
+		return nil, errors.New("invalid CA certificate, should be in PEM format")
+	}
+	return pool, nil
+}
+
+// 构造信任自定义根证书的HTTP客户端
+func newCustomHTTPClient(caCert string, timeout time.Duration) (*http.Client, error) {
+	pool, err := ParseCACertPool(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs: pool,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/iwind/TeaGo/dbs"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseCACertPool(t *testing.T) {
+	_, err := ParseCACertPool("invalid")
+	if err == nil {
+		t.Fatal("should fail with invalid certificate")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseCACertPool(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProviderWithCode(t *testing.T) {
+	for _, code := range []string{DefaultProviderCode, "zerossl", "google", "buypass"} {
+		if FindProviderWithCode(code) == nil {
+			t.Fatal("provider '" + code + "' not found")
+		}
+	}
+}
+
+type testCustomProviderDAO struct {
+	count int
+	err   error
+}
+
+func (this *testCustomProviderDAO) FindAllEnabledCustomProviders(tx *dbs.Tx) ([]*Provider, error) {
+	this.count++
+	if this.err != nil {
+		return nil, this.err
+	}
+	return []*Provider{
+		{
+			Name:     "Custom",
+			Code:     CustomProviderPrefix + "1",
+			IsCustom: true,
+		},
+	}, nil
+}
+
+func TestFindCustomProviders_Cache(t *testing.T) {
+	var dao = &testCustomProviderDAO{}
+	SetCustomProviderDAO(dao)
+	defer SetCustomProviderDAO(nil)
+
+	for i := 0; i < 3; i++ {
+		if FindProviderWithCode(CustomProviderPrefix+"1") == nil {
+			t.Fatal("custom provider not found")
+		}
+	}
+	if dao.count != 1 {
+		t.Fatal("custom providers should be cached, but queried", dao.count, "times")
+	}
+
+	// 内置的服务商不需要查询
+	ResetCustomProviders()
+	if FindProviderWithCode(DefaultProviderCode) == nil {
+		t.Fatal("provider '" + DefaultProviderCode + "' not found")
+	}
+	if dao.count != 1 {
+		t.Fatal("should not query custom providers for builtin provider")
+	}
+
+	dao.err = errors.New("test error")
+	_, err := FindAllAvailableProviders()
+	if err == nil {
+		t.Fatal("should return the error")
+	}
+}
@@ -20,5 +20,22 @@ func FindAllProviders() []*Provider {
 			RequireEAB:     true,
 			EABDescription: "在官网<a href=\"https://app.zerossl.com/developer\" target=\"_blank\">[Developer]</a>页面底部点击\"Generate\"按钮生成。",
 		},
+		{
+			Name:           "Google Trust Services",
+			Code:           "google",
+			Description:    "Google提供的免费证书，相关文档 <a href=\"https://cloud.google.com/certificate-manager/docs/public-ca-tutorial\" target=\"_blank\">https://cloud.google.com/certificate-manager/docs/public-ca-tutorial</a>。",
+			APIURL:         "https://dv.acme-v02.api.pki.goog/directory",
+			TestAPIURL:     "https://dv.acme-v02.test-api.pki.goog/directory",
+			RequireEAB:     true,
+			EABDescription: "使用命令\"gcloud publicca external-account-keys create\"生成，其中keyId为KID，b64MacKey为Key。",
+		},
+		{
+			Name:        "Buypass",
+			Code:        "buypass",
+			Description: "挪威Buypass提供的免费证书，有效期为180天。",
+			APIURL:      "https://api.buypass.com/acme/directory",
+			TestAPIURL:  "https://api.test4.buypass.no/acme/directory",
+			RequireEAB:  false,
+		},
 	}
 }
@@ -0,0 +1,122 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certificate"
+	"math/rand"
+	"time"
+)
+
+type RenewalSource = string
+
+const (
+	RenewalSourceARI  RenewalSource = "ari"  // 服务商建议的时间段
+	RenewalSourceRule RenewalSource = "rule" // 根据证书有效期计算
+)
+
+const (
+	MaxRenewBeforeDays = 60 // 最大提前续期天数
+)
+
+// RenewalWindow 续期时间段
+type RenewalWindow struct {
+	Start          int64         `json:"start"`
+	End            int64         `json:"end"`
+	Source         RenewalSource `json:"source"`
+	ExplanationURL string        `json:"explanationURL"`
+	CheckedAt      int64         `json:"checkedAt"` // 计算时间
+}
+
+// FetchRenewalInfo 通过ACME ARI扩展获取服务商建议的续期时间段
+// 如果服务商不支持ARI，则返回nil
+func (this *Request) FetchRenewalInfo(certData []byte) (*RenewalWindow, error) {
+	if this.task.Provider == nil {
+		return nil, errors.New("provider should not be nil")
+	}
+	if this.task.User == nil {
+		return nil, errors.New("'user' must not be nil")
+	}
+
+	leaf, err := parseLeafCert(certData)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := this.newClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: leaf})
+	if err != nil {
+		if errors.Is(err, api.ErrNoARI) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if info.SuggestedWindow.Start.IsZero() || !info.SuggestedWindow.End.After(info.SuggestedWindow.Start) {
+		return nil, nil
+	}
+	return &RenewalWindow{
+		Start:          info.SuggestedWindow.Start.Unix(),
+		End:            info.SuggestedWindow.End.Unix(),
+		Source:         RenewalSourceARI,
+		ExplanationURL: info.ExplanationURL,
+		CheckedAt:      time.Now().Unix(),
+	}, nil
+}
+
+// RuleRenewalWindow 根据证书有效期计算续期时间段
+// renewBeforeDays 为提前续期的天数，为0时取证书有效期的三分之一
+func RuleRenewalWindow(notBefore int64, notAfter int64, renewBeforeDays int) *RenewalWindow {
+	var renewBefore = int64(renewBeforeDays) * 86400
+	if renewBefore <= 0 {
+		renewBefore = (notAfter - notBefore) / 3
+	}
+	if renewBefore <= 0 {
+		renewBefore = 86400
+	}
+
+	// 在时间段内随机，避免大量证书同时续期
+	var end = notAfter - renewBefore
+	var start = end - renewBefore/10
+	if start < notBefore {
+		start = notBefore
+	}
+	if end < start {
+		end = start
+	}
+	return &RenewalWindow{
+		Start:     start,
+		End:       end,
+		Source:    RenewalSourceRule,
+		CheckedAt: time.Now().Unix(),
+	}
+}
+
+// RandomRenewAt 在续期时间段内随机选择一个续期时间
+func (this *RenewalWindow) RandomRenewAt() int64 {
+	if this.End <= this.Start {
+		return this.Start
+	}
+	return this.Start + rand.Int63n(this.End-this.Start)
+}
+
+// RenewalRetryBackoff 续期失败后的重试间隔，随着失败次数指数增长
+func RenewalRetryBackoff(failures int) time.Duration {
+	return utils.RenewalRetryBackoff(failures)
+}
+
+// 解析证书链中的第一个证书
+func parseLeafCert(certData []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, errors.New("invalid certificate data")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestRuleRenewalWindow(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var notBefore = time.Now().Unix()
+	var notAfter = notBefore + 90*86400
+
+	{
+		var window = RuleRenewalWindow(notBefore, notAfter, 0)
+		a.IsTrue(window.End == notAfter-30*86400)
+		a.IsTrue(window.Start == window.End-3*86400)
+		a.IsTrue(window.Source == RenewalSourceRule)
+
+		var renewAt = window.RandomRenewAt()
+		a.IsTrue(renewAt >= window.Start && renewAt <= window.End)
+	}
+	{
+		var window = RuleRenewalWindow(notBefore, notAfter, 10)
+		a.IsTrue(window.End == notAfter-10*86400)
+		a.IsTrue(window.Start == window.End-86400)
+	}
+	{
+		// 提前天数超过有效期
+		var window = RuleRenewalWindow(notBefore, notAfter, 100)
+		a.IsTrue(window.Start == notBefore)
+		a.IsTrue(window.End == notBefore)
+	}
+}
+
+func TestRenewalRetryBackoff(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(RenewalRetryBackoff(0) == 0)
+	a.IsTrue(RenewalRetryBackoff(1) == 1*time.Hour)
+	a.IsTrue(RenewalRetryBackoff(3) == 4*time.Hour)
+	a.IsTrue(RenewalRetryBackoff(10) == 24*time.Hour)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
 	acmelog "github.com/go-acme/lego/v4/log"
 	"github.com/go-acme/lego/v4/registration"
@@ -19,6 +20,13 @@ type Request struct {
 
 	task   *Task
 	onAuth AuthCallback
+
+	onTLSALPNAuth    TLSALPNAuthCallback
+	onTLSALPNCleanUp TLSALPNCleanUpCallback
+
+	dualCertData []byte
+	dualKeyData  []byte
+	dualErr      error
 }
 
 func NewRequest(task *Task) *Request {
@@ -35,6 +43,19 @@ func (this *Request) OnAuth(onAuth AuthCallback) {
 	this.onAuth = onAuth
 }
 
+// OnTLSALPN 设置TLS-ALPN-01认证回调
+func (this *Request) OnTLSALPN(onAuth TLSALPNAuthCallback, onCleanUp TLSALPNCleanUpCallback) {
+	this.onTLSALPNAuth = onAuth
+	this.onTLSALPNCleanUp = onCleanUp
+}
+
+// DualCert 获取同时签发的另外一种密钥类型的证书
+// 需要在Run()成功后调用，如果没有设置Task.DualKeyType，则返回空
+// 另外一种证书签发失败时不影响主证书，错误通过 err 返回
+func (this *Request) DualCert() (certData []byte, keyData []byte, err error) {
+	return this.dualCertData, this.dualKeyData, this.dualErr
+}
+
 func (this *Request) Run() (certData []byte, keyData []byte, err error) {
 	if this.task.Provider == nil {
 		err = errors.New("provider should not be nil")
@@ -50,6 +71,8 @@ func (this *Request) Run() (certData []byte, keyData []byte, err error) {
 		return this.runDNS()
 	case AuthTypeHTTP:
 		return this.runHTTP()
+	case AuthTypeTLSALPN:
+		return this.runTLSALPN()
 	default:
 		err = errors.New("invalid task type '" + this.task.AuthType + "'")
 		return
@@ -80,65 +103,30 @@ func (this *Request) runDNS() (certData []byte, keyData []byte, err error) {
 		return
 	}
 
-	var config = lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
-	config.CADirURL = this.task.Provider.APIURL
-	config.UserAgent = teaconst.ProductName + "/" + teaconst.Version
-
-	client, err := lego.NewClient(config)
+	client, err := this.newClient()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 注册用户
-	var resource = this.task.User.GetRegistration()
-	if resource != nil {
-		_, err = client.Registration.QueryRegistration()
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		if this.task.Provider.RequireEAB {
-			resource, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
-				TermsOfServiceAgreed: true,
-				Kid:                  this.task.Account.EABKid,
-				HmacEncoded:          this.task.Account.EABKey,
-			})
-			if err != nil {
-				return nil, nil, fmt.Errorf("register user failed: %w", err)
-			}
-			err = this.task.User.Register(resource)
-			if err != nil {
-				return nil, nil, err
-			}
-		} else {
-			resource, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-			if err != nil {
-				return nil, nil, err
-			}
-			err = this.task.User.Register(resource)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
+	err = this.register(client)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err = client.Challenge.SetDNS01Provider(NewDNSProvider(this.task.DNSProvider, this.task.DNSDomain))
+	var dnsProvider = NewDNSProvider(this.task.DNSProvider, this.task.DNSDomain)
+	dnsProvider.SetDelegations(this.task.DNSDelegations)
+	err = client.Challenge.SetDNS01Provider(dnsProvider, dns01.WrapPreCheck(dnsProvider.WrapPreCheck))
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 申请证书
-	var request = certificate.ObtainRequest{
-		Domains: this.task.Domains,
-		Bundle:  true,
-	}
-	certResource, err := client.Certificate.Obtain(request)
+	certData, keyData, err = this.obtain(client)
 	if err != nil {
 		return nil, nil, fmt.Errorf("obtain cert failed: %w", err)
 	}
-
-	return certResource.Certificate, certResource.PrivateKey, nil
+	return certData, keyData, nil
 }
 
 func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
@@ -153,22 +141,65 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 		return
 	}
 
-	var config = lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
-	config.CADirURL = this.task.Provider.APIURL
-	config.UserAgent = teaconst.ProductName + "/" + teaconst.Version
+	client, err := this.newClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 注册用户
+	err = this.register(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 申请证书
+	return this.obtain(client)
+}
 
-	client, err := lego.NewClient(config)
+func (this *Request) runTLSALPN() (certData []byte, keyData []byte, err error) {
+	if !this.debug {
+		if !Tea.IsTesting() {
+			acmelog.Logger = log.New(io.Discard, "", log.LstdFlags)
+		}
+	}
+
+	if this.task.User == nil {
+		err = errors.New("'user' must not be nil")
+		return
+	}
+
+	client, err := this.newClient()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 注册用户
+	err = this.register(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = client.Challenge.SetTLSALPN01Provider(NewTLSALPNProvider(this.onTLSALPNAuth, this.onTLSALPNCleanUp))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 申请证书
+	return this.obtain(client)
+}
+
+// 注册用户
+func (this *Request) register(client *lego.Client) (err error) {
 	var resource = this.task.User.GetRegistration()
 	if resource != nil {
 		_, err = client.Registration.QueryRegistration()
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
 	} else {
 		if this.task.Provider.RequireEAB {
@@ -178,38 +209,87 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 				HmacEncoded:          this.task.Account.EABKey,
 			})
 			if err != nil {
-				return nil, nil, fmt.Errorf("register user failed: %w", err)
+				return fmt.Errorf("register user failed: %w", err)
 			}
 			err = this.task.User.Register(resource)
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 		} else {
 			resource, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 			err = this.task.User.Register(resource)
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 		}
 	}
+	return nil
+}
 
-	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
+// 创建ACME客户端
+func (this *Request) newClient() (*lego.Client, error) {
+	config, err := this.newConfig()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	return lego.NewClient(config)
+}
 
-	// 申请证书
-	var request = certificate.ObtainRequest{
+// 创建ACME客户端配置
+func (this *Request) newConfig() (*lego.Config, error) {
+	var config = lego.NewConfig(this.task.User)
+	config.Certificate.KeyType = legoKeyType(this.task.KeyType)
+	config.CADirURL = this.task.Provider.APIURL
+	config.UserAgent = teaconst.ProductName + "/" + teaconst.Version
+
+	// 私有CA
+	if len(this.task.Provider.CACert) > 0 {
+		httpClient, err := newCustomHTTPClient(this.task.Provider.CACert, config.HTTPClient.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		config.HTTPClient = httpClient
+	}
+
+	return config, nil
+}
+
+// 申请证书，如果设置了DualKeyType，则再使用另外一种密钥申请一个证书
+// 另外一种密钥的证书申请失败时，仍然返回已经申请成功的主证书
+func (this *Request) obtain(client *lego.Client) (certData []byte, keyData []byte, err error) {
+	certResource, err := client.Certificate.Obtain(certificate.ObtainRequest{
 		Domains: this.task.Domains,
 		Bundle:  true,
-	}
-	certResource, err := client.Certificate.Obtain(request)
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if len(this.task.DualKeyType) > 0 && legoKeyType(this.task.DualKeyType) != legoKeyType(this.task.KeyType) {
+		this.dualCertData, this.dualKeyData, this.dualErr = this.obtainDual(client)
+	}
+
 	return certResource.Certificate, certResource.PrivateKey, nil
 }
+
+// 使用另外一种密钥申请证书
+func (this *Request) obtainDual(client *lego.Client) (certData []byte, keyData []byte, err error) {
+	privateKey, err := certcrypto.GeneratePrivateKey(legoKeyType(this.task.DualKeyType))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate '%s' private key failed: %w", this.task.DualKeyType, err)
+	}
+
+	// 域名已经验证过，通常不需要再次验证
+	dualResource, err := client.Certificate.Obtain(certificate.ObtainRequest{
+		Domains:    this.task.Domains,
+		Bundle:     true,
+		PrivateKey: privateKey,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("obtain '%s' cert failed: %w", this.task.DualKeyType, err)
+	}
+	return dualResource.Certificate, dualResource.PrivateKey, nil
+}
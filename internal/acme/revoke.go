@@ -0,0 +1,121 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	legoacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+type RevokeReason = uint
+
+// ACME允许使用的吊销原因，参考 RFC 8555 7.6
+const (
+	RevokeReasonUnspecified          RevokeReason = legoacme.CRLReasonUnspecified
+	RevokeReasonKeyCompromise        RevokeReason = legoacme.CRLReasonKeyCompromise
+	RevokeReasonAffiliationChanged   RevokeReason = legoacme.CRLReasonAffiliationChanged
+	RevokeReasonSuperseded           RevokeReason = legoacme.CRLReasonSuperseded
+	RevokeReasonCessationOfOperation RevokeReason = legoacme.CRLReasonCessationOfOperation
+)
+
+type RevokeReasonDefinition struct {
+	Name string       `json:"name"`
+	Code RevokeReason `json:"code"`
+}
+
+// FindAllRevokeReasons 所有吊销原因
+func FindAllRevokeReasons() []*RevokeReasonDefinition {
+	return []*RevokeReasonDefinition{
+		{
+			Name: "未指定",
+			Code: RevokeReasonUnspecified,
+		},
+		{
+			Name: "私钥泄露",
+			Code: RevokeReasonKeyCompromise,
+		},
+		{
+			Name: "域名所有权变更",
+			Code: RevokeReasonAffiliationChanged,
+		},
+		{
+			Name: "已被新证书替代",
+			Code: RevokeReasonSuperseded,
+		},
+		{
+			Name: "停止使用",
+			Code: RevokeReasonCessationOfOperation,
+		},
+	}
+}
+
+// IsValidRevokeReason 检查吊销原因是否有效
+func IsValidRevokeReason(reason RevokeReason) bool {
+	for _, def := range FindAllRevokeReasons() {
+		if def.Code == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoke 使用ACME用户的账号密钥吊销证书
+func (this *Request) Revoke(certData []byte, reason RevokeReason) error {
+	if this.task.Provider == nil {
+		return errors.New("provider should not be nil")
+	}
+	if this.task.User == nil {
+		return errors.New("'user' must not be nil")
+	}
+	if this.task.User.GetRegistration() == nil {
+		return errors.New("acme user has not been registered")
+	}
+	if !IsValidRevokeReason(reason) {
+		return fmt.Errorf("invalid revoke reason '%d'", reason)
+	}
+
+	client, err := this.newClient()
+	if err != nil {
+		return err
+	}
+	return client.Certificate.RevokeWithReason(certData, &reason)
+}
+
+// RevokeWithCertKey 使用证书自身的私钥吊销证书
+// 不依赖ACME用户，一些服务商只接受使用证书私钥提交的"私钥泄露"原因
+func (this *Request) RevokeWithCertKey(certData []byte, keyData []byte, reason RevokeReason) error {
+	if this.task.Provider == nil {
+		return errors.New("provider should not be nil")
+	}
+	if !IsValidRevokeReason(reason) {
+		return fmt.Errorf("invalid revoke reason '%d'", reason)
+	}
+
+	leaf, err := parseLeafCert(certData)
+	if err != nil {
+		return err
+	}
+	privateKey, err := certcrypto.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return fmt.Errorf("parse private key failed: %w", err)
+	}
+
+	config, err := this.newConfig()
+	if err != nil {
+		return err
+	}
+
+	// 不设置kid，使用证书私钥的JWK签名
+	core, err := api.New(config.HTTPClient, config.UserAgent, config.CADirURL, "", privateKey)
+	if err != nil {
+		return err
+	}
+	return core.Certificates.Revoke(legoacme.RevokeCertMessage{
+		Certificate: base64.RawURLEncoding.EncodeToString(leaf.Raw),
+		Reason:      &reason,
+	})
+}
@@ -0,0 +1,18 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestIsValidRevokeReason(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(IsValidRevokeReason(RevokeReasonUnspecified))
+	a.IsTrue(IsValidRevokeReason(RevokeReasonKeyCompromise))
+	a.IsTrue(IsValidRevokeReason(RevokeReasonCessationOfOperation))
+	a.IsFalse(IsValidRevokeReason(2))  // cACompromise
+	a.IsFalse(IsValidRevokeReason(6))  // certificateHold
+	a.IsFalse(IsValidRevokeReason(11)) // 不存在
+}
@@ -5,8 +5,9 @@ import "github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 type AuthType = string
 
 const (
-	AuthTypeDNS  AuthType = "dns"
-	AuthTypeHTTP AuthType = "http"
+	AuthTypeDNS     AuthType = "dns"
+	AuthTypeHTTP    AuthType = "http"
+	AuthTypeTLSALPN AuthType = "tls-alpn"
 )
 
 type Task struct {
@@ -16,7 +17,12 @@ type Task struct {
 	AuthType AuthType
 	Domains  []string
 
+	// 证书密钥类型
+	KeyType     KeyType
+	DualKeyType KeyType // 同时签发的另外一种密钥类型的证书，为空表示不签发
+
 	// DNS相关
-	DNSProvider dnsclients.ProviderInterface
-	DNSDomain   string
+	DNSProvider    dnsclients.ProviderInterface
+	DNSDomain      string
+	DNSDelegations map[string]string // CNAME委托：域名 => CNAME目标
 }
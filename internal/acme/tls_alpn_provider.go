@@ -0,0 +1,39 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+)
+
+// TLSALPNProvider TLS-ALPN-01认证
+// 生成的认证证书通过回调函数分发到边缘节点，由边缘节点在443端口响应认证请求
+type TLSALPNProvider struct {
+	onAuth    TLSALPNAuthCallback
+	onCleanUp TLSALPNCleanUpCallback
+}
+
+func NewTLSALPNProvider(onAuth TLSALPNAuthCallback, onCleanUp TLSALPNCleanUpCallback) *TLSALPNProvider {
+	return &TLSALPNProvider{
+		onAuth:    onAuth,
+		onCleanUp: onCleanUp,
+	}
+}
+
+func (this *TLSALPNProvider) Present(domain, token, keyAuth string) error {
+	certData, keyData, err := tlsalpn01.ChallengeBlocks(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	if this.onAuth != nil {
+		return this.onAuth(domain, certData, keyData)
+	}
+	return nil
+}
+
+func (this *TLSALPNProvider) CleanUp(domain, token, keyAuth string) error {
+	if this.onCleanUp != nil {
+		return this.onCleanUp(domain)
+	}
+	return nil
+}
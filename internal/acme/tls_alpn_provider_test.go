@@ -0,0 +1,45 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestTLSALPNProvider_Present(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var presentedDomain string
+	var cleanedDomain string
+	var provider = NewTLSALPNProvider(func(domain string, certData []byte, keyData []byte) error {
+		presentedDomain = domain
+
+		cert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.IsTrue(len(leaf.DNSNames) == 1 && leaf.DNSNames[0] == domain)
+		return nil
+	}, func(domain string) error {
+		cleanedDomain = domain
+		return nil
+	})
+
+	err := provider.Present("example.com", "token", "keyAuth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.CleanUp("example.com", "token", "keyAuth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(presentedDomain == "example.com")
+	a.IsTrue(cleanedDomain == "example.com")
+}
@@ -0,0 +1,157 @@
+package acme
+
+import (
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+const (
+	ACMEProviderStateEnabled  = 1 // 已启用
+	ACMEProviderStateDisabled = 0 // 已禁用
+)
+
+type ACMEProviderDAO dbs.DAO
+
+func NewACMEProviderDAO() *ACMEProviderDAO {
+	return dbs.NewDAO(&ACMEProviderDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeACMEProviders",
+			Model:  new(ACMEProvider),
+			PkName: "id",
+		},
+	}).(*ACMEProviderDAO)
+}
+
+var SharedACMEProviderDAO *ACMEProviderDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedACMEProviderDAO = NewACMEProviderDAO()
+		acmeutils.SetCustomProviderDAO(SharedACMEProviderDAO)
+	})
+}
+
+// EnableACMEProvider 启用条目
+func (this *ACMEProviderDAO) EnableACMEProvider(tx *dbs.Tx, id int64) error {
+	_, err := this.Query(tx).
+		Pk(id).
+		Set("state", ACMEProviderStateEnabled).
+		Update()
+	if err != nil {
+		return err
+	}
+	acmeutils.ResetCustomProviders()
+	return nil
+}
+
+// DisableACMEProvider 禁用条目
+func (this *ACMEProviderDAO) DisableACMEProvider(tx *dbs.Tx, id int64) error {
+	_, err := this.Query(tx).
+		Pk(id).
+		Set("state", ACMEProviderStateDisabled).
+		Update()
+	if err != nil {
+		return err
+	}
+	acmeutils.ResetCustomProviders()
+	return nil
+}
+
+// FindEnabledACMEProvider 查找启用中的条目
+func (this *ACMEProviderDAO) FindEnabledACMEProvider(tx *dbs.Tx, id int64) (*ACMEProvider, error) {
+	result, err := this.Query(tx).
+		Pk(id).
+		State(ACMEProviderStateEnabled).
+		Find()
+	if result == nil {
+		return nil, err
+	}
+	return result.(*ACMEProvider), err
+}
+
+// CreateProvider 创建服务商
+// 代号会自动生成，以避免和内置的服务商冲突
+func (this *ACMEProviderDAO) CreateProvider(tx *dbs.Tx, adminId int64, name string, description string, apiURL string, requireEAB bool, eabDescription string, caCert string) (int64, error) {
+	var op = NewACMEProviderOperator()
+	op.AdminId = adminId
+	op.Name = name
+	op.Description = description
+	op.ApiURL = apiURL
+	op.RequireEAB = requireEAB
+	op.EabDescription = eabDescription
+	op.CaCert = caCert
+	op.IsOn = true
+	op.CreatedAt = time.Now().Unix()
+	op.State = ACMEProviderStateEnabled
+	err := this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	var providerId = types.Int64(op.Id)
+	err = this.Query(tx).
+		Pk(providerId).
+		Set("code", acmeutils.CustomProviderPrefix+types.String(providerId)).
+		UpdateQuickly()
+	if err != nil {
+		return 0, err
+	}
+	acmeutils.ResetCustomProviders()
+	return providerId, nil
+}
+
+// UpdateProvider 修改服务商
+func (this *ACMEProviderDAO) UpdateProvider(tx *dbs.Tx, providerId int64, name string, description string, apiURL string, requireEAB bool, eabDescription string, caCert string, isOn bool) error {
+	if providerId <= 0 {
+		return errors.New("invalid providerId")
+	}
+
+	var op = NewACMEProviderOperator()
+	op.Id = providerId
+	op.Name = name
+	op.Description = description
+	op.ApiURL = apiURL
+	op.RequireEAB = requireEAB
+	op.EabDescription = eabDescription
+	op.CaCert = caCert
+	op.IsOn = isOn
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	acmeutils.ResetCustomProviders()
+	return nil
+}
+
+// FindAllEnabledProviders 列出所有服务商
+func (this *ACMEProviderDAO) FindAllEnabledProviders(tx *dbs.Tx) (result []*ACMEProvider, err error) {
+	_, err = this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllEnabledCustomProviders 列出所有启用的服务商定义
+func (this *ACMEProviderDAO) FindAllEnabledCustomProviders(tx *dbs.Tx) ([]*acmeutils.Provider, error) {
+	var result = []*acmeutils.Provider{}
+	ones, err := this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		Attr("isOn", true).
+		AscPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, one.(*ACMEProvider).ToProvider())
+	}
+	return result, nil
+}
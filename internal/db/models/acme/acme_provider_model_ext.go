@@ -0,0 +1,17 @@
+package acme
+
+import acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
+
+// ToProvider 转换为ACME服务商定义
+func (this *ACMEProvider) ToProvider() *acmeutils.Provider {
+	return &acmeutils.Provider{
+		Name:           this.Name,
+		Code:           this.Code,
+		Description:    this.Description,
+		APIURL:         this.ApiURL,
+		RequireEAB:     this.RequireEAB,
+		EABDescription: this.EabDescription,
+		IsCustom:       true,
+		CACert:         this.CaCert,
+	}
+}
@@ -30,6 +30,9 @@ const (
 	ACMETaskStateDisabled = 0 // 已禁用
 )
 
+// TLS-ALPN-01认证证书下发后等待节点更新的最长时间
+const tlsALPNWaitTimeout = 60 * time.Second
+
 type ACMETaskDAO dbs.DAO
 
 func NewACMETaskDAO() *ACMETaskDAO {
@@ -187,7 +190,7 @@ func (this *ACMETaskDAO) ListEnabledACMETasks(tx *dbs.Tx, userId int64, isAvaila
 }
 
 // CreateACMETask 创建任务
-func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acmeutils.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string) (int64, error) {
+func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acmeutils.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string, keyType acmeutils.KeyType, dualKeyType acmeutils.KeyType) (int64, error) {
 	var op = NewACMETaskOperator()
 	op.AdminId = adminId
 	op.UserId = userId
@@ -208,6 +211,8 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 
 	op.AutoRenew = autoRenew
 	op.AuthURL = authURL
+	op.KeyType = keyType
+	op.DualKeyType = dualKeyType
 	op.IsOn = true
 	op.State = ACMETaskStateEnabled
 	err := this.Save(tx, op)
@@ -218,7 +223,7 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 }
 
 // UpdateACMETask 修改任务
-func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string) error {
+func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string, keyType acmeutils.KeyType, dualKeyType acmeutils.KeyType) error {
 	if acmeTaskId <= 0 {
 		return errors.New("invalid acmeTaskId")
 	}
@@ -241,6 +246,9 @@ func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId
 
 	op.AutoRenew = autoRenew
 	op.AuthURL = authURL
+	op.KeyType = keyType
+	op.DualKeyType = dualKeyType
+	op.NextRenewAt = 0 // 重新计算续期时间
 	err := this.Save(tx, op)
 	return err
 }
@@ -279,10 +287,295 @@ func (this *ACMETaskDAO) UpdateACMETaskCert(tx *dbs.Tx, taskId int64, certId int
 	return err
 }
 
+// UpdateACMETaskDualCert 设置任务同时签发的证书
+func (this *ACMETaskDAO) UpdateACMETaskDualCert(tx *dbs.Tx, taskId int64, dualCertId int64) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.DualCertId = dualCertId
+	err := this.Save(tx, op)
+	return err
+}
+
+// UpdateACMETaskDNSDelegations 设置任务的CNAME委托：域名 => CNAME目标
+func (this *ACMETaskDAO) UpdateACMETaskDNSDelegations(tx *dbs.Tx, taskId int64, delegations map[string]string) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+	if delegations == nil {
+		delegations = map[string]string{}
+	}
+	delegationsJSON, err := json.Marshal(delegations)
+	if err != nil {
+		return err
+	}
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.DnsDelegations = delegationsJSON
+	return this.Save(tx, op)
+}
+
+// UpdateACMETaskRenewBeforeDays 设置任务提前续期的天数，0表示自动
+func (this *ACMETaskDAO) UpdateACMETaskRenewBeforeDays(tx *dbs.Tx, taskId int64, renewBeforeDays int32) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+	if renewBeforeDays < 0 {
+		renewBeforeDays = 0
+	}
+	if renewBeforeDays > acmeutils.MaxRenewBeforeDays {
+		renewBeforeDays = acmeutils.MaxRenewBeforeDays
+	}
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.RenewBeforeDays = renewBeforeDays
+	op.NextRenewAt = 0 // 重新计算续期时间
+	return this.Save(tx, op)
+}
+
+// ScheduleACMETaskRenewal 计算任务下次续期的时间
+// 优先使用服务商通过ARI建议的时间段，服务商不支持时根据证书有效期计算
+func (this *ACMETaskDAO) ScheduleACMETaskRenewal(tx *dbs.Tx, taskId int64) error {
+	task, err := this.FindEnabledACMETask(tx, taskId)
+	if err != nil {
+		return err
+	}
+	if task == nil || task.CertId == 0 {
+		return nil
+	}
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, int64(task.CertId))
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return nil
+	}
+
+	var window = acmeutils.RuleRenewalWindow(int64(cert.TimeBeginAt), int64(cert.TimeEndAt), int(task.RenewBeforeDays))
+
+	remoteTask, errMsg := this.buildRemoteTask(tx, task)
+	if len(errMsg) == 0 {
+		ariWindow, err := acmeutils.NewRequest(remoteTask).FetchRenewalInfo(cert.CertData)
+		if err != nil {
+			remotelogs.Warn("ACME", "fetch renewal info for task '"+types.String(taskId)+"' failed: "+err.Error())
+		} else if ariWindow != nil && (task.RenewBeforeDays == 0 || ariWindow.Start < window.Start) {
+			// 设置了提前续期天数时，只有服务商建议的时间更早才使用
+			window = ariWindow
+		}
+	}
+
+	windowJSON, err := json.Marshal(window)
+	if err != nil {
+		return err
+	}
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.NextRenewAt = window.RandomRenewAt()
+	op.RenewFailures = 0
+	op.RenewWindow = windowJSON
+	return this.Save(tx, op)
+}
+
+// UpdateACMETaskRenewFailed 续期失败后推迟下次续期时间，并返回连续失败的次数
+func (this *ACMETaskDAO) UpdateACMETaskRenewFailed(tx *dbs.Tx, taskId int64) (failures int, err error) {
+	failures, err = this.Query(tx).
+		Pk(taskId).
+		Result("renewFailures").
+		FindIntCol(0)
+	if err != nil {
+		return 0, err
+	}
+	failures++
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.RenewFailures = failures
+	op.NextRenewAt = time.Now().Add(acmeutils.RenewalRetryBackoff(failures)).Unix()
+	err = this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+// FindAllUnscheduledRenewalTasks 查找所有尚未计算续期时间的任务
+func (this *ACMETaskDAO) FindAllUnscheduledRenewalTasks(tx *dbs.Tx) (result []*ACMETask, err error) {
+	_, err = this.renewalQuery(tx).
+		Attr("nextRenewAt", 0).
+		Result("id").
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllScheduledRenewalTasks 查找所有已计算续期时间但尚未到期的任务
+func (this *ACMETaskDAO) FindAllScheduledRenewalTasks(tx *dbs.Tx) (result []*ACMETask, err error) {
+	_, err = this.renewalQuery(tx).
+		Gt("nextRenewAt", time.Now().Unix()).
+		Result("id", "renewWindow", "renewFailures").
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllDueRenewalTasks 查找所有已到续期时间的任务
+func (this *ACMETaskDAO) FindAllDueRenewalTasks(tx *dbs.Tx) (result []*ACMETask, err error) {
+	_, err = this.renewalQuery(tx).
+		Gt("nextRenewAt", 0).
+		Lte("nextRenewAt", time.Now().Unix()).
+		Asc("nextRenewAt").
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// CountRenewalQueue 计算续期队列中的任务数量
+func (this *ACMETaskDAO) CountRenewalQueue(tx *dbs.Tx, userId int64) (int64, error) {
+	var query = this.renewalQuery(tx).
+		Gt("nextRenewAt", 0)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	return query.Count()
+}
+
+// ListRenewalQueue 列出续期队列中的任务，按计划续期时间排序
+func (this *ACMETaskDAO) ListRenewalQueue(tx *dbs.Tx, userId int64, offset int64, size int64) (result []*ACMETask, err error) {
+	var query = this.renewalQuery(tx).
+		Gt("nextRenewAt", 0)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	_, err = query.
+		Asc("nextRenewAt").
+		AscPk().
+		Offset(offset).
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// 需要自动续期的任务
+func (this *ACMETaskDAO) renewalQuery(tx *dbs.Tx) *dbs.Query {
+	return this.Query(tx).
+		State(ACMETaskStateEnabled).
+		Attr("isOn", true).
+		Attr("autoRenew", 1).
+		Gt("certId", 0)
+}
+
+// RevokeACMETaskCert 吊销任务申请的证书并记录日志
+// useCertKey 是否使用证书私钥签名，否则使用ACME用户的账号密钥
+func (this *ACMETaskDAO) RevokeACMETaskCert(tx *dbs.Tx, taskId int64, certId int64, reason acmeutils.RevokeReason, useCertKey bool) (isOk bool, errMsg string) {
+	errMsg = this.revokeCertWithoutLog(tx, taskId, certId, reason, useCertKey)
+	isOk = len(errMsg) == 0
+
+	var logMsg = errMsg
+	if isOk {
+		logMsg = "吊销证书 " + types.String(certId) + "，原因代码：" + types.String(reason)
+	}
+	err := SharedACMETaskLogDAO.CreateACMETaskActionLog(tx, taskId, ACMETaskLogActionRevoke, isOk, logMsg)
+	if err != nil {
+		remotelogs.Error("ACME", "create task log failed: "+err.Error())
+	}
+	return
+}
+
+// RevokeResult 吊销证书的结果
+type RevokeResult struct {
+	IsOk           bool
+	ErrMsg         string
+	ReissueIsOk    bool   // 是否重新申请成功
+	ReissueErrMsg  string // 重新申请时的错误信息
+	ReissuedCertId int64  // 重新申请的证书ID
+}
+
+// RevokeAndReissueACMETaskCert 吊销任务申请的证书，并根据需要立即重新申请
+func (this *ACMETaskDAO) RevokeAndReissueACMETaskCert(tx *dbs.Tx, taskId int64, certId int64, reason acmeutils.RevokeReason, useCertKey bool, reissue bool) *RevokeResult {
+	var result = &RevokeResult{}
+	result.IsOk, result.ErrMsg = this.RevokeACMETaskCert(tx, taskId, certId, reason, useCertKey)
+	if !result.IsOk || !reissue {
+		return result
+	}
+
+	// 重新申请时会生成新的私钥
+	result.ReissueIsOk, result.ReissueErrMsg, result.ReissuedCertId = this.RunTask(tx, taskId)
+	return result
+}
+
+func (this *ACMETaskDAO) revokeCertWithoutLog(tx *dbs.Tx, taskId int64, certId int64, reason acmeutils.RevokeReason, useCertKey bool) (errMsg string) {
+	task, err := this.FindEnabledACMETask(tx, taskId)
+	if err != nil {
+		errMsg = "查询任务信息时出错：" + err.Error()
+		return
+	}
+	if task == nil {
+		errMsg = "找不到要执行的任务"
+		return
+	}
+
+	// 只能吊销任务申请的证书
+	if certId <= 0 || (certId != int64(task.CertId) && certId != int64(task.DualCertId)) {
+		errMsg = "证书不属于此任务"
+		return
+	}
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
+	if err != nil {
+		errMsg = "查询证书信息时出错：" + err.Error()
+		return
+	}
+	if cert == nil {
+		errMsg = "找不到要吊销的证书"
+		return
+	}
+	if cert.IsRevoked {
+		errMsg = "证书已经被吊销"
+		return
+	}
+
+	remoteTask, errMsg := this.buildRemoteTask(tx, task)
+	if len(errMsg) > 0 {
+		return
+	}
+
+	var req = acmeutils.NewRequest(remoteTask)
+	if useCertKey {
+		err = req.RevokeWithCertKey(cert.CertData, cert.KeyData, reason)
+	} else {
+		err = req.Revoke(cert.CertData, reason)
+	}
+	if err != nil {
+		errMsg = "吊销证书时出错：" + err.Error()
+		return
+	}
+
+	err = models.SharedSSLCertDAO.UpdateCertRevoked(tx, certId, uint8(reason))
+	if err != nil {
+		errMsg = "证书已吊销，但更新证书状态时出错：" + err.Error()
+		return
+	}
+	return
+}
+
 // RunTask 执行任务并记录日志
 func (this *ACMETaskDAO) RunTask(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64) {
 	isOk, errMsg, resultCertId = this.runTaskWithoutLog(tx, taskId)
 
+	// 重新计算续期时间
+	if isOk {
+		err := this.ScheduleACMETaskRenewal(tx, taskId)
+		if err != nil {
+			remotelogs.Error("ACME", "schedule renewal for task '"+types.String(taskId)+"' failed: "+err.Error())
+		}
+	}
+
 	// 记录日志
 	err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg)
 	if err != nil {
@@ -308,6 +601,144 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		return
 	}
 
+	acmeTask, errMsg := this.buildRemoteTask(tx, task)
+	if len(errMsg) > 0 {
+		return
+	}
+
+	var acmeRequest = acmeutils.NewRequest(acmeTask)
+	acmeRequest.OnAuth(func(domain, token, keyAuth string) {
+		err := SharedACMEAuthenticationDAO.CreateAuth(tx, taskId, domain, token, keyAuth)
+		if err != nil {
+			remotelogs.Error("ACME", "write authentication to database error: "+err.Error())
+		} else {
+			// 调用校验URL
+			if len(task.AuthURL) > 0 {
+				authJSON, err := json.Marshal(maps.Map{
+					"domain": domain,
+					"token":  token,
+					"key":    keyAuth,
+				})
+				if err != nil {
+					remotelogs.Error("ACME", "encode auth data failed: '"+task.AuthURL+"'")
+				} else {
+					var client = utils.SharedHttpClient(10 * time.Second)
+					req, err := http.NewRequest(http.MethodPost, task.AuthURL, bytes.NewReader(authJSON))
+					req.Header.Set("Content-Type", "application/json")
+					req.Header.Set("User-Agent", teaconst.ProductName+"/"+teaconst.Version)
+					if err != nil {
+						remotelogs.Error("ACME", "parse auth url failed '"+task.AuthURL+"': "+err.Error())
+					} else {
+						resp, err := client.Do(req)
+						if err != nil {
+							remotelogs.Error("ACME", "call auth url failed '"+task.AuthURL+"': "+err.Error())
+						} else {
+							_ = resp.Body.Close()
+						}
+					}
+				}
+			}
+		}
+	})
+	acmeRequest.OnTLSALPN(func(domain string, certData []byte, keyData []byte) error {
+		err := SharedACMETLSALPNChallengeDAO.CreateChallenge(tx, taskId, domain, certData, keyData)
+		if err != nil {
+			return err
+		}
+
+		// 等待节点获取认证证书
+		ok, err := SharedACMETLSALPNChallengeDAO.WaitForNodes(tx, domain, tlsALPNWaitTimeout)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			remotelogs.Warn("ACME", "timeout waiting for nodes to load TLS-ALPN-01 challenge of '"+domain+"'")
+		}
+		return nil
+	}, func(domain string) error {
+		return SharedACMETLSALPNChallengeDAO.DeleteChallenge(tx, taskId, domain)
+	})
+	certData, keyData, err := acmeRequest.Run()
+	if err != nil {
+		errMsg = "证书生成失败：" + err.Error()
+		return
+	}
+
+	// 分析证书
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+	}
+	err = sslConfig.Init(context.Background())
+	if err != nil {
+		errMsg = "证书生成成功，但是分析证书信息时发生错误：" + err.Error()
+		return
+	}
+
+	// 保存证书
+	resultCertId = int64(task.CertId)
+	if resultCertId > 0 {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, resultCertId)
+		if err != nil {
+			errMsg = "证书生成成功，但查询已绑定的证书时出错：" + err.Error()
+			return
+		}
+		if cert == nil {
+			errMsg = "证书已被管理员或用户删除"
+
+			// 禁用
+			err = SharedACMETaskDAO.DisableACMETask(tx, taskId)
+			if err != nil {
+				errMsg = "禁用失效的ACME任务出错：" + err.Error()
+			}
+
+			return
+		}
+
+		err = models.SharedSSLCertDAO.UpdateCert(tx, resultCertId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+		if err != nil {
+			errMsg = "证书生成成功，但是修改数据库中的证书信息时出错：" + err.Error()
+			return
+		}
+	} else {
+		resultCertId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, task.DnsDomain+"免费证书", "免费申请的证书", "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+		if err != nil {
+			errMsg = "证书生成成功，但是保存到数据库失败：" + err.Error()
+			return
+		}
+
+		err = models.SharedSSLCertDAO.UpdateCertACME(tx, resultCertId, int64(task.Id))
+		if err != nil {
+			errMsg = "证书生成成功，修改证书ACME信息时出错：" + err.Error()
+			return
+		}
+
+		// 设置成功
+		err = SharedACMETaskDAO.UpdateACMETaskCert(tx, taskId, resultCertId)
+		if err != nil {
+			errMsg = "证书生成成功，设置任务关联的证书时出错：" + err.Error()
+			return
+		}
+	}
+
+	// 主证书已经保存，同时签发的证书失败时只记录错误
+	isOk = true
+
+	// 同时签发的证书
+	dualCertData, dualKeyData, dualErr := acmeRequest.DualCert()
+	if dualErr != nil {
+		errMsg = "证书生成成功，但是签发'" + task.DualKeyType + "'密钥类型的证书时出错：" + dualErr.Error()
+		remotelogs.Warn("ACME", "task '"+types.String(taskId)+"': "+errMsg)
+		return
+	}
+	if len(dualCertData) > 0 && len(dualKeyData) > 0 {
+		errMsg = this.saveDualCert(tx, task, dualCertData, dualKeyData)
+	}
+	return
+}
+
+// 构造ACME任务
+func (this *ACMETaskDAO) buildRemoteTask(tx *dbs.Tx, task *ACMETask) (acmeTask *acmeutils.Task, errMsg string) {
 	// ACME用户
 	user, err := SharedACMEUserDAO.FindEnabledACMEUser(tx, int64(task.AcmeUserId))
 	if err != nil {
@@ -369,7 +800,6 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		}
 	}
 
-	var acmeTask *acmeutils.Task = nil
 	if task.AuthType == acmeutils.AuthTypeDNS {
 		// DNS服务商
 		dnsProvider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, int64(task.DnsProviderId))
@@ -381,7 +811,7 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 			errMsg = "找不到DNS服务商账号"
 			return
 		}
-		providerInterface := dnsclients.FindProvider(dnsProvider.Type, int64(dnsProvider.Id))
+		providerInterface := dnsclients.FindGuardedProvider(dnsProvider.Type, int64(dnsProvider.Id), dnsProvider.GuardConfig)
 		if providerInterface == nil {
 			errMsg = "暂不支持此类型的DNS服务商 '" + dnsProvider.Type + "'"
 			return
@@ -399,11 +829,12 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		}
 
 		acmeTask = &acmeutils.Task{
-			User:        remoteUser,
-			AuthType:    acmeutils.AuthTypeDNS,
-			DNSProvider: providerInterface,
-			DNSDomain:   task.DnsDomain,
-			Domains:     task.DecodeDomains(),
+			User:           remoteUser,
+			AuthType:       acmeutils.AuthTypeDNS,
+			DNSProvider:    providerInterface,
+			DNSDomain:      task.DnsDomain,
+			DNSDelegations: task.DecodeDNSDelegations(),
+			Domains:        task.DecodeDomains(),
 		}
 	} else if task.AuthType == acmeutils.AuthTypeHTTP {
 		acmeTask = &acmeutils.Task{
@@ -411,107 +842,64 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 			AuthType: acmeutils.AuthTypeHTTP,
 			Domains:  task.DecodeDomains(),
 		}
-	}
-	acmeTask.Provider = acmeProvider
-	acmeTask.Account = acmeAccount
-
-	var acmeRequest = acmeutils.NewRequest(acmeTask)
-	acmeRequest.OnAuth(func(domain, token, keyAuth string) {
-		err := SharedACMEAuthenticationDAO.CreateAuth(tx, taskId, domain, token, keyAuth)
-		if err != nil {
-			remotelogs.Error("ACME", "write authentication to database error: "+err.Error())
-		} else {
-			// 调用校验URL
-			if len(task.AuthURL) > 0 {
-				authJSON, err := json.Marshal(maps.Map{
-					"domain": domain,
-					"token":  token,
-					"key":    keyAuth,
-				})
-				if err != nil {
-					remotelogs.Error("ACME", "encode auth data failed: '"+task.AuthURL+"'")
-				} else {
-					var client = utils.SharedHttpClient(10 * time.Second)
-					req, err := http.NewRequest(http.MethodPost, task.AuthURL, bytes.NewReader(authJSON))
-					req.Header.Set("Content-Type", "application/json")
-					req.Header.Set("User-Agent", teaconst.ProductName+"/"+teaconst.Version)
-					if err != nil {
-						remotelogs.Error("ACME", "parse auth url failed '"+task.AuthURL+"': "+err.Error())
-					} else {
-						resp, err := client.Do(req)
-						if err != nil {
-							remotelogs.Error("ACME", "call auth url failed '"+task.AuthURL+"': "+err.Error())
-						} else {
-							_ = resp.Body.Close()
-						}
-					}
-				}
-			}
+	} else if task.AuthType == acmeutils.AuthTypeTLSALPN {
+		acmeTask = &acmeutils.Task{
+			User:     remoteUser,
+			AuthType: acmeutils.AuthTypeTLSALPN,
+			Domains:  task.DecodeDomains(),
 		}
-	})
-	certData, keyData, err := acmeRequest.Run()
-	if err != nil {
-		errMsg = "证书生成失败：" + err.Error()
+	} else {
+		errMsg = "不支持的认证方式 '" + task.AuthType + "'"
 		return
 	}
+	acmeTask.Provider = acmeProvider
+	acmeTask.Account = acmeAccount
+	acmeTask.KeyType = task.KeyType
+	acmeTask.DualKeyType = task.DualKeyType
+	return
+}
 
-	// 分析证书
+// 保存同时签发的证书
+func (this *ACMETaskDAO) saveDualCert(tx *dbs.Tx, task *ACMETask, certData []byte, keyData []byte) (errMsg string) {
 	var sslConfig = &sslconfigs.SSLCertConfig{
 		CertData: certData,
 		KeyData:  keyData,
 	}
-	err = sslConfig.Init(context.Background())
+	err := sslConfig.Init(context.Background())
 	if err != nil {
-		errMsg = "证书生成成功，但是分析证书信息时发生错误：" + err.Error()
-		return
+		return "'" + task.DualKeyType + "'证书生成成功，但是分析证书信息时发生错误：" + err.Error()
 	}
 
-	// 保存证书
-	resultCertId = int64(task.CertId)
-	if resultCertId > 0 {
-		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, resultCertId)
+	var dualCertId = int64(task.DualCertId)
+	if dualCertId > 0 {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, dualCertId)
 		if err != nil {
-			errMsg = "证书生成成功，但查询已绑定的证书时出错：" + err.Error()
-			return
+			return "'" + task.DualKeyType + "'证书生成成功，但查询已绑定的证书时出错：" + err.Error()
 		}
-		if cert == nil {
-			errMsg = "证书已被管理员或用户删除"
-
-			// 禁用
-			err = SharedACMETaskDAO.DisableACMETask(tx, taskId)
+		if cert != nil {
+			err = models.SharedSSLCertDAO.UpdateCert(tx, dualCertId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
 			if err != nil {
-				errMsg = "禁用失效的ACME任务出错：" + err.Error()
+				return "'" + task.DualKeyType + "'证书生成成功，但是修改数据库中的证书信息时出错：" + err.Error()
 			}
-
-			return
+			return ""
 		}
 
-		err = models.SharedSSLCertDAO.UpdateCert(tx, resultCertId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
-		if err != nil {
-			errMsg = "证书生成成功，但是修改数据库中的证书信息时出错：" + err.Error()
-			return
-		}
-	} else {
-		resultCertId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, task.DnsDomain+"免费证书", "免费申请的证书", "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
-		if err != nil {
-			errMsg = "证书生成成功，但是保存到数据库失败：" + err.Error()
-			return
-		}
+		// 证书已被删除时重新创建
+	}
 
-		err = models.SharedSSLCertDAO.UpdateCertACME(tx, resultCertId, int64(task.Id))
-		if err != nil {
-			errMsg = "证书生成成功，修改证书ACME信息时出错：" + err.Error()
-			return
-		}
+	dualCertId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, task.DnsDomain+"免费证书（"+task.DualKeyType+"）", "免费申请的证书", "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+	if err != nil {
+		return "'" + task.DualKeyType + "'证书生成成功，但是保存到数据库失败：" + err.Error()
+	}
 
-		// 设置成功
-		err = SharedACMETaskDAO.UpdateACMETaskCert(tx, taskId, resultCertId)
-		if err != nil {
-			errMsg = "证书生成成功，设置任务关联的证书时出错：" + err.Error()
-			return
-		}
+	err = models.SharedSSLCertDAO.UpdateCertACME(tx, dualCertId, int64(task.Id))
+	if err != nil {
+		return "'" + task.DualKeyType + "'证书生成成功，修改证书ACME信息时出错：" + err.Error()
 	}
 
-	isOk = true
-	return
+	err = this.UpdateACMETaskDualCert(tx, int64(task.Id), dualCertId)
+	if err != nil {
+		return "'" + task.DualKeyType + "'证书生成成功，设置任务关联的证书时出错：" + err.Error()
+	}
+	return ""
 }
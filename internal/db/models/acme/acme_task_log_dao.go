@@ -7,6 +7,13 @@ import (
 	"github.com/iwind/TeaGo/dbs"
 )
 
+type ACMETaskLogAction = string
+
+const (
+	ACMETaskLogActionRun    ACMETaskLogAction = "run"    // 执行任务
+	ACMETaskLogActionRevoke ACMETaskLogAction = "revoke" // 吊销证书
+)
+
 type ACMETaskLogDAO dbs.DAO
 
 func NewACMETaskLogDAO() *ACMETaskLogDAO {
@@ -30,8 +37,14 @@ func init() {
 
 // CreateACMETaskLog 生成日志
 func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk bool, errMsg string) error {
+	return this.CreateACMETaskActionLog(tx, taskId, ACMETaskLogActionRun, isOk, errMsg)
+}
+
+// CreateACMETaskActionLog 生成某个操作的日志
+func (this *ACMETaskLogDAO) CreateACMETaskActionLog(tx *dbs.Tx, taskId int64, action ACMETaskLogAction, isOk bool, errMsg string) error {
 	var op = NewACMETaskLogOperator()
 	op.TaskId = taskId
+	op.Action = action
 	op.Error = utils.LimitString(errMsg, 1024)
 	op.IsOk = isOk
 	err := this.Save(tx, op)
@@ -42,6 +55,7 @@ func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk boo
 func (this *ACMETaskLogDAO) FindLatestACMETasKLog(tx *dbs.Tx, taskId int64) (*ACMETaskLog, error) {
 	one, err := this.Query(tx).
 		Attr("taskId", taskId).
+		Neq("action", ACMETaskLogActionRevoke).
 		DescPk().
 		Find()
 	if err != nil || one == nil {
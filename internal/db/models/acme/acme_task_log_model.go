@@ -7,6 +7,7 @@ type ACMETaskLog struct {
 	IsOk      bool   `field:"isOk"`      // 是否成功
 	Error     string `field:"error"`     // 错误信息
 	CreatedAt uint64 `field:"createdAt"` // 运行时间
+	Action    string `field:"action"`    // 操作类型
 }
 
 type ACMETaskLogOperator struct {
@@ -15,6 +16,7 @@ type ACMETaskLogOperator struct {
 	IsOk      interface{} // 是否成功
 	Error     interface{} // 错误信息
 	CreatedAt interface{} // 运行时间
+	Action    interface{} // 操作类型
 }
 
 func NewACMETaskLogOperator() *ACMETaskLogOperator {
@@ -4,37 +4,53 @@ import "github.com/iwind/TeaGo/dbs"
 
 // ACMETask ACME任务
 type ACMETask struct {
-	Id            uint64   `field:"id"`            // ID
-	AdminId       uint32   `field:"adminId"`       // 管理员ID
-	UserId        uint32   `field:"userId"`        // 用户ID
-	IsOn          bool     `field:"isOn"`          // 是否启用
-	AcmeUserId    uint32   `field:"acmeUserId"`    // ACME用户ID
-	DnsDomain     string   `field:"dnsDomain"`     // DNS主域名
-	DnsProviderId uint64   `field:"dnsProviderId"` // DNS服务商
-	Domains       dbs.JSON `field:"domains"`       // 证书域名
-	CreatedAt     uint64   `field:"createdAt"`     // 创建时间
-	State         uint8    `field:"state"`         // 状态
-	CertId        uint64   `field:"certId"`        // 生成的证书ID
-	AutoRenew     uint8    `field:"autoRenew"`     // 是否自动更新
-	AuthType      string   `field:"authType"`      // 认证类型
-	AuthURL       string   `field:"authURL"`       // 认证URL
+	Id              uint64   `field:"id"`              // ID
+	AdminId         uint32   `field:"adminId"`         // 管理员ID
+	UserId          uint32   `field:"userId"`          // 用户ID
+	IsOn            bool     `field:"isOn"`            // 是否启用
+	AcmeUserId      uint32   `field:"acmeUserId"`      // ACME用户ID
+	DnsDomain       string   `field:"dnsDomain"`       // DNS主域名
+	DnsProviderId   uint64   `field:"dnsProviderId"`   // DNS服务商
+	Domains         dbs.JSON `field:"domains"`         // 证书域名
+	CreatedAt       uint64   `field:"createdAt"`       // 创建时间
+	State           uint8    `field:"state"`           // 状态
+	CertId          uint64   `field:"certId"`          // 生成的证书ID
+	AutoRenew       uint8    `field:"autoRenew"`       // 是否自动更新
+	AuthType        string   `field:"authType"`        // 认证类型
+	AuthURL         string   `field:"authURL"`         // 认证URL
+	KeyType         string   `field:"keyType"`         // 证书密钥类型
+	DualKeyType     string   `field:"dualKeyType"`     // 同时签发的另外一种密钥类型
+	DualCertId      uint64   `field:"dualCertId"`      // 同时签发的证书ID
+	DnsDelegations  dbs.JSON `field:"dnsDelegations"`  // DNS CNAME委托
+	RenewBeforeDays uint32   `field:"renewBeforeDays"` // 提前续期天数，0表示自动
+	NextRenewAt     uint64   `field:"nextRenewAt"`     // 下次续期时间
+	RenewFailures   uint32   `field:"renewFailures"`   // 连续续期失败次数
+	RenewWindow     dbs.JSON `field:"renewWindow"`     // 续期时间段
 }
 
 type ACMETaskOperator struct {
-	Id            interface{} // ID
-	AdminId       interface{} // 管理员ID
-	UserId        interface{} // 用户ID
-	IsOn          interface{} // 是否启用
-	AcmeUserId    interface{} // ACME用户ID
-	DnsDomain     interface{} // DNS主域名
-	DnsProviderId interface{} // DNS服务商
-	Domains       interface{} // 证书域名
-	CreatedAt     interface{} // 创建时间
-	State         interface{} // 状态
-	CertId        interface{} // 生成的证书ID
-	AutoRenew     interface{} // 是否自动更新
-	AuthType      interface{} // 认证类型
-	AuthURL       interface{} // 认证URL
+	Id              interface{} // ID
+	AdminId         interface{} // 管理员ID
+	UserId          interface{} // 用户ID
+	IsOn            interface{} // 是否启用
+	AcmeUserId      interface{} // ACME用户ID
+	DnsDomain       interface{} // DNS主域名
+	DnsProviderId   interface{} // DNS服务商
+	Domains         interface{} // 证书域名
+	CreatedAt       interface{} // 创建时间
+	State           interface{} // 状态
+	CertId          interface{} // 生成的证书ID
+	AutoRenew       interface{} // 是否自动更新
+	AuthType        interface{} // 认证类型
+	AuthURL         interface{} // 认证URL
+	KeyType         interface{} // 证书密钥类型
+	DualKeyType     interface{} // 同时签发的另外一种密钥类型
+	DualCertId      interface{} // 同时签发的证书ID
+	DnsDelegations  interface{} // DNS CNAME委托
+	RenewBeforeDays interface{} // 提前续期天数，0表示自动
+	NextRenewAt     interface{} // 下次续期时间
+	RenewFailures   interface{} // 连续续期失败次数
+	RenewWindow     interface{} // 续期时间段
 }
 
 func NewACMETaskOperator() *ACMETaskOperator {
@@ -2,6 +2,7 @@ package acme
 
 import (
 	"encoding/json"
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/iwind/TeaGo/logs"
 )
 
@@ -18,3 +19,30 @@ func (this *ACMETask) DecodeDomains() []string {
 	}
 	return result
 }
+
+// DecodeDNSDelegations 解析CNAME委托设置：域名 => CNAME目标
+func (this *ACMETask) DecodeDNSDelegations() map[string]string {
+	var result = map[string]string{}
+	if len(this.DnsDelegations) == 0 {
+		return result
+	}
+	err := json.Unmarshal(this.DnsDelegations, &result)
+	if err != nil {
+		logs.Error(err)
+	}
+	return result
+}
+
+// DecodeRenewWindow 解析续期时间段
+func (this *ACMETask) DecodeRenewWindow() *acmeutils.RenewalWindow {
+	if len(this.RenewWindow) == 0 {
+		return nil
+	}
+	var window = &acmeutils.RenewalWindow{}
+	err := json.Unmarshal(this.RenewWindow, window)
+	if err != nil {
+		logs.Error(err)
+		return nil
+	}
+	return window
+}
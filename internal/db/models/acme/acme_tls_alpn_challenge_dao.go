@@ -0,0 +1,195 @@
+package acme
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+// ACMETLSALPNChallengeLife 认证证书有效期，超过此时间的认证证书不再下发到节点
+const ACMETLSALPNChallengeLife = 1 * time.Hour
+
+// 检查节点是否已获取认证证书的间隔
+const tlsALPNWaitInterval = 1 * time.Second
+
+type ACMETLSALPNChallengeDAO dbs.DAO
+
+func NewACMETLSALPNChallengeDAO() *ACMETLSALPNChallengeDAO {
+	return dbs.NewDAO(&ACMETLSALPNChallengeDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeACMETLSALPNChallenges",
+			Model:  new(ACMETLSALPNChallenge),
+			PkName: "id",
+		},
+	}).(*ACMETLSALPNChallengeDAO)
+}
+
+var SharedACMETLSALPNChallengeDAO *ACMETLSALPNChallengeDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedACMETLSALPNChallengeDAO = NewACMETLSALPNChallengeDAO()
+	})
+}
+
+// CreateChallenge 创建认证证书，同一个域名只保留最新的一个
+func (this *ACMETLSALPNChallengeDAO) CreateChallenge(tx *dbs.Tx, taskId int64, domain string, certData []byte, keyData []byte) error {
+	err := this.DeleteExpiredChallenges(tx)
+	if err != nil {
+		return err
+	}
+
+	err = this.Query(tx).
+		Attr("domain", domain).
+		DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	var op = NewACMETLSALPNChallengeOperator()
+	op.TaskId = taskId
+	op.Domain = domain
+	op.CertData = certData
+	op.KeyData = keyData
+	op.CreatedAt = time.Now().Unix()
+	err = this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+
+	_, err = this.NotifyUpdate(tx, domain)
+	return err
+}
+
+// DeleteChallenge 删除认证证书
+func (this *ACMETLSALPNChallengeDAO) DeleteChallenge(tx *dbs.Tx, taskId int64, domain string) error {
+	err := this.Query(tx).
+		Attr("taskId", taskId).
+		Attr("domain", domain).
+		DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	_, err = this.NotifyUpdate(tx, domain)
+	return err
+}
+
+// FindAllAvailableChallenges 查找所有有效的认证证书
+func (this *ACMETLSALPNChallengeDAO) FindAllAvailableChallenges(tx *dbs.Tx) (result []*ACMETLSALPNChallenge, err error) {
+	_, err = this.Query(tx).
+		Gte("createdAt", time.Now().Unix()-int64(ACMETLSALPNChallengeLife.Seconds())).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllAvailableClusterChallenges 查找一组集群中部署的域名的有效认证证书
+func (this *ACMETLSALPNChallengeDAO) FindAllAvailableClusterChallenges(tx *dbs.Tx, clusterIds []int64) (result []*ACMETLSALPNChallenge, err error) {
+	if len(clusterIds) == 0 {
+		return
+	}
+
+	challenges, err := this.FindAllAvailableChallenges(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, challenge := range challenges {
+		for _, clusterId := range clusterIds {
+			exists, err := models.SharedServerDAO.ExistServerNameInCluster(tx, clusterId, challenge.Domain, 0, true)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				result = append(result, challenge)
+				break
+			}
+		}
+	}
+	return
+}
+
+// DeleteExpiredChallenges 清理过期的认证证书
+func (this *ACMETLSALPNChallengeDAO) DeleteExpiredChallenges(tx *dbs.Tx) error {
+	return this.Query(tx).
+		Lt("createdAt", time.Now().Unix()-int64(ACMETLSALPNChallengeLife.Seconds())).
+		DeleteQuickly()
+}
+
+// WaitForNodes 等待部署此域名的集群在线节点获取认证证书
+// 离线节点无法完成任务，所以只等待在线节点；如果超时还有节点没有完成更新，则返回 false
+func (this *ACMETLSALPNChallengeDAO) WaitForNodes(tx *dbs.Tx, domain string, timeout time.Duration) (ok bool, err error) {
+	clusterIds, err := this.findDomainClusterIds(tx, domain)
+	if err != nil {
+		return false, err
+	}
+	if len(clusterIds) == 0 {
+		return false, errors.New("no cluster is serving the domain '" + domain + "'")
+	}
+
+	var nodeIds = []int64{}
+	for _, clusterId := range clusterIds {
+		clusterNodeIds, err := models.SharedNodeDAO.FindAllOnlineNodeIdsWithClusterId(tx, clusterId)
+		if err != nil {
+			return false, err
+		}
+		nodeIds = append(nodeIds, clusterNodeIds...)
+	}
+	if len(nodeIds) == 0 {
+		return false, errors.New("no online node is serving the domain '" + domain + "'")
+	}
+
+	var deadline = time.Now().Add(timeout)
+	for {
+		exists, err := models.SharedNodeTaskDAO.ExistsDoingClusterTasks(tx, nodeconfigs.NodeRoleNode, clusterIds, nodeIds, models.NodeTaskTypeACMETLSALPNChanged)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(tlsALPNWaitInterval)
+	}
+}
+
+// NotifyUpdate 通知部署此域名的集群更新认证证书
+func (this *ACMETLSALPNChallengeDAO) NotifyUpdate(tx *dbs.Tx, domain string) (clusterIds []int64, err error) {
+	clusterIds, err = this.findDomainClusterIds(tx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, clusterId := range clusterIds {
+		err = models.SharedNodeTaskDAO.CreateClusterTask(tx, nodeconfigs.NodeRoleNode, clusterId, 0, 0, models.NodeTaskTypeACMETLSALPNChanged)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// 查找部署此域名的集群
+func (this *ACMETLSALPNChallengeDAO) findDomainClusterIds(tx *dbs.Tx, domain string) (clusterIds []int64, err error) {
+	servers, err := models.SharedServerDAO.FindAllEnabledServersWithDomain(tx, domain)
+	if err != nil {
+		return nil, err
+	}
+	var clusterIdMap = map[int64]bool{}
+	for _, server := range servers {
+		var clusterId = int64(server.ClusterId)
+		if clusterId <= 0 || clusterIdMap[clusterId] {
+			continue
+		}
+		clusterIdMap[clusterId] = true
+		clusterIds = append(clusterIds, clusterId)
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package acme
+
+// ACMETLSALPNChallenge TLS-ALPN-01认证证书
+type ACMETLSALPNChallenge struct {
+	Id        uint64 `field:"id"`        // ID
+	TaskId    uint64 `field:"taskId"`    // 任务ID
+	Domain    string `field:"domain"`    // 域名
+	CertData  []byte `field:"certData"`  // 认证证书
+	KeyData   []byte `field:"keyData"`   // 认证证书私钥
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type ACMETLSALPNChallengeOperator struct {
+	Id        any // ID
+	TaskId    any // 任务ID
+	Domain    any // 域名
+	CertData  any // 认证证书
+	KeyData   any // 认证证书私钥
+	CreatedAt any // 创建时间
+}
+
+func NewACMETLSALPNChallengeOperator() *ACMETLSALPNChallengeOperator {
+	return &ACMETLSALPNChallengeOperator{}
+}
@@ -6,6 +6,9 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
+	"fmt"
+	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
@@ -66,7 +69,12 @@ func (this *ACMEUserDAO) FindEnabledACMEUser(tx *dbs.Tx, id int64) (*ACMEUser, e
 	if result == nil {
 		return nil, err
 	}
-	return result.(*ACMEUser), err
+	var user = result.(*ACMEUser)
+	user.PrivateKey, err = encrypt.DecryptSecretString(user.PrivateKey, this.privateKeyAAD(int64(user.Id)))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key of acme user '%d' failed: %w", user.Id, err)
+	}
+	return user, nil
 }
 
 // CreateACMEUser 创建用户
@@ -81,8 +89,6 @@ func (this *ACMEUserDAO) CreateACMEUser(tx *dbs.Tx, adminId int64, userId int64,
 	if err != nil {
 		return 0, err
 	}
-	privateKeyText := base64.StdEncoding.EncodeToString(privateKeyData)
-
 	var op = NewACMEUserOperator()
 	op.AdminId = adminId
 	op.UserId = userId
@@ -90,13 +96,29 @@ func (this *ACMEUserDAO) CreateACMEUser(tx *dbs.Tx, adminId int64, userId int64,
 	op.AccountId = accountId
 	op.Email = email
 	op.Description = description
-	op.PrivateKey = privateKeyText
 	op.State = ACMEUserStateEnabled
-	err = this.Save(tx, op)
+
+	var acmeUserId int64
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err := this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		acmeUserId = types.Int64(op.Id)
+
+		privateKeyText, err := encrypt.EncryptSecretString(base64.StdEncoding.EncodeToString(privateKeyData), this.privateKeyAAD(acmeUserId))
+		if err != nil {
+			return err
+		}
+		return this.Query(tx).
+			Pk(acmeUserId).
+			Set("privateKey", privateKeyText).
+			UpdateQuickly()
+	})
 	if err != nil {
 		return 0, err
 	}
-	return types.Int64(op.Id), nil
+	return acmeUserId, nil
 }
 
 // UpdateACMEUser 修改用户信息
@@ -190,6 +212,14 @@ func (this *ACMEUserDAO) FindAllACMEUsers(tx *dbs.Tx, adminId int64, userId int6
 	return
 }
 
+// CountACMEUsersWithProviderCode 计算使用某个服务商的用户数量
+func (this *ACMEUserDAO) CountACMEUsersWithProviderCode(tx *dbs.Tx, providerCode string) (int64, error) {
+	return this.Query(tx).
+		State(ACMEUserStateEnabled).
+		Attr("providerCode", providerCode).
+		Count()
+}
+
 // CheckACMEUser 检查用户权限
 func (this *ACMEUserDAO) CheckACMEUser(tx *dbs.Tx, acmeUserId int64, adminId int64, userId int64) (bool, error) {
 	if acmeUserId <= 0 {
@@ -209,3 +239,47 @@ func (this *ACMEUserDAO) CheckACMEUser(tx *dbs.Tx, acmeUserId int64, adminId int
 		State(ACMEUserStateEnabled).
 		Exist()
 }
+
+// RewrapPrivateKeys 使用当前主密钥重新加密所有用户私钥
+func (this *ACMEUserDAO) RewrapPrivateKeys(tx *dbs.Tx) (count int64, err error) {
+	var lastId int64
+	for {
+		var users []*ACMEUser
+		_, err = this.Query(tx).
+			Gt("id", lastId).
+			Where("LENGTH(privateKey)>0").
+			Result("id", "privateKey").
+			AscPk().
+			Limit(100).
+			Slice(&users).
+			FindAll()
+		if err != nil || len(users) == 0 {
+			return
+		}
+
+		for _, user := range users {
+			lastId = int64(user.Id)
+
+			privateKey, changed, rewrapErr := encrypt.SharedKeyring().Rewrap([]byte(user.PrivateKey), this.privateKeyAAD(int64(user.Id)))
+			if rewrapErr != nil {
+				return count, fmt.Errorf("rewrap acme user '%d' failed: %w", user.Id, rewrapErr)
+			}
+			if !changed {
+				continue
+			}
+			err = this.Query(tx).
+				Pk(user.Id).
+				Set("privateKey", string(privateKey)).
+				UpdateQuickly()
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 私钥加密时使用的附加认证数据
+func (this *ACMEUserDAO) privateKeyAAD(acmeUserId int64) []byte {
+	return encrypt.AAD(this.Table, "privateKey", acmeUserId)
+}
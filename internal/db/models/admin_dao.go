@@ -1,13 +1,16 @@
 package models
 
 import (
+	"encoding/json"
 	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ssoutils"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/types"
-	stringutil "github.com/iwind/TeaGo/utils/string"
+	"strings"
 )
 
 const (
@@ -15,6 +18,13 @@ const (
 	AdminStateDisabled = 0 // 已禁用
 )
 
+var (
+	ErrSSOAdminNotFound     = errors.New("sso admin not found")
+	ErrSSOAdminDisabled     = errors.New("sso admin is disabled")
+	ErrSSOUsernameConflicts = errors.New("sso username is used by another admin")
+	ErrSSOAdminNoRoles      = errors.New("sso admin has no roles")
+)
+
 type AdminDAO dbs.DAO
 
 func NewAdminDAO() *AdminDAO {
@@ -100,18 +110,59 @@ func (this *AdminDAO) FindAdminFullname(tx *dbs.Tx, adminId int64) (string, erro
 }
 
 // CheckAdminPassword 检查用户名、密码
+// 如果密码使用的是旧的哈希算法，校验成功后会自动升级
 func (this *AdminDAO) CheckAdminPassword(tx *dbs.Tx, username string, encryptedPassword string) (int64, error) {
 	if len(username) == 0 || len(encryptedPassword) == 0 {
 		return 0, nil
 	}
-	return this.Query(tx).
+	one, err := this.Query(tx).
 		Attr("username", username).
-		Attr("password", encryptedPassword).
 		Attr("state", AdminStateEnabled).
 		Attr("isOn", true).
 		Attr("canLogin", 1).
-		ResultPk().
-		FindInt64Col(0)
+		Result("id", "password", "passwordHistory").
+		Find()
+	if err != nil || one == nil {
+		return 0, err
+	}
+	var admin = one.(*Admin)
+
+	ok, newHash := verifyLoginPassword(tx, admin.Password, encryptedPassword)
+	if !ok {
+		return 0, nil
+	}
+	if len(newHash) > 0 {
+		historyJSON, err := composePasswordHistory(admin.PasswordHistory, admin.Password, newHash)
+		if err != nil {
+			return 0, err
+		}
+		var op = NewAdminOperator()
+		op.Id = admin.Id
+		op.Password = newHash
+		op.PasswordHistory = historyJSON
+		op.PasswordIsWeak = isWeakEncodedPassword(encryptedPassword)
+		err = this.Save(tx, op)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(admin.Id), nil
+}
+
+// CheckAdminPasswordReused 检查密码是否和最近使用过的密码重复
+func (this *AdminDAO) CheckAdminPasswordReused(tx *dbs.Tx, adminId int64, password string, historySize int) (bool, error) {
+	if adminId <= 0 || historySize <= 0 {
+		return false, nil
+	}
+	one, err := this.Query(tx).
+		Pk(adminId).
+		Result("password", "passwordHistory").
+		Find()
+	if err != nil || one == nil {
+		return false, err
+	}
+	var admin = one.(*Admin)
+	return checkPasswordReused(admin.Password, admin.PasswordHistory, password, historySize), nil
 }
 
 // FindAdminIdWithUsername 根据用户名查询管理员ID
@@ -150,8 +201,11 @@ func (this *AdminDAO) UpdateAdminPassword(tx *dbs.Tx, adminId int64, password st
 	}
 	var op = NewAdminOperator()
 	op.Id = adminId
-	op.Password = stringutil.Md5(password)
-	err := this.Save(tx, op)
+	err := this.composePassword(tx, adminId, password, op)
+	if err != nil {
+		return err
+	}
+	err = this.Save(tx, op)
 	return err
 }
 
@@ -162,7 +216,10 @@ func (this *AdminDAO) CreateAdmin(tx *dbs.Tx, username string, canLogin bool, pa
 	op.State = AdminStateEnabled
 	op.Username = username
 	op.CanLogin = canLogin
-	op.Password = stringutil.Md5(password)
+	err := this.composePassword(tx, 0, password, op)
+	if err != nil {
+		return 0, err
+	}
 	op.Fullname = fullname
 	op.IsSuper = isSuper
 	if len(modulesJSON) > 0 {
@@ -170,7 +227,7 @@ func (this *AdminDAO) CreateAdmin(tx *dbs.Tx, username string, canLogin bool, pa
 	} else {
 		op.Modules = "[]"
 	}
-	err := this.Save(tx, op)
+	err = this.Save(tx, op)
 	if err != nil {
 		return 0, err
 	}
@@ -200,7 +257,10 @@ func (this *AdminDAO) UpdateAdmin(tx *dbs.Tx, adminId int64, username string, ca
 	op.Username = username
 	op.CanLogin = canLogin
 	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+		err := this.composePassword(tx, adminId, password, op)
+		if err != nil {
+			return err
+		}
 	}
 	op.IsSuper = isSuper
 	if len(modulesJSON) > 0 {
@@ -247,7 +307,10 @@ func (this *AdminDAO) UpdateAdminLogin(tx *dbs.Tx, adminId int64, username strin
 	op.Id = adminId
 	op.Username = username
 	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+		err := this.composePassword(tx, adminId, password, op)
+		if err != nil {
+			return err
+		}
 	}
 	err := this.Save(tx, op)
 	return err
@@ -287,7 +350,8 @@ func (this *AdminDAO) CountAllEnabledAdmins(tx *dbs.Tx, keyword string, hasWeakP
 		query.Param("keyword", dbutils.QuoteLike(keyword))
 	}
 	if hasWeakPasswords {
-		query.Attr("password", weakPasswords)
+		query.Where("(passwordIsWeak=1 OR FIND_IN_SET(password, :weakPasswords))").
+			Param("weakPasswords", strings.Join(weakPasswords, ","))
 		query.Attr("isOn", true)
 	}
 	return query.
@@ -303,13 +367,14 @@ func (this *AdminDAO) ListEnabledAdmins(tx *dbs.Tx, keyword string, hasWeakPassw
 		query.Param("keyword", dbutils.QuoteLike(keyword))
 	}
 	if hasWeakPasswords {
-		query.Attr("password", weakPasswords)
+		query.Where("(passwordIsWeak=1 OR FIND_IN_SET(password, :weakPasswords))").
+			Param("weakPasswords", strings.Join(weakPasswords, ","))
 		query.Attr("isOn", true)
 	}
 
 	_, err = query.
 		State(AdminStateEnabled).
-		Result("id", "isOn", "username", "fullname", "isSuper", "createdAt", "canLogin", "password").
+		Result("id", "isOn", "username", "fullname", "isSuper", "createdAt", "canLogin", "password", "passwordIsWeak").
 		Offset(offset).
 		Limit(size).
 		DescPk().
@@ -345,3 +410,183 @@ func (this *AdminDAO) CheckSuperAdmin(tx *dbs.Tx, adminId int64) (bool, error) {
 		Attr("isSuper", true).
 		Exist()
 }
+
+// UpdateAdminRoleIds 设置管理员的角色
+func (this *AdminDAO) UpdateAdminRoleIds(tx *dbs.Tx, adminId int64, roleIds []int64) error {
+	if adminId <= 0 {
+		return errors.New("invalid adminId")
+	}
+	if roleIds == nil {
+		roleIds = []int64{}
+	}
+	roleIdsJSON, err := json.Marshal(roleIds)
+	if err != nil {
+		return err
+	}
+	return this.Query(tx).
+		Pk(adminId).
+		Set("roleIds", roleIdsJSON).
+		UpdateQuickly()
+}
+
+// FindAdminRoleIds 查找管理员的角色
+func (this *AdminDAO) FindAdminRoleIds(tx *dbs.Tx, adminId int64) (isSuper bool, roleIds []int64, err error) {
+	one, err := this.Query(tx).
+		Pk(adminId).
+		State(AdminStateEnabled).
+		Result("isSuper", "roleIds").
+		Find()
+	if err != nil || one == nil {
+		return false, nil, err
+	}
+	var admin = one.(*Admin)
+	return admin.IsSuper, admin.DecodeRoleIds(), nil
+}
+
+// FindOrCreateSSOAdmin 查找通过单点登录认证的管理员，如果不存在并且 autoCreate 为true，则自动创建
+// 不会自动关联同名的本地管理员，防止身份提供方中的同名账号接管本地账号
+func (this *AdminDAO) FindOrCreateSSOAdmin(tx *dbs.Tx, identity *ssoutils.Identity, grant *ssoutils.RoleGrant, autoCreate bool, syncRoles bool) (int64, error) {
+	if identity == nil || len(identity.Source) == 0 || len(identity.Subject) == 0 {
+		return 0, errors.New("invalid sso identity")
+	}
+	if grant == nil {
+		grant = &ssoutils.RoleGrant{}
+	}
+	var roleIds = grant.RoleIds
+	if roleIds == nil {
+		roleIds = []int64{}
+	}
+	roleIdsJSON, err := json.Marshal(roleIds)
+	if err != nil {
+		return 0, err
+	}
+
+	// 没有角色的管理员不受权限限制，所以不能通过单点登录设置
+	var hasRoles = grant.IsSuper || len(roleIds) > 0
+
+	// 在同一个事务中查找和创建，并锁定查询到的记录，防止同一个身份同时登录时创建多个管理员
+	var adminId int64
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		one, err := this.Query(tx).
+			Attr("ssoSource", identity.Source).
+			Attr("ssoSubject", identity.Subject).
+			State(AdminStateEnabled).
+			Result("id", "isOn", "canLogin").
+			Lock("FOR UPDATE").
+			Find()
+		if err != nil {
+			return err
+		}
+		if one != nil {
+			var admin = one.(*Admin)
+			if !admin.IsOn || !admin.CanLogin {
+				return ErrSSOAdminDisabled
+			}
+
+			var op = NewAdminOperator()
+			op.Id = admin.Id
+			if len(identity.Fullname) > 0 {
+				op.Fullname = identity.Fullname
+			}
+			if syncRoles {
+				if !hasRoles {
+					return ErrSSOAdminNoRoles
+				}
+				op.IsSuper = grant.IsSuper
+				op.RoleIds = roleIdsJSON
+			}
+			err = this.Save(tx, op)
+			if err != nil {
+				return err
+			}
+			adminId = int64(admin.Id)
+			return nil
+		}
+
+		if !autoCreate {
+			return ErrSSOAdminNotFound
+		}
+		if !hasRoles {
+			return ErrSSOAdminNoRoles
+		}
+
+		var username = identity.Username
+		if len(username) == 0 || len(username) > 100 {
+			return errors.New("invalid sso username '" + username + "'")
+		}
+		exists, err := this.CheckAdminUsername(tx, 0, username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrSSOUsernameConflicts
+		}
+
+		var fullname = identity.Fullname
+		if len(fullname) == 0 {
+			fullname = username
+		}
+
+		// 使用随机密码，通过单点登录创建的管理员不能使用密码登录
+		password, err := ssoutils.RandomString()
+		if err != nil {
+			return err
+		}
+
+		var op = NewAdminOperator()
+		op.IsOn = true
+		op.State = AdminStateEnabled
+		op.Username = username
+		op.CanLogin = true
+		err = this.composePassword(tx, 0, password, op)
+		if err != nil {
+			return err
+		}
+		op.PasswordIsWeak = false
+		op.Fullname = fullname
+		op.IsSuper = grant.IsSuper
+		op.Modules = "[]"
+		op.RoleIds = roleIdsJSON
+		op.SsoSource = identity.Source
+		op.SsoSubject = identity.Subject
+		err = this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		adminId = types.Int64(op.Id)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return adminId, nil
+}
+
+// 设置新密码
+// password 为明文密码
+func (this *AdminDAO) composePassword(tx *dbs.Tx, adminId int64, password string, op *AdminOperator) error {
+	hash, err := hashLoginPassword(tx, password)
+	if err != nil {
+		return err
+	}
+
+	var oldHistoryJSON []byte
+	if adminId > 0 {
+		oldHistoryJSON, err = this.Query(tx).
+			Pk(adminId).
+			Result("passwordHistory").
+			FindBytesCol()
+		if err != nil {
+			return err
+		}
+	}
+	historyJSON, err := composePasswordHistory(oldHistoryJSON, "", hash)
+	if err != nil {
+		return err
+	}
+
+	op.Password = hash
+	op.PasswordHistory = historyJSON
+	op.PasswordIsWeak = passwordutils.IsWeakPassword(password)
+	return nil
+}
@@ -1,40 +1,45 @@
 package models
 
-import stringutil "github.com/iwind/TeaGo/utils/string"
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	stringutil "github.com/iwind/TeaGo/utils/string"
+)
 
 // 弱密码集合
 var weakPasswords = []string{}
 
 func init() {
 	// 初始化弱密码集合
-	for _, password := range []string{
-		"123",
-		"1234",
-		"12345",
-		"123456",
-		"12345678",
-		"123456789",
-		"000000",
-		"111111",
-		"666666",
-		"888888",
-		"654321",
-		"123456789",
-		"password",
-		"qwerty",
-		"admin",
-	} {
+	for _, password := range passwordutils.WeakPasswords {
 		weakPasswords = append(weakPasswords, stringutil.Md5(password))
 	}
 }
 
 func (this *Admin) HasWeakPassword() bool {
+	if this.PasswordIsWeak {
+		return true
+	}
 	if len(this.Password) == 0 {
 		return false
 	}
 
+	return isWeakEncodedPassword(this.Password)
+}
+
+// DecodeRoleIds 解析角色ID
+func (this *Admin) DecodeRoleIds() []int64 {
+	var roleIds = []int64{}
+	if IsNotNull(this.RoleIds) {
+		_ = json.Unmarshal(this.RoleIds, &roleIds)
+	}
+	return roleIds
+}
+
+// 判断MD5之后的密码是否为弱密码
+func isWeakEncodedPassword(encodedPassword string) bool {
 	for _, weakPassword := range weakPasswords {
-		if weakPassword == this.Password {
+		if weakPassword == encodedPassword {
 			return true
 		}
 	}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+const (
+	AdminRoleStateEnabled  = 1 // 已启用
+	AdminRoleStateDisabled = 0 // 已禁用
+)
+
+type AdminRoleDAO dbs.DAO
+
+func NewAdminRoleDAO() *AdminRoleDAO {
+	return dbs.NewDAO(&AdminRoleDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeAdminRoles",
+			Model:  new(AdminRole),
+			PkName: "id",
+		},
+	}).(*AdminRoleDAO)
+}
+
+var SharedAdminRoleDAO *AdminRoleDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedAdminRoleDAO = NewAdminRoleDAO()
+	})
+}
+
+// DisableAdminRole 禁用角色
+func (this *AdminRoleDAO) DisableAdminRole(tx *dbs.Tx, roleId int64) error {
+	_, err := this.Query(tx).
+		Pk(roleId).
+		Set("state", AdminRoleStateDisabled).
+		Update()
+	return err
+}
+
+// FindEnabledAdminRole 查找启用中的角色
+func (this *AdminRoleDAO) FindEnabledAdminRole(tx *dbs.Tx, roleId int64) (*AdminRole, error) {
+	result, err := this.Query(tx).
+		Pk(roleId).
+		State(AdminRoleStateEnabled).
+		Find()
+	if result == nil {
+		return nil, err
+	}
+	return result.(*AdminRole), err
+}
+
+// CreateAdminRole 创建角色
+func (this *AdminRoleDAO) CreateAdminRole(tx *dbs.Tx, name string, description string, permissions []string, isOn bool) (int64, error) {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return 0, err
+	}
+
+	var op = NewAdminRoleOperator()
+	op.Name = name
+	op.Description = description
+	op.Permissions = permissionsJSON
+	op.IsOn = isOn
+	op.CreatedAt = time.Now().Unix()
+	op.UpdatedAt = time.Now().Unix()
+	op.State = AdminRoleStateEnabled
+	err = this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+	return types.Int64(op.Id), nil
+}
+
+// UpdateAdminRole 修改角色
+func (this *AdminRoleDAO) UpdateAdminRole(tx *dbs.Tx, roleId int64, name string, description string, permissions []string, isOn bool) error {
+	if roleId <= 0 {
+		return errors.New("invalid roleId")
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+
+	var op = NewAdminRoleOperator()
+	op.Id = roleId
+	op.Name = name
+	op.Description = description
+	op.Permissions = permissionsJSON
+	op.IsOn = isOn
+	op.UpdatedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// FindAllEnabledAdminRoles 列出所有角色
+func (this *AdminRoleDAO) FindAllEnabledAdminRoles(tx *dbs.Tx) (result []*AdminRole, err error) {
+	_, err = this.Query(tx).
+		State(AdminRoleStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindRolesPermissions 查找一组角色的所有权限
+// 已禁用的角色会被忽略
+func (this *AdminRoleDAO) FindRolesPermissions(tx *dbs.Tx, roleIds []int64) ([]string, error) {
+	var result = []string{}
+	if len(roleIds) == 0 {
+		return result, nil
+	}
+
+	ones, err := this.Query(tx).
+		Attr("id", roleIds).
+		State(AdminRoleStateEnabled).
+		Attr("isOn", true).
+		Result("permissions").
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, one.(*AdminRole).DecodePermissions()...)
+	}
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+const (
+	AdminRoleField_Id          dbs.FieldName = "id"          // ID
+	AdminRoleField_Name        dbs.FieldName = "name"        // 名称
+	AdminRoleField_Description dbs.FieldName = "description" // 描述
+	AdminRoleField_Permissions dbs.FieldName = "permissions" // 权限
+	AdminRoleField_IsOn        dbs.FieldName = "isOn"        // 是否启用
+	AdminRoleField_CreatedAt   dbs.FieldName = "createdAt"   // 创建时间
+	AdminRoleField_UpdatedAt   dbs.FieldName = "updatedAt"   // 修改时间
+	AdminRoleField_State       dbs.FieldName = "state"       // 状态
+)
+
+// AdminRole 管理员角色
+type AdminRole struct {
+	Id          uint32   `field:"id"`          // ID
+	Name        string   `field:"name"`        // 名称
+	Description string   `field:"description"` // 描述
+	Permissions dbs.JSON `field:"permissions"` // 权限
+	IsOn        bool     `field:"isOn"`        // 是否启用
+	CreatedAt   uint64   `field:"createdAt"`   // 创建时间
+	UpdatedAt   uint64   `field:"updatedAt"`   // 修改时间
+	State       uint8    `field:"state"`       // 状态
+}
+
+type AdminRoleOperator struct {
+	Id          any // ID
+	Name        any // 名称
+	Description any // 描述
+	Permissions any // 权限
+	IsOn        any // 是否启用
+	CreatedAt   any // 创建时间
+	UpdatedAt   any // 修改时间
+	State       any // 状态
+}
+
+func NewAdminRoleOperator() *AdminRoleOperator {
+	return &AdminRoleOperator{}
+}
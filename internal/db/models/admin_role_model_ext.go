@@ -0,0 +1,12 @@
+package models
+
+import "encoding/json"
+
+// DecodePermissions 解析权限
+func (this *AdminRole) DecodePermissions() []string {
+	var permissions = []string{}
+	if IsNotNull(this.Permissions) {
+		_ = json.Unmarshal(this.Permissions, &permissions)
+	}
+	return permissions
+}
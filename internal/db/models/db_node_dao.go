@@ -2,6 +2,8 @@ package models
 
 import (
 	"encoding/base64"
+	"fmt"
+	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
@@ -73,7 +75,10 @@ func (this *DBNodeDAO) FindEnabledDBNode(tx *dbs.Tx, id int64) (*DBNode, error)
 		return nil, err
 	}
 	node := result.(*DBNode)
-	node.Password = this.DecodePassword(node.Password)
+	node.Password, err = this.DecodePassword(int64(node.Id), node.Password)
+	if err != nil {
+		return nil, err
+	}
 	return node, nil
 }
 
@@ -101,8 +106,14 @@ func (this *DBNodeDAO) ListEnabledNodes(tx *dbs.Tx, offset int64, size int64) (r
 		Slice(&result).
 		DescPk().
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
 	for _, node := range result {
-		node.Password = this.DecodePassword(node.Password)
+		node.Password, err = this.DecodePassword(int64(node.Id), node.Password)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return
 }
@@ -118,13 +129,30 @@ func (this *DBNodeDAO) CreateDBNode(tx *dbs.Tx, isOn bool, name string, descript
 	op.Port = port
 	op.Database = database
 	op.Username = username
-	op.Password = this.EncodePassword(password)
 	op.Charset = charset
-	err := this.Save(tx, op)
+
+	var nodeId int64
+	err := dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err := this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		nodeId = types.Int64(op.Id)
+
+		// 密码和节点ID绑定
+		encodedPassword, err := this.EncodePassword(nodeId, password)
+		if err != nil {
+			return err
+		}
+		return this.Query(tx).
+			Pk(nodeId).
+			Set("password", encodedPassword).
+			UpdateQuickly()
+	})
 	if err != nil {
 		return 0, err
 	}
-	return types.Int64(op.Id), nil
+	return nodeId, nil
 }
 
 // UpdateNode 修改节点
@@ -141,9 +169,13 @@ func (this *DBNodeDAO) UpdateNode(tx *dbs.Tx, nodeId int64, isOn bool, name stri
 	op.Port = port
 	op.Database = database
 	op.Username = username
-	op.Password = this.EncodePassword(password)
+	encodedPassword, err := this.EncodePassword(nodeId, password)
+	if err != nil {
+		return err
+	}
+	op.Password = encodedPassword
 	op.Charset = charset
-	err := this.Save(tx, op)
+	err = this.Save(tx, op)
 	return err
 }
 
@@ -155,32 +187,104 @@ func (this *DBNodeDAO) FindAllEnabledAndOnDBNodes(tx *dbs.Tx) (result []*DBNode,
 		Slice(&result).
 		DescPk().
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
 	for _, node := range result {
-		node.Password = this.DecodePassword(node.Password)
+		node.Password, err = this.DecodePassword(int64(node.Id), node.Password)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return
 }
 
 // EncodePassword 加密密码
-func (this *DBNodeDAO) EncodePassword(password string) string {
-	if strings.HasPrefix(password, DBNodePasswordEncodedPrefix) {
-		return password
+// 如果已配置主密钥则使用信封加密，否则使用旧的加密方式
+func (this *DBNodeDAO) EncodePassword(nodeId int64, password string) (string, error) {
+	if strings.HasPrefix(password, DBNodePasswordEncodedPrefix) || encrypt.IsEnvelope([]byte(password)) {
+		return password, nil
+	}
+	if encrypt.SharedKeyring().IsEnabled() {
+		encodedPassword, err := encrypt.EncryptSecretString(password, this.passwordAAD(nodeId))
+		if err != nil {
+			return "", fmt.Errorf("encrypt password of db node '%d' failed: %w", nodeId, err)
+		}
+		return encodedPassword, nil
 	}
 	encodedString := base64.StdEncoding.EncodeToString(encrypt.MagicKeyEncode([]byte(password)))
-	return DBNodePasswordEncodedPrefix + encodedString
+	return DBNodePasswordEncodedPrefix + encodedString, nil
 }
 
 // DecodePassword 解密密码
-func (this *DBNodeDAO) DecodePassword(password string) string {
+func (this *DBNodeDAO) DecodePassword(nodeId int64, password string) (string, error) {
+	if encrypt.IsEnvelope([]byte(password)) {
+		decodedPassword, err := encrypt.DecryptSecretString(password, this.passwordAAD(nodeId))
+		if err != nil {
+			return "", fmt.Errorf("decrypt password of db node '%d' failed: %w", nodeId, err)
+		}
+		return decodedPassword, nil
+	}
 	if !strings.HasPrefix(password, DBNodePasswordEncodedPrefix) {
-		return password
+		return password, nil
 	}
 	dataString := password[len(DBNodePasswordEncodedPrefix):]
 	data, err := base64.StdEncoding.DecodeString(dataString)
 	if err != nil {
-		return password
+		return password, nil
+	}
+	return string(encrypt.MagicKeyDecode(data)), nil
+}
+
+// RewrapPasswords 使用当前主密钥重新加密所有节点密码
+func (this *DBNodeDAO) RewrapPasswords(tx *dbs.Tx) (count int64, err error) {
+	var lastId int64
+	for {
+		var nodes []*DBNode
+		_, err = this.Query(tx).
+			Gt("id", lastId).
+			Where("LENGTH(password)>0").
+			Result("id", "password").
+			AscPk().
+			Limit(100).
+			Slice(&nodes).
+			FindAll()
+		if err != nil || len(nodes) == 0 {
+			return
+		}
+
+		for _, node := range nodes {
+			lastId = int64(node.Id)
+
+			var password = node.Password
+			if strings.HasPrefix(password, DBNodePasswordEncodedPrefix) {
+				password, err = this.DecodePassword(int64(node.Id), password)
+				if err != nil {
+					return count, err
+				}
+			}
+			newPassword, changed, rewrapErr := encrypt.SharedKeyring().Rewrap([]byte(password), this.passwordAAD(int64(node.Id)))
+			if rewrapErr != nil {
+				return count, fmt.Errorf("rewrap db node '%d' failed: %w", node.Id, rewrapErr)
+			}
+			if !changed {
+				continue
+			}
+			err = this.Query(tx).
+				Pk(node.Id).
+				Set("password", string(newPassword)).
+				UpdateQuickly()
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
 	}
-	return string(encrypt.MagicKeyDecode(data))
+}
+
+// 密码加密时使用的附加认证数据
+func (this *DBNodeDAO) passwordAAD(nodeId int64) []byte {
+	return encrypt.AAD(this.Table, "password", nodeId)
 }
 
 // CheckNodeIsOn 检查节点是否已经启用
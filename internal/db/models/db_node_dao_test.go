@@ -14,8 +14,14 @@ func TestDBNodeDAO_EncodePassword(t *testing.T) {
 		"$%#@!@(*))*&^&=]{|",
 		"中文",
 	} {
-		encoded := dao.EncodePassword(password)
-		decoded := dao.DecodePassword(encoded)
+		encoded, err := dao.EncodePassword(1, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := dao.DecodePassword(1, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
 		if decoded != password {
 			t.Fatal(decoded, password)
 		}
@@ -25,7 +31,10 @@ func TestDBNodeDAO_EncodePassword(t *testing.T) {
 func TestDBNodeDAO_EncodePassword_Encoded(t *testing.T) {
 	dao := NewDBNodeDAO()
 	password := DBNodePasswordEncodedPrefix + "123456"
-	encoded := dao.EncodePassword(password)
+	encoded, err := dao.EncodePassword(1, password)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if encoded != password {
 		t.Fatal()
 	}
@@ -35,7 +44,10 @@ func TestDBNodeDAO_EncodePassword_Encoded(t *testing.T) {
 func TestDBNodeDAO_EncodePassword_Decoded(t *testing.T) {
 	dao := NewDBNodeDAO()
 	password := "123456"
-	decoded := dao.DecodePassword(password)
+	decoded, err := dao.DecodePassword(1, password)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if decoded != password {
 		t.Fatal()
 	}
@@ -0,0 +1,129 @@
+package dns
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+type DNSChangeSource = string
+
+const (
+	DNSChangeSourceTask   DNSChangeSource = "task"   // DNS任务
+	DNSChangeSourceDrift  DNSChangeSource = "drift"  // 偏差修正
+	DNSChangeSourceManual DNSChangeSource = "manual" // 手动修改
+)
+
+type DNSChangeAction = string
+
+const (
+	DNSChangeActionAdd    DNSChangeAction = "add"
+	DNSChangeActionUpdate DNSChangeAction = "update"
+	DNSChangeActionDelete DNSChangeAction = "delete"
+)
+
+type DNSChangeLogDAO dbs.DAO
+
+func NewDNSChangeLogDAO() *DNSChangeLogDAO {
+	return dbs.NewDAO(&DNSChangeLogDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeDNSChangeLogs",
+			Model:  new(DNSChangeLog),
+			PkName: "id",
+		},
+	}).(*DNSChangeLogDAO)
+}
+
+var SharedDNSChangeLogDAO *DNSChangeLogDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedDNSChangeLogDAO = NewDNSChangeLogDAO()
+	})
+}
+
+// CreateLog 记录变更
+// oldRecord 和 newRecord 可以为nil
+func (this *DNSChangeLogDAO) CreateLog(tx *dbs.Tx, source DNSChangeSource, clusterId int64, domainId int64, taskId int64, action DNSChangeAction, oldRecord *dnstypes.Record, newRecord *dnstypes.Record, resultErr error) error {
+	var op = NewDNSChangeLogOperator()
+	op.ClusterId = clusterId
+	op.DomainId = domainId
+	op.TaskId = taskId
+	op.Source = source
+	op.Action = action
+
+	var record = newRecord
+	if record == nil {
+		record = oldRecord
+	}
+	if record != nil {
+		op.RecordName = record.Name
+		op.RecordType = record.Type
+	}
+
+	if oldRecord != nil {
+		oldRecordJSON, err := json.Marshal(oldRecord)
+		if err != nil {
+			return err
+		}
+		op.OldRecord = oldRecordJSON
+	}
+	if newRecord != nil {
+		newRecordJSON, err := json.Marshal(newRecord)
+		if err != nil {
+			return err
+		}
+		op.NewRecord = newRecordJSON
+	}
+
+	op.IsOk = resultErr == nil
+	if resultErr != nil {
+		op.Error = resultErr.Error()
+	}
+	op.CreatedAt = time.Now().Unix()
+	op.Day = timeutil.Format("Ymd")
+	return this.Save(tx, op)
+}
+
+// CountLogs 计算日志数量
+func (this *DNSChangeLogDAO) CountLogs(tx *dbs.Tx, clusterId int64, domainId int64) (int64, error) {
+	var query = this.Query(tx)
+	if clusterId > 0 {
+		query.Attr("clusterId", clusterId)
+	}
+	if domainId > 0 {
+		query.Attr("domainId", domainId)
+	}
+	return query.Count()
+}
+
+// ListLogs 列出单页日志
+func (this *DNSChangeLogDAO) ListLogs(tx *dbs.Tx, clusterId int64, domainId int64, offset int64, size int64) (result []*DNSChangeLog, err error) {
+	var query = this.Query(tx)
+	if clusterId > 0 {
+		query.Attr("clusterId", clusterId)
+	}
+	if domainId > 0 {
+		query.Attr("domainId", domainId)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteLogsBeforeDay 删除某日期以前的日志
+func (this *DNSChangeLogDAO) DeleteLogsBeforeDay(tx *dbs.Tx, day string) error {
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
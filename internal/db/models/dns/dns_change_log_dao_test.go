@@ -0,0 +1,31 @@
+package dns_test
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestDNSChangeLogDAO_CreateLog(t *testing.T) {
+	dbs.NotifyReady()
+
+	var tx *dbs.Tx
+	var record = &dnstypes.Record{
+		Name:  "cdn",
+		Type:  dnstypes.RecordTypeA,
+		Value: "192.168.1.100",
+	}
+	err := dns.SharedDNSChangeLogDAO.CreateLog(tx, dns.DNSChangeSourceTask, 1, 2, 0, dns.DNSChangeActionAdd, nil, record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dns.SharedDNSChangeLogDAO.CreateLog(tx, dns.DNSChangeSourceDrift, 1, 2, 0, dns.DNSChangeActionDelete, record, nil, errors.New("test error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ok")
+}
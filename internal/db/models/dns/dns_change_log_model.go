@@ -0,0 +1,59 @@
+package dns
+
+import "github.com/iwind/TeaGo/dbs"
+
+const (
+	DNSChangeLogField_Id         dbs.FieldName = "id"         // ID
+	DNSChangeLogField_ClusterId  dbs.FieldName = "clusterId"  // 集群ID
+	DNSChangeLogField_DomainId   dbs.FieldName = "domainId"   // 域名ID
+	DNSChangeLogField_TaskId     dbs.FieldName = "taskId"     // 任务ID
+	DNSChangeLogField_Source     dbs.FieldName = "source"     // 来源
+	DNSChangeLogField_Action     dbs.FieldName = "action"     // 动作
+	DNSChangeLogField_RecordName dbs.FieldName = "recordName" // 记录名
+	DNSChangeLogField_RecordType dbs.FieldName = "recordType" // 记录类型
+	DNSChangeLogField_OldRecord  dbs.FieldName = "oldRecord"  // 修改前的记录
+	DNSChangeLogField_NewRecord  dbs.FieldName = "newRecord"  // 修改后的记录
+	DNSChangeLogField_IsOk       dbs.FieldName = "isOk"       // 是否成功
+	DNSChangeLogField_Error      dbs.FieldName = "error"      // 错误信息
+	DNSChangeLogField_CreatedAt  dbs.FieldName = "createdAt"  // 创建时间
+	DNSChangeLogField_Day        dbs.FieldName = "day"        // 日期YYYYMMDD
+)
+
+// DNSChangeLog DNS记录变更日志
+type DNSChangeLog struct {
+	Id         uint64   `field:"id"`         // ID
+	ClusterId  uint32   `field:"clusterId"`  // 集群ID
+	DomainId   uint32   `field:"domainId"`   // 域名ID
+	TaskId     uint64   `field:"taskId"`     // 任务ID
+	Source     string   `field:"source"`     // 来源
+	Action     string   `field:"action"`     // 动作
+	RecordName string   `field:"recordName"` // 记录名
+	RecordType string   `field:"recordType"` // 记录类型
+	OldRecord  dbs.JSON `field:"oldRecord"`  // 修改前的记录
+	NewRecord  dbs.JSON `field:"newRecord"`  // 修改后的记录
+	IsOk       bool     `field:"isOk"`       // 是否成功
+	Error      string   `field:"error"`      // 错误信息
+	CreatedAt  uint64   `field:"createdAt"`  // 创建时间
+	Day        string   `field:"day"`        // 日期YYYYMMDD
+}
+
+type DNSChangeLogOperator struct {
+	Id         any // ID
+	ClusterId  any // 集群ID
+	DomainId   any // 域名ID
+	TaskId     any // 任务ID
+	Source     any // 来源
+	Action     any // 动作
+	RecordName any // 记录名
+	RecordType any // 记录类型
+	OldRecord  any // 修改前的记录
+	NewRecord  any // 修改后的记录
+	IsOk       any // 是否成功
+	Error      any // 错误信息
+	CreatedAt  any // 创建时间
+	Day        any // 日期YYYYMMDD
+}
+
+func NewDNSChangeLogOperator() *DNSChangeLogOperator {
+	return &DNSChangeLogOperator{}
+}
@@ -1,7 +1,10 @@
 package dns
 
 import (
+	"encoding/json"
+	"fmt"
 	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
@@ -63,7 +66,12 @@ func (this *DNSProviderDAO) FindEnabledDNSProvider(tx *dbs.Tx, id int64) (*DNSPr
 	if result == nil {
 		return nil, err
 	}
-	return result.(*DNSProvider), err
+	var provider = result.(*DNSProvider)
+	err = this.decodeProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
 }
 
 // CreateDNSProvider 创建服务商
@@ -73,20 +81,37 @@ func (this *DNSProviderDAO) CreateDNSProvider(tx *dbs.Tx, adminId int64, userId
 	op.UserId = userId
 	op.Type = providerType
 	op.Name = name
-	if len(apiParamsJSON) > 0 {
-		op.ApiParams = apiParamsJSON
-	}
 
 	if minTTL >= 0 {
 		op.MinTTL = minTTL
 	}
 
 	op.State = DNSProviderStateEnabled
-	err := this.Save(tx, op)
+
+	var providerId int64
+	err := dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err := this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		providerId = types.Int64(op.Id)
+
+		if len(apiParamsJSON) == 0 {
+			return nil
+		}
+		encodedParamsJSON, err := this.encodeAPIParams(providerId, apiParamsJSON)
+		if err != nil {
+			return err
+		}
+		return this.Query(tx).
+			Pk(providerId).
+			Set("apiParams", encodedParamsJSON).
+			UpdateQuickly()
+	})
 	if err != nil {
 		return 0, err
 	}
-	return types.Int64(op.Id), nil
+	return providerId, nil
 }
 
 // UpdateDNSProvider 修改服务商
@@ -101,7 +126,11 @@ func (this *DNSProviderDAO) UpdateDNSProvider(tx *dbs.Tx, dnsProviderId int64, n
 
 	// 如果留空则表示不修改
 	if len(apiParamsJSON) > 0 {
-		op.ApiParams = apiParamsJSON
+		encodedParamsJSON, err := this.encodeAPIParams(dnsProviderId, apiParamsJSON)
+		if err != nil {
+			return err
+		}
+		op.ApiParams = encodedParamsJSON
 	}
 
 	if minTTL >= 0 {
@@ -157,6 +186,10 @@ func (this *DNSProviderDAO) ListEnabledDNSProviders(tx *dbs.Tx, adminId int64, u
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeProviders(result)
 	return
 }
 
@@ -167,6 +200,10 @@ func (this *DNSProviderDAO) FindAllEnabledDNSProviders(tx *dbs.Tx, adminId int64
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeProviders(result)
 	return
 }
 
@@ -178,6 +215,10 @@ func (this *DNSProviderDAO) FindAllEnabledDNSProvidersWithType(tx *dbs.Tx, provi
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeProviders(result)
 	return
 }
 
@@ -189,3 +230,117 @@ func (this *DNSProviderDAO) UpdateProviderDataUpdatedTime(tx *dbs.Tx, providerId
 		Update()
 	return err
 }
+
+// UpdateDNSProviderGuardConfig 修改接口调用保护设置
+func (this *DNSProviderDAO) UpdateDNSProviderGuardConfig(tx *dbs.Tx, providerId int64, guardConfigJSON []byte) error {
+	if providerId <= 0 {
+		return errors.New("invalid providerId")
+	}
+
+	if len(guardConfigJSON) == 0 {
+		guardConfigJSON = []byte("null")
+	}
+
+	return this.Query(tx).
+		Pk(providerId).
+		Set("guardConfig", guardConfigJSON).
+		UpdateQuickly()
+}
+
+// RewrapAPIParams 使用当前主密钥重新加密所有服务商的API参数
+func (this *DNSProviderDAO) RewrapAPIParams(tx *dbs.Tx) (count int64, err error) {
+	var lastId int64
+	for {
+		var providers []*DNSProvider
+		_, err = this.Query(tx).
+			Gt("id", lastId).
+			Where("apiParams IS NOT NULL").
+			Result("id", "apiParams").
+			AscPk().
+			Limit(100).
+			Slice(&providers).
+			FindAll()
+		if err != nil || len(providers) == 0 {
+			return
+		}
+
+		for _, provider := range providers {
+			lastId = int64(provider.Id)
+
+			var data = []byte(provider.ApiParams)
+			var s string
+			if json.Unmarshal(provider.ApiParams, &s) == nil && encrypt.IsEnvelope([]byte(s)) {
+				data = []byte(s)
+			} else if len(data) == 0 || string(data) == "null" {
+				continue
+			}
+
+			newData, changed, rewrapErr := encrypt.SharedKeyring().Rewrap(data, this.apiParamsAAD(int64(provider.Id)))
+			if rewrapErr != nil {
+				return count, fmt.Errorf("rewrap dns provider '%d' failed: %w", provider.Id, rewrapErr)
+			}
+			if !changed {
+				continue
+			}
+			newDataJSON, err := json.Marshal(string(newData))
+			if err != nil {
+				return count, err
+			}
+			err = this.Query(tx).
+				Pk(provider.Id).
+				Set("apiParams", newDataJSON).
+				UpdateQuickly()
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 加密API参数
+// 加密后的数据以JSON字符串形式保存
+func (this *DNSProviderDAO) encodeAPIParams(providerId int64, apiParamsJSON []byte) ([]byte, error) {
+	data, err := encrypt.EncryptSecret(apiParamsJSON, this.apiParamsAAD(providerId))
+	if err != nil {
+		return nil, err
+	}
+	if !encrypt.IsEnvelope(data) {
+		return apiParamsJSON, nil
+	}
+	return json.Marshal(string(data))
+}
+
+// API参数加密时使用的附加认证数据
+func (this *DNSProviderDAO) apiParamsAAD(providerId int64) []byte {
+	return encrypt.AAD(this.Table, "apiParams", providerId)
+}
+
+// 解密服务商API参数
+func (this *DNSProviderDAO) decodeProvider(provider *DNSProvider) error {
+	if provider == nil || len(provider.ApiParams) == 0 || provider.ApiParams[0] != '"' {
+		return nil
+	}
+	var s string
+	err := json.Unmarshal(provider.ApiParams, &s)
+	if err != nil || !encrypt.IsEnvelope([]byte(s)) {
+		return nil
+	}
+	data, err := encrypt.DecryptSecret([]byte(s), this.apiParamsAAD(int64(provider.Id)))
+	if err != nil {
+		return fmt.Errorf("decrypt api params of dns provider '%d' failed: %w", provider.Id, err)
+	}
+	provider.ApiParams = data
+	return nil
+}
+
+// 解密一组服务商API参数
+func (this *DNSProviderDAO) decodeProviders(providers []*DNSProvider) error {
+	for _, provider := range providers {
+		err := this.decodeProvider(provider)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ const (
 	DNSProviderField_State         dbs.FieldName = "state"         // 状态
 	DNSProviderField_DataUpdatedAt dbs.FieldName = "dataUpdatedAt" // 数据同步时间
 	DNSProviderField_MinTTL        dbs.FieldName = "minTTL"        // 最小TTL
+	DNSProviderField_GuardConfig   dbs.FieldName = "guardConfig"   // 接口调用保护设置
 )
 
 // DNSProvider DNS服务商
@@ -27,6 +28,7 @@ type DNSProvider struct {
 	State         uint8    `field:"state"`         // 状态
 	DataUpdatedAt uint64   `field:"dataUpdatedAt"` // 数据同步时间
 	MinTTL        uint32   `field:"minTTL"`        // 最小TTL
+	GuardConfig   dbs.JSON `field:"guardConfig"`   // 接口调用保护设置
 }
 
 type DNSProviderOperator struct {
@@ -40,6 +42,7 @@ type DNSProviderOperator struct {
 	State         any // 状态
 	DataUpdatedAt any // 数据同步时间
 	MinTTL        any // 最小TTL
+	GuardConfig   any // 接口调用保护设置
 }
 
 func NewDNSProviderOperator() *DNSProviderOperator {
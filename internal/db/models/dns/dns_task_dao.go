@@ -20,6 +20,15 @@ const (
 	DNSTaskTypeDomainChange        DNSTaskType = "domainChange"
 )
 
+type DNSTaskVerifyStatus = string
+
+const (
+	DNSTaskVerifyStatusNone      DNSTaskVerifyStatus = ""          // 未检查
+	DNSTaskVerifyStatusVerifying DNSTaskVerifyStatus = "verifying" // 检查中
+	DNSTaskVerifyStatusVerified  DNSTaskVerifyStatus = "verified"  // 已在权威服务器上生效
+	DNSTaskVerifyStatusStale     DNSTaskVerifyStatus = "stale"     // 超时仍未生效
+)
+
 var DNSTasksNotifier = make(chan bool, 2)
 
 type DNSTaskDAO dbs.DAO
@@ -61,12 +70,13 @@ func (this *DNSTaskDAO) CreateDNSTask(tx *dbs.Tx, clusterId int64, serverId int6
 		"error":      "",
 		"version":    time.Now().UnixNano(),
 	}, maps.Map{
-		"updatedAt":  time.Now().Unix(),
-		"isDone":     false,
-		"isOk":       false,
-		"error":      "",
-		"version":    time.Now().UnixNano(),
-		"countFails": 0,
+		"updatedAt":    time.Now().Unix(),
+		"isDone":       false,
+		"isOk":         false,
+		"error":        "",
+		"version":      time.Now().UnixNano(),
+		"countFails":   0,
+		"verifyStatus": DNSTaskVerifyStatusNone,
 	})
 	if err != nil {
 		return err
@@ -235,3 +245,50 @@ func (this *DNSTaskDAO) DeleteDNSTasksWithClusterId(tx *dbs.Tx, clusterId int64)
 		Attr("clusterId", clusterId).
 		DeleteQuickly()
 }
+
+// UpdateDNSTaskVerifying 设置任务正在检查生效状态
+func (this *DNSTaskDAO) UpdateDNSTaskVerifying(tx *dbs.Tx, taskId int64) error {
+	if taskId <= 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Pk(taskId).
+		Set("verifyStatus", DNSTaskVerifyStatusVerifying).
+		UpdateQuickly()
+}
+
+// UpdateDNSTaskVerifyResult 设置任务生效检查结果
+// 如果任务版本已经发生变化，则忽略此次结果
+func (this *DNSTaskDAO) UpdateDNSTaskVerifyResult(tx *dbs.Tx, taskId int64, taskVersion int64, status DNSTaskVerifyStatus, resultJSON []byte) error {
+	if taskId <= 0 {
+		return nil
+	}
+
+	var query = this.Query(tx).
+		Pk(taskId)
+	if taskVersion > 0 {
+		query.Attr("version", taskVersion)
+	}
+	if len(resultJSON) == 0 {
+		resultJSON = []byte("null")
+	}
+	return query.
+		Set("verifyStatus", status).
+		Set("verifyResult", resultJSON).
+		Set("verifiedAt", time.Now().Unix()).
+		UpdateQuickly()
+}
+
+// FindAllStaleTasks 查找超时仍未生效的任务
+func (this *DNSTaskDAO) FindAllStaleTasks(tx *dbs.Tx, clusterId int64) (result []*DNSTask, err error) {
+	var query = this.Query(tx)
+	if clusterId > 0 {
+		query.Attr("clusterId", clusterId)
+	}
+	_, err = query.
+		Attr("verifyStatus", DNSTaskVerifyStatusStale).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
@@ -0,0 +1,479 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnsverify"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/iputils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/lists"
+	"net"
+)
+
+type DNSChangeTarget = string
+
+const (
+	DNSChangeTargetNode   DNSChangeTarget = "node"   // 节点A/AAAA记录
+	DNSChangeTargetServer DNSChangeTarget = "server" // 服务CNAME记录
+	DNSChangeTargetCNAME  DNSChangeTarget = "cname"  // 集群自动设置的CNAME记录
+)
+
+// DNSChange 单个记录变更
+type DNSChange struct {
+	Action    dns.DNSChangeAction `json:"action"`
+	Target    DNSChangeTarget     `json:"target"`
+	TargetId  int64               `json:"targetId"`
+	Record    *dnstypes.Record    `json:"record"`    // 新记录，删除时为nil
+	OldRecord *dnstypes.Record    `json:"oldRecord"` // 老记录，添加时为nil
+}
+
+// ClusterDNSPlan 集群DNS变更计划
+type ClusterDNSPlan struct {
+	ClusterId      int64        `json:"clusterId"`
+	DomainId       int64        `json:"domainId"`
+	Domain         string       `json:"domain"`
+	ClusterDNSName string       `json:"clusterDNSName"`
+	Changes        []*DNSChange `json:"changes"`
+	Drifts         []*DNSChange `json:"drifts"` // 在系统之外被修改、但没有开启自动修正的记录，只报告不执行
+}
+
+// IsEmpty 判断是否没有任何变更
+func (this *ClusterDNSPlan) IsEmpty() bool {
+	return len(this.Changes) == 0
+}
+
+// Count 计算某个动作的变更数量
+func (this *ClusterDNSPlan) Count(action dns.DNSChangeAction) int {
+	var count = 0
+	for _, change := range this.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (this *ClusterDNSPlan) add(target DNSChangeTarget, targetId int64, record *dnstypes.Record) {
+	this.Changes = append(this.Changes, &DNSChange{
+		Action:   dns.DNSChangeActionAdd,
+		Target:   target,
+		TargetId: targetId,
+		Record:   record,
+	})
+}
+
+func (this *ClusterDNSPlan) update(target DNSChangeTarget, targetId int64, oldRecord *dnstypes.Record, record *dnstypes.Record) {
+	this.Changes = append(this.Changes, &DNSChange{
+		Action:    dns.DNSChangeActionUpdate,
+		Target:    target,
+		TargetId:  targetId,
+		Record:    record,
+		OldRecord: oldRecord,
+	})
+}
+
+func (this *ClusterDNSPlan) drift(target DNSChangeTarget, targetId int64, oldRecord *dnstypes.Record, record *dnstypes.Record) {
+	this.Drifts = append(this.Drifts, &DNSChange{
+		Action:    dns.DNSChangeActionUpdate,
+		Target:    target,
+		TargetId:  targetId,
+		Record:    record,
+		OldRecord: oldRecord,
+	})
+}
+
+func (this *ClusterDNSPlan) delete(target DNSChangeTarget, oldRecord *dnstypes.Record) {
+	this.Changes = append(this.Changes, &DNSChange{
+		Action:    dns.DNSChangeActionDelete,
+		Target:    target,
+		OldRecord: oldRecord,
+	})
+}
+
+// PlanClusterDNS 对比集群期望的记录和服务商上的实际记录，计算需要执行的变更
+// 如果集群没有设置DNS，则返回的plan为nil
+func PlanClusterDNS(tx *dbs.Tx, clusterId int64, nodesOnly bool) (plan *ClusterDNSPlan, manager dnsclients.ProviderInterface, err error) {
+	clusterDNS, err := models.SharedNodeClusterDAO.FindClusterDNSInfo(tx, clusterId, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if clusterDNS == nil || len(clusterDNS.DnsName) == 0 || clusterDNS.DnsDomainId <= 0 {
+		return nil, nil, nil
+	}
+
+	dnsConfig, err := clusterDNS.DecodeDNSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var domainId = int64(clusterDNS.DnsDomainId)
+	dnsDomain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, domainId, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dnsDomain == nil || dnsDomain.ProviderId <= 0 {
+		return nil, nil, nil
+	}
+	provider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, int64(dnsDomain.ProviderId))
+	if err != nil {
+		return nil, nil, err
+	}
+	if provider == nil {
+		return nil, nil, nil
+	}
+	if dnsclients.FindProvider(provider.Type, int64(provider.Id)) == nil {
+		remotelogs.Error("DNS_PLAN", "unsupported dns provider type '"+provider.Type+"'")
+		return nil, nil, nil
+	}
+	manager, err = FindDomainProvider(tx, dnsDomain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var domain = dnsDomain.Name
+	var clusterDNSName = clusterDNS.DnsName
+	var clusterDomain = clusterDNSName + "." + domain
+
+	plan = &ClusterDNSPlan{
+		ClusterId:      clusterId,
+		DomainId:       domainId,
+		Domain:         domain,
+		ClusterDNSName: clusterDNSName,
+	}
+
+	var ttl int32 = 0
+	if dnsConfig != nil {
+		ttl = dnsConfig.TTL
+	}
+
+	// 以前的节点记录
+	records, err := manager.GetRecords(domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	var oldRecordsMap = map[string]*dnstypes.Record{}      // route@value => record
+	var oldCnameRecordsMap = map[string]*dnstypes.Record{} // cname => record
+	for _, record := range records {
+		if (record.Type == dnstypes.RecordTypeA || record.Type == dnstypes.RecordTypeAAAA) && record.Name == clusterDNSName {
+			var key = record.Route + "@" + record.Value
+			oldRecordsMap[key] = record
+		}
+
+		if record.Type == dnstypes.RecordTypeCNAME {
+			oldCnameRecordsMap[record.Name] = record
+		}
+	}
+
+	// 当前的节点记录
+	var newRecordKeys = []string{}
+	nodes, err := models.SharedNodeDAO.FindAllEnabledNodesDNSWithClusterId(tx, clusterId, true, dnsConfig != nil && dnsConfig.IncludingLnNodes, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var regionResolver = newRegionRouteResolver(tx, dnsDomain)
+	var nodeItems = []*nodeDNSItem{}
+	for _, node := range nodes {
+		shouldSkip, shouldOverwrite, ipAddressesStrings, err := models.SharedNodeDAO.CheckNodeIPAddresses(tx, node)
+		if err != nil {
+			return nil, nil, err
+		}
+		if shouldSkip {
+			continue
+		}
+
+		// 线路：节点设置 > 区域设置 > 默认线路
+		routes, err := node.DNSRouteCodesForDomainId(domainId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(routes) == 0 {
+			routes, err = regionResolver.Routes(int64(node.RegionId))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(routes) == 0 {
+			routes = []string{manager.DefaultRoute()}
+		}
+
+		// 所有的IP记录
+		if !shouldOverwrite {
+			ipAddresses, err := models.SharedNodeIPAddressDAO.FindAllEnabledAddressesWithNode(tx, int64(node.Id), nodeconfigs.NodeRoleNode)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(ipAddresses) == 0 {
+				continue
+			}
+			for _, ipAddress := range ipAddresses {
+				// 检查专属节点
+				if !ipAddress.IsValidInCluster(clusterId) {
+					continue
+				}
+
+				var ip = ipAddress.DNSIP()
+				if len(ip) == 0 || !ipAddress.CanAccess || !ipAddress.IsUp || !ipAddress.IsOn {
+					continue
+				}
+				if net.ParseIP(ip) == nil {
+					continue
+				}
+				ipAddressesStrings = append(ipAddressesStrings, ip)
+			}
+		}
+
+		if len(ipAddressesStrings) == 0 {
+			continue
+		}
+
+		nodeItems = append(nodeItems, &nodeDNSItem{
+			nodeId: int64(node.Id),
+			ips:    ipAddressesStrings,
+			routes: routes,
+			weight: node.DNSWeight(),
+		})
+	}
+
+	// 权重：服务商支持时直接设置记录权重，否则在同一线路的节点之间按权重分配解析的IP数量
+	// 所有节点权重相同时不设置权重，如果以前设置过，则统一恢复为1
+	var supportsWeight = dnsclients.SupportsWeight(manager)
+	_, isUniformWeight := nodeWeights(nodeItems)
+	var routeIPCountsMap = map[string]map[int64]int{} // route => { nodeId => count }
+	if !supportsWeight && !isUniformWeight {
+		var routeItemsMap = map[string][]*nodeDNSItem{}
+		for _, item := range nodeItems {
+			for _, route := range item.routes {
+				routeItemsMap[route] = append(routeItemsMap[route], item)
+			}
+		}
+		for route, routeItems := range routeItemsMap {
+			routeIPCountsMap[route] = weightedIPCounts(routeItems)
+		}
+	}
+	var hasOldWeights = false
+	for _, record := range oldRecordsMap {
+		if record.Weight > 0 && record.Weight != 1 {
+			hasOldWeights = true
+			break
+		}
+	}
+
+	var addingNodeRecordKeysMap = map[string]bool{} // clusterDnsName_type_ip_route
+	for _, item := range nodeItems {
+		var weight int32 = 0
+		if supportsWeight {
+			if !isUniformWeight {
+				weight = item.weight
+			} else if hasOldWeights {
+				weight = 1
+			}
+		}
+
+		for _, route := range item.routes {
+			var ips = item.ips
+			ipCounts, ok := routeIPCountsMap[route]
+			if ok {
+				ips = ips[:ipCounts[item.nodeId]]
+			}
+
+			for _, ip := range ips {
+				var key = route + "@" + ip
+				oldRecord, ok := oldRecordsMap[key]
+				if ok {
+					newRecordKeys = append(newRecordKeys, key)
+
+					// 修正权重
+					if weight > 0 && oldRecord.Weight != weight {
+						var newRecord = oldRecord.Clone()
+						newRecord.Weight = weight
+						plan.update(DNSChangeTargetNode, item.nodeId, oldRecord, newRecord)
+					}
+					continue
+				}
+
+				var recordType = dnstypes.RecordTypeA
+				if iputils.IsIPv6(ip) {
+					recordType = dnstypes.RecordTypeAAAA
+				}
+
+				// 避免添加重复的记录
+				var fullKey = clusterDNSName + "_" + recordType + "_" + ip + "_" + route
+				if addingNodeRecordKeysMap[fullKey] {
+					continue
+				}
+				addingNodeRecordKeysMap[fullKey] = true
+
+				plan.add(DNSChangeTargetNode, item.nodeId, &dnstypes.Record{
+					Id:     "",
+					Name:   clusterDNSName,
+					Type:   recordType,
+					Value:  ip,
+					Route:  route,
+					TTL:    ttl,
+					Weight: weight,
+				})
+				newRecordKeys = append(newRecordKeys, key)
+			}
+		}
+	}
+
+	// 多余的节点解析记录
+	for key, record := range oldRecordsMap {
+		if !lists.ContainsString(newRecordKeys, key) {
+			plan.delete(DNSChangeTargetNode, record)
+		}
+	}
+
+	if nodesOnly {
+		return plan, manager, nil
+	}
+
+	// 服务域名
+	servers, err := models.SharedServerDAO.FindAllServersDNSWithClusterId(tx, clusterId)
+	if err != nil {
+		return nil, nil, err
+	}
+	var serverRecords = []*dnstypes.Record{}             // 之所以用数组再存一遍，是因为dnsName可能会重复
+	var serverRecordsMap = map[string]*dnstypes.Record{} // dnsName => *Record
+	for _, record := range records {
+		if record.Type == dnstypes.RecordTypeCNAME && record.Value == clusterDomain+"." {
+			serverRecords = append(serverRecords, record)
+			serverRecordsMap[record.Name] = record
+		}
+	}
+
+	// 添加或修正CNAME记录
+	var addCNAME = func(target DNSChangeTarget, targetId int64, dnsName string) {
+		_, ok := serverRecordsMap[dnsName]
+		if ok {
+			return
+		}
+
+		var newRecord = &dnstypes.Record{
+			Id:    "",
+			Name:  dnsName,
+			Type:  dnstypes.RecordTypeCNAME,
+			Value: clusterDomain + ".",
+			Route: "", // 注意这里为空，需要在执行过程中获取默认值
+			TTL:   ttl,
+		}
+
+		// 同名的CNAME记录指向了别处，通常是在系统之外被修改了，只有开启自动修正时才覆盖
+		oldRecord, ok := oldCnameRecordsMap[dnsName]
+		if ok {
+			newRecord.Route = oldRecord.Route
+			if clusterDNS.DnsAutoFixDrift {
+				plan.update(target, targetId, oldRecord, newRecord)
+			} else {
+				plan.drift(target, targetId, oldRecord, newRecord)
+			}
+			return
+		}
+
+		plan.add(target, targetId, newRecord)
+	}
+
+	// 新增的域名
+	var serverDNSNames = []string{}
+	for _, server := range servers {
+		var dnsName = server.DnsName
+		if len(dnsName) == 0 {
+			continue
+		}
+		serverDNSNames = append(serverDNSNames, dnsName)
+		addCNAME(DNSChangeTargetServer, int64(server.Id), dnsName)
+	}
+
+	// 自动设置的CNAME
+	var cnameRecords = []string{}
+	if dnsConfig != nil {
+		cnameRecords = dnsConfig.CNAMERecords
+	}
+	for _, cnameRecord := range cnameRecords {
+		// 如果记录已存在，则跳过
+		if lists.ContainsString(serverDNSNames, cnameRecord) {
+			continue
+		}
+
+		serverDNSNames = append(serverDNSNames, cnameRecord)
+		addCNAME(DNSChangeTargetCNAME, 0, cnameRecord)
+	}
+
+	// 多余的域名
+	for _, record := range serverRecords {
+		if !lists.ContainsString(serverDNSNames, record.Name) {
+			plan.delete(DNSChangeTargetServer, record)
+		}
+	}
+
+	return plan, manager, nil
+}
+
+// ApplyClusterDNSPlan 执行变更计划，每个变更都会记录到变更日志中
+// 遇到错误时立即停止，isChanged表示是否已经有变更成功执行
+func ApplyClusterDNSPlan(tx *dbs.Tx, manager dnsclients.ProviderInterface, plan *ClusterDNSPlan, source dns.DNSChangeSource, taskId int64) (isChanged bool, err error) {
+	if plan == nil || manager == nil {
+		return false, nil
+	}
+
+	for _, change := range plan.Changes {
+		var changeErr error
+		switch change.Action {
+		case dns.DNSChangeActionAdd:
+			changeErr = manager.AddRecord(plan.Domain, change.Record)
+		case dns.DNSChangeActionUpdate:
+			changeErr = manager.UpdateRecord(plan.Domain, change.OldRecord, change.Record)
+		case dns.DNSChangeActionDelete:
+			changeErr = manager.DeleteRecord(plan.Domain, change.OldRecord)
+		default:
+			continue
+		}
+
+		logErr := dns.SharedDNSChangeLogDAO.CreateLog(tx, source, plan.ClusterId, plan.DomainId, taskId, change.Action, change.OldRecord, change.Record, changeErr)
+		if logErr != nil {
+			remotelogs.Error("DNS_PLAN", "create dns change log failed: "+logErr.Error())
+		}
+
+		if changeErr != nil {
+			return isChanged, changeErr
+		}
+		isChanged = true
+	}
+
+	return isChanged, nil
+}
+
+// BuildPlanExpectations 根据变更计划生成期望的解析结果，用于检查记录是否已经生效
+// 只有默认线路上的记录才能从外部检查，其他线路的变更会被忽略
+func BuildPlanExpectations(plan *ClusterDNSPlan, defaultRoute string) []*dnsverify.Expectation {
+	var set = dnsverify.NewExpectationSet()
+	if plan == nil {
+		return set.All()
+	}
+
+	var isDefaultRoute = func(record *dnstypes.Record) bool {
+		return len(record.Route) == 0 || record.Route == defaultRoute
+	}
+	var fullName = func(record *dnstypes.Record) string {
+		if len(record.Name) == 0 || record.Name == "@" {
+			return plan.Domain
+		}
+		return record.Name + "." + plan.Domain
+	}
+
+	for _, change := range plan.Changes {
+		if change.OldRecord != nil && isDefaultRoute(change.OldRecord) && (change.Action == dns.DNSChangeActionDelete || change.Action == dns.DNSChangeActionUpdate) {
+			set.AddAbsent(fullName(change.OldRecord), change.OldRecord.Type, change.OldRecord.Value)
+		}
+		if change.Record != nil && isDefaultRoute(change.Record) && (change.Action == dns.DNSChangeActionAdd || change.Action == dns.DNSChangeActionUpdate) {
+			set.AddPresent(fullName(change.Record), change.Record.Type, change.Record.Value)
+		}
+	}
+	return set.All()
+}
@@ -3,15 +3,19 @@
 package dnsutils
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnsverify"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/iwind/TeaGo/dbs"
+	"strings"
 )
 
 // CheckClusterDNS 检查集群的DNS问题
@@ -68,7 +72,7 @@ func CheckClusterDNS(tx *dbs.Tx, cluster *models.NodeCluster, checkNodeIssues bo
 		})
 		return
 	}
-	var dnsProvider = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	var dnsProvider = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if dnsProvider == nil {
 		issues = append(issues, &pb.DNSIssue{
 			Target:      cluster.Name,
@@ -104,6 +108,48 @@ func CheckClusterDNS(tx *dbs.Tx, cluster *models.NodeCluster, checkNodeIssues bo
 
 	// TODO 检查域名是否已解析
 
+	// 检查尚未在权威服务器上生效的记录
+	staleTasks, err := dns.SharedDNSTaskDAO.FindAllStaleTasks(tx, clusterId)
+	if err != nil {
+		return nil, err
+	}
+	var staleKeys = map[string]bool{} // name|type
+	for _, task := range staleTasks {
+		if len(task.VerifyResult) == 0 {
+			continue
+		}
+		var lagging = []*dnsverify.Result{}
+		err = json.Unmarshal(task.VerifyResult, &lagging)
+		if err != nil {
+			continue
+		}
+		for _, result := range lagging {
+			if result.Expectation == nil {
+				continue
+			}
+			var key = result.Expectation.Name + "|" + result.Expectation.Type
+			if staleKeys[key] {
+				continue
+			}
+			staleKeys[key] = true
+
+			issues = append(issues, &pb.DNSIssue{
+				Target:      cluster.Name,
+				TargetId:    clusterId,
+				Type:        "cluster",
+				Description: "记录\"" + result.Expectation.Name + "\"（" + result.Expectation.Type + "）在权威服务器上尚未生效",
+				Params: map[string]string{
+					"recordName": result.Expectation.Name,
+					"recordType": result.Expectation.Type,
+					"server":     result.Server,
+					"answers":    strings.Join(result.Answers, ", "),
+					"error":      result.Error,
+				},
+				MustFix: false,
+			})
+		}
+	}
+
 	// 检查节点
 	if checkNodeIssues {
 		nodes, err := models.SharedNodeDAO.FindAllEnabledNodesDNSWithClusterId(tx, clusterId, true, clusterDNSConfig != nil && clusterDNSConfig.IncludingLnNodes, true)
@@ -205,28 +251,62 @@ func CheckClusterDNS(tx *dbs.Tx, cluster *models.NodeCluster, checkNodeIssues bo
 
 // FindDefaultDomainRoute 获取域名默认的线路
 func FindDefaultDomainRoute(tx *dbs.Tx, domain *dns.DNSDomain) (string, error) {
+	dnsProvider, err := FindDomainProvider(tx, domain)
+	if err != nil {
+		return "", err
+	}
+	return dnsProvider.DefaultRoute(), nil
+}
+
+// FindDomainProvider 获取域名对应的已认证的服务商实例
+func FindDomainProvider(tx *dbs.Tx, domain *dns.DNSDomain) (dnsclients.ProviderInterface, error) {
 	if domain == nil {
-		return "", errors.New("can not find domain")
+		return nil, errors.New("can not find domain")
 	}
 
 	provider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, int64(domain.ProviderId))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if provider == nil {
-		return "", errors.New("provider not found")
+		return nil, errors.New("provider not found")
 	}
 	paramsMap, err := provider.DecodeAPIParams()
 	if err != nil {
-		return "", fmt.Errorf("decode provider params failed: %w", err)
+		return nil, fmt.Errorf("decode provider params failed: %w", err)
 	}
-	var dnsProvider = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	var dnsProvider = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if dnsProvider == nil {
-		return "", errors.New("not supported provider type '" + provider.Type + "'")
+		return nil, errors.New("not supported provider type '" + provider.Type + "'")
 	}
 	err = dnsProvider.Auth(paramsMap)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return dnsProvider.DefaultRoute(), nil
+	dnsProvider.SetMinTTL(int32(provider.MinTTL))
+	return dnsProvider, nil
+}
+
+// ConvertRecordToPB 转换解析记录为RPC使用的格式
+func ConvertRecordToPB(record *dnstypes.Record) (*pb.DNSRecord, error) {
+	var flagsJSON []byte
+	if len(record.Flags) > 0 {
+		var err error
+		flagsJSON, err = json.Marshal(record.Flags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.DNSRecord{
+		Id:        record.Id,
+		Name:      record.Name,
+		Type:      record.Type,
+		Value:     record.Value,
+		Route:     record.Route,
+		Ttl:       record.TTL,
+		Priority:  record.Priority,
+		Weight:    record.Weight,
+		FlagsJSON: flagsJSON,
+	}, nil
 }
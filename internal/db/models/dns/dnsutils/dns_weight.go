@@ -0,0 +1,114 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/iwind/TeaGo/dbs"
+	"math"
+)
+
+// 节点的DNS解析信息
+type nodeDNSItem struct {
+	nodeId int64
+	ips    []string
+	routes []string
+	weight int32
+}
+
+// 计算节点的权重设置
+// isUniform 所有节点权重相同，此时不需要设置权重
+func nodeWeights(items []*nodeDNSItem) (maxWeight int32, isUniform bool) {
+	isUniform = true
+	for index, item := range items {
+		if index > 0 && item.weight != maxWeight {
+			isUniform = false
+		}
+		if item.weight > maxWeight {
+			maxWeight = item.weight
+		}
+	}
+	return
+}
+
+// 在服务商不支持权重时，根据权重计算每个节点需要解析的IP数量
+// 按照权重在节点之间分配解析记录的数量，权重较低的节点可能不会被解析，但权重最高的节点至少保留一个IP
+func weightedIPCounts(items []*nodeDNSItem) map[int64]int {
+	// 在每个节点IP数量的限制下，计算每单位权重能分配的记录数
+	var scale float64 = -1
+	for _, item := range items {
+		if len(item.ips) == 0 || item.weight <= 0 {
+			continue
+		}
+		var itemScale = float64(len(item.ips)) / float64(item.weight)
+		if scale < 0 || itemScale < scale {
+			scale = itemScale
+		}
+	}
+
+	var result = map[int64]int{} // nodeId => count
+	for _, item := range items {
+		var count = len(item.ips)
+		if scale > 0 && item.weight > 0 {
+			count = int(math.Round(scale * float64(item.weight)))
+			if count > len(item.ips) {
+				count = len(item.ips)
+			}
+		}
+		result[item.nodeId] = count
+	}
+	return result
+}
+
+// 区域线路查找器
+type regionRouteResolver struct {
+	tx           *dbs.Tx
+	domainId     int64
+	domainRoutes map[string]string  // route name => route code
+	cacheMap     map[int64][]string // regionId => routes
+}
+
+func newRegionRouteResolver(tx *dbs.Tx, dnsDomain *dns.DNSDomain) *regionRouteResolver {
+	var resolver = &regionRouteResolver{
+		tx:           tx,
+		domainId:     int64(dnsDomain.Id),
+		domainRoutes: map[string]string{},
+		cacheMap:     map[int64][]string{},
+	}
+	routes, err := dnsDomain.DecodeRoutes()
+	if err == nil {
+		for _, route := range routes {
+			resolver.domainRoutes[route.Name] = route.Code
+		}
+	}
+	return resolver
+}
+
+// Routes 查找区域对应的线路
+// 优先使用区域中设置的线路，其次使用和区域同名的服务商线路
+func (this *regionRouteResolver) Routes(regionId int64) ([]string, error) {
+	if regionId <= 0 {
+		return nil, nil
+	}
+	routes, ok := this.cacheMap[regionId]
+	if ok {
+		return routes, nil
+	}
+
+	region, err := models.SharedNodeRegionDAO.FindEnabledNodeRegion(this.tx, regionId)
+	if err != nil {
+		return nil, err
+	}
+	if region != nil && region.IsOn {
+		routes = region.DNSRouteCodesForDomainId(this.domainId)
+		if len(routes) == 0 {
+			code, ok := this.domainRoutes[region.Name]
+			if ok {
+				routes = []string{code}
+			}
+		}
+	}
+	this.cacheMap[regionId] = routes
+	return routes, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsutils
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestWeightedIPCounts(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	// 每个节点只有一个IP
+	{
+		var counts = weightedIPCounts([]*nodeDNSItem{
+			{nodeId: 1, ips: []string{"1.1.1.1"}, weight: 100},
+			{nodeId: 2, ips: []string{"1.1.1.2"}, weight: 10},
+			{nodeId: 3, ips: []string{"1.1.1.3"}, weight: 100},
+		})
+		a.IsTrue(counts[1] == 1)
+		a.IsTrue(counts[2] == 0)
+		a.IsTrue(counts[3] == 1)
+	}
+
+	// 多IP节点按权重分配
+	{
+		var counts = weightedIPCounts([]*nodeDNSItem{
+			{nodeId: 1, ips: []string{"1.1.1.1", "1.1.1.2", "1.1.1.3", "1.1.1.4"}, weight: 100},
+			{nodeId: 2, ips: []string{"1.1.2.1", "1.1.2.2", "1.1.2.3", "1.1.2.4"}, weight: 50},
+			{nodeId: 3, ips: []string{"1.1.3.1", "1.1.3.2"}, weight: 25},
+		})
+		a.IsTrue(counts[1] == 4)
+		a.IsTrue(counts[2] == 2)
+		a.IsTrue(counts[3] == 1)
+	}
+
+	// 权重最高的节点至少保留一个IP
+	{
+		var counts = weightedIPCounts([]*nodeDNSItem{
+			{nodeId: 1, ips: []string{"1.1.1.1"}, weight: 1},
+			{nodeId: 2, ips: []string{"1.1.2.1"}, weight: 100},
+		})
+		a.IsTrue(counts[1] == 0)
+		a.IsTrue(counts[2] == 1)
+	}
+}
+
+func TestNodeWeights(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	{
+		maxWeight, isUniform := nodeWeights([]*nodeDNSItem{{weight: 4}, {weight: 4}})
+		a.IsTrue(maxWeight == 4)
+		a.IsTrue(isUniform)
+	}
+	{
+		maxWeight, isUniform := nodeWeights([]*nodeDNSItem{{weight: 2}, {weight: 8}, {weight: 4}})
+		a.IsTrue(maxWeight == 8)
+		a.IsFalse(isUniform)
+	}
+}
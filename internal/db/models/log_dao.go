@@ -38,7 +38,7 @@ func init() {
 }
 
 // CreateLog 创建管理员日志
-func (this *LogDAO) CreateLog(tx *dbs.Tx, adminType string, adminId int64, level string, description string, action string, ip string, langMessageCode langs.MessageCode, langMessageArgs []any) error {
+func (this *LogDAO) CreateLog(tx *dbs.Tx, adminType string, adminId int64, subUserId int64, level string, description string, action string, ip string, langMessageCode langs.MessageCode, langMessageArgs []any) error {
 	var op = NewLogOperator()
 	op.Level = level
 	op.Description = utils.LimitString(description, 1000)
@@ -51,6 +51,7 @@ func (this *LogDAO) CreateLog(tx *dbs.Tx, adminType string, adminId int64, level
 		op.AdminId = adminId
 	case "user":
 		op.UserId = adminId
+		op.SubUserId = subUserId
 	case "provider":
 		op.ProviderId = adminId
 	}
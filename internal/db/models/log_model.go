@@ -9,6 +9,7 @@ const (
 	LogField_CreatedAt       dbs.FieldName = "createdAt"       // 创建时间
 	LogField_Action          dbs.FieldName = "action"          // 动作
 	LogField_UserId          dbs.FieldName = "userId"          // 用户ID
+	LogField_SubUserId       dbs.FieldName = "subUserId"       // 子用户ID
 	LogField_AdminId         dbs.FieldName = "adminId"         // 管理员ID
 	LogField_ProviderId      dbs.FieldName = "providerId"      // 供应商ID
 	LogField_Ip              dbs.FieldName = "ip"              // IP地址
@@ -28,6 +29,7 @@ type Log struct {
 	CreatedAt       uint64   `field:"createdAt"`       // 创建时间
 	Action          string   `field:"action"`          // 动作
 	UserId          uint32   `field:"userId"`          // 用户ID
+	SubUserId       uint32   `field:"subUserId"`       // 子用户ID
 	AdminId         uint32   `field:"adminId"`         // 管理员ID
 	ProviderId      uint32   `field:"providerId"`      // 供应商ID
 	Ip              string   `field:"ip"`              // IP地址
@@ -46,6 +48,7 @@ type LogOperator struct {
 	CreatedAt       any // 创建时间
 	Action          any // 动作
 	UserId          any // 用户ID
+	SubUserId       any // 子用户ID
 	AdminId         any // 管理员ID
 	ProviderId      any // 供应商ID
 	Ip              any // IP地址
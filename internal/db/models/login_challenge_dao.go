@@ -0,0 +1,146 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"time"
+)
+
+const (
+	LoginChallengeTypeWebAuthnRegister = "webAuthnRegister" // WebAuthn注册
+	LoginChallengeTypeWebAuthnLogin    = "webAuthnLogin"    // WebAuthn登录
+	LoginChallengeTypeOIDCLogin        = "oidcLogin"        // OpenID Connect登录
+)
+
+type LoginChallengeDAO dbs.DAO
+
+func NewLoginChallengeDAO() *LoginChallengeDAO {
+	return dbs.NewDAO(&LoginChallengeDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeLoginChallenges",
+			Model:  new(LoginChallenge),
+			PkName: "id",
+		},
+	}).(*LoginChallengeDAO)
+}
+
+var SharedLoginChallengeDAO *LoginChallengeDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedLoginChallengeDAO = NewLoginChallengeDAO()
+	})
+}
+
+// CreateChallenge 创建挑战值
+func (this *LoginChallengeDAO) CreateChallenge(tx *dbs.Tx, adminId int64, userId int64, challengeType string, challenge string, life time.Duration) error {
+	if adminId <= 0 && userId <= 0 {
+		return errors.New("invalid adminId and userId")
+	}
+	return this.createChallenge(tx, adminId, userId, challengeType, challenge, nil, life)
+}
+
+// CreateAnonymousChallenge 创建登录之前使用的挑战值，比如单点登录的state
+// params 用来保存和挑战值相关的参数，使用时会原样返回
+func (this *LoginChallengeDAO) CreateAnonymousChallenge(tx *dbs.Tx, challengeType string, challenge string, params maps.Map, life time.Duration) error {
+	return this.createChallenge(tx, 0, 0, challengeType, challenge, params, life)
+}
+
+// ConsumeAnonymousChallenge 使用登录之前的挑战值，并返回创建时的参数
+// 挑战值不存在或者已过期时返回nil
+func (this *LoginChallengeDAO) ConsumeAnonymousChallenge(tx *dbs.Tx, challengeType string, challenge string) (maps.Map, error) {
+	if len(challenge) == 0 {
+		return nil, nil
+	}
+	one, err := this.Query(tx).
+		Result("id", "params").
+		Attr("adminId", 0).
+		Attr("userId", 0).
+		Attr("type", challengeType).
+		Attr("challenge", challenge).
+		Gte("expiresAt", time.Now().Unix()).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	var loginChallenge = one.(*LoginChallenge)
+
+	// 并发使用时只有一个能删除成功
+	rows, err := this.Query(tx).
+		Pk(loginChallenge.Id).
+		Delete()
+	if err != nil || rows <= 0 {
+		return nil, err
+	}
+
+	var params = maps.Map{}
+	if IsNotNull(loginChallenge.Params) {
+		err = json.Unmarshal(loginChallenge.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+func (this *LoginChallengeDAO) createChallenge(tx *dbs.Tx, adminId int64, userId int64, challengeType string, challenge string, params maps.Map, life time.Duration) error {
+	if len(challenge) == 0 {
+		return errors.New("'challenge' should not be empty")
+	}
+
+	// 顺便清理过期的挑战值
+	err := this.Query(tx).
+		Lt("expiresAt", time.Now().Unix()).
+		DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	var op = NewLoginChallengeOperator()
+	op.AdminId = adminId
+	op.UserId = userId
+	op.Type = challengeType
+	op.Challenge = challenge
+	if params != nil {
+		paramsJSON, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		op.Params = paramsJSON
+	}
+	op.CreatedAt = time.Now().Unix()
+	op.ExpiresAt = time.Now().Add(life).Unix()
+	return this.Save(tx, op)
+}
+
+// ConsumeChallenge 使用挑战值，每个挑战值只能使用一次
+func (this *LoginChallengeDAO) ConsumeChallenge(tx *dbs.Tx, adminId int64, userId int64, challengeType string, challenge string) (bool, error) {
+	if len(challenge) == 0 {
+		return false, nil
+	}
+	challengeId, err := this.Query(tx).
+		ResultPk().
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("type", challengeType).
+		Attr("challenge", challenge).
+		Gte("expiresAt", time.Now().Unix()).
+		FindInt64Col(0)
+	if err != nil || challengeId <= 0 {
+		return false, err
+	}
+
+	// 并发使用时只有一个能删除成功
+	rows, err := this.Query(tx).
+		Pk(challengeId).
+		Delete()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+// LoginChallenge 登录认证挑战值
+type LoginChallenge struct {
+	Id        uint64   `field:"id"`        // ID
+	AdminId   uint64   `field:"adminId"`   // 管理员ID
+	UserId    uint64   `field:"userId"`    // 用户ID
+	Type      string   `field:"type"`      // 类型
+	Challenge string   `field:"challenge"` // 挑战值
+	Params    dbs.JSON `field:"params"`    // 参数
+	CreatedAt uint64   `field:"createdAt"` // 创建时间
+	ExpiresAt uint64   `field:"expiresAt"` // 过期时间
+}
+
+type LoginChallengeOperator struct {
+	Id        any // ID
+	AdminId   any // 管理员ID
+	UserId    any // 用户ID
+	Type      any // 类型
+	Challenge any // 挑战值
+	Params    any // 参数
+	CreatedAt any // 创建时间
+	ExpiresAt any // 过期时间
+}
+
+func NewLoginChallengeOperator() *LoginChallengeOperator {
+	return &LoginChallengeOperator{}
+}
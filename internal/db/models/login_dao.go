@@ -1,7 +1,10 @@
 package models
 
 import (
+	"encoding/json"
+	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/otputils"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
@@ -16,9 +19,16 @@ const (
 type LoginType = string
 
 const (
-	LoginTypeOTP LoginType = "otp"
+	LoginTypeOTP          LoginType = "otp"
+	LoginTypeWebAuthn     LoginType = "webAuthn"     // WebAuthn通行密钥和安全密钥
+	LoginTypeRecoveryCode LoginType = "recoveryCode" // 一次性恢复码
 )
 
+// AllSecondFactorLoginTypes 所有可以作为第二步认证的方式
+func AllSecondFactorLoginTypes() []LoginType {
+	return []LoginType{LoginTypeOTP, LoginTypeWebAuthn, LoginTypeRecoveryCode}
+}
+
 type LoginDAO dbs.DAO
 
 func NewLoginDAO() *LoginDAO {
@@ -179,3 +189,169 @@ func (this *LoginDAO) CheckLoginIsOn(tx *dbs.Tx, adminId int64, userId int64, lo
 
 	return query.Exist()
 }
+
+// FindEnabledSecondFactorTypes 查找管理员或用户已经启用的第二步认证方式
+func (this *LoginDAO) FindEnabledSecondFactorTypes(tx *dbs.Tx, adminId int64, userId int64) ([]LoginType, error) {
+	if adminId <= 0 && userId <= 0 {
+		return nil, nil
+	}
+
+	var query = this.Query(tx).
+		Result("type").
+		Attr("type", AllSecondFactorLoginTypes()).
+		State(LoginStateEnabled).
+		Attr("isOn", true)
+	if adminId > 0 {
+		query.Attr("adminId", adminId)
+	} else {
+		query.Attr("userId", userId)
+	}
+
+	ones, err := query.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var result = []LoginType{}
+	for _, one := range ones {
+		result = append(result, one.(*Login).Type)
+	}
+	return result, nil
+}
+
+// FindTwoFactorStatus 查找已启用的第二步认证方式
+// requireSetup 表示策略要求启用双因素认证，但账号还没有设置（恢复码不能单独作为第二步认证方式）
+func (this *LoginDAO) FindTwoFactorStatus(tx *dbs.Tx, adminId int64, userId int64) (loginTypes []LoginType, requireSetup bool, err error) {
+	loginTypes, err = this.FindEnabledSecondFactorTypes(tx, adminId, userId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	policy, err := SharedSysSettingDAO.ReadTwoFactorPolicy(tx)
+	if err != nil {
+		return nil, false, err
+	}
+	if (adminId > 0 && policy.RequireForAdmins) || (adminId <= 0 && userId > 0 && policy.RequireForUsers) {
+		requireSetup = true
+		for _, loginType := range loginTypes {
+			if loginType != LoginTypeRecoveryCode {
+				requireSetup = false
+				break
+			}
+		}
+	}
+	return
+}
+
+// ResetRecoveryCodes 重新生成恢复码，之前的恢复码全部失效
+// 返回新的恢复码明文，只在生成时显示一次
+func (this *LoginDAO) ResetRecoveryCodes(tx *dbs.Tx, adminId int64, userId int64) ([]string, error) {
+	codes, err := otputils.GenerateRecoveryCodes(otputils.DefaultRecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	var hashes = []string{}
+	for _, code := range codes {
+		hashes = append(hashes, otputils.HashRecoveryCode(code))
+	}
+	err = this.UpdateLogin(tx, adminId, userId, LoginTypeRecoveryCode, maps.Map{
+		"codes": hashes,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CountRecoveryCodes 计算剩余可用的恢复码数量
+func (this *LoginDAO) CountRecoveryCodes(tx *dbs.Tx, adminId int64, userId int64) (int, error) {
+	hashes, err := this.findRecoveryCodeHashes(tx, adminId, userId)
+	if err != nil {
+		return 0, err
+	}
+	return len(hashes), nil
+}
+
+// ConsumeRecoveryCode 使用恢复码，每个恢复码只能使用一次
+// 在事务中锁定恢复码记录，防止同一个恢复码被并发使用
+func (this *LoginDAO) ConsumeRecoveryCode(tx *dbs.Tx, adminId int64, userId int64, code string) (consumed bool, err error) {
+	if len(otputils.NormalizeRecoveryCode(code)) == 0 {
+		return false, nil
+	}
+	if adminId <= 0 && userId <= 0 {
+		return false, nil
+	}
+
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		var query = this.Query(tx).
+			Attr("type", LoginTypeRecoveryCode).
+			State(LoginStateEnabled).
+			Attr("isOn", true).
+			Lock("FOR UPDATE")
+		if adminId > 0 {
+			query.Attr("adminId", adminId)
+		} else {
+			query.Attr("userId", userId)
+		}
+		one, err := query.Find()
+		if err != nil || one == nil {
+			return err
+		}
+		var login = one.(*Login)
+		if IsNull(login.Params) {
+			return nil
+		}
+
+		var params = &struct {
+			Codes []string `json:"codes"`
+		}{}
+		err = json.Unmarshal(login.Params, params)
+		if err != nil {
+			return err
+		}
+
+		var codeHash = otputils.HashRecoveryCode(code)
+		var newHashes = []string{}
+		for _, hash := range params.Codes {
+			if hash == codeHash && !consumed {
+				consumed = true
+				continue
+			}
+			newHashes = append(newHashes, hash)
+		}
+		if !consumed {
+			return nil
+		}
+
+		var op = NewLoginOperator()
+		op.Id = login.Id
+		op.IsOn = len(newHashes) > 0
+		op.Params = maps.Map{
+			"codes": newHashes,
+		}.AsJSON()
+		return this.Save(tx, op)
+	})
+	if err != nil {
+		return false, err
+	}
+	return consumed, nil
+}
+
+// 读取恢复码的哈希值
+func (this *LoginDAO) findRecoveryCodeHashes(tx *dbs.Tx, adminId int64, userId int64) ([]string, error) {
+	login, err := this.FindEnabledLoginWithType(tx, adminId, userId, LoginTypeRecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if login == nil || !login.IsOn || IsNull(login.Params) {
+		return nil, nil
+	}
+
+	var params = &struct {
+		Codes []string `json:"codes"`
+	}{}
+	err = json.Unmarshal(login.Params, params)
+	if err != nil {
+		return nil, err
+	}
+	return params.Codes, nil
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+const (
+	LoginFailureRoleAdmin = "admin"
+	LoginFailureRoleUser  = "user" // 包括平台用户和子用户
+
+	LoginFailureTypePassword     = "password"
+	LoginFailureTypeOTP          = "otp"
+	LoginFailureTypeWebAuthn     = "webAuthn"
+	LoginFailureTypeRecoveryCode = "recoveryCode"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		// 清理过期的失败记录
+		var ticker = time.NewTicker(1 * time.Hour)
+		goman.New(func() {
+			for range ticker.C {
+				err := SharedLoginFailureDAO.Clean(nil)
+				if err != nil {
+					remotelogs.Error("LoginFailureDAO", "clean expired data failed: "+err.Error())
+				}
+			}
+		})
+	})
+}
+
+type LoginFailureDAO dbs.DAO
+
+func NewLoginFailureDAO() *LoginFailureDAO {
+	return dbs.NewDAO(&LoginFailureDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeLoginFailures",
+			Model:  new(LoginFailure),
+			PkName: "id",
+		},
+	}).(*LoginFailureDAO)
+}
+
+var SharedLoginFailureDAO *LoginFailureDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedLoginFailureDAO = NewLoginFailureDAO()
+	})
+}
+
+// CheckLogin 检查是否允许尝试登录
+func (this *LoginFailureDAO) CheckLogin(tx *dbs.Tx, role string, username string, ip string) (*loginutils.Decision, error) {
+	policy, err := SharedSysSettingDAO.ReadLoginFailurePolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.IsOn {
+		return &loginutils.Decision{}, nil
+	}
+
+	stat, err := this.findFailureStat(tx, policy, role, username, ip)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Evaluate(stat, time.Now().Unix()), nil
+}
+
+// RecordFailure 记录登录失败，并返回记录之后的检查结果
+// accountId 为管理员或者用户ID，不存在时为0；subUserId 为子用户ID
+func (this *LoginFailureDAO) RecordFailure(tx *dbs.Tx, role string, username string, ip string, failureType string, accountId int64, subUserId int64) (*loginutils.Decision, error) {
+	policy, err := SharedSysSettingDAO.ReadLoginFailurePolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.IsOn {
+		return &loginutils.Decision{}, nil
+	}
+
+	var op = NewLoginFailureOperator()
+	op.Role = role
+	op.Username = username
+	op.Ip = ip
+	op.Type = failureType
+	op.CreatedAt = time.Now().Unix()
+	err = this.Save(tx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := this.findFailureStat(tx, policy, role, username, ip)
+	if err != nil {
+		return nil, err
+	}
+	var decision = policy.Evaluate(stat, time.Now().Unix())
+
+	// 记录安全事件
+	var description = "登录失败（" + failureType + "）：" + username
+	var level = LevelWarning
+	if decision.IsLocked {
+		description = "多次登录失败，已临时锁定：" + username + "，账号失败" + types.String(stat.AccountFailures) + "次，IP失败" + types.String(stat.IPFailures) + "次"
+		level = LevelError
+	}
+	err = SharedLogDAO.CreateLog(tx, role, accountId, subUserId, level, description, "", ip, "", nil)
+	if err != nil {
+		remotelogs.Error("LoginFailureDAO", "create security log failed: "+err.Error())
+	}
+
+	return decision, nil
+}
+
+// DeleteAccountFailures 删除某个账号的失败记录，用于登录成功后或者管理员解锁
+func (this *LoginFailureDAO) DeleteAccountFailures(tx *dbs.Tx, role string, username string) error {
+	if len(username) == 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Attr("role", role).
+		Attr("username", username).
+		DeleteQuickly()
+}
+
+// DeleteIPFailures 删除某个IP的失败记录
+func (this *LoginFailureDAO) DeleteIPFailures(tx *dbs.Tx, ip string) error {
+	if len(ip) == 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Attr("ip", ip).
+		DeleteQuickly()
+}
+
+// Clean 清理过期的失败记录
+func (this *LoginFailureDAO) Clean(tx *dbs.Tx) error {
+	policy, err := SharedSysSettingDAO.ReadLoginFailurePolicy(tx)
+	if err != nil {
+		return err
+	}
+	var maxAge = policy.Window
+	if policy.LockDuration > maxAge {
+		maxAge = policy.LockDuration
+	}
+	_, err = this.Query(tx).
+		Lt("createdAt", time.Now().Unix()-int64(maxAge)).
+		Delete()
+	return err
+}
+
+// 统计失败记录
+func (this *LoginFailureDAO) findFailureStat(tx *dbs.Tx, policy *loginutils.Policy, role string, username string, ip string) (*loginutils.FailureStat, error) {
+	var stat = &loginutils.FailureStat{}
+	var fromTime = time.Now().Unix() - int64(policy.Window)
+
+	if len(username) > 0 {
+		count, err := this.Query(tx).
+			Attr("role", role).
+			Attr("username", username).
+			Gte("createdAt", fromTime).
+			Count()
+		if err != nil {
+			return nil, err
+		}
+		stat.AccountFailures = int(count)
+		if count > 0 {
+			stat.LastAccountFailedAt, err = this.Query(tx).
+				Result("MAX(createdAt)").
+				Attr("role", role).
+				Attr("username", username).
+				FindInt64Col(0)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(ip) > 0 {
+		count, err := this.Query(tx).
+			Attr("ip", ip).
+			Gte("createdAt", fromTime).
+			Count()
+		if err != nil {
+			return nil, err
+		}
+		stat.IPFailures = int(count)
+		if count > 0 {
+			stat.LastIPFailedAt, err = this.Query(tx).
+				Result("MAX(createdAt)").
+				Attr("ip", ip).
+				FindInt64Col(0)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,24 @@
+package models
+
+// LoginFailure 登录失败记录
+type LoginFailure struct {
+	Id        uint64 `field:"id"`        // ID
+	Role      string `field:"role"`      // 角色：admin、user
+	Username  string `field:"username"`  // 用户名
+	Ip        string `field:"ip"`        // IP
+	Type      string `field:"type"`      // 失败类型：password、otp
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type LoginFailureOperator struct {
+	Id        any // ID
+	Role      any // 角色：admin、user
+	Username  any // 用户名
+	Ip        any // IP
+	Type      any // 失败类型：password、otp
+	CreatedAt any // 创建时间
+}
+
+func NewLoginFailureOperator() *LoginFailureOperator {
+	return &LoginFailureOperator{}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+// 登录地区保留的天数
+const loginLocationKeepDays = 180
+
+func init() {
+	dbs.OnReadyDone(func() {
+		// 清理长期没有使用的登录地区
+		var ticker = time.NewTicker(24 * time.Hour)
+		goman.New(func() {
+			for range ticker.C {
+				err := SharedLoginLocationDAO.Clean(nil)
+				if err != nil {
+					remotelogs.Error("LoginLocationDAO", "clean expired data failed: "+err.Error())
+				}
+			}
+		})
+	})
+}
+
+type LoginLocationDAO dbs.DAO
+
+func NewLoginLocationDAO() *LoginLocationDAO {
+	return dbs.NewDAO(&LoginLocationDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeLoginLocations",
+			Model:  new(LoginLocation),
+			PkName: "id",
+		},
+	}).(*LoginLocationDAO)
+}
+
+var SharedLoginLocationDAO *LoginLocationDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedLoginLocationDAO = NewLoginLocationDAO()
+	})
+}
+
+// FindAccountLocations 查找某个账号登录过的地区
+func (this *LoginLocationDAO) FindAccountLocations(tx *dbs.Tx, adminId int64, userId int64, subUserId int64) (result []string, err error) {
+	if adminId <= 0 && userId <= 0 {
+		return
+	}
+	ones, err := this.Query(tx).
+		Result("location").
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("subUserId", subUserId).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, one.(*LoginLocation).Location)
+	}
+	return
+}
+
+// AddAccountLocation 记录账号登录的地区
+func (this *LoginLocationDAO) AddAccountLocation(tx *dbs.Tx, adminId int64, userId int64, subUserId int64, location string) error {
+	if (adminId <= 0 && userId <= 0) || len(location) == 0 {
+		return nil
+	}
+
+	locationId, err := this.Query(tx).
+		ResultPk().
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("subUserId", subUserId).
+		Attr("location", location).
+		FindInt64Col(0)
+	if err != nil {
+		return err
+	}
+
+	var now = time.Now().Unix()
+	var op = NewLoginLocationOperator()
+	if locationId > 0 {
+		op.Id = locationId
+	} else {
+		op.AdminId = adminId
+		op.UserId = userId
+		op.SubUserId = subUserId
+		op.Location = location
+		op.CreatedAt = now
+	}
+	op.LastLoginAt = now
+	return this.Save(tx, op)
+}
+
+// Clean 清理长期没有使用的登录地区
+func (this *LoginLocationDAO) Clean(tx *dbs.Tx) error {
+	_, err := this.Query(tx).
+		Lt("lastLoginAt", time.Now().Unix()-loginLocationKeepDays*86400).
+		Delete()
+	return err
+}
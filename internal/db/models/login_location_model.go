@@ -0,0 +1,26 @@
+package models
+
+// LoginLocation 账号登录过的地区
+type LoginLocation struct {
+	Id          uint64 `field:"id"`          // ID
+	AdminId     uint64 `field:"adminId"`     // 管理员ID
+	UserId      uint64 `field:"userId"`      // 用户ID
+	SubUserId   uint64 `field:"subUserId"`   // 子用户ID
+	Location    string `field:"location"`    // 登录地区
+	CreatedAt   uint64 `field:"createdAt"`   // 首次登录时间
+	LastLoginAt uint64 `field:"lastLoginAt"` // 最后登录时间
+}
+
+type LoginLocationOperator struct {
+	Id          any // ID
+	AdminId     any // 管理员ID
+	UserId      any // 用户ID
+	SubUserId   any // 子用户ID
+	Location    any // 登录地区
+	CreatedAt   any // 首次登录时间
+	LastLoginAt any // 最后登录时间
+}
+
+func NewLoginLocationOperator() *LoginLocationOperator {
+	return &LoginLocationOperator{}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/iwind/TeaGo/dbs"
+	stringutil "github.com/iwind/TeaGo/utils/string"
+)
+
+// 管理员和用户登录密码的公共处理函数
+// 客户端登录时提交的是密码的MD5值，所以哈希之前需要先计算明文密码的MD5
+
+// 生成登录密码哈希
+// password 为明文密码
+func hashLoginPassword(tx *dbs.Tx, password string) (hash string, err error) {
+	policy, err := SharedSysSettingDAO.ReadPasswordPolicy(tx)
+	if err != nil {
+		return "", err
+	}
+	return passwordutils.HashPassword(stringutil.Md5(password), policy.HashAlgorithm)
+}
+
+// 校验登录密码
+// encodedPassword 为客户端提交的密码MD5；如果需要升级哈希，则返回新的哈希
+func verifyLoginPassword(tx *dbs.Tx, hash string, encodedPassword string) (ok bool, newHash string) {
+	policy, err := SharedSysSettingDAO.ReadPasswordPolicy(tx)
+	if err != nil {
+		remotelogs.Error("LOGIN_PASSWORD", "read password policy failed: "+err.Error())
+		policy = passwordutils.DefaultPolicy()
+	}
+
+	ok, needsRehash := passwordutils.VerifyPassword(hash, encodedPassword, policy.HashAlgorithm)
+	if !ok || !needsRehash {
+		return
+	}
+
+	newHash, err = passwordutils.HashPassword(encodedPassword, policy.HashAlgorithm)
+	if err != nil {
+		remotelogs.Error("LOGIN_PASSWORD", "rehash password failed: "+err.Error())
+		return true, ""
+	}
+	return true, newHash
+}
+
+// 检查密码是否在历史记录中
+// password 为明文密码
+func checkPasswordReused(currentHash string, historyJSON []byte, password string, historySize int) bool {
+	if historySize <= 0 {
+		return false
+	}
+
+	var encodedPassword = stringutil.Md5(password)
+	ok, _ := passwordutils.VerifyPassword(currentHash, encodedPassword, "")
+	if ok {
+		return true
+	}
+	return passwordutils.IsInHistory(decodePasswordHistory(historyJSON), encodedPassword, historySize)
+}
+
+// 将新的哈希加入到历史记录
+// 如果指定了oldHash，则用新的哈希替换旧的哈希（用于密码哈希升级）
+func composePasswordHistory(historyJSON []byte, oldHash string, newHash string) ([]byte, error) {
+	var history = decodePasswordHistory(historyJSON)
+	if len(oldHash) > 0 {
+		var newHistory = []string{}
+		for _, hash := range history {
+			if hash != oldHash {
+				newHistory = append(newHistory, hash)
+			}
+		}
+		history = newHistory
+	}
+	return json.Marshal(passwordutils.AppendHistory(history, newHash))
+}
+
+func decodePasswordHistory(historyJSON []byte) []string {
+	var history = []string{}
+	if IsNotNull(historyJSON) {
+		_ = json.Unmarshal(historyJSON, &history)
+	}
+	return history
+}
@@ -3,11 +3,16 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sessionutils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/iplibrary"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/types"
+	"strings"
 	"time"
 )
 
@@ -56,9 +61,11 @@ func (this *LoginSessionDAO) CreateSession(tx *dbs.Tx, sid string, ip string, ex
 
 	op.Sid = sid
 	op.Ip = ip
+	op.Location = this.lookupLocation(ip)
 	op.Values = "{}"
 	op.ExpiresAt = expiresAt
 	op.CreatedAt = time.Now().Unix()
+	op.ActiveAt = time.Now().Unix()
 
 	if oldSessionId > 0 {
 		err := this.Save(tx, op)
@@ -126,6 +133,8 @@ func (this *LoginSessionDAO) WriteSessionValue(tx *dbs.Tx, sid string, key strin
 		adminId = types.Int64(value)
 	case "userId":
 		userId = types.Int64(value)
+	case "subUserId":
+		sessionOp.SubUserId = types.Int64(value)
 	}
 
 	if adminId > 0 || userId > 0 {
@@ -140,8 +149,16 @@ func (this *LoginSessionDAO) WriteSessionValue(tx *dbs.Tx, sid string, key strin
 	// IP
 	if key == "@ip" {
 		sessionOp.Ip = value
+		sessionOp.Location = this.lookupLocation(types.String(value))
 	}
 
+	// UserAgent
+	if key == "@userAgent" {
+		sessionOp.UserAgent = utils.LimitString(types.String(value), 500)
+	}
+
+	sessionOp.ActiveAt = time.Now().Unix()
+
 	return this.Save(tx, sessionOp)
 }
 
@@ -170,48 +187,196 @@ func (this *LoginSessionDAO) FindSession(tx *dbs.Tx, sid string) (*LoginSession,
 		if err != nil {
 			return nil, err
 		}
+		return session, nil
 	}
+
+	// 更新活跃时间，避免频繁写入，每分钟最多更新一次
+	var now = time.Now().Unix()
+	if now-int64(session.ActiveAt) >= 60 {
+		err = this.Query(tx).
+			Pk(session.Id).
+			Set("activeAt", now).
+			UpdateQuickly()
+		if err != nil {
+			return nil, err
+		}
+		session.ActiveAt = uint64(now)
+	}
+
 	return session, nil
 }
 
-func (this *LoginSessionDAO) ClearOldSessions(tx *dbs.Tx, adminId int64, userId int64, sid string, ip string) error {
-	// 删除此用户之前创建的SESSION
-	err := this.Query(tx).
+// FindAvailableSessionWithId 根据ID查找可用的SESSION
+func (this *LoginSessionDAO) FindAvailableSessionWithId(tx *dbs.Tx, sessionId int64) (*LoginSession, error) {
+	one, err := this.Query(tx).
+		Pk(sessionId).
+		Where("(expiresAt=0 OR expiresAt>:now)").
+		Param("now", time.Now().Unix()).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*LoginSession), nil
+}
+
+// FindAllAvailableSessions 查找某个账号所有可用的SESSION
+func (this *LoginSessionDAO) FindAllAvailableSessions(tx *dbs.Tx, adminId int64, userId int64, subUserId int64) (result []*LoginSession, err error) {
+	if adminId <= 0 && userId <= 0 {
+		return
+	}
+	_, err = this.Query(tx).
 		Attr("adminId", adminId).
 		Attr("userId", userId).
-		Neq("sid", sid).
-		Neq("ip", ip). // 同一个IP允许多个SID，因为有人可能会同时使用手机端和PC端
+		Attr("subUserId", subUserId).
+		Where("(expiresAt=0 OR expiresAt>:now)").
+		Param("now", time.Now().Unix()).
+		Desc("activeAt").
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteSessionWithId 根据ID删除SESSION
+func (this *LoginSessionDAO) DeleteSessionWithId(tx *dbs.Tx, sessionId int64) error {
+	if sessionId <= 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Pk(sessionId).
 		DeleteQuickly()
+}
+
+// DeleteAllSessions 删除某个账号的所有SESSION
+// exceptSid 为需要保留的SESSION，通常是当前正在使用的SESSION
+func (this *LoginSessionDAO) DeleteAllSessions(tx *dbs.Tx, adminId int64, userId int64, subUserId int64, exceptSid string) error {
+	if adminId <= 0 && userId <= 0 {
+		return nil
+	}
+	var query = this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("subUserId", subUserId)
+	if len(exceptSid) > 0 {
+		query.Neq("sid", exceptSid)
+	}
+	return query.DeleteQuickly()
+}
+
+// ClearOldSessions 登录成功后根据SESSION策略清理此账号的其他SESSION
+func (this *LoginSessionDAO) ClearOldSessions(tx *dbs.Tx, adminId int64, userId int64, subUserId int64, sid string, ip string) error {
+	policy, err := SharedSysSettingDAO.ReadLoginSessionPolicy(tx)
+	if err != nil {
+		return err
+	}
+
+	// 检查是否从新的地区登录
+	// 需要和此账号登录过的所有地区比较，而不只是当前存在的SESSION，因为SESSION可能已经被清理
+	var location = this.lookupLocation(ip)
+	if policy.NotifyNewLocation {
+		knownLocations, err := SharedLoginLocationDAO.FindAccountLocations(tx, adminId, userId, subUserId)
+		if err != nil {
+			return err
+		}
+
+		// 兼容还没有登录地区记录的账号
+		otherOnes, err := this.Query(tx).
+			Result("location").
+			Attr("adminId", adminId).
+			Attr("userId", userId).
+			Attr("subUserId", subUserId).
+			Neq("sid", sid).
+			FindAll()
+		if err != nil {
+			return err
+		}
+		for _, otherOne := range otherOnes {
+			knownLocations = append(knownLocations, otherOne.(*LoginSession).Location)
+		}
+		if sessionutils.IsNewLocation(location, knownLocations) {
+			err = this.notifyNewLocation(tx, adminId, userId, subUserId, ip, location)
+			if err != nil {
+				remotelogs.Error("LOGIN_SESSION", "notify new login location failed: "+err.Error())
+			}
+		}
+	}
+	err = SharedLoginLocationDAO.AddAccountLocation(tx, adminId, userId, subUserId, location)
 	if err != nil {
 		return err
 	}
 
+	// 删除此用户之前创建的SESSION
+	if policy.SingleIP {
+		err = this.Query(tx).
+			Attr("adminId", adminId).
+			Attr("userId", userId).
+			Attr("subUserId", subUserId).
+			Neq("sid", sid).
+			Neq("ip", ip). // 同一个IP允许多个SID，因为有人可能会同时使用手机端和PC端
+			DeleteQuickly()
+		if err != nil {
+			return err
+		}
+	}
+
 	// 删除过多的SESSION
-	oldOnes, queryErr := this.Query(tx).
+	oldOnes, err := this.Query(tx).
 		ResultPk().
 		Attr("adminId", adminId).
 		Attr("userId", userId).
+		Attr("subUserId", subUserId).
 		Neq("sid", sid).
 		AscPk().
 		FindAll()
-	if queryErr != nil {
-		return queryErr
-	}
-	var oldCount = len(oldOnes)
-	if oldCount > 3 {
-		for _, oldOne := range oldOnes[:oldCount-3] {
-			var oldId = oldOne.(*LoginSession).Id
-			if oldOne.(*LoginSession).Sid == sid {
-				continue
-			}
-			err = this.Query(tx).
-				Pk(oldId).
-				DeleteQuickly()
-			if err != nil {
-				return err
-			}
+	if err != nil {
+		return err
+	}
+	var deleteCount = policy.CountSessionsToDelete(len(oldOnes))
+	for _, oldOne := range oldOnes[:deleteCount] {
+		err = this.Query(tx).
+			Pk(oldOne.(*LoginSession).Id).
+			DeleteQuickly()
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// 查询IP所在地区
+func (this *LoginSessionDAO) lookupLocation(ip string) string {
+	if len(ip) == 0 {
+		return ""
+	}
+	var result = iplibrary.LookupIP(ip)
+	if result == nil || !result.IsOk() {
+		return ""
+	}
+
+	var pieces = []string{}
+	for _, piece := range []string{result.CountryName(), result.ProvinceName(), result.CityName()} {
+		if len(piece) > 0 && (len(pieces) == 0 || pieces[len(pieces)-1] != piece) {
+			pieces = append(pieces, piece)
+		}
+	}
+	return strings.Join(pieces, " ")
+}
+
+// 发送从新地区登录的通知
+func (this *LoginSessionDAO) notifyNewLocation(tx *dbs.Tx, adminId int64, userId int64, subUserId int64, ip string, location string) error {
+	var subject = "从新的地区登录"
+	var body = "账号从新的地区登录：" + location + "（IP：" + ip + "），如果不是本人操作，请及时修改密码并退出其他登录。"
+	if subUserId > 0 {
+		subUserName, err := SharedSubUserDAO.FindSubUserName(tx, subUserId)
+		if err != nil {
+			return err
+		}
+		body = "子用户\"" + subUserName + "\"" + body
+	}
+	return SharedMessageDAO.CreateMessage(tx, adminId, userId, MessageTypeLoginNewLocation, MessageLevelWarning, subject, body, maps.Map{
+		"ip":        ip,
+		"location":  location,
+		"subUserId": subUserId,
+	}.AsJSON())
+}
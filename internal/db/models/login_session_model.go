@@ -7,10 +7,14 @@ type LoginSession struct {
 	Id        uint64   `field:"id"`        // ID
 	AdminId   uint64   `field:"adminId"`   // 管理员ID
 	UserId    uint64   `field:"userId"`    // 用户ID
+	SubUserId uint64   `field:"subUserId"` // 子用户ID
 	Sid       string   `field:"sid"`       // 令牌
 	Values    dbs.JSON `field:"values"`    // 数据
 	Ip        string   `field:"ip"`        // 登录IP
+	UserAgent string   `field:"userAgent"` // 浏览器UserAgent
+	Location  string   `field:"location"`  // 登录地区
 	CreatedAt uint64   `field:"createdAt"` // 创建时间
+	ActiveAt  uint64   `field:"activeAt"`  // 最后活跃时间
 	ExpiresAt uint64   `field:"expiresAt"` // 过期时间
 }
 
@@ -18,10 +22,14 @@ type LoginSessionOperator struct {
 	Id        any // ID
 	AdminId   any // 管理员ID
 	UserId    any // 用户ID
+	SubUserId any // 子用户ID
 	Sid       any // 令牌
 	Values    any // 数据
 	Ip        any // 登录IP
+	UserAgent any // 浏览器UserAgent
+	Location  any // 登录地区
 	CreatedAt any // 创建时间
+	ActiveAt  any // 最后活跃时间
 	ExpiresAt any // 过期时间
 }
 
@@ -37,9 +37,11 @@ const (
 	MessageTypeNodeInactive               MessageType = "NodeInactive"               // 边缘节点不活跃
 	MessageTypeNodeActive                 MessageType = "NodeActive"                 // 边缘节点活跃
 	MessageTypeClusterDNSSyncFailed       MessageType = "ClusterDNSSyncFailed"       // DNS同步失败
+	MessageTypeClusterDNSDrift            MessageType = "ClusterDNSDrift"            // DNS记录和期望的不一致
 	MessageTypeSSLCertExpiring            MessageType = "SSLCertExpiring"            // SSL证书即将过期
 	MessageTypeSSLCertACMETaskFailed      MessageType = "SSLCertACMETaskFailed"      // SSL证书任务执行失败
 	MessageTypeSSLCertACMETaskSuccess     MessageType = "SSLCertACMETaskSuccess"     // SSL证书任务执行成功
+	MessageTypeSSLCertRenewFailed         MessageType = "SSLCertRenewFailed"         // CA签发的证书续期失败
 	MessageTypeLogCapacityOverflow        MessageType = "LogCapacityOverflow"        // 日志超出最大限制
 	MessageTypeServerNamesAuditingSuccess MessageType = "ServerNamesAuditingSuccess" // 服务域名审核成功（用户）
 	MessageTypeServerNamesAuditingFailed  MessageType = "ServerNamesAuditingFailed"  // 服务域名审核失败（用户）
@@ -57,6 +59,7 @@ const (
 	MessageTypeConnectivity       MessageType = "Connectivity"       // 连通性
 	MessageTypeNodeSchedule       MessageType = "NodeSchedule"       // 节点调度信息
 	MessageTypeNodeOfflineDay     MessageType = "NodeOfflineDay"     // 节点到下线日期
+	MessageTypeLoginNewLocation   MessageType = "LoginNewLocation"   // 从新的地区登录
 )
 
 type MessageDAO dbs.DAO
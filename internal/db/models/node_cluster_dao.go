@@ -496,7 +496,7 @@ func (this *NodeClusterDAO) FindAllEnabledClustersHaveDNSDomain(tx *dbs.Tx) (res
 	_, err = this.Query(tx).
 		State(NodeClusterStateEnabled).
 		Gt("dnsDomainId", 0).
-		Result("id", "name", "dnsName", "dnsDomainId", "isOn").
+		Result("id", "name", "dnsName", "dnsDomainId", "isOn", "dnsAutoFixDrift").
 		Slice(&result).
 		FindAll()
 	return
@@ -543,7 +543,7 @@ func (this *NodeClusterDAO) FindClusterDNSInfo(tx *dbs.Tx, clusterId int64, cach
 
 	one, err := this.Query(tx).
 		Pk(clusterId).
-		Result("id", "name", "dnsName", "dnsDomainId", "dns", "isOn", "state").
+		Result("id", "name", "dnsName", "dnsDomainId", "dns", "isOn", "state", "dnsAutoFixDrift").
 		Find()
 	if err != nil {
 		return nil, err
@@ -557,6 +557,17 @@ func (this *NodeClusterDAO) FindClusterDNSInfo(tx *dbs.Tx, clusterId int64, cach
 	return one.(*NodeCluster), nil
 }
 
+// UpdateClusterDNSAutoFixDrift 设置是否自动修正DNS记录偏差
+func (this *NodeClusterDAO) UpdateClusterDNSAutoFixDrift(tx *dbs.Tx, clusterId int64, autoFix bool) error {
+	if clusterId <= 0 {
+		return errors.New("invalid clusterId")
+	}
+	return this.Query(tx).
+		Pk(clusterId).
+		Set("dnsAutoFixDrift", autoFix).
+		UpdateQuickly()
+}
+
 // ExistClusterDNSName 检查某个子域名是否可用
 func (this *NodeClusterDAO) ExistClusterDNSName(tx *dbs.Tx, dnsName string, excludeClusterId int64) (bool, error) {
 	return this.Query(tx).
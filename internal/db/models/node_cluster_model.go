@@ -48,6 +48,7 @@ const (
 	NodeClusterField_AutoTrimDisks        dbs.FieldName = "autoTrimDisks"        // 是否自动执行TRIM
 	NodeClusterField_MaxConcurrentReads   dbs.FieldName = "maxConcurrentReads"   // 节点并发读限制
 	NodeClusterField_MaxConcurrentWrites  dbs.FieldName = "maxConcurrentWrites"  // 节点并发写限制
+	NodeClusterField_DnsAutoFixDrift      dbs.FieldName = "dnsAutoFixDrift"      // 是否自动修正DNS记录偏差
 )
 
 // NodeCluster 节点集群
@@ -97,6 +98,7 @@ type NodeCluster struct {
 	AutoTrimDisks        bool     `field:"autoTrimDisks"`        // 是否自动执行TRIM
 	MaxConcurrentReads   uint32   `field:"maxConcurrentReads"`   // 节点并发读限制
 	MaxConcurrentWrites  uint32   `field:"maxConcurrentWrites"`  // 节点并发写限制
+	DnsAutoFixDrift      bool     `field:"dnsAutoFixDrift"`      // 是否自动修正DNS记录偏差
 }
 
 type NodeClusterOperator struct {
@@ -145,6 +147,7 @@ type NodeClusterOperator struct {
 	AutoTrimDisks        any // 是否自动执行TRIM
 	MaxConcurrentReads   any // 节点并发读限制
 	MaxConcurrentWrites  any // 节点并发写限制
+	DnsAutoFixDrift      any // 是否自动修正DNS记录偏差
 }
 
 func NewNodeClusterOperator() *NodeClusterOperator {
@@ -35,6 +35,8 @@ import (
 const (
 	NodeStateEnabled  = 1 // 已启用
 	NodeStateDisabled = 0 // 已禁用
+
+	MaxNodeDNSWeight = 100 // 节点DNS最大权重
 )
 
 type NodeDAO dbs.DAO
@@ -722,6 +724,26 @@ func (this *NodeDAO) FindEnabledAndOnNodeIdsWithClusterId(tx *dbs.Tx, clusterId
 	return result, nil
 }
 
+// FindAllOnlineNodeIdsWithClusterId 查找某个集群下所有启用并在线的节点IDs，包括从集群中的节点
+func (this *NodeDAO) FindAllOnlineNodeIdsWithClusterId(tx *dbs.Tx, clusterId int64) (result []int64, err error) {
+	ones, err := this.Query(tx).
+		Where("(clusterId=:primaryClusterId OR JSON_CONTAINS(secondaryClusterIds, :primaryClusterIdString))").
+		Param("primaryClusterId", clusterId).
+		Param("primaryClusterIdString", types.String(clusterId)).
+		Attr("isOn", true).
+		State(NodeStateEnabled).
+		Where("isActive AND UNIX_TIMESTAMP()-JSON_EXTRACT(status, '$.updatedAt')<=60").
+		ResultPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, int64(one.(*Node).Id))
+	}
+	return
+}
+
 // FindAllEnabledNodeIdsWithClusterId 获取一个集群的所有节点Ids
 func (this *NodeDAO) FindAllEnabledNodeIdsWithClusterId(tx *dbs.Tx, clusterId int64) (result []int64, err error) {
 	ones, err := this.Query(tx).
@@ -1632,7 +1654,7 @@ func (this *NodeDAO) FindAllEnabledNodesDNSWithClusterId(tx *dbs.Tx, clusterId i
 		Attr("isOn", true).
 		Attr("isUp", true).
 		Attr("isInstalled", isInstalled).
-		Result("id", "name", "dnsRoutes", "isOn", "offlineDay", "actionStatus", "isBackupForCluster", "isBackupForGroup", "backupIPs", "clusterId", "groupId").
+		Result("id", "name", "dnsRoutes", "dnsWeight", "maxCPU", "status", "regionId", "isOn", "offlineDay", "actionStatus", "isBackupForCluster", "isBackupForGroup", "backupIPs", "clusterId", "groupId").
 		DescPk().
 		Slice(&result).
 		FindAll()
@@ -1657,7 +1679,7 @@ func (this *NodeDAO) FindEnabledNodeDNS(tx *dbs.Tx, nodeId int64) (*Node, error)
 	one, err := this.Query(tx).
 		State(NodeStateEnabled).
 		Pk(nodeId).
-		Result("id", "name", "dnsRoutes", "clusterId", "isOn", "offlineDay", "isBackupForCluster", "isBackupForGroup", "actionStatus").
+		Result("id", "name", "dnsRoutes", "dnsWeight", "regionId", "clusterId", "isOn", "offlineDay", "isBackupForCluster", "isBackupForGroup", "actionStatus").
 		Find()
 	if one == nil {
 		return nil, err
@@ -1710,6 +1732,29 @@ func (this *NodeDAO) UpdateNodeDNS(tx *dbs.Tx, nodeId int64, routes map[int64][]
 	return nil
 }
 
+// UpdateNodeDNSWeight 修改节点的DNS权重
+// weight为0时表示根据节点容量自动计算
+func (this *NodeDAO) UpdateNodeDNSWeight(tx *dbs.Tx, nodeId int64, weight int32) error {
+	if nodeId <= 0 {
+		return errors.New("invalid nodeId")
+	}
+	if weight < 0 {
+		weight = 0
+	}
+	if weight > MaxNodeDNSWeight {
+		weight = MaxNodeDNSWeight
+	}
+	var op = NewNodeOperator()
+	op.Id = nodeId
+	op.DnsWeight = weight
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+
+	return this.NotifyDNSUpdate(tx, nodeId)
+}
+
 // FindNodeDNSResolver 查找域名DNS Resolver
 func (this *NodeDAO) FindNodeDNSResolver(tx *dbs.Tx, nodeId int64) (*nodeconfigs.DNSResolverConfig, error) {
 	configJSON, err := this.Query(tx).
@@ -2303,3 +2348,26 @@ func (this *NodeDAO) NotifyDNSUpdate(tx *dbs.Tx, nodeId int64) error {
 	}
 	return nil
 }
+
+// NotifyRegionDNSUpdate 通知某个区域内节点所在集群DNS更新
+func (this *NodeDAO) NotifyRegionDNSUpdate(tx *dbs.Tx, regionId int64) error {
+	ones, err := this.Query(tx).
+		State(NodeStateEnabled).
+		Attr("regionId", regionId).
+		Result("DISTINCT(clusterId) AS clusterId").
+		FindAll()
+	if err != nil {
+		return err
+	}
+	for _, one := range ones {
+		var clusterId = int64(one.(*Node).ClusterId)
+		if clusterId <= 0 {
+			continue
+		}
+		err = dns.SharedDNSTaskDAO.CreateClusterTask(tx, clusterId, dns.DNSTaskTypeClusterNodesChange)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
@@ -61,7 +63,12 @@ func (this *NodeGrantDAO) FindEnabledNodeGrant(tx *dbs.Tx, id int64) (*NodeGrant
 	if result == nil {
 		return nil, err
 	}
-	return result.(*NodeGrant), err
+	var grant = result.(*NodeGrant)
+	err = this.decodeGrant(grant)
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
 }
 
 // FindNodeGrantName 根据主键查找名称
@@ -74,7 +81,7 @@ func (this *NodeGrantDAO) FindNodeGrantName(tx *dbs.Tx, id uint32) (string, erro
 }
 
 // CreateGrant 创建认证信息
-func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, method string, username string, password string, privateKey string, passphrase string, description string, nodeId int64, su bool) (grantId int64, err error) {
+func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, method string, username string, password string, privateKey string, passphrase string, certificate string, description string, nodeId int64, su bool) (grantId int64, err error) {
 	var op = NewNodeGrantOperator()
 	op.AdminId = adminId
 	op.Name = name
@@ -83,11 +90,9 @@ func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, me
 	switch method {
 	case "user":
 		op.Username = username
-		op.Password = password
 	case "privateKey":
 		op.Username = username
-		op.PrivateKey = privateKey
-		op.Passphrase = passphrase
+		op.Certificate = certificate
 	}
 	if username != "root" { // only for non-root user
 		op.Su = su
@@ -95,16 +100,35 @@ func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, me
 	op.Description = description
 	op.NodeId = nodeId
 	op.State = NodeGrantStateEnabled
-	err = this.Save(tx, op)
-	return types.Int64(op.Id), err
+
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err := this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		grantId = types.Int64(op.Id)
+
+		var secretOp = NewNodeGrantOperator()
+		secretOp.Id = grantId
+		err = this.encodeGrantSecrets(secretOp, grantId, method, password, privateKey, passphrase)
+		if err != nil {
+			return err
+		}
+		return this.Save(tx, secretOp)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return grantId, nil
 }
 
 // UpdateGrant 修改认证信息
-func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, method string, username string, password string, privateKey string, passphrase string, description string, nodeId int64, su bool) error {
+func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, method string, username string, password string, privateKey string, passphrase string, certificate string, description string, nodeId int64, su bool) error {
 	if grantId <= 0 {
 		return errors.New("invalid grantId")
 	}
 
+	var err error
 	var op = NewNodeGrantOperator()
 	op.Id = grantId
 	op.Name = name
@@ -113,11 +137,13 @@ func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, me
 	switch method {
 	case "user":
 		op.Username = username
-		op.Password = password
 	case "privateKey":
 		op.Username = username
-		op.PrivateKey = privateKey
-		op.Passphrase = passphrase
+		op.Certificate = certificate
+	}
+	err = this.encodeGrantSecrets(op, grantId, method, password, privateKey, passphrase)
+	if err != nil {
+		return err
 	}
 	if username != "root" { // only for non-root user
 		op.Su = su
@@ -126,7 +152,7 @@ func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, me
 	}
 	op.Description = description
 	op.NodeId = nodeId
-	err := this.Save(tx, op)
+	err = this.Save(tx, op)
 	return err
 }
 
@@ -155,6 +181,15 @@ func (this *NodeGrantDAO) ListEnabledGrants(tx *dbs.Tx, keyword string, offset i
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range result {
+		err = this.decodeGrant(grant)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return
 }
 
@@ -165,5 +200,101 @@ func (this *NodeGrantDAO) FindAllEnabledGrants(tx *dbs.Tx) (result []*NodeGrant,
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range result {
+		err = this.decodeGrant(grant)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return
 }
+
+// RewrapGrantSecrets 使用当前主密钥重新加密所有认证信息
+func (this *NodeGrantDAO) RewrapGrantSecrets(tx *dbs.Tx) (count int64, err error) {
+	var lastId int64
+	for {
+		var grants []*NodeGrant
+		_, err = this.Query(tx).
+			Gt("id", lastId).
+			Result("id", "password", "privateKey", "passphrase").
+			AscPk().
+			Limit(100).
+			Slice(&grants).
+			FindAll()
+		if err != nil || len(grants) == 0 {
+			return
+		}
+
+		for _, grant := range grants {
+			lastId = int64(grant.Id)
+
+			var hasChanges = false
+			var values = map[string]string{
+				"password":   grant.Password,
+				"privateKey": grant.PrivateKey,
+				"passphrase": grant.Passphrase,
+			}
+			var query = this.Query(tx).Pk(grant.Id)
+			for field, value := range values {
+				newValue, changed, rewrapErr := encrypt.SharedKeyring().Rewrap([]byte(value), encrypt.AAD(this.Table, field, int64(grant.Id)))
+				if rewrapErr != nil {
+					return count, fmt.Errorf("rewrap node grant '%d' failed: %w", grant.Id, rewrapErr)
+				}
+				if changed {
+					query.Set(field, string(newValue))
+					hasChanges = true
+				}
+			}
+			if !hasChanges {
+				continue
+			}
+			err = query.UpdateQuickly()
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 加密认证信息中的密码和私钥
+func (this *NodeGrantDAO) encodeGrantSecrets(op *NodeGrantOperator, grantId int64, method string, password string, privateKey string, passphrase string) (err error) {
+	switch method {
+	case "user":
+		op.Password, err = encrypt.EncryptSecretString(password, encrypt.AAD(this.Table, "password", grantId))
+		if err != nil {
+			return err
+		}
+	case "privateKey":
+		op.PrivateKey, err = encrypt.EncryptSecretString(privateKey, encrypt.AAD(this.Table, "privateKey", grantId))
+		if err != nil {
+			return err
+		}
+		op.Passphrase, err = encrypt.EncryptSecretString(passphrase, encrypt.AAD(this.Table, "passphrase", grantId))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解密认证信息
+func (this *NodeGrantDAO) decodeGrant(grant *NodeGrant) (err error) {
+	var grantId = int64(grant.Id)
+	grant.Password, err = encrypt.DecryptSecretString(grant.Password, encrypt.AAD(this.Table, "password", grantId))
+	if err != nil {
+		return fmt.Errorf("decrypt password of node grant '%d' failed: %w", grant.Id, err)
+	}
+	grant.PrivateKey, err = encrypt.DecryptSecretString(grant.PrivateKey, encrypt.AAD(this.Table, "privateKey", grantId))
+	if err != nil {
+		return fmt.Errorf("decrypt private key of node grant '%d' failed: %w", grant.Id, err)
+	}
+	grant.Passphrase, err = encrypt.DecryptSecretString(grant.Passphrase, encrypt.AAD(this.Table, "passphrase", grantId))
+	if err != nil {
+		return fmt.Errorf("decrypt passphrase of node grant '%d' failed: %w", grant.Id, err)
+	}
+	return nil
+}
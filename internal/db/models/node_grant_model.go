@@ -11,6 +11,7 @@ type NodeGrant struct {
 	Su          uint8  `field:"su"`          // 是否需要su
 	PrivateKey  string `field:"privateKey"`  // 私钥
 	Passphrase  string `field:"passphrase"`  // 私钥密码
+	Certificate string `field:"certificate"` // OpenSSH用户证书
 	Description string `field:"description"` // 备注
 	NodeId      uint32 `field:"nodeId"`      // 专有节点
 	Role        string `field:"role"`        // 角色
@@ -28,6 +29,7 @@ type NodeGrantOperator struct {
 	Su          interface{} // 是否需要su
 	PrivateKey  interface{} // 私钥
 	Passphrase  interface{} // 私钥密码
+	Certificate interface{} // OpenSSH用户证书
 	Description interface{} // 备注
 	NodeId      interface{} // 专有节点
 	Role        interface{} // 角色
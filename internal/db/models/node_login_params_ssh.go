@@ -1,6 +1,14 @@
 package models
 
 type NodeLoginSSHParams struct {
+	GrantId   int64                     `json:"grantId"`
+	Host      string                    `json:"host"`
+	Port      int                       `json:"port"`
+	JumpHosts []*NodeLoginSSHJumpParams `json:"jumpHosts"` // 跳板机，按照连接顺序排列
+}
+
+// NodeLoginSSHJumpParams 跳板机参数
+type NodeLoginSSHJumpParams struct {
 	GrantId int64  `json:"grantId"`
 	Host    string `json:"host"`
 	Port    int    `json:"port"`
@@ -36,6 +36,7 @@ type Node struct {
 	MaxThreads             uint32   `field:"maxThreads"`             // 最大线程数
 	DdosProtection         dbs.JSON `field:"ddosProtection"`         // DDOS配置
 	DnsRoutes              dbs.JSON `field:"dnsRoutes"`              // DNS线路设置
+	DnsWeight              uint32   `field:"dnsWeight"`              // DNS权重，0表示自动
 	MaxCacheDiskCapacity   dbs.JSON `field:"maxCacheDiskCapacity"`   // 硬盘缓存容量
 	MaxCacheMemoryCapacity dbs.JSON `field:"maxCacheMemoryCapacity"` // 内存缓存容量
 	CacheDiskDir           string   `field:"cacheDiskDir"`           // 主缓存目录
@@ -84,6 +85,7 @@ type NodeOperator struct {
 	MaxThreads             any // 最大线程数
 	DdosProtection         any // DDOS配置
 	DnsRoutes              any // DNS线路设置
+	DnsWeight              any // DNS权重，0表示自动
 	MaxCacheDiskCapacity   any // 硬盘缓存容量
 	MaxCacheMemoryCapacity any // 内存缓存容量
 	CacheDiskDir           any // 主缓存目录
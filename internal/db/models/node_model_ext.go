@@ -60,6 +60,28 @@ func (this *Node) DNSRouteCodes() map[int64][]string {
 	return routes
 }
 
+// DNSWeight 节点DNS权重，范围为1-100
+// 没有手动设置时，根据节点可用的CPU数量自动计算
+func (this *Node) DNSWeight() int32 {
+	var weight = int32(this.DnsWeight)
+	if weight <= 0 {
+		weight = int32(this.MaxCPU)
+		if weight <= 0 {
+			status, err := this.DecodeStatus()
+			if err == nil && status != nil {
+				weight = int32(status.CPULogicalCount)
+			}
+		}
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	if weight > MaxNodeDNSWeight {
+		weight = MaxNodeDNSWeight
+	}
+	return weight
+}
+
 // DNSRouteCodesForDomainId DNS线路
 func (this *Node) DNSRouteCodesForDomainId(dnsDomainId int64) ([]string, error) {
 	var routes = map[int64][]string{} // domainId => routes
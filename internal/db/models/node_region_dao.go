@@ -198,3 +198,45 @@ func (this *NodeRegionDAO) UpdateRegionItemPrice(tx *dbs.Tx, regionId int64, ite
 		Update()
 	return err
 }
+
+// UpdateRegionDNSRoutes 修改区域在某个域名下对应的DNS线路
+func (this *NodeRegionDAO) UpdateRegionDNSRoutes(tx *dbs.Tx, regionId int64, dnsDomainId int64, routes []string) error {
+	if regionId <= 0 {
+		return errors.New("invalid regionId")
+	}
+	region, err := this.FindEnabledNodeRegion(tx, regionId)
+	if err != nil {
+		return err
+	}
+	if region == nil {
+		return nil
+	}
+
+	var routesMap = map[int64][]string{} // domainId => routes
+	if IsNotNull(region.DnsRoutes) {
+		err = json.Unmarshal(region.DnsRoutes, &routesMap)
+		if err != nil {
+			return err
+		}
+	}
+	if len(routes) > 0 {
+		routesMap[dnsDomainId] = routes
+	} else {
+		delete(routesMap, dnsDomainId)
+	}
+	routesJSON, err := json.Marshal(routesMap)
+	if err != nil {
+		return err
+	}
+
+	var op = NewNodeRegionOperator()
+	op.Id = regionId
+	op.DnsRoutes = routesJSON
+	err = this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+
+	// 通知区域内节点所在集群更新DNS
+	return SharedNodeDAO.NotifyRegionDNSUpdate(tx, regionId)
+}
@@ -12,6 +12,7 @@ type NodeRegion struct {
 	Order       uint32   `field:"order"`       // 排序
 	CreatedAt   uint64   `field:"createdAt"`   // 创建时间
 	Prices      dbs.JSON `field:"prices"`      // 流量价格
+	DnsRoutes   dbs.JSON `field:"dnsRoutes"`   // DNS线路设置
 	State       uint8    `field:"state"`       // 状态
 }
 
@@ -24,6 +25,7 @@ type NodeRegionOperator struct {
 	Order       any // 排序
 	CreatedAt   any // 创建时间
 	Prices      any // 流量价格
+	DnsRoutes   any // DNS线路设置
 	State       any // 状态
 }
 
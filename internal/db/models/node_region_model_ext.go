@@ -1,6 +1,9 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"sort"
+)
 
 func (this *NodeRegion) DecodePriceMap() map[int64]float64 {
 	var m = map[int64]float64{}
@@ -16,3 +19,21 @@ func (this *NodeRegion) DecodePriceMap() map[int64]float64 {
 
 	return m
 }
+
+// DNSRouteCodesForDomainId 区域在某个域名下对应的DNS线路
+func (this *NodeRegion) DNSRouteCodesForDomainId(dnsDomainId int64) []string {
+	var routes = map[int64][]string{} // domainId => routes
+	if len(this.DnsRoutes) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(this.DnsRoutes, &routes)
+	if err != nil {
+		// 忽略错误
+		return nil
+	}
+	var domainRoutes = routes[dnsDomainId]
+	if len(domainRoutes) > 0 {
+		sort.Strings(domainRoutes)
+	}
+	return domainRoutes
+}
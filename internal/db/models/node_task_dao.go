@@ -35,6 +35,7 @@ const (
 	NodeTaskTypeUpdatingServers              NodeTaskType = "updatingServers"              // 更新一组服务
 	NodeTaskTypeTOAChanged                   NodeTaskType = "toaChanged"                   // TOA配置变化
 	NodeTaskTypePlanChanged                  NodeTaskType = "planChanged"                  // 套餐变化
+	NodeTaskTypeACMETLSALPNChanged           NodeTaskType = "acmeTLSALPNChanged"           // ACME TLS-ALPN-01认证证书变化
 
 	// NS相关
 
@@ -370,6 +371,30 @@ func (this *NodeTaskDAO) ExistsDoingNodeTasks(tx *dbs.Tx, role string, excludeTy
 	return query.Exist()
 }
 
+// ExistsDoingClusterTasks 检查一组集群中的某些节点是否有某个类型的任务还没有完成
+// 包括还没有分解的集群任务，不在 nodeIds 中的节点任务将被忽略
+func (this *NodeTaskDAO) ExistsDoingClusterTasks(tx *dbs.Tx, role string, clusterIds []int64, nodeIds []int64, taskType NodeTaskType) (bool, error) {
+	if len(clusterIds) == 0 {
+		return false, nil
+	}
+
+	var query = this.Query(tx).
+		Attr("role", role).
+		Attr("clusterId", clusterIds).
+		Attr("type", taskType).
+		Attr("isDone", 0)
+	if len(nodeIds) > 0 {
+		var nodeIdStrings = []string{}
+		for _, nodeId := range nodeIds {
+			nodeIdStrings = append(nodeIdStrings, types.String(nodeId))
+		}
+		query.Where("(nodeId=0 OR nodeId IN (" + strings.Join(nodeIdStrings, ",") + "))")
+	} else {
+		query.Attr("nodeId", 0)
+	}
+	return query.Exist()
+}
+
 // ExistsErrorNodeTasks 是否有错误的任务
 func (this *NodeTaskDAO) ExistsErrorNodeTasks(tx *dbs.Tx, role string, excludeTypes []NodeTaskType) (bool, error) {
 	var query = this.Query(tx).
@@ -365,6 +365,9 @@ func (this *OriginDAO) CloneOrigin(tx *dbs.Tx, fromOriginId int64) (newOriginId
 		// TODO 需要Clone证书
 		op.Cert = origin.Cert
 	}
+	if IsNotNull(origin.CaCerts) {
+		op.CaCerts = origin.CaCerts
+	}
 	if IsNotNull(origin.Ftp) {
 		op.Ftp = origin.Ftp
 	}
@@ -527,6 +530,26 @@ func (this *OriginDAO) ComposeOriginConfig(tx *dbs.Tx, originId int64, dataMap *
 		}
 	}
 
+	// 校验源站证书使用的CA证书
+	if IsNotNull(origin.CaCerts) {
+		var refs = []*sslconfigs.SSLCertRef{}
+		err = json.Unmarshal(origin.CaCerts, &refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			certConfig, err := SharedSSLCertDAO.ComposeCertConfig(tx, ref.CertId, false, dataMap, cacheMap)
+			if err != nil {
+				return nil, err
+			}
+			if certConfig == nil {
+				continue
+			}
+			config.CACertRefs = append(config.CACertRefs, ref)
+			config.CACerts = append(config.CACerts, certConfig)
+		}
+	}
+
 	if IsNotNull(origin.Ftp) {
 		// TODO
 	}
@@ -538,33 +561,76 @@ func (this *OriginDAO) ComposeOriginConfig(tx *dbs.Tx, originId int64, dataMap *
 	return config, nil
 }
 
+// UpdateOriginCACerts 修改校验源站证书使用的CA证书
+func (this *OriginDAO) UpdateOriginCACerts(tx *dbs.Tx, originId int64, caCertRefs []*sslconfigs.SSLCertRef) error {
+	if originId <= 0 {
+		return errors.New("invalid originId")
+	}
+
+	var op = NewOriginOperator()
+	op.Id = originId
+	if len(caCertRefs) > 0 {
+		refsJSON, err := json.Marshal(caCertRefs)
+		if err != nil {
+			return err
+		}
+		op.CaCerts = refsJSON
+	} else {
+		op.CaCerts = dbs.SQL("NULL")
+	}
+	op.Version = dbs.SQL("version+1")
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	return this.NotifyUpdate(tx, originId)
+}
+
 // CheckUserOrigin 检查源站权限
 func (this *OriginDAO) CheckUserOrigin(tx *dbs.Tx, userId int64, originId int64) error {
 	if originId <= 0 {
 		return ErrNotFound
 	}
 
+	reverseProxyId, err := this.findOriginReverseProxyId(tx, originId)
+	if err != nil {
+		return err
+	}
+	if reverseProxyId == 0 {
+		// 这里我们不允许源站没有被使用
+		return ErrNotFound
+	}
+	return SharedReverseProxyDAO.CheckUserReverseProxy(tx, userId, reverseProxyId)
+}
+
+// FindOriginServerId 查找使用某个源站的网站ID
+func (this *OriginDAO) FindOriginServerId(tx *dbs.Tx, originId int64) (serverId int64, err error) {
+	if originId <= 0 {
+		return 0, nil
+	}
+	reverseProxyId, err := this.findOriginReverseProxyId(tx, originId)
+	if err != nil || reverseProxyId == 0 {
+		return 0, err
+	}
+	return SharedServerDAO.FindEnabledServerIdWithReverseProxyId(tx, reverseProxyId)
+}
+
+// 查找源站所属的反向代理ID
+func (this *OriginDAO) findOriginReverseProxyId(tx *dbs.Tx, originId int64) (int64, error) {
 	// 快速查找
 	reverseProxyId, err := this.Query(tx).
 		Pk(originId).
 		Result(OriginField_ReverseProxyId).
 		FindInt64Col(0)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// 再次查找
 	if reverseProxyId <= 0 {
-		reverseProxyId, err = SharedReverseProxyDAO.FindReverseProxyContainsOriginId(tx, originId)
-		if err != nil {
-			return err
-		}
-	}
-	if reverseProxyId == 0 {
-		// 这里我们不允许源站没有被使用
-		return ErrNotFound
+		return SharedReverseProxyDAO.FindReverseProxyContainsOriginId(tx, originId)
 	}
-	return SharedReverseProxyDAO.CheckUserReverseProxy(tx, userId, reverseProxyId)
+	return reverseProxyId, nil
 }
 
 // ExistsOrigin 检查源站是否存在
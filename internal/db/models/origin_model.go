@@ -33,6 +33,7 @@ const (
 	OriginField_FollowPort         dbs.FieldName = "followPort"         // 端口跟随
 	OriginField_State              dbs.FieldName = "state"              // 状态
 	OriginField_Http2Enabled       dbs.FieldName = "http2Enabled"       // 是否支持HTTP/2
+	OriginField_CaCerts            dbs.FieldName = "caCerts"            // 校验源站证书使用的CA证书
 )
 
 // Origin 源站
@@ -67,6 +68,7 @@ type Origin struct {
 	FollowPort         bool     `field:"followPort"`         // 端口跟随
 	State              uint8    `field:"state"`              // 状态
 	Http2Enabled       bool     `field:"http2Enabled"`       // 是否支持HTTP/2
+	CaCerts            dbs.JSON `field:"caCerts"`            // 校验源站证书使用的CA证书
 }
 
 type OriginOperator struct {
@@ -100,6 +102,7 @@ type OriginOperator struct {
 	FollowPort         any // 端口跟随
 	State              any // 状态
 	Http2Enabled       any // 是否支持HTTP/2
+	CaCerts            any // 校验源站证书使用的CA证书
 }
 
 func NewOriginOperator() *OriginOperator {
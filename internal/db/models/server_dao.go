@@ -802,7 +802,8 @@ func (this *ServerDAO) CountAllEnabledServers(tx *dbs.Tx) (int64, error) {
 // 参数：
 //
 //	groupId 分组ID，如果为-1，则搜索没有分组的服务
-func (this *ServerDAO) CountAllEnabledServersMatch(tx *dbs.Tx, groupId int64, keyword string, userId int64, clusterId int64, auditingFlag configutils.BoolState, protocolFamilies []string, userPlanId int64) (int64, error) {
+//	serverIds 限定的服务ID，为空时不限制
+func (this *ServerDAO) CountAllEnabledServersMatch(tx *dbs.Tx, groupId int64, keyword string, userId int64, clusterId int64, auditingFlag configutils.BoolState, protocolFamilies []string, userPlanId int64, serverIds []int64) (int64, error) {
 	query := this.Query(tx).
 		State(ServerStateEnabled)
 	if groupId > 0 {
@@ -837,6 +838,9 @@ func (this *ServerDAO) CountAllEnabledServersMatch(tx *dbs.Tx, groupId int64, ke
 		query.Attr("clusterId", clusterId)
 		query.UseIndex("clusterId")
 	}
+	if len(serverIds) > 0 {
+		query.Attr("id", serverIds)
+	}
 	if auditingFlag == configutils.BoolStateYes {
 		query.Attr("isAuditing", true)
 	}
@@ -867,7 +871,8 @@ func (this *ServerDAO) CountAllEnabledServersMatch(tx *dbs.Tx, groupId int64, ke
 // 参数：
 //
 //	groupId 分组ID，如果为-1，则搜索没有分组的服务
-func (this *ServerDAO) ListEnabledServersMatch(tx *dbs.Tx, offset int64, size int64, groupId int64, keyword string, userId int64, clusterId int64, auditingFlag int32, protocolFamilies []string, order string) (result []*Server, err error) {
+//	serverIds 限定的服务ID，为空时不限制
+func (this *ServerDAO) ListEnabledServersMatch(tx *dbs.Tx, offset int64, size int64, groupId int64, keyword string, userId int64, clusterId int64, auditingFlag int32, protocolFamilies []string, order string, serverIds []int64) (result []*Server, err error) {
 	var query = this.Query(tx).
 		State(ServerStateEnabled).
 		Offset(offset).
@@ -906,6 +911,9 @@ func (this *ServerDAO) ListEnabledServersMatch(tx *dbs.Tx, offset int64, size in
 		query.Attr("clusterId", clusterId)
 		query.UseIndex("clusterId")
 	}
+	if len(serverIds) > 0 {
+		query.Attr("id", serverIds)
+	}
 	if auditingFlag == 1 {
 		query.Attr("isAuditing", true)
 	}
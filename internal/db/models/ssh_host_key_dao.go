@@ -0,0 +1,224 @@
+package models
+
+import (
+	"errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	SSHHostKeyStateEnabled  = 1 // 已启用
+	SSHHostKeyStateDisabled = 0 // 已禁用
+
+	SSHHostKeySourceTOFU       = "tofu"       // 首次连接时记录
+	SSHHostKeySourceKnownHosts = "knownHosts" // 从known_hosts导入
+	SSHHostKeySourceApproved   = "approved"   // 管理员确认
+)
+
+type SSHHostKeyDAO dbs.DAO
+
+func NewSSHHostKeyDAO() *SSHHostKeyDAO {
+	return dbs.NewDAO(&SSHHostKeyDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeSSHHostKeys",
+			Model:  new(SSHHostKey),
+			PkName: "id",
+		},
+	}).(*SSHHostKeyDAO)
+}
+
+var SharedSSHHostKeyDAO *SSHHostKeyDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedSSHHostKeyDAO = NewSSHHostKeyDAO()
+	})
+}
+
+// FindHostKey 根据节点、主机地址和端口查找公钥
+// 不同节点可能通过不同的跳板机使用相同的内网地址和端口，所以节点的公钥需要单独记录；nodeId为0表示不属于任何节点，比如跳板机
+func (this *SSHHostKeyDAO) FindHostKey(tx *dbs.Tx, nodeId int64, host string, port int) (*SSHHostKey, error) {
+	if nodeId < 0 {
+		nodeId = 0
+	}
+	one, err := this.Query(tx).
+		Attr("nodeId", nodeId).
+		Attr("host", host).
+		Attr("port", port).
+		State(SSHHostKeyStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*SSHHostKey), nil
+}
+
+// FindAllHostKeysWithNodeId 查找某个节点相关的所有公钥
+func (this *SSHHostKeyDAO) FindAllHostKeysWithNodeId(tx *dbs.Tx, nodeId int64) (result []*SSHHostKey, err error) {
+	_, err = this.Query(tx).
+		Attr("nodeId", nodeId).
+		State(SSHHostKeyStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// PinHostKey 记录主机公钥
+func (this *SSHHostKeyDAO) PinHostKey(tx *dbs.Tx, nodeId int64, host string, port int, key ssh.PublicKey, source string) error {
+	if len(host) == 0 || port <= 0 {
+		return errors.New("invalid host or port")
+	}
+	if key == nil {
+		return errors.New("'key' should not be nil")
+	}
+
+	var publicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	var fingerprint = ssh.FingerprintSHA256(key)
+
+	if nodeId < 0 {
+		nodeId = 0
+	}
+	hostKey, err := this.FindHostKey(tx, nodeId, host, port)
+	if err != nil {
+		return err
+	}
+	if hostKey != nil {
+		var op = NewSSHHostKeyOperator()
+		op.Id = hostKey.Id
+		op.KeyType = key.Type()
+		op.Fingerprint = fingerprint
+		op.PublicKey = publicKey
+		op.Source = source
+		op.PendingFingerprint = ""
+		op.PendingPublicKey = ""
+		op.PendingAt = 0
+		op.UpdatedAt = time.Now().Unix()
+		return this.Save(tx, op)
+	}
+
+	var op = NewSSHHostKeyOperator()
+	op.NodeId = nodeId
+	op.Host = host
+	op.Port = port
+	op.KeyType = key.Type()
+	op.Fingerprint = fingerprint
+	op.PublicKey = publicKey
+	op.Source = source
+	op.CreatedAt = time.Now().Unix()
+	op.UpdatedAt = time.Now().Unix()
+	op.State = SSHHostKeyStateEnabled
+	return this.Save(tx, op)
+}
+
+// UpdateHostKeyPending 记录和已有公钥不一致的新公钥，等待管理员确认
+func (this *SSHHostKeyDAO) UpdateHostKeyPending(tx *dbs.Tx, hostKeyId int64, key ssh.PublicKey) error {
+	if key == nil {
+		return errors.New("'key' should not be nil")
+	}
+	return this.Query(tx).
+		Pk(hostKeyId).
+		Set("pendingFingerprint", ssh.FingerprintSHA256(key)).
+		Set("pendingPublicKey", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))).
+		Set("pendingAt", time.Now().Unix()).
+		UpdateQuickly()
+}
+
+// ApprovePendingHostKey 确认待确认的新公钥
+func (this *SSHHostKeyDAO) ApprovePendingHostKey(tx *dbs.Tx, hostKeyId int64) error {
+	one, err := this.Query(tx).
+		Pk(hostKeyId).
+		State(SSHHostKeyStateEnabled).
+		Find()
+	if err != nil {
+		return err
+	}
+	if one == nil {
+		return errors.New("host key not found")
+	}
+	var hostKey = one.(*SSHHostKey)
+	if len(hostKey.PendingPublicKey) == 0 {
+		return errors.New("no pending host key to approve")
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey.PendingPublicKey))
+	if err != nil {
+		return errors.New("parse pending host key failed: " + err.Error())
+	}
+	return this.PinHostKey(tx, int64(hostKey.NodeId), hostKey.Host, int(hostKey.Port), key, SSHHostKeySourceApproved)
+}
+
+// CheckHostKeyWithNodeId 检查公钥是否属于某个节点
+func (this *SSHHostKeyDAO) CheckHostKeyWithNodeId(tx *dbs.Tx, hostKeyId int64, nodeId int64) (bool, error) {
+	return this.Query(tx).
+		Pk(hostKeyId).
+		Attr("nodeId", nodeId).
+		State(SSHHostKeyStateEnabled).
+		Exist()
+}
+
+// ResetHostKeysWithNodeId 清除某个节点记录的公钥，下次连接时重新记录
+func (this *SSHHostKeyDAO) ResetHostKeysWithNodeId(tx *dbs.Tx, nodeId int64) error {
+	if nodeId <= 0 {
+		return nil
+	}
+	_, err := this.Query(tx).
+		Attr("nodeId", nodeId).
+		Delete()
+	return err
+}
+
+// ImportKnownHosts 从known_hosts格式的内容中导入公钥
+// 不支持哈希过的主机名和通配符
+func (this *SSHHostKeyDAO) ImportKnownHosts(tx *dbs.Tx, nodeId int64, data []byte) (count int, err error) {
+	for len(data) > 0 {
+		var marker string
+		var hosts []string
+		var key ssh.PublicKey
+		marker, hosts, key, _, data, err = ssh.ParseKnownHosts(data)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, errors.New("parse known hosts failed: " + err.Error())
+		}
+		if len(marker) > 0 {
+			continue
+		}
+		for _, host := range hosts {
+			if strings.HasPrefix(host, "|") || strings.ContainsAny(host, "*?!") {
+				continue
+			}
+			hostname, port := this.splitKnownHost(host)
+			if len(hostname) == 0 {
+				continue
+			}
+			err = this.PinHostKey(tx, nodeId, hostname, port, key, SSHHostKeySourceKnownHosts)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return
+}
+
+// 分析known_hosts中的主机名，格式为 host 或者 [host]:port
+func (this *SSHHostKeyDAO) splitKnownHost(host string) (hostname string, port int) {
+	if strings.HasPrefix(host, "[") {
+		h, p, err := net.SplitHostPort(host)
+		if err != nil {
+			return "", 0
+		}
+		return h, types.Int(p)
+	}
+	return host, 22
+}
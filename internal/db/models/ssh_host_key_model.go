@@ -0,0 +1,59 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+const (
+	SSHHostKeyField_Id                 dbs.FieldName = "id"                 // ID
+	SSHHostKeyField_NodeId             dbs.FieldName = "nodeId"             // 节点ID
+	SSHHostKeyField_Host               dbs.FieldName = "host"               // 主机地址
+	SSHHostKeyField_Port               dbs.FieldName = "port"               // 端口
+	SSHHostKeyField_KeyType            dbs.FieldName = "keyType"            // 公钥类型
+	SSHHostKeyField_Fingerprint        dbs.FieldName = "fingerprint"        // 公钥指纹
+	SSHHostKeyField_PublicKey          dbs.FieldName = "publicKey"          // 公钥
+	SSHHostKeyField_Source             dbs.FieldName = "source"             // 来源：tofu, knownHosts, approved
+	SSHHostKeyField_PendingFingerprint dbs.FieldName = "pendingFingerprint" // 待确认的公钥指纹
+	SSHHostKeyField_PendingPublicKey   dbs.FieldName = "pendingPublicKey"   // 待确认的公钥
+	SSHHostKeyField_PendingAt          dbs.FieldName = "pendingAt"          // 发现公钥变化的时间
+	SSHHostKeyField_CreatedAt          dbs.FieldName = "createdAt"          // 创建时间
+	SSHHostKeyField_UpdatedAt          dbs.FieldName = "updatedAt"          // 修改时间
+	SSHHostKeyField_State              dbs.FieldName = "state"              // 状态
+)
+
+// SSHHostKey SSH主机公钥
+type SSHHostKey struct {
+	Id                 uint64 `field:"id"`                 // ID
+	NodeId             uint64 `field:"nodeId"`             // 节点ID
+	Host               string `field:"host"`               // 主机地址
+	Port               uint32 `field:"port"`               // 端口
+	KeyType            string `field:"keyType"`            // 公钥类型
+	Fingerprint        string `field:"fingerprint"`        // 公钥指纹
+	PublicKey          string `field:"publicKey"`          // 公钥
+	Source             string `field:"source"`             // 来源：tofu, knownHosts, approved
+	PendingFingerprint string `field:"pendingFingerprint"` // 待确认的公钥指纹
+	PendingPublicKey   string `field:"pendingPublicKey"`   // 待确认的公钥
+	PendingAt          uint64 `field:"pendingAt"`          // 发现公钥变化的时间
+	CreatedAt          uint64 `field:"createdAt"`          // 创建时间
+	UpdatedAt          uint64 `field:"updatedAt"`          // 修改时间
+	State              uint8  `field:"state"`              // 状态
+}
+
+type SSHHostKeyOperator struct {
+	Id                 any // ID
+	NodeId             any // 节点ID
+	Host               any // 主机地址
+	Port               any // 端口
+	KeyType            any // 公钥类型
+	Fingerprint        any // 公钥指纹
+	PublicKey          any // 公钥
+	Source             any // 来源：tofu, knownHosts, approved
+	PendingFingerprint any // 待确认的公钥指纹
+	PendingPublicKey   any // 待确认的公钥
+	PendingAt          any // 发现公钥变化的时间
+	CreatedAt          any // 创建时间
+	UpdatedAt          any // 修改时间
+	State              any // 状态
+}
+
+func NewSSHHostKeyOperator() *SSHHostKeyOperator {
+	return &SSHHostKeyOperator{}
+}
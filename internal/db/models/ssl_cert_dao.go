@@ -2,10 +2,14 @@ package models
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/certutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/shared"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
 	_ "github.com/go-sql-driver/mysql"
@@ -79,7 +83,12 @@ func (this *SSLCertDAO) FindEnabledSSLCert(tx *dbs.Tx, id int64) (*SSLCert, erro
 	if result == nil {
 		return nil, err
 	}
-	return result.(*SSLCert), err
+	var cert = result.(*SSLCert)
+	err = this.decodeCert(cert)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
 }
 
 // FindSSLCertName 根据主键查找名称
@@ -102,7 +111,6 @@ func (this *SSLCertDAO) CreateCert(tx *dbs.Tx, adminId int64, userId int64, isOn
 	op.ServerName = serverName
 	op.IsCA = isCA
 	op.CertData = certData
-	op.KeyData = keyData
 	op.TimeBeginAt = timeBeginAt
 	op.TimeEndAt = timeEndAt
 
@@ -120,11 +128,27 @@ func (this *SSLCertDAO) CreateCert(tx *dbs.Tx, adminId int64, userId int64, isOn
 
 	op.OcspIsUpdated = false
 
-	err = this.Save(tx, op)
+	var certId int64
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err := this.Save(tx, op)
+		if err != nil {
+			return err
+		}
+		certId = types.Int64(op.Id)
+
+		encodedKeyData, err := encrypt.EncryptSecret(keyData, this.keyDataAAD(certId))
+		if err != nil {
+			return err
+		}
+		return this.Query(tx).
+			Pk(certId).
+			Set("keyData", encodedKeyData).
+			UpdateQuickly()
+	})
 	if err != nil {
 		return 0, err
 	}
-	return types.Int64(op.Id), nil
+	return certId, nil
 }
 
 // UpdateCert 修改证书
@@ -144,7 +168,9 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx,
 		return errors.New("invalid certId")
 	}
 
-	oldOne, err := this.Query(tx).Find()
+	oldOne, err := this.Query(tx).
+		Pk(certId).
+		Find()
 	if err != nil {
 		return err
 	}
@@ -152,6 +178,10 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx,
 		return nil
 	}
 	var oldCert = oldOne.(*SSLCert)
+	err = this.decodeCert(oldCert)
+	if err != nil {
+		return err
+	}
 	var dataIsChanged = !bytes.Equal(certData, oldCert.CertData) || !bytes.Equal(keyData, oldCert.KeyData)
 
 	var op = NewSSLCertOperator()
@@ -167,7 +197,11 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx,
 		op.CertData = certData
 	}
 	if len(keyData) > 0 {
-		op.KeyData = keyData
+		encodedKeyData, err := encrypt.EncryptSecret(keyData, this.keyDataAAD(certId))
+		if err != nil {
+			return err
+		}
+		op.KeyData = encodedKeyData
 	}
 
 	op.TimeBeginAt = timeBeginAt
@@ -195,6 +229,13 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx,
 		op.OcspExpiresAt = 0
 	}
 
+	// 上传了新的证书后才不再是吊销状态，只修改名称等信息时需要保留吊销状态
+	if len(certData) > 0 && !bytes.Equal(certData, oldCert.CertData) {
+		op.IsRevoked = false
+		op.RevokedAt = 0
+		op.RevokeReason = 0
+	}
+
 	err = this.Save(tx, op)
 	if err != nil {
 		return err
@@ -230,16 +271,22 @@ func (this *SSLCertDAO) ComposeCertConfig(tx *dbs.Tx, certId int64, ignoreData b
 	config.Name = cert.Name
 	config.Description = cert.Description
 	if !ignoreData {
+		// CA证书的私钥不需要下发
+		var keyData = cert.KeyData
+		if cert.IsCA {
+			keyData = nil
+		}
+
 		if dataMap != nil {
 			if len(cert.CertData) > 0 {
 				config.CertData = dataMap.Put(cert.CertData)
 			}
-			if len(cert.KeyData) > 0 {
-				config.KeyData = dataMap.Put(cert.KeyData)
+			if len(keyData) > 0 {
+				config.KeyData = dataMap.Put(keyData)
 			}
 		} else {
 			config.CertData = cert.CertData
-			config.KeyData = cert.KeyData
+			config.KeyData = keyData
 		}
 	}
 	config.ServerName = cert.ServerName
@@ -393,6 +440,293 @@ func (this *SSLCertDAO) UpdateCertACME(tx *dbs.Tx, certId int64, acmeTaskId int6
 	return err
 }
 
+// CreateCA 创建CA证书
+func (this *SSLCertDAO) CreateCA(tx *dbs.Tx, adminId int64, userId int64, name string, description string, options *certutils.CAOptions) (int64, error) {
+	certData, keyData, err := certutils.CreateCA(options)
+	if err != nil {
+		return 0, err
+	}
+
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+		IsCA:     true,
+	}
+	err = sslConfig.Init(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	if len(name) == 0 {
+		name = options.CommonName
+	}
+	return this.CreateCert(tx, adminId, userId, true, name, description, "", true, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+}
+
+// IssueCertWithCA 使用CA证书签发新证书
+func (this *SSLCertDAO) IssueCertWithCA(tx *dbs.Tx, adminId int64, userId int64, caCertId int64, name string, description string, options *certutils.IssueOptions) (int64, error) {
+	certData, keyData, err := this.issueCert(tx, caCertId, options)
+	if err != nil {
+		return 0, err
+	}
+
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+	}
+	err = sslConfig.Init(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	if len(name) == 0 {
+		name = options.CommonName
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return 0, err
+	}
+
+	var certId int64
+	err = dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		certId, err = this.CreateCert(tx, adminId, userId, true, name, description, "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+		if err != nil {
+			return err
+		}
+
+		var op = NewSSLCertOperator()
+		op.Id = certId
+		op.IssuerCertId = caCertId
+		op.IssueOptions = optionsJSON
+		return this.Save(tx, op)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return certId, nil
+}
+
+// RenewIssuedCert 使用原来的CA和选项重新签发证书
+func (this *SSLCertDAO) RenewIssuedCert(tx *dbs.Tx, certId int64) error {
+	cert, err := this.FindEnabledSSLCert(tx, certId)
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return errors.New("can not find cert")
+	}
+	var options = cert.DecodeIssueOptions()
+	if cert.IssuerCertId == 0 || options == nil {
+		return errors.New("the cert was not issued by CA")
+	}
+
+	certData, keyData, err := this.issueCert(tx, int64(cert.IssuerCertId), options)
+	if err != nil {
+		return err
+	}
+
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+	}
+	err = sslConfig.Init(context.Background())
+	if err != nil {
+		return err
+	}
+	return dbutils.RunTx(this, tx, func(tx *dbs.Tx) error {
+		err = this.UpdateCert(tx, certId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+		if err != nil {
+			return err
+		}
+
+		var op = NewSSLCertOperator()
+		op.Id = certId
+		op.RenewFailures = 0
+		op.RenewFailedAt = 0
+		return this.Save(tx, op)
+	})
+}
+
+// UpdateIssuedCertRenewFailed 记录CA签发证书续期失败，并返回连续失败次数
+func (this *SSLCertDAO) UpdateIssuedCertRenewFailed(tx *dbs.Tx, certId int64) (failures int, err error) {
+	failures, err = this.Query(tx).
+		Pk(certId).
+		Result("renewFailures").
+		FindIntCol(0)
+	if err != nil {
+		return 0, err
+	}
+	failures++
+
+	var op = NewSSLCertOperator()
+	op.Id = certId
+	op.RenewFailures = failures
+	op.RenewFailedAt = time.Now().Unix()
+	err = this.Save(tx, op)
+	return
+}
+
+// FindAllIssuedCertsToRenew 查找需要续期的CA签发证书
+// 剩余有效期小于总有效期的三分之一时续期；续期失败的证书需要等待一段时间后再重试
+func (this *SSLCertDAO) FindAllIssuedCertsToRenew(tx *dbs.Tx) (result []*SSLCert, err error) {
+	var certs []*SSLCert
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Gt("issuerCertId", 0).
+		Where("JSON_CONTAINS(issueOptions, 'true', '$.autoRenew')").
+		Where("(timeEndAt-UNIX_TIMESTAMP())*3<(timeEndAt-timeBeginAt)").
+		Result("id", "name", "adminId", "userId", "issuerCertId", "dnsNames", "renewFailures", "renewFailedAt").
+		Slice(&certs).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var now = time.Now()
+	for _, cert := range certs {
+		if cert.RenewFailures > 0 {
+			var retryAt = time.Unix(int64(cert.RenewFailedAt), 0).Add(utils.RenewalRetryBackoff(int(cert.RenewFailures)))
+			if now.Before(retryAt) {
+				continue
+			}
+		}
+		result = append(result, cert)
+	}
+	return
+}
+
+// CountIssuedCerts 计算某个CA签发的证书数量
+func (this *SSLCertDAO) CountIssuedCerts(tx *dbs.Tx, caCertId int64) (int64, error) {
+	return this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("issuerCertId", caCertId).
+		Count()
+}
+
+// ListIssuedCerts 列出某个CA签发的证书
+func (this *SSLCertDAO) ListIssuedCerts(tx *dbs.Tx, caCertId int64, offset int64, size int64) (result []*SSLCert, err error) {
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("issuerCertId", caCertId).
+		Result("id", "name", "isOn", "timeBeginAt", "timeEndAt", "dnsNames", "issueOptions").
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// 使用CA证书签发证书
+func (this *SSLCertDAO) issueCert(tx *dbs.Tx, caCertId int64, options *certutils.IssueOptions) (certData []byte, keyData []byte, err error) {
+	caCert, err := this.FindEnabledSSLCert(tx, caCertId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if caCert == nil {
+		return nil, nil, errors.New("can not find CA cert")
+	}
+	if !caCert.IsCA || len(caCert.KeyData) == 0 {
+		return nil, nil, errors.New("the CA cert has no private key")
+	}
+	return certutils.IssueCert(caCert.CertData, caCert.KeyData, options)
+}
+
+// UpdateCertChain 修改证书链内容
+func (this *SSLCertDAO) UpdateCertChain(tx *dbs.Tx, certId int64, certData []byte) error {
+	if certId <= 0 {
+		return errors.New("invalid certId")
+	}
+	if len(certData) == 0 {
+		return errors.New("invalid certData")
+	}
+	var op = NewSSLCertOperator()
+	op.Id = certId
+	op.CertData = certData
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	return this.NotifyUpdate(tx, certId)
+}
+
+// FindAllCertsToCheckChain 查找需要检查证书链的证书
+// userId 为0时查找所有证书
+func (this *SSLCertDAO) FindAllCertsToCheckChain(tx *dbs.Tx, userId int64) (result []*SSLCert, err error) {
+	var query = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isCA", false)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	_, err = query.
+		Result("id", "name", "userId", "certData", "keyData", "isACME", "timeEndAt").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeCerts(result)
+	return
+}
+
+// UpdateCertRevoked 设置证书为已吊销
+func (this *SSLCertDAO) UpdateCertRevoked(tx *dbs.Tx, certId int64, reason uint8) error {
+	if certId <= 0 {
+		return errors.New("invalid certId")
+	}
+	var op = NewSSLCertOperator()
+	op.Id = certId
+	op.IsRevoked = true
+	op.RevokedAt = time.Now().Unix()
+	op.RevokeReason = reason
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	return this.NotifyUpdate(tx, certId)
+}
+
+// RewrapCertKeys 使用当前主密钥重新加密所有证书私钥
+func (this *SSLCertDAO) RewrapCertKeys(tx *dbs.Tx) (count int64, err error) {
+	var lastId int64
+	for {
+		var certs []*SSLCert
+		_, err = this.Query(tx).
+			Gt("id", lastId).
+			Where("LENGTH(keyData)>0").
+			Result("id", "keyData").
+			AscPk().
+			Limit(100).
+			Slice(&certs).
+			FindAll()
+		if err != nil || len(certs) == 0 {
+			return
+		}
+
+		for _, cert := range certs {
+			lastId = int64(cert.Id)
+
+			keyData, changed, rewrapErr := encrypt.SharedKeyring().Rewrap(cert.KeyData, this.keyDataAAD(int64(cert.Id)))
+			if rewrapErr != nil {
+				return count, fmt.Errorf("rewrap cert '%d' failed: %w", cert.Id, rewrapErr)
+			}
+			if !changed {
+				continue
+			}
+			err = this.Query(tx).
+				Pk(cert.Id).
+				Set("keyData", keyData).
+				UpdateQuickly()
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
 // FindAllExpiringCerts 查找需要自动更新的任务
 // 这里我们只返回有限的字段以节省内存
 func (this *SSLCertDAO) FindAllExpiringCerts(tx *dbs.Tx, days int) (result []*SSLCert, err error) {
@@ -491,6 +825,10 @@ func (this *SSLCertDAO) ListCertsToUpdateOCSP(tx *dbs.Tx, maxTries int, size int
 		Limit(size).
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeCerts(result)
 	return
 }
 
@@ -605,6 +943,10 @@ func (this *SSLCertDAO) ListSSLCertsWithOCSPError(tx *dbs.Tx, keyword string, of
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	err = this.decodeCerts(result)
 	return
 }
 
@@ -652,6 +994,35 @@ func (this *SSLCertDAO) ResetAllSSLCertsWithOCSPError(tx *dbs.Tx) error {
 		UpdateQuickly()
 }
 
+// 私钥加密时使用的附加认证数据
+func (this *SSLCertDAO) keyDataAAD(certId int64) []byte {
+	return encrypt.AAD(this.Table, "keyData", certId)
+}
+
+// 解密证书私钥
+func (this *SSLCertDAO) decodeCert(cert *SSLCert) error {
+	if cert == nil || !encrypt.IsEnvelope(cert.KeyData) {
+		return nil
+	}
+	keyData, err := encrypt.DecryptSecret(cert.KeyData, this.keyDataAAD(int64(cert.Id)))
+	if err != nil {
+		return fmt.Errorf("decrypt private key of cert '%d' failed: %w", cert.Id, err)
+	}
+	cert.KeyData = keyData
+	return nil
+}
+
+// 解密一组证书私钥
+func (this *SSLCertDAO) decodeCerts(certs []*SSLCert) error {
+	for _, cert := range certs {
+		err := this.decodeCert(cert)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // NotifyUpdate 通知更新
 func (this *SSLCertDAO) NotifyUpdate(tx *dbs.Tx, certId int64) error {
 	policyIds, err := SharedSSLPolicyDAO.FindAllEnabledPolicyIdsWithCertId(tx, certId)
@@ -32,6 +32,13 @@ type SSLCert struct {
 	OcspUpdatedVersion uint64   `field:"ocspUpdatedVersion"` // OCSP更新版本
 	OcspExpiresAt      uint64   `field:"ocspExpiresAt"`      // OCSP过期时间(UTC)
 	OcspTries          uint32   `field:"ocspTries"`          // OCSP尝试次数
+	IsRevoked          bool     `field:"isRevoked"`          // 是否已吊销
+	RevokedAt          uint64   `field:"revokedAt"`          // 吊销时间
+	RevokeReason       uint8    `field:"revokeReason"`       // 吊销原因
+	IssuerCertId       uint64   `field:"issuerCertId"`       // 签发此证书的CA证书ID
+	IssueOptions       dbs.JSON `field:"issueOptions"`       // 签发选项
+	RenewFailures      uint32   `field:"renewFailures"`      // 连续续期失败次数
+	RenewFailedAt      uint64   `field:"renewFailedAt"`      // 最后一次续期失败时间
 }
 
 type SSLCertOperator struct {
@@ -63,6 +70,13 @@ type SSLCertOperator struct {
 	OcspUpdatedVersion interface{} // OCSP更新版本
 	OcspExpiresAt      interface{} // OCSP过期时间(UTC)
 	OcspTries          interface{} // OCSP尝试次数
+	IsRevoked          interface{} // 是否已吊销
+	RevokedAt          interface{} // 吊销时间
+	RevokeReason       interface{} // 吊销原因
+	IssuerCertId       interface{} // 签发此证书的CA证书ID
+	IssueOptions       interface{} // 签发选项
+	RenewFailures      interface{} // 连续续期失败次数
+	RenewFailedAt      interface{} // 最后一次续期失败时间
 }
 
 func NewSSLCertOperator() *SSLCertOperator {
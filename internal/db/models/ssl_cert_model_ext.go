@@ -1,6 +1,9 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/certutils"
+)
 
 func (this *SSLCert) DecodeDNSNames() []string {
 	if len(this.DnsNames) == 0 {
@@ -29,3 +32,17 @@ func (this *SSLCert) DecodeCommonNames() []string {
 
 	return result
 }
+
+// DecodeIssueOptions 解析签发选项
+func (this *SSLCert) DecodeIssueOptions() *certutils.IssueOptions {
+	if len(this.IssueOptions) == 0 {
+		return nil
+	}
+
+	var options = &certutils.IssueOptions{}
+	var err = json.Unmarshal(this.IssueOptions, options)
+	if err != nil {
+		return nil
+	}
+	return options
+}
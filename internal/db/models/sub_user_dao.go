@@ -1,9 +1,14 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/types"
+	"time"
 )
 
 const (
@@ -11,6 +16,23 @@ const (
 	SubUserStateDisabled = 0 // 已禁用
 )
 
+const (
+	SubUserFeatureView   = "view"   // 查看网站
+	SubUserFeatureStat   = "stat"   // 查看统计
+	SubUserFeaturePurge  = "purge"  // 刷新和预热缓存
+	SubUserFeatureConfig = "config" // 修改网站配置
+)
+
+// AllSubUserFeatures 子用户可以使用的所有功能
+func AllSubUserFeatures() []string {
+	return []string{
+		SubUserFeatureView,
+		SubUserFeatureStat,
+		SubUserFeaturePurge,
+		SubUserFeatureConfig,
+	}
+}
+
 type SubUserDAO dbs.DAO
 
 func NewSubUserDAO() *SubUserDAO {
@@ -33,7 +55,7 @@ func init() {
 }
 
 // 启用条目
-func (this *SubUserDAO) EnableSubUser(tx *dbs.Tx, id uint32) error {
+func (this *SubUserDAO) EnableSubUser(tx *dbs.Tx, id int64) error {
 	_, err := this.Query(tx).
 		Pk(id).
 		Set("state", SubUserStateEnabled).
@@ -42,7 +64,7 @@ func (this *SubUserDAO) EnableSubUser(tx *dbs.Tx, id uint32) error {
 }
 
 // 禁用条目
-func (this *SubUserDAO) DisableSubUser(tx *dbs.Tx, id uint32) error {
+func (this *SubUserDAO) DisableSubUser(tx *dbs.Tx, id int64) error {
 	_, err := this.Query(tx).
 		Pk(id).
 		Set("state", SubUserStateDisabled).
@@ -51,7 +73,7 @@ func (this *SubUserDAO) DisableSubUser(tx *dbs.Tx, id uint32) error {
 }
 
 // 查找启用中的条目
-func (this *SubUserDAO) FindEnabledSubUser(tx *dbs.Tx, id uint32) (*SubUser, error) {
+func (this *SubUserDAO) FindEnabledSubUser(tx *dbs.Tx, id int64) (*SubUser, error) {
 	result, err := this.Query(tx).
 		Pk(id).
 		Attr("state", SubUserStateEnabled).
@@ -63,9 +85,340 @@ func (this *SubUserDAO) FindEnabledSubUser(tx *dbs.Tx, id uint32) (*SubUser, err
 }
 
 // 根据主键查找名称
-func (this *SubUserDAO) FindSubUserName(tx *dbs.Tx, id uint32) (string, error) {
+func (this *SubUserDAO) FindSubUserName(tx *dbs.Tx, id int64) (string, error) {
 	return this.Query(tx).
 		Pk(id).
 		Result("name").
 		FindStringCol("")
 }
+
+// CreateSubUser 创建子用户
+// password 为明文密码
+func (this *SubUserDAO) CreateSubUser(tx *dbs.Tx, userId int64, name string, username string, password string, features []string, serverIds []int64, serverGroupIds []int64, isOn bool) (int64, error) {
+	if userId <= 0 {
+		return 0, errors.New("invalid userId")
+	}
+	if len(password) == 0 {
+		return 0, errors.New("'password' should not be empty")
+	}
+
+	var op = NewSubUserOperator()
+	op.UserId = userId
+	op.Name = name
+	op.Username = username
+	hash, err := hashLoginPassword(tx, password)
+	if err != nil {
+		return 0, err
+	}
+	op.Password = hash
+	err = this.composeScopes(op, features, serverIds, serverGroupIds)
+	if err != nil {
+		return 0, err
+	}
+	op.IsOn = isOn
+	op.CreatedAt = time.Now().Unix()
+	op.UpdatedAt = time.Now().Unix()
+	op.State = SubUserStateEnabled
+	err = this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+	return types.Int64(op.Id), nil
+}
+
+// UpdateSubUser 修改子用户
+// password 为空时不修改密码
+func (this *SubUserDAO) UpdateSubUser(tx *dbs.Tx, subUserId int64, name string, username string, password string, features []string, serverIds []int64, serverGroupIds []int64, isOn bool) error {
+	if subUserId <= 0 {
+		return errors.New("invalid subUserId")
+	}
+
+	var op = NewSubUserOperator()
+	op.Id = subUserId
+	op.Name = name
+	op.Username = username
+	if len(password) > 0 {
+		hash, err := hashLoginPassword(tx, password)
+		if err != nil {
+			return err
+		}
+		op.Password = hash
+	}
+	err := this.composeScopes(op, features, serverIds, serverGroupIds)
+	if err != nil {
+		return err
+	}
+	op.IsOn = isOn
+	op.UpdatedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// UpdateSubUserOTP 修改子用户的OTP设置
+func (this *SubUserDAO) UpdateSubUserOTP(tx *dbs.Tx, subUserId int64, isOn bool, paramsJSON []byte) error {
+	if len(paramsJSON) == 0 {
+		paramsJSON = []byte("{}")
+	}
+	return this.Query(tx).
+		Pk(subUserId).
+		Set("otpIsOn", isOn).
+		Set("otpParams", paramsJSON).
+		UpdateQuickly()
+}
+
+// CountAllEnabledSubUsers 计算某个用户的子用户数量
+func (this *SubUserDAO) CountAllEnabledSubUsers(tx *dbs.Tx, userId int64) (int64, error) {
+	return this.Query(tx).
+		Attr("userId", userId).
+		State(SubUserStateEnabled).
+		Count()
+}
+
+// ListEnabledSubUsers 列出某个用户单页的子用户
+func (this *SubUserDAO) ListEnabledSubUsers(tx *dbs.Tx, userId int64, offset int64, size int64) (result []*SubUser, err error) {
+	_, err = this.Query(tx).
+		Attr("userId", userId).
+		State(SubUserStateEnabled).
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// ExistSubUsername 检查用户名是否已被使用
+// 子用户和主用户使用同一个登录入口，所以需要同时检查主用户
+func (this *SubUserDAO) ExistSubUsername(tx *dbs.Tx, subUserId int64, username string) (bool, error) {
+	exists, err := this.Query(tx).
+		State(SubUserStateEnabled).
+		Attr("username", username).
+		Neq("id", subUserId).
+		Exist()
+	if err != nil || exists {
+		return exists, err
+	}
+	return SharedUserDAO.ExistUser(tx, 0, username)
+}
+
+// CheckUserSubUser 检查子用户是否属于某个用户
+func (this *SubUserDAO) CheckUserSubUser(tx *dbs.Tx, userId int64, subUserId int64) error {
+	if userId <= 0 || subUserId <= 0 {
+		return ErrNotFound
+	}
+	exists, err := this.Query(tx).
+		Pk(subUserId).
+		Attr("userId", userId).
+		State(SubUserStateEnabled).
+		Exist()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CheckSubUserPassword 检查子用户名+密码
+// encryptedPassword 为客户端提交的密码MD5
+func (this *SubUserDAO) CheckSubUserPassword(tx *dbs.Tx, username string, encryptedPassword string) (subUser *SubUser, err error) {
+	if len(username) == 0 || len(encryptedPassword) == 0 {
+		return nil, nil
+	}
+	one, err := this.Query(tx).
+		Attr("username", username).
+		State(SubUserStateEnabled).
+		Attr("isOn", true).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	subUser = one.(*SubUser)
+
+	ok, newHash := verifyLoginPassword(tx, subUser.Password, encryptedPassword)
+	if !ok {
+		return nil, nil
+	}
+	if len(newHash) > 0 {
+		err = this.Query(tx).
+			Pk(subUser.Id).
+			Set("password", newHash).
+			UpdateQuickly()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 主用户需要可用
+	enabled, err := SharedUserDAO.CheckUserServersEnabled(tx, int64(subUser.UserId))
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	return subUser, nil
+}
+
+// FindSubUserWithUsername 根据用户名查找子用户
+func (this *SubUserDAO) FindSubUserWithUsername(tx *dbs.Tx, username string) (*SubUser, error) {
+	one, err := this.Query(tx).
+		Attr("username", username).
+		State(SubUserStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*SubUser), nil
+}
+
+// CheckSubUserFeature 检查子用户是否可以使用某个功能
+func (this *SubUserDAO) CheckSubUserFeature(tx *dbs.Tx, subUserId int64, feature string) error {
+	subUser, err := this.FindEnabledSubUser(tx, subUserId)
+	if err != nil {
+		return err
+	}
+	if subUser == nil || !subUser.IsOn {
+		return ErrNotFound
+	}
+	if !subUser.HasFeature(feature) {
+		return errors.New("sub user is not allowed to use feature '" + feature + "'")
+	}
+	return nil
+}
+
+// CheckSubUserServer 检查子用户是否可以对某个网站使用某个功能
+func (this *SubUserDAO) CheckSubUserServer(tx *dbs.Tx, subUserId int64, serverId int64, feature string) error {
+	subUser, err := this.FindEnabledSubUser(tx, subUserId)
+	if err != nil {
+		return err
+	}
+	if subUser == nil || !subUser.IsOn {
+		return ErrNotFound
+	}
+	if len(feature) > 0 && !subUser.HasFeature(feature) {
+		return errors.New("sub user is not allowed to use feature '" + feature + "'")
+	}
+
+	// 网站需要属于主用户
+	err = SharedServerDAO.CheckUserServer(tx, int64(subUser.UserId), serverId)
+	if err != nil {
+		return err
+	}
+
+	if lists.ContainsInt64(subUser.DecodeServerIds(), serverId) {
+		return nil
+	}
+	var allowedGroupIds = subUser.DecodeServerGroupIds()
+	if len(allowedGroupIds) > 0 {
+		groupIds, err := SharedServerDAO.FindServerGroupIds(tx, serverId)
+		if err != nil {
+			return err
+		}
+		for _, groupId := range groupIds {
+			if lists.ContainsInt64(allowedGroupIds, groupId) {
+				return nil
+			}
+		}
+	}
+	return ErrNotFound
+}
+
+// CheckSubUserServerGroup 检查子用户是否可以对某个网站分组使用某个功能
+func (this *SubUserDAO) CheckSubUserServerGroup(tx *dbs.Tx, subUserId int64, groupId int64, feature string) error {
+	subUser, err := this.FindEnabledSubUser(tx, subUserId)
+	if err != nil {
+		return err
+	}
+	if subUser == nil || !subUser.IsOn {
+		return ErrNotFound
+	}
+	if len(feature) > 0 && !subUser.HasFeature(feature) {
+		return errors.New("sub user is not allowed to use feature '" + feature + "'")
+	}
+
+	err = SharedServerGroupDAO.CheckUserGroup(tx, int64(subUser.UserId), groupId)
+	if err != nil {
+		return err
+	}
+	if !lists.ContainsInt64(subUser.DecodeServerGroupIds(), groupId) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindSubUserServerIds 查找子用户可以管理的所有网站ID，包括分组中的网站
+func (this *SubUserDAO) FindSubUserServerIds(tx *dbs.Tx, subUserId int64) ([]int64, error) {
+	subUser, err := this.FindEnabledSubUser(tx, subUserId)
+	if err != nil {
+		return nil, err
+	}
+	if subUser == nil || !subUser.IsOn {
+		return []int64{}, nil
+	}
+
+	var candidateIds = subUser.DecodeServerIds()
+	for _, groupId := range subUser.DecodeServerGroupIds() {
+		groupServerIds, err := SharedServerDAO.FindAllEnabledServerIdsWithGroupId(tx, groupId)
+		if err != nil {
+			return nil, err
+		}
+		candidateIds = append(candidateIds, groupServerIds...)
+	}
+	if len(candidateIds) == 0 {
+		return []int64{}, nil
+	}
+
+	// 网站需要属于主用户
+	ones, err := SharedServerDAO.Query(tx).
+		State(ServerStateEnabled).
+		Attr("userId", subUser.UserId).
+		Attr("id", candidateIds).
+		ResultPk().
+		AscPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var serverIds = []int64{}
+	for _, one := range ones {
+		serverIds = append(serverIds, int64(one.(*Server).Id))
+	}
+	return serverIds, nil
+}
+
+// 设置功能和网站范围
+func (this *SubUserDAO) composeScopes(op *SubUserOperator, features []string, serverIds []int64, serverGroupIds []int64) error {
+	for _, feature := range features {
+		if !lists.ContainsString(AllSubUserFeatures(), feature) {
+			return errors.New("invalid feature '" + feature + "'")
+		}
+	}
+	if features == nil {
+		features = []string{}
+	}
+	if serverIds == nil {
+		serverIds = []int64{}
+	}
+	if serverGroupIds == nil {
+		serverGroupIds = []int64{}
+	}
+
+	featuresJSON, err := json.Marshal(features)
+	if err != nil {
+		return err
+	}
+	serverIdsJSON, err := json.Marshal(serverIds)
+	if err != nil {
+		return err
+	}
+	serverGroupIdsJSON, err := json.Marshal(serverGroupIds)
+	if err != nil {
+		return err
+	}
+	op.Features = featuresJSON
+	op.ServerIds = serverIdsJSON
+	op.ServerGroupIds = serverGroupIdsJSON
+	return nil
+}
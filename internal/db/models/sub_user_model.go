@@ -1,24 +1,40 @@
 package models
 
+import "github.com/iwind/TeaGo/dbs"
+
 // SubUser 子用户
 type SubUser struct {
-	Id       uint32 `field:"id"`       // ID
-	UserId   uint32 `field:"userId"`   // 所属主用户ID
-	IsOn     bool   `field:"isOn"`     // 是否启用
-	Name     string `field:"name"`     // 名称
-	Username string `field:"username"` // 用户名
-	Password string `field:"password"` // 密码
-	State    uint8  `field:"state"`    // 状态
+	Id             uint32   `field:"id"`             // ID
+	UserId         uint32   `field:"userId"`         // 所属主用户ID
+	IsOn           bool     `field:"isOn"`           // 是否启用
+	Name           string   `field:"name"`           // 名称
+	Username       string   `field:"username"`       // 用户名
+	Password       string   `field:"password"`       // 密码
+	Features       dbs.JSON `field:"features"`       // 允许使用的功能
+	ServerIds      dbs.JSON `field:"serverIds"`      // 允许管理的网站ID
+	ServerGroupIds dbs.JSON `field:"serverGroupIds"` // 允许管理的网站分组ID
+	OtpIsOn        bool     `field:"otpIsOn"`        // 是否启用OTP
+	OtpParams      dbs.JSON `field:"otpParams"`      // OTP参数
+	CreatedAt      uint64   `field:"createdAt"`      // 创建时间
+	UpdatedAt      uint64   `field:"updatedAt"`      // 修改时间
+	State          uint8    `field:"state"`          // 状态
 }
 
 type SubUserOperator struct {
-	Id       interface{} // ID
-	UserId   interface{} // 所属主用户ID
-	IsOn     interface{} // 是否启用
-	Name     interface{} // 名称
-	Username interface{} // 用户名
-	Password interface{} // 密码
-	State    interface{} // 状态
+	Id             interface{} // ID
+	UserId         interface{} // 所属主用户ID
+	IsOn           interface{} // 是否启用
+	Name           interface{} // 名称
+	Username       interface{} // 用户名
+	Password       interface{} // 密码
+	Features       interface{} // 允许使用的功能
+	ServerIds      interface{} // 允许管理的网站ID
+	ServerGroupIds interface{} // 允许管理的网站分组ID
+	OtpIsOn        interface{} // 是否启用OTP
+	OtpParams      interface{} // OTP参数
+	CreatedAt      interface{} // 创建时间
+	UpdatedAt      interface{} // 修改时间
+	State          interface{} // 状态
 }
 
 func NewSubUserOperator() *SubUserOperator {
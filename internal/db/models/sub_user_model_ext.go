@@ -1 +1,38 @@
 package models
+
+import (
+	"encoding/json"
+	"github.com/iwind/TeaGo/lists"
+)
+
+// DecodeFeatures 解析允许使用的功能
+func (this *SubUser) DecodeFeatures() []string {
+	var features = []string{}
+	if IsNotNull(this.Features) {
+		_ = json.Unmarshal(this.Features, &features)
+	}
+	return features
+}
+
+// DecodeServerIds 解析允许管理的网站ID
+func (this *SubUser) DecodeServerIds() []int64 {
+	var serverIds = []int64{}
+	if IsNotNull(this.ServerIds) {
+		_ = json.Unmarshal(this.ServerIds, &serverIds)
+	}
+	return serverIds
+}
+
+// DecodeServerGroupIds 解析允许管理的网站分组ID
+func (this *SubUser) DecodeServerGroupIds() []int64 {
+	var groupIds = []int64{}
+	if IsNotNull(this.ServerGroupIds) {
+		_ = json.Unmarshal(this.ServerGroupIds, &groupIds)
+	}
+	return groupIds
+}
+
+// HasFeature 检查是否可以使用某个功能
+func (this *SubUser) HasFeature(feature string) bool {
+	return lists.ContainsString(this.DecodeFeatures(), feature)
+}
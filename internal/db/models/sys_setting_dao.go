@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sessionutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ssoutils"
 	"github.com/TeaOSLab/EdgeAPI/internal/zero"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
@@ -245,6 +249,116 @@ func (this *SysSettingDAO) ReadUserRegisterConfig(tx *dbs.Tx) (*userconfigs.User
 	return config, nil
 }
 
+// ReadPasswordPolicy 读取密码策略
+func (this *SysSettingDAO) ReadPasswordPolicy(tx *dbs.Tx) (*passwordutils.Policy, error) {
+	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodePasswordPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy = passwordutils.DefaultPolicy()
+	if len(valueJSON) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(valueJSON, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Init()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ReadLoginSessionPolicy 读取登录SESSION策略
+func (this *SysSettingDAO) ReadLoginSessionPolicy(tx *dbs.Tx) (*sessionutils.Policy, error) {
+	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodeLoginSessionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy = sessionutils.DefaultPolicy()
+	if len(valueJSON) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(valueJSON, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Init()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ReadLoginFailurePolicy 读取登录失败防护策略
+func (this *SysSettingDAO) ReadLoginFailurePolicy(tx *dbs.Tx) (*loginutils.Policy, error) {
+	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodeLoginFailurePolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy = loginutils.DefaultPolicy()
+	if len(valueJSON) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(valueJSON, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Init()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ReadTwoFactorPolicy 读取双因素认证策略
+func (this *SysSettingDAO) ReadTwoFactorPolicy(tx *dbs.Tx) (*loginutils.TwoFactorPolicy, error) {
+	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodeTwoFactorPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy = loginutils.DefaultTwoFactorPolicy()
+	if len(valueJSON) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(valueJSON, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Init()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ReadAdminSSOPolicy 读取管理员单点登录策略
+func (this *SysSettingDAO) ReadAdminSSOPolicy(tx *dbs.Tx) (*ssoutils.Policy, error) {
+	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodeAdminSSOPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policy = ssoutils.DefaultPolicy()
+	if len(valueJSON) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(valueJSON, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Init()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
 func (this *SysSettingDAO) ReadDatabaseConfig(tx *dbs.Tx) (config *systemconfigs.DatabaseConfig, err error) {
 	valueJSON, err := this.ReadSetting(tx, systemconfigs.SettingCodeDatabaseConfigSetting)
 	if err != nil {
@@ -15,7 +15,6 @@ import (
 	"github.com/iwind/TeaGo/lists"
 	"github.com/iwind/TeaGo/rands"
 	"github.com/iwind/TeaGo/types"
-	stringutil "github.com/iwind/TeaGo/utils/string"
 	timeutil "github.com/iwind/TeaGo/utils/time"
 )
 
@@ -212,7 +211,10 @@ func (this *UserDAO) CreateUser(tx *dbs.Tx, username string,
 	isVerified bool) (int64, error) {
 	var op = NewUserOperator()
 	op.Username = username
-	op.Password = stringutil.Md5(password)
+	err := this.composePassword(tx, 0, password, op)
+	if err != nil {
+		return 0, err
+	}
 	op.Fullname = fullname
 	op.Mobile = mobile
 	op.Tel = tel
@@ -238,7 +240,7 @@ func (this *UserDAO) CreateUser(tx *dbs.Tx, username string,
 
 	op.IsOn = true
 	op.State = UserStateEnabled
-	err := this.Save(tx, op)
+	err = this.Save(tx, op)
 	if err != nil {
 		return 0, err
 	}
@@ -255,7 +257,10 @@ func (this *UserDAO) UpdateUser(tx *dbs.Tx, userId int64, username string, passw
 	op.Id = userId
 	op.Username = username
 	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+		err := this.composePassword(tx, userId, password, op)
+		if err != nil {
+			return err
+		}
 	}
 	op.Fullname = fullname
 	op.Mobile = mobile
@@ -303,7 +308,10 @@ func (this *UserDAO) UpdateUserLogin(tx *dbs.Tx, userId int64, username string,
 	op.Id = userId
 	op.Username = username
 	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+		err := this.composePassword(tx, userId, password, op)
+		if err != nil {
+			return err
+		}
 	}
 	return this.Save(tx, op)
 }
@@ -316,7 +324,10 @@ func (this *UserDAO) UpdateUserPassword(tx *dbs.Tx, userId int64, password strin
 	var op = NewUserOperator()
 	op.Id = userId
 	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+		err := this.composePassword(tx, userId, password, op)
+		if err != nil {
+			return err
+		}
 	}
 	return this.Save(tx, op)
 }
@@ -418,44 +429,33 @@ func (this *UserDAO) ListEnabledUserIds(tx *dbs.Tx, offset, size int64) ([]int64
 
 // CheckUserPassword 检查用户名+密码
 func (this *UserDAO) CheckUserPassword(tx *dbs.Tx, username string, encryptedPassword string) (int64, error) {
-	if len(username) == 0 || len(encryptedPassword) == 0 {
-		return 0, nil
-	}
-	return this.Query(tx).
-		Attr("username", username).
-		Attr("password", encryptedPassword).
-		Attr("state", UserStateEnabled).
-		Attr("isOn", true).
-		ResultPk().
-		FindInt64Col(0)
+	return this.checkPassword(tx, "username", username, encryptedPassword)
 }
 
 // CheckUserEmailPassword 检查邮箱+密码
 func (this *UserDAO) CheckUserEmailPassword(tx *dbs.Tx, verifiedEmail string, encryptedPassword string) (int64, error) {
-	if len(verifiedEmail) == 0 || len(encryptedPassword) == 0 {
-		return 0, nil
-	}
-	return this.Query(tx).
-		Attr("verifiedEmail", verifiedEmail).
-		Attr("password", encryptedPassword).
-		Attr("state", UserStateEnabled).
-		Attr("isOn", true).
-		ResultPk().
-		FindInt64Col(0)
+	return this.checkPassword(tx, "verifiedEmail", verifiedEmail, encryptedPassword)
 }
 
-// CheckUserMobilePassword 检查邮箱+密码
-func (this *UserDAO) CheckUserMobilePassword(tx *dbs.Tx, verifiedEmail string, encryptedPassword string) (int64, error) {
-	if len(verifiedEmail) == 0 || len(encryptedPassword) == 0 {
-		return 0, nil
+// CheckUserMobilePassword 检查手机号+密码
+func (this *UserDAO) CheckUserMobilePassword(tx *dbs.Tx, verifiedMobile string, encryptedPassword string) (int64, error) {
+	return this.checkPassword(tx, "verifiedMobile", verifiedMobile, encryptedPassword)
+}
+
+// CheckUserPasswordReused 检查密码是否和最近使用过的密码重复
+func (this *UserDAO) CheckUserPasswordReused(tx *dbs.Tx, userId int64, password string, historySize int) (bool, error) {
+	if userId <= 0 || historySize <= 0 {
+		return false, nil
 	}
-	return this.Query(tx).
-		Attr("verifiedMobile", verifiedEmail).
-		Attr("password", encryptedPassword).
-		Attr("state", UserStateEnabled).
-		Attr("isOn", true).
-		ResultPk().
-		FindInt64Col(0)
+	one, err := this.Query(tx).
+		Pk(userId).
+		Result("password", "passwordHistory").
+		Find()
+	if err != nil || one == nil {
+		return false, err
+	}
+	var user = one.(*User)
+	return checkPasswordReused(user.Password, user.PasswordHistory, password, historySize), nil
 }
 
 // FindUserClusterId 查找用户所在集群
@@ -780,3 +780,69 @@ func (this *UserDAO) NotifyUpdate(tx *dbs.Tx, userId int64) error {
 
 	return nil
 }
+
+// 检查登录账号+密码
+// 如果密码使用的是旧的哈希算法，校验成功后会自动升级
+func (this *UserDAO) checkPassword(tx *dbs.Tx, field string, value string, encryptedPassword string) (int64, error) {
+	if len(value) == 0 || len(encryptedPassword) == 0 {
+		return 0, nil
+	}
+	one, err := this.Query(tx).
+		Attr(field, value).
+		Attr("state", UserStateEnabled).
+		Attr("isOn", true).
+		Result("id", "password", "passwordHistory").
+		Find()
+	if err != nil || one == nil {
+		return 0, err
+	}
+	var user = one.(*User)
+
+	ok, newHash := verifyLoginPassword(tx, user.Password, encryptedPassword)
+	if !ok {
+		return 0, nil
+	}
+	if len(newHash) > 0 {
+		historyJSON, err := composePasswordHistory(user.PasswordHistory, user.Password, newHash)
+		if err != nil {
+			return 0, err
+		}
+		var op = NewUserOperator()
+		op.Id = user.Id
+		op.Password = newHash
+		op.PasswordHistory = historyJSON
+		err = this.Save(tx, op)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(user.Id), nil
+}
+
+// 设置新密码
+// password 为明文密码
+func (this *UserDAO) composePassword(tx *dbs.Tx, userId int64, password string, op *UserOperator) error {
+	hash, err := hashLoginPassword(tx, password)
+	if err != nil {
+		return err
+	}
+
+	var oldHistoryJSON []byte
+	if userId > 0 {
+		oldHistoryJSON, err = this.Query(tx).
+			Pk(userId).
+			Result("passwordHistory").
+			FindBytesCol()
+		if err != nil {
+			return err
+		}
+	}
+	historyJSON, err := composePasswordHistory(oldHistoryJSON, "", hash)
+	if err != nil {
+		return err
+	}
+
+	op.Password = hash
+	op.PasswordHistory = historyJSON
+	return nil
+}
@@ -37,6 +37,7 @@ const (
 	UserField_BandwidthAlgo     dbs.FieldName = "bandwidthAlgo"     // 带宽算法
 	UserField_BandwidthModifier dbs.FieldName = "bandwidthModifier" // 带宽修正值
 	UserField_Lang              dbs.FieldName = "lang"              // 语言代号
+	UserField_PasswordHistory   dbs.FieldName = "passwordHistory"   // 密码历史
 )
 
 // User 用户
@@ -75,6 +76,7 @@ type User struct {
 	BandwidthAlgo     string   `field:"bandwidthAlgo"`     // 带宽算法
 	BandwidthModifier float64  `field:"bandwidthModifier"` // 带宽修正值
 	Lang              string   `field:"lang"`              // 语言代号
+	PasswordHistory   dbs.JSON `field:"passwordHistory"`   // 密码历史
 }
 
 type UserOperator struct {
@@ -112,6 +114,7 @@ type UserOperator struct {
 	BandwidthAlgo     any // 带宽算法
 	BandwidthModifier any // 带宽修正值
 	Lang              any // 语言代号
+	PasswordHistory   any // 密码历史
 }
 
 func NewUserOperator() *UserOperator {
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/webauthnutils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	WebAuthnCredentialStateEnabled  = 1 // 已启用
+	WebAuthnCredentialStateDisabled = 0 // 已禁用
+)
+
+type WebAuthnCredentialDAO dbs.DAO
+
+func NewWebAuthnCredentialDAO() *WebAuthnCredentialDAO {
+	return dbs.NewDAO(&WebAuthnCredentialDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeWebAuthnCredentials",
+			Model:  new(WebAuthnCredential),
+			PkName: "id",
+		},
+	}).(*WebAuthnCredentialDAO)
+}
+
+var SharedWebAuthnCredentialDAO *WebAuthnCredentialDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedWebAuthnCredentialDAO = NewWebAuthnCredentialDAO()
+	})
+}
+
+// CreateCredential 保存注册成功的凭证
+func (this *WebAuthnCredentialDAO) CreateCredential(tx *dbs.Tx, adminId int64, userId int64, name string, result *webauthnutils.RegistrationResult) (int64, error) {
+	if adminId <= 0 && userId <= 0 {
+		return 0, errors.New("invalid adminId and userId")
+	}
+	if result == nil {
+		return 0, errors.New("'result' should not be nil")
+	}
+
+	var credentialId = webauthnutils.EncodeBase64URL(result.CredentialId)
+	exists, err := this.Query(tx).
+		Attr("credentialId", credentialId).
+		State(WebAuthnCredentialStateEnabled).
+		Exist()
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errors.New("the credential has been registered")
+	}
+
+	var op = NewWebAuthnCredentialOperator()
+	op.AdminId = adminId
+	op.UserId = userId
+	op.Name = utils.LimitString(name, 100)
+	op.CredentialId = credentialId
+	op.PublicKey = result.PublicKey
+	op.Alg = result.Alg
+	op.SignCount = result.SignCount
+	op.Aaguid = hex.EncodeToString(result.AAGUID)
+	op.CreatedAt = time.Now().Unix()
+	op.State = WebAuthnCredentialStateEnabled
+	credentialIntId, err := this.SaveInt64(tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	err = this.syncLogin(tx, adminId, userId)
+	if err != nil {
+		return 0, err
+	}
+	return credentialIntId, nil
+}
+
+// DisableCredential 删除凭证
+func (this *WebAuthnCredentialDAO) DisableCredential(tx *dbs.Tx, credentialIntId int64) error {
+	credential, err := this.FindEnabledCredential(tx, credentialIntId)
+	if err != nil || credential == nil {
+		return err
+	}
+
+	err = this.Query(tx).
+		Pk(credentialIntId).
+		Set("state", WebAuthnCredentialStateDisabled).
+		UpdateQuickly()
+	if err != nil {
+		return err
+	}
+	return this.syncLogin(tx, int64(credential.AdminId), int64(credential.UserId))
+}
+
+// FindEnabledCredential 查找凭证
+func (this *WebAuthnCredentialDAO) FindEnabledCredential(tx *dbs.Tx, credentialIntId int64) (*WebAuthnCredential, error) {
+	one, err := this.Query(tx).
+		Pk(credentialIntId).
+		State(WebAuthnCredentialStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*WebAuthnCredential), nil
+}
+
+// FindEnabledCredentialWithCredentialId 根据凭证ID查找管理员或用户的凭证
+func (this *WebAuthnCredentialDAO) FindEnabledCredentialWithCredentialId(tx *dbs.Tx, adminId int64, userId int64, credentialId string) (*WebAuthnCredential, error) {
+	one, err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("credentialId", credentialId).
+		State(WebAuthnCredentialStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*WebAuthnCredential), nil
+}
+
+// FindAllEnabledCredentials 查找管理员或用户的所有凭证
+func (this *WebAuthnCredentialDAO) FindAllEnabledCredentials(tx *dbs.Tx, adminId int64, userId int64) (result []*WebAuthnCredential, err error) {
+	if adminId <= 0 && userId <= 0 {
+		return
+	}
+	_, err = this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		State(WebAuthnCredentialStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateCredentialUsed 登录成功后更新签名计数和使用时间
+func (this *WebAuthnCredentialDAO) UpdateCredentialUsed(tx *dbs.Tx, credentialIntId int64, signCount uint32) error {
+	return this.Query(tx).
+		Pk(credentialIntId).
+		Set("signCount", signCount).
+		Set("lastUsedAt", time.Now().Unix()).
+		UpdateQuickly()
+}
+
+// 根据凭证数量同步认证方式的开关
+func (this *WebAuthnCredentialDAO) syncLogin(tx *dbs.Tx, adminId int64, userId int64) error {
+	count, err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		State(WebAuthnCredentialStateEnabled).
+		Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return SharedLoginDAO.UpdateLogin(tx, adminId, userId, LoginTypeWebAuthn, nil, true)
+	}
+	return SharedLoginDAO.DisableLoginWithType(tx, adminId, userId, LoginTypeWebAuthn)
+}
@@ -0,0 +1,36 @@
+package models
+
+// WebAuthnCredential WebAuthn凭证（通行密钥、安全密钥等）
+type WebAuthnCredential struct {
+	Id           uint64 `field:"id"`           // ID
+	AdminId      uint64 `field:"adminId"`      // 管理员ID
+	UserId       uint64 `field:"userId"`       // 用户ID
+	Name         string `field:"name"`         // 名称
+	CredentialId string `field:"credentialId"` // 凭证ID，base64url编码
+	PublicKey    []byte `field:"publicKey"`    // 公钥，COSE格式
+	Alg          int32  `field:"alg"`          // 公钥算法
+	SignCount    uint32 `field:"signCount"`    // 签名计数
+	Aaguid       string `field:"aaguid"`       // 认证器型号
+	CreatedAt    uint64 `field:"createdAt"`    // 创建时间
+	LastUsedAt   uint64 `field:"lastUsedAt"`   // 最后使用时间
+	State        uint8  `field:"state"`        // 状态
+}
+
+type WebAuthnCredentialOperator struct {
+	Id           any // ID
+	AdminId      any // 管理员ID
+	UserId       any // 用户ID
+	Name         any // 名称
+	CredentialId any // 凭证ID，base64url编码
+	PublicKey    any // 公钥，COSE格式
+	Alg          any // 公钥算法
+	SignCount    any // 签名计数
+	Aaguid       any // 认证器型号
+	CreatedAt    any // 创建时间
+	LastUsedAt   any // 最后使用时间
+	State        any // 状态
+}
+
+func NewWebAuthnCredentialOperator() *WebAuthnCredentialOperator {
+	return &WebAuthnCredentialOperator{}
+}
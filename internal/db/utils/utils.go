@@ -27,6 +27,15 @@ func NewQuery(tx *dbs.Tx, dao dbs.DAOWrapper, adminId int64, userId int64) *dbs.
 	return query
 }
 
+// RunTx 在事务中执行操作
+// 如果传入的tx不为空，说明已经在事务中，则直接使用此事务
+func RunTx(dao dbs.DAOWrapper, tx *dbs.Tx, callback func(tx *dbs.Tx) error) error {
+	if tx != nil {
+		return callback(tx)
+	}
+	return dao.Object().Instance.RunTx(callback)
+}
+
 // QuoteLikeKeyword 处理关键词中的特殊字符
 func QuoteLikeKeyword(keyword string) string {
 	keyword = strings.ReplaceAll(keyword, "%", "\\%")
@@ -19,5 +19,7 @@ type RecordListResponse struct {
 		Line   string `json:"line"`
 		LineId string `json:"line_id"`
 		TTL    string `json:"ttl"`
+		MX     string `json:"mx"`
+		Weight any    `json:"weight"`
 	} `json:"records"`
 }
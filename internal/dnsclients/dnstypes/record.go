@@ -1,5 +1,10 @@
 package dnstypes
 
+import (
+	"strconv"
+	"strings"
+)
+
 type RecordType = string
 
 const (
@@ -7,25 +12,90 @@ const (
 	RecordTypeAAAA  RecordType = "AAAA"
 	RecordTypeCNAME RecordType = "CNAME"
 	RecordTypeTXT   RecordType = "TXT"
+	RecordTypeMX    RecordType = "MX"
+	RecordTypeSRV   RecordType = "SRV"
+	RecordTypeCAA   RecordType = "CAA"
+	RecordTypeNS    RecordType = "NS"
+	RecordTypeHTTPS RecordType = "HTTPS"
+	RecordTypeSVCB  RecordType = "SVCB"
+)
+
+// 服务商专有标记
+const (
+	RecordFlagProxied = "proxied" // CloudFlare代理（橙色云朵）
 )
 
+// FindAllRecordTypes 所有支持的记录类型
+func FindAllRecordTypes() []RecordType {
+	return []RecordType{
+		RecordTypeA,
+		RecordTypeAAAA,
+		RecordTypeCNAME,
+		RecordTypeTXT,
+		RecordTypeMX,
+		RecordTypeSRV,
+		RecordTypeCAA,
+		RecordTypeNS,
+		RecordTypeHTTPS,
+		RecordTypeSVCB,
+	}
+}
+
+// IsValidRecordType 判断记录类型是否支持
+func IsValidRecordType(recordType RecordType) bool {
+	for _, t := range FindAllRecordTypes() {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPriority 判断记录类型是否有优先级
+func HasPriority(recordType RecordType) bool {
+	switch recordType {
+	case RecordTypeMX, RecordTypeSRV, RecordTypeHTTPS, RecordTypeSVCB:
+		return true
+	}
+	return false
+}
+
+// HasHostValue 判断记录值是否为主机名，主机名需要以点（.）结尾
+func HasHostValue(recordType RecordType) bool {
+	switch recordType {
+	case RecordTypeCNAME, RecordTypeMX, RecordTypeNS:
+		return true
+	}
+	return false
+}
+
+// Record 解析记录
+// 对于MX、SRV、HTTPS、SVCB记录，Value中不包含优先级，优先级单独放在Priority中；
+// 对于SRV记录，Value格式为"端口 目标主机"，权重放在Weight中；
+// 对于其他记录，Weight表示服务商的负载均衡权重（如果服务商支持的话）
 type Record struct {
-	Id    string     `json:"id"`
-	Name  string     `json:"name"`
-	Type  RecordType `json:"type"`
-	Value string     `json:"value"`
-	Route string     `json:"route"`
-	TTL   int32      `json:"ttl"`
+	Id       string         `json:"id"`
+	Name     string         `json:"name"`
+	Type     RecordType     `json:"type"`
+	Value    string         `json:"value"`
+	Route    string         `json:"route"`
+	TTL      int32          `json:"ttl"`
+	Priority int32          `json:"priority,omitempty"` // 优先级
+	Weight   int32          `json:"weight,omitempty"`   // 权重
+	Flags    map[string]any `json:"flags,omitempty"`    // 服务商专有标记
 }
 
 func (this *Record) Clone() *Record {
 	return &Record{
-		Id:    this.Id,
-		Name:  this.Name,
-		Type:  this.Type,
-		Value: this.Value,
-		Route: this.Route,
-		TTL:   this.TTL,
+		Id:       this.Id,
+		Name:     this.Name,
+		Type:     this.Type,
+		Value:    this.Value,
+		Route:    this.Route,
+		TTL:      this.TTL,
+		Priority: this.Priority,
+		Weight:   this.Weight,
+		Flags:    this.cloneFlags(),
 	}
 }
 
@@ -39,4 +109,94 @@ func (this *Record) Copy(anotherRecord *Record) {
 	this.Value = anotherRecord.Value
 	this.Route = anotherRecord.Route
 	this.TTL = anotherRecord.TTL
+	this.Priority = anotherRecord.Priority
+	this.Weight = anotherRecord.Weight
+	this.Flags = anotherRecord.cloneFlags()
+}
+
+// BoolFlag 读取布尔型标记
+func (this *Record) BoolFlag(flag string) bool {
+	if this.Flags == nil {
+		return false
+	}
+	v, ok := this.Flags[flag]
+	if !ok || v == nil {
+		return false
+	}
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "1" || b == "true"
+	case float64:
+		return b != 0
+	case int:
+		return b != 0
+	}
+	return false
+}
+
+// SetFlag 设置标记
+func (this *Record) SetFlag(flag string, value any) {
+	if this.Flags == nil {
+		this.Flags = map[string]any{}
+	}
+	this.Flags[flag] = value
+}
+
+// RData 获取符合RFC 1035格式的记录数据，即在Value前面加上优先级和权重（如果有的话）
+func (this *Record) RData() string {
+	switch this.Type {
+	case RecordTypeMX, RecordTypeHTTPS, RecordTypeSVCB:
+		return strconv.Itoa(int(this.Priority)) + " " + this.Value
+	case RecordTypeSRV:
+		return strconv.Itoa(int(this.Priority)) + " " + strconv.Itoa(int(this.Weight)) + " " + this.Value
+	}
+	return this.Value
+}
+
+// SetRData 从RFC 1035格式的记录数据中解析出优先级、权重和记录值
+// 需要在设置Type之后调用
+func (this *Record) SetRData(rdata string) {
+	rdata = strings.TrimSpace(rdata)
+
+	var fields []string
+	switch this.Type {
+	case RecordTypeMX, RecordTypeHTTPS, RecordTypeSVCB:
+		fields = strings.SplitN(rdata, " ", 2)
+		if len(fields) == 2 {
+			priority, err := strconv.Atoi(fields[0])
+			if err == nil {
+				this.Priority = int32(priority)
+				this.Value = strings.TrimSpace(fields[1])
+				return
+			}
+		}
+	case RecordTypeSRV:
+		fields = strings.Fields(rdata)
+		if len(fields) == 4 {
+			priority, err := strconv.Atoi(fields[0])
+			if err == nil {
+				weight, err := strconv.Atoi(fields[1])
+				if err == nil {
+					this.Priority = int32(priority)
+					this.Weight = int32(weight)
+					this.Value = fields[2] + " " + fields[3]
+					return
+				}
+			}
+		}
+	}
+	this.Value = rdata
+}
+
+func (this *Record) cloneFlags() map[string]any {
+	if this.Flags == nil {
+		return nil
+	}
+	var result = map[string]any{}
+	for k, v := range this.Flags {
+		result[k] = v
+	}
+	return result
 }
@@ -0,0 +1,76 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnstypes_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestRecord_RData(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeA, Value: "192.168.1.100"}
+		a.IsTrue(record.RData() == "192.168.1.100")
+	}
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeMX, Value: "mail.example.com.", Priority: 10}
+		a.IsTrue(record.RData() == "10 mail.example.com.")
+	}
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeSRV, Value: "5060 sip.example.com.", Priority: 1, Weight: 5}
+		a.IsTrue(record.RData() == "1 5 5060 sip.example.com.")
+	}
+}
+
+func TestRecord_SetRData(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeMX}
+		record.SetRData("10 mail.example.com.")
+		a.IsTrue(record.Priority == 10)
+		a.IsTrue(record.Value == "mail.example.com.")
+	}
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeSRV}
+		record.SetRData("1 5 5060 sip.example.com.")
+		a.IsTrue(record.Priority == 1)
+		a.IsTrue(record.Weight == 5)
+		a.IsTrue(record.Value == "5060 sip.example.com.")
+	}
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeHTTPS}
+		record.SetRData(`1 . alpn="h2,h3"`)
+		a.IsTrue(record.Priority == 1)
+		a.IsTrue(record.Value == `. alpn="h2,h3"`)
+	}
+	{
+		// 格式错误时原样保存
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeMX}
+		record.SetRData("mail.example.com.")
+		a.IsTrue(record.Priority == 0)
+		a.IsTrue(record.Value == "mail.example.com.")
+	}
+	{
+		var record = &dnstypes.Record{Type: dnstypes.RecordTypeCAA}
+		record.SetRData(`0 issue "letsencrypt.org"`)
+		a.IsTrue(record.Value == `0 issue "letsencrypt.org"`)
+	}
+}
+
+func TestRecord_Flags(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var record = &dnstypes.Record{Type: dnstypes.RecordTypeA}
+	a.IsFalse(record.BoolFlag(dnstypes.RecordFlagProxied))
+	record.SetFlag(dnstypes.RecordFlagProxied, true)
+	a.IsTrue(record.BoolFlag(dnstypes.RecordFlagProxied))
+
+	var clonedRecord = record.Clone()
+	clonedRecord.SetFlag(dnstypes.RecordFlagProxied, false)
+	a.IsTrue(record.BoolFlag(dnstypes.RecordFlagProxied))
+	a.IsFalse(clonedRecord.BoolFlag(dnstypes.RecordFlagProxied))
+}
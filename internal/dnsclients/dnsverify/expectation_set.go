@@ -0,0 +1,81 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsverify
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/lists"
+	"strings"
+)
+
+// ExpectationSet 合并多个变更的期望结果
+type ExpectationSet struct {
+	m    map[string]*Expectation // name|type => *Expectation
+	keys []string
+}
+
+// NewExpectationSet 获取新对象
+func NewExpectationSet() *ExpectationSet {
+	return &ExpectationSet{
+		m: map[string]*Expectation{},
+	}
+}
+
+// AddPresent 添加必须出现的值
+func (this *ExpectationSet) AddPresent(name string, recordType dnstypes.RecordType, value string) {
+	var expectation = this.find(name, recordType)
+	value = normalizeValue(recordType, value)
+	if !lists.ContainsString(expectation.Present, value) {
+		expectation.Present = append(expectation.Present, value)
+	}
+}
+
+// AddAbsent 添加不能出现的值
+func (this *ExpectationSet) AddAbsent(name string, recordType dnstypes.RecordType, value string) {
+	var expectation = this.find(name, recordType)
+	value = normalizeValue(recordType, value)
+	if !lists.ContainsString(expectation.Absent, value) {
+		expectation.Absent = append(expectation.Absent, value)
+	}
+}
+
+// All 获取所有期望结果
+// 同时出现在Present和Absent中的值以Present为准
+func (this *ExpectationSet) All() []*Expectation {
+	var result = []*Expectation{}
+	for _, key := range this.keys {
+		var expectation = this.m[key]
+		var absent = []string{}
+		for _, value := range expectation.Absent {
+			if !lists.ContainsString(expectation.Present, value) {
+				absent = append(absent, value)
+			}
+		}
+		expectation.Absent = absent
+		if len(expectation.Present) == 0 && len(expectation.Absent) == 0 {
+			continue
+		}
+		result = append(result, expectation)
+	}
+	return result
+}
+
+// Len 期望结果数量
+func (this *ExpectationSet) Len() int {
+	return len(this.keys)
+}
+
+func (this *ExpectationSet) find(name string, recordType dnstypes.RecordType) *Expectation {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	var key = name + "|" + recordType
+	expectation, ok := this.m[key]
+	if !ok {
+		expectation = &Expectation{
+			Name: name,
+			Type: recordType,
+		}
+		this.m[key] = expectation
+		this.keys = append(this.keys, key)
+	}
+	return expectation
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsverify
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+// Expectation 期望的解析结果
+type Expectation struct {
+	Name    string              `json:"name"`    // 完整域名
+	Type    dnstypes.RecordType `json:"type"`    // 记录类型，支持A、AAAA、CNAME
+	Present []string            `json:"present"` // 必须出现的值
+	Absent  []string            `json:"absent"`  // 不能出现的值
+}
+
+// Result 检查结果
+type Result struct {
+	Expectation *Expectation `json:"expectation"`
+	IsOk        bool         `json:"isOk"`
+	Server      string       `json:"server"`  // 结果不一致的权威服务器
+	Answers     []string     `json:"answers"` // 权威服务器返回的值
+	Error       string       `json:"error"`   // 查询错误
+}
+
+// Verifier 向权威服务器查询记录是否已经生效
+type Verifier struct {
+	zone    string
+	servers []string // ip:port
+	client  *dns.Client
+}
+
+// NewVerifier 获取新对象
+func NewVerifier(zone string) *Verifier {
+	return &Verifier{
+		zone: dns.Fqdn(strings.ToLower(zone)),
+		client: &dns.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// SetServers 设置权威服务器地址
+func (this *Verifier) SetServers(servers []string) {
+	this.servers = servers
+}
+
+// Servers 获取权威服务器地址
+func (this *Verifier) Servers() []string {
+	return this.servers
+}
+
+// FindServers 查找域名的权威服务器地址
+func (this *Verifier) FindServers() error {
+	nsList, err := net.LookupNS(this.zone)
+	if err != nil {
+		return err
+	}
+
+	var servers = []string{}
+	for _, ns := range nsList {
+		addrs, err := net.LookupHost(strings.TrimSuffix(ns.Host, "."))
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			servers = append(servers, net.JoinHostPort(addr, "53"))
+		}
+	}
+	if len(servers) == 0 {
+		return errors.New("can not find authoritative nameservers for '" + this.zone + "'")
+	}
+	this.servers = servers
+	return nil
+}
+
+// Check 检查所有权威服务器上的结果，只返回尚未生效的记录
+func (this *Verifier) Check(expectations []*Expectation) (lagging []*Result, err error) {
+	if len(this.servers) == 0 {
+		err = this.FindServers()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, expectation := range expectations {
+		for _, server := range this.servers {
+			var result = this.checkServer(server, expectation)
+			if !result.IsOk {
+				lagging = append(lagging, result)
+				break
+			}
+		}
+	}
+	return
+}
+
+// Wait 持续检查，直到所有记录生效或者超时
+// 返回超时时仍未生效的记录
+func (this *Verifier) Wait(expectations []*Expectation, timeout time.Duration, interval time.Duration) (lagging []*Result, err error) {
+	var deadline = time.Now().Add(timeout)
+	var pending = expectations
+	for {
+		lagging, err = this.Check(pending)
+		if err != nil {
+			return nil, err
+		}
+		if len(lagging) == 0 || time.Now().Add(interval).After(deadline) {
+			return
+		}
+
+		// 下次只检查未生效的
+		pending = []*Expectation{}
+		for _, result := range lagging {
+			pending = append(pending, result.Expectation)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// 检查单个权威服务器
+func (this *Verifier) checkServer(server string, expectation *Expectation) *Result {
+	var result = &Result{
+		Expectation: expectation,
+		Server:      server,
+	}
+
+	var qType uint16
+	switch expectation.Type {
+	case dnstypes.RecordTypeA:
+		qType = dns.TypeA
+	case dnstypes.RecordTypeAAAA:
+		qType = dns.TypeAAAA
+	case dnstypes.RecordTypeCNAME:
+		qType = dns.TypeCNAME
+	default:
+		result.IsOk = true
+		return result
+	}
+
+	var m = new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(expectation.Name), qType)
+	m.RecursionDesired = false
+
+	r, _, err := this.client.Exchange(m, server)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		result.Error = "unexpected rcode '" + dns.RcodeToString[r.Rcode] + "'"
+		return result
+	}
+
+	var answersMap = map[string]bool{}
+	for _, answer := range r.Answer {
+		var value string
+		switch rr := answer.(type) {
+		case *dns.A:
+			if qType != dns.TypeA {
+				continue
+			}
+			value = rr.A.String()
+		case *dns.AAAA:
+			if qType != dns.TypeAAAA {
+				continue
+			}
+			value = rr.AAAA.String()
+		case *dns.CNAME:
+			if qType != dns.TypeCNAME {
+				continue
+			}
+			value = rr.Target
+		default:
+			continue
+		}
+		value = normalizeValue(expectation.Type, value)
+		answersMap[value] = true
+		result.Answers = append(result.Answers, value)
+	}
+
+	for _, value := range expectation.Present {
+		if !answersMap[normalizeValue(expectation.Type, value)] {
+			return result
+		}
+	}
+	for _, value := range expectation.Absent {
+		if answersMap[normalizeValue(expectation.Type, value)] {
+			return result
+		}
+	}
+
+	result.IsOk = true
+	return result
+}
+
+func normalizeValue(recordType dnstypes.RecordType, value string) string {
+	switch recordType {
+	case dnstypes.RecordTypeA, dnstypes.RecordTypeAAAA:
+		var ip = net.ParseIP(value)
+		if ip != nil {
+			return ip.String()
+		}
+	case dnstypes.RecordTypeCNAME:
+		return strings.ToLower(dns.Fqdn(value))
+	}
+	return value
+}
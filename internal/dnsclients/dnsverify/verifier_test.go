@@ -0,0 +1,93 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsverify_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnsverify"
+	"github.com/iwind/TeaGo/assert"
+	"github.com/miekg/dns"
+	"net"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T) (addr string, stop func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mux = dns.NewServeMux()
+	mux.HandleFunc("example.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		var m = new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		var question = req.Question[0]
+		switch {
+		case question.Name == "cdn.example.com." && question.Qtype == dns.TypeA:
+			rr, _ := dns.NewRR("cdn.example.com. 600 IN A 192.168.1.100")
+			m.Answer = append(m.Answer, rr)
+			rr, _ = dns.NewRR("cdn.example.com. 600 IN A 192.168.1.101")
+			m.Answer = append(m.Answer, rr)
+		case question.Name == "www.example.com." && question.Qtype == dns.TypeCNAME:
+			rr, _ := dns.NewRR("www.example.com. 600 IN CNAME cdn.example.com.")
+			m.Answer = append(m.Answer, rr)
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	var server = &dns.Server{PacketConn: conn, Handler: mux}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	return conn.LocalAddr().String(), func() {
+		_ = server.Shutdown()
+	}
+}
+
+func TestVerifier_Check(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	addr, stop := startTestServer(t)
+	defer stop()
+
+	var verifier = dnsverify.NewVerifier("example.com")
+	verifier.SetServers([]string{addr})
+
+	var set = dnsverify.NewExpectationSet()
+	set.AddPresent("cdn.example.com", dnstypes.RecordTypeA, "192.168.1.100")
+	set.AddAbsent("cdn.example.com", dnstypes.RecordTypeA, "192.168.1.102")
+	set.AddPresent("www.example.com", dnstypes.RecordTypeCNAME, "CDN.example.com")
+	lagging, err := verifier.Check(set.All())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(len(lagging) == 0)
+
+	set = dnsverify.NewExpectationSet()
+	set.AddPresent("cdn.example.com", dnstypes.RecordTypeA, "192.168.1.103")
+	set.AddAbsent("cdn.example.com", dnstypes.RecordTypeA, "192.168.1.101")
+	set.AddPresent("api.example.com", dnstypes.RecordTypeCNAME, "cdn.example.com.")
+	lagging, err = verifier.Wait(set.All(), 500*time.Millisecond, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range lagging {
+		t.Logf("%+v %+v", result.Expectation, result)
+	}
+	a.IsTrue(len(lagging) == 2)
+}
+
+func TestExpectationSet(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var set = dnsverify.NewExpectationSet()
+	set.AddAbsent("cdn.example.com.", dnstypes.RecordTypeA, "192.168.1.100")
+	set.AddPresent("cdn.example.com", dnstypes.RecordTypeA, "192.168.1.100")
+	set.AddAbsent("old.example.com", dnstypes.RecordTypeA, "192.168.1.101")
+	var expectations = set.All()
+	a.IsTrue(len(expectations) == 2)
+	a.IsTrue(len(expectations[0].Present) == 1 && len(expectations[0].Absent) == 0)
+	a.IsTrue(len(expectations[1].Absent) == 1)
+}
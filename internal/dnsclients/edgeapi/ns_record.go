@@ -0,0 +1,23 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package edgeapi
+
+// NSRecord 接口返回的记录信息
+type NSRecord struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	TTL         int32  `json:"ttl"`
+	Weight      int32  `json:"weight"`
+	MXPriority  int32  `json:"mxPriority"`
+	SRVPriority int32  `json:"srvPriority"`
+	SRVWeight   int32  `json:"srvWeight"`
+	SRVPort     int32  `json:"srvPort"`
+	CAAFlag     int32  `json:"caaFlag"`
+	CAATag      string `json:"caaTag"`
+	NSRoutes    []struct {
+		Name string `json:"name"`
+		Code string `json:"code"`
+	} `json:"nsRoutes"`
+}
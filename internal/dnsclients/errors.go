@@ -0,0 +1,107 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"errors"
+	alierrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	tencenterrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatusError 服务商接口返回的HTTP状态码错误
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func NewStatusError(statusCode int, message string) *StatusError {
+	return &StatusError{
+		StatusCode: statusCode,
+		Message:    message,
+	}
+}
+
+func (this *StatusError) Error() string {
+	return "response error: status code: " + strconv.Itoa(this.StatusCode) + ", response data: " + this.Message
+}
+
+// IsRetryableError 判断错误是否为可以重试的临时错误
+// 包括：429、5xx、网络超时、服务商返回的限流或内部错误
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// HTTP状态码
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	// 阿里云
+	var aliErr *alierrors.ServerError
+	if errors.As(err, &aliErr) {
+		var code = aliErr.ErrorCode()
+		return aliErr.HttpStatus() == http.StatusTooManyRequests ||
+			aliErr.HttpStatus() >= 500 ||
+			strings.HasPrefix(code, "Throttling") ||
+			code == "ServiceUnavailable" ||
+			code == "InternalError"
+	}
+
+	// 腾讯云
+	var tencentErr *tencenterrors.TencentCloudSDKError
+	if errors.As(err, &tencentErr) {
+		var code = tencentErr.Code
+		return strings.HasPrefix(code, "RequestLimitExceeded") ||
+			strings.HasPrefix(code, "InternalError") ||
+			code == "ClientError.NetworkError" ||
+			code == "ClientError.HttpStatusCodeError"
+	}
+
+	// 网络错误
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var errString = err.Error()
+	return strings.Contains(errString, "connection reset by peer") ||
+		strings.Contains(errString, "connection refused") ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsRejectedError 判断错误是否表示请求在生效前就被拒绝，比如429和服务商的限流错误
+// 修改类的调用只有在这种情况下才可以安全重试
+func IsRejectedError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// HTTP状态码
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	// 阿里云
+	var aliErr *alierrors.ServerError
+	if errors.As(err, &aliErr) {
+		return aliErr.HttpStatus() == http.StatusTooManyRequests ||
+			strings.HasPrefix(aliErr.ErrorCode(), "Throttling")
+	}
+
+	// 腾讯云
+	var tencentErr *tencenterrors.TencentCloudSDKError
+	if errors.As(err, &tencentErr) {
+		return strings.HasPrefix(tencentErr.Code, "RequestLimitExceeded")
+	}
+
+	return false
+}
@@ -10,6 +10,7 @@ type ZoneRecordSetsResponse struct {
 		Ttl     int      `json:"ttl"`
 		Records []string `json:"records"`
 		Line    string   `json:"line"`
+		Weight  int      `json:"weight"`
 	} `json:"recordsets"`
 	Metadata struct {
 		TotalCount int `json:"total_count"`
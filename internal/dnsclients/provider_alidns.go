@@ -96,18 +96,28 @@ func (this *AliDNSProvider) GetRecords(domain string) (records []*dnstypes.Recor
 		}
 		for _, record := range resp.DomainRecords.Record {
 			// 修正Record
-			if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Value, ".") {
+			if dnstypes.HasHostValue(record.Type) && !strings.HasSuffix(record.Value, ".") {
 				record.Value += "."
 			}
 
-			records = append(records, &dnstypes.Record{
+			var newRecord = &dnstypes.Record{
 				Id:    record.RecordId,
 				Name:  record.RR,
 				Type:  record.Type,
-				Value: record.Value,
 				Route: record.Line,
 				TTL:   types.Int32(record.TTL),
-			})
+			}
+			switch record.Type {
+			case dnstypes.RecordTypeMX:
+				newRecord.Value = record.Value
+				newRecord.Priority = types.Int32(record.Priority)
+			case dnstypes.RecordTypeSRV, dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+				newRecord.SetRData(record.Value)
+			default:
+				newRecord.Value = record.Value
+				newRecord.Weight = types.Int32(record.Weight)
+			}
+			records = append(records, newRecord)
 		}
 
 		pageNumber++
@@ -193,13 +203,16 @@ func (this *AliDNSProvider) AddRecord(domain string, newRecord *dnstypes.Record)
 	var req = alidns.CreateAddDomainRecordRequest()
 	req.RR = newRecord.Name
 	req.Type = newRecord.Type
-	req.Value = newRecord.Value
+	req.Value = this.recordValue(newRecord)
 	req.DomainName = domain
 	req.Line = newRecord.Route
 
 	if newRecord.TTL > 0 {
 		req.TTL = requests.NewInteger(types.Int(newRecord.TTL))
 	}
+	if newRecord.Type == dnstypes.RecordTypeMX {
+		req.Priority = requests.NewInteger(types.Int(newRecord.Priority))
+	}
 
 	var resp = alidns.CreateAddDomainRecordResponse()
 	err := this.doAPI(req, resp)
@@ -226,12 +239,15 @@ func (this *AliDNSProvider) UpdateRecord(domain string, record *dnstypes.Record,
 	req.RecordId = record.Id
 	req.RR = newRecord.Name
 	req.Type = newRecord.Type
-	req.Value = newRecord.Value
+	req.Value = this.recordValue(newRecord)
 	req.Line = newRecord.Route
 
 	if newRecord.TTL > 0 {
 		req.TTL = requests.NewInteger(types.Int(newRecord.TTL))
 	}
+	if newRecord.Type == dnstypes.RecordTypeMX {
+		req.Priority = requests.NewInteger(types.Int(newRecord.Priority))
+	}
 
 	var resp = alidns.CreateUpdateDomainRecordResponse()
 	err := this.doAPI(req, resp)
@@ -273,6 +289,14 @@ func (this *AliDNSProvider) DefaultRoute() string {
 	return "default"
 }
 
+// 阿里云的SRV、HTTPS、SVCB记录值中需要包含优先级，而MX记录的优先级是单独的参数
+func (this *AliDNSProvider) recordValue(record *dnstypes.Record) string {
+	if record.Type == dnstypes.RecordTypeMX {
+		return record.Value
+	}
+	return record.RData()
+}
+
 // 执行请求
 func (this *AliDNSProvider) doAPI(req requests.AcsRequest, resp responses.AcsResponse) error {
 	req.SetScheme("https")
@@ -286,7 +310,7 @@ func (this *AliDNSProvider) doAPI(req requests.AcsRequest, resp responses.AcsRes
 		return err
 	}
 	if !resp.IsSuccess() {
-		return errors.New(resp.GetHttpContentString())
+		return NewStatusError(resp.GetHttpStatus(), resp.GetHttpContentString())
 	}
 	return nil
 }
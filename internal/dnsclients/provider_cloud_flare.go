@@ -119,20 +119,7 @@ func (this *CloudFlareProvider) GetRecords(domain string) (records []*dnstypes.R
 		}
 
 		for _, record := range resp.Result {
-			// 修正Record
-			if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Content, ".") {
-				record.Content += "."
-			}
-
-			record.Name = strings.TrimSuffix(record.Name, "."+domain)
-
-			records = append(records, &dnstypes.Record{
-				Id:    record.Id,
-				Name:  record.Name,
-				Type:  record.Type,
-				Value: record.Content,
-				Route: CloudFlareDefaultRoute,
-			})
+			records = append(records, this.convertRecord(domain, record))
 		}
 	}
 
@@ -167,23 +154,7 @@ func (this *CloudFlareProvider) QueryRecord(domain string, name string, recordTy
 		return nil, nil
 	}
 
-	var record = resp.Result[0]
-
-	// 修正Record
-	if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Content, ".") {
-		record.Content += "."
-	}
-
-	record.Name = strings.TrimSuffix(record.Name, "."+domain)
-
-	return &dnstypes.Record{
-		Id:    record.Id,
-		Name:  record.Name,
-		Type:  record.Type,
-		Value: record.Content,
-		TTL:   types.Int32(record.Ttl),
-		Route: CloudFlareDefaultRoute,
-	}, nil
+	return this.convertRecord(domain, resp.Result[0]), nil
 }
 
 // QueryRecords 查询多个记录
@@ -207,21 +178,7 @@ func (this *CloudFlareProvider) QueryRecords(domain string, name string, recordT
 	}
 
 	for _, record := range resp.Result {
-		// 修正Record
-		if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Content, ".") {
-			record.Content += "."
-		}
-
-		record.Name = strings.TrimSuffix(record.Name, "."+domain)
-
-		records = append(records, &dnstypes.Record{
-			Id:    record.Id,
-			Name:  record.Name,
-			Type:  record.Type,
-			Value: record.Content,
-			TTL:   types.Int32(record.Ttl),
-			Route: CloudFlareDefaultRoute,
-		})
+		records = append(records, this.convertRecord(domain, record))
 	}
 	return records, nil
 }
@@ -234,18 +191,7 @@ func (this *CloudFlareProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 	}
 
 	resp := new(cloudflare.CreateDNSRecordResponse)
-
-	var ttl = newRecord.TTL
-	if ttl <= 0 {
-		ttl = 1 // 自动默认
-	}
-
-	err = this.doAPI(http.MethodPost, "zones/"+zoneId+"/dns_records", nil, maps.Map{
-		"type":    newRecord.Type,
-		"name":    newRecord.Name + "." + domain,
-		"content": newRecord.Value,
-		"ttl":     ttl,
-	}, resp)
+	err = this.doAPI(http.MethodPost, "zones/"+zoneId+"/dns_records", nil, this.recordBody(domain, newRecord), resp)
 	if err != nil {
 		return this.WrapError(err, domain, newRecord)
 	}
@@ -259,18 +205,8 @@ func (this *CloudFlareProvider) UpdateRecord(domain string, record *dnstypes.Rec
 		return this.WrapError(err, domain, newRecord)
 	}
 
-	var ttl = newRecord.TTL
-	if ttl <= 0 {
-		ttl = 1 // 自动默认
-	}
-
 	resp := new(cloudflare.UpdateDNSRecordResponse)
-	return this.doAPI(http.MethodPut, "zones/"+zoneId+"/dns_records/"+record.Id, nil, maps.Map{
-		"type":    newRecord.Type,
-		"name":    newRecord.Name + "." + domain,
-		"content": newRecord.Value,
-		"ttl":     ttl,
-	}, resp)
+	return this.doAPI(http.MethodPut, "zones/"+zoneId+"/dns_records/"+record.Id, nil, this.recordBody(domain, newRecord), resp)
 }
 
 // DeleteRecord 删除记录
@@ -293,6 +229,109 @@ func (this *CloudFlareProvider) DefaultRoute() string {
 	return CloudFlareDefaultRoute
 }
 
+// 转换API返回的记录
+func (this *CloudFlareProvider) convertRecord(domain string, record *cloudflare.DNSRecord) *dnstypes.Record {
+	// 修正Record
+	if dnstypes.HasHostValue(record.Type) && !strings.HasSuffix(record.Content, ".") {
+		record.Content += "."
+	}
+
+	var name = strings.TrimSuffix(record.Name, "."+domain)
+	if name == domain {
+		name = "@"
+	}
+
+	var result = &dnstypes.Record{
+		Id:    record.Id,
+		Name:  name,
+		Type:  record.Type,
+		Value: record.Content,
+		TTL:   types.Int32(record.Ttl),
+		Route: CloudFlareDefaultRoute,
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		result.Priority = types.Int32(record.Priority)
+	case dnstypes.RecordTypeSRV:
+		result.Priority = types.Int32(record.Data.Priority)
+		result.Weight = types.Int32(record.Data.Weight)
+		result.Value = types.String(record.Data.Port) + " " + strings.TrimSuffix(record.Data.Target, ".") + "."
+	case dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		result.Priority = types.Int32(record.Data.Priority)
+		result.Value = strings.TrimSpace(record.Data.Target + " " + record.Data.Value)
+	}
+
+	if record.Proxied {
+		result.SetFlag(dnstypes.RecordFlagProxied, true)
+	}
+
+	return result
+}
+
+// 构造记录请求数据
+func (this *CloudFlareProvider) recordBody(domain string, record *dnstypes.Record) maps.Map {
+	var ttl = record.TTL
+	if ttl <= 0 {
+		ttl = 1 // 自动默认
+	}
+
+	var fullname = domain
+	if len(record.Name) > 0 && record.Name != "@" {
+		fullname = record.Name + "." + domain
+	}
+
+	var body = maps.Map{
+		"type": record.Type,
+		"name": fullname,
+		"ttl":  ttl,
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		body["content"] = record.Value
+		body["priority"] = record.Priority
+	case dnstypes.RecordTypeSRV:
+		// Value格式为：端口 目标主机
+		var port, target, _ = strings.Cut(record.Value, " ")
+		body["data"] = maps.Map{
+			"priority": record.Priority,
+			"weight":   record.Weight,
+			"port":     types.Int(port),
+			"target":   strings.TrimSpace(target),
+		}
+	case dnstypes.RecordTypeCAA:
+		// Value格式为：flags tag "value"
+		var pieces = strings.SplitN(record.Value, " ", 3)
+		if len(pieces) == 3 {
+			body["data"] = maps.Map{
+				"flags": types.Int(pieces[0]),
+				"tag":   pieces[1],
+				"value": strings.Trim(pieces[2], "\""),
+			}
+		} else {
+			body["content"] = record.Value
+		}
+	case dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		// Value格式为：目标主机 参数
+		var target, value, _ = strings.Cut(record.Value, " ")
+		body["data"] = maps.Map{
+			"priority": record.Priority,
+			"target":   target,
+			"value":    strings.TrimSpace(value),
+		}
+	default:
+		body["content"] = record.Value
+	}
+
+	// 只有A、AAAA、CNAME支持代理
+	if record.Type == dnstypes.RecordTypeA || record.Type == dnstypes.RecordTypeAAAA || record.Type == dnstypes.RecordTypeCNAME {
+		body["proxied"] = record.BoolFlag(dnstypes.RecordFlagProxied)
+	}
+
+	return body
+}
+
 // 执行API
 func (this *CloudFlareProvider) doAPI(method string, apiPath string, args map[string]string, bodyMap maps.Map, respPtr cloudflare.ResponseInterface) error {
 	apiURL := CloudFlareAPIEndpoint + strings.TrimLeft(apiPath, "/")
@@ -341,7 +380,7 @@ func (this *CloudFlareProvider) doAPI(method string, apiPath string, args map[st
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("response error: " + string(data))
+		return NewStatusError(resp.StatusCode, string(data))
 	}
 
 	err = json.Unmarshal(data, respPtr)
@@ -209,7 +209,7 @@ func (this *CustomHTTPProvider) post(params maps.Map) (respData []byte, err erro
 		_ = resp.Body.Close()
 	}()
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("status should be 200, but got '" + strconv.Itoa(resp.StatusCode) + "'")
+		return nil, NewStatusError(resp.StatusCode, "status should be 200, but got '"+strconv.Itoa(resp.StatusCode)+"'")
 	}
 	return io.ReadAll(resp.Body)
 }
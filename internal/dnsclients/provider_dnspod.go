@@ -138,14 +138,24 @@ func (this *DNSPodProvider) GetRecords(domain string) (records []*dnstypes.Recor
 
 		// 记录
 		for _, record := range resp.Records {
-			records = append(records, &dnstypes.Record{
+			var newRecord = &dnstypes.Record{
 				Id:    types.String(record.Id),
 				Name:  record.Name,
 				Type:  record.Type,
-				Value: record.Value,
 				Route: record.Line,
 				TTL:   types.Int32(record.TTL),
-			})
+			}
+			switch record.Type {
+			case dnstypes.RecordTypeMX:
+				newRecord.Value = record.Value
+				newRecord.Priority = types.Int32(record.MX)
+			case dnstypes.RecordTypeSRV, dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+				newRecord.SetRData(record.Value)
+			default:
+				newRecord.Value = record.Value
+				newRecord.Weight = types.Int32(record.Weight)
+			}
+			records = append(records, newRecord)
 		}
 
 		// 检查是否到头
@@ -312,8 +322,8 @@ func (this *DNSPodProvider) AddRecord(domain string, newRecord *dnstypes.Record)
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	// 在CNAME等记录后面加入点
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -327,6 +337,7 @@ func (this *DNSPodProvider) AddRecord(domain string, newRecord *dnstypes.Record)
 	if newRecord.TTL > 0 && newRecord.TTL <= DNSPodMaxTTL {
 		args["ttl"] = types.String(newRecord.TTL)
 	}
+	this.fillRecordArgs(args, newRecord)
 	var resp = new(dnspod.RecordCreateResponse)
 	err := this.doAPI("/Record.Create", args, resp)
 	if err != nil {
@@ -355,8 +366,8 @@ func (this *DNSPodProvider) UpdateRecord(domain string, record *dnstypes.Record,
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	// 在CNAME等记录后面加入点
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -371,6 +382,7 @@ func (this *DNSPodProvider) UpdateRecord(domain string, record *dnstypes.Record,
 	if newRecord.TTL > 0 && newRecord.TTL <= DNSPodMaxTTL {
 		args["ttl"] = types.String(newRecord.TTL)
 	}
+	this.fillRecordArgs(args, newRecord)
 	var resp = new(dnspod.RecordModifyResponse)
 	err := this.doAPI("/Record.Modify", args, resp)
 	if err != nil {
@@ -476,6 +488,26 @@ func (this *DNSPodProvider) DefaultRoute() string {
 	return "默认"
 }
 
+// SupportsWeight 是否支持权重
+func (this *DNSPodProvider) SupportsWeight() bool {
+	return true
+}
+
+// 设置优先级、权重等附加参数
+func (this *DNSPodProvider) fillRecordArgs(args map[string]string, record *dnstypes.Record) {
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		// 0 是有效的优先级（比如 RFC 7505 中的空MX记录），所以这里不设置默认值
+		args["mx"] = types.String(record.Priority)
+	case dnstypes.RecordTypeSRV, dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		args["value"] = record.RData()
+	default:
+		if record.Weight > 0 {
+			args["weight"] = types.String(record.Weight)
+		}
+	}
+}
+
 func (this *DNSPodProvider) isInternational() bool {
 	return this.region == DNSPodInternational
 }
@@ -137,19 +137,7 @@ func (this *EdgeDNSAPIProvider) GetRecords(domain string) (records []*dnstypes.R
 
 		var nsRecords = recordsResp.Data.NSRecords
 		for _, record := range nsRecords {
-			var routeCode = this.DefaultRoute()
-			if len(record.NSRoutes) > 0 {
-				routeCode = record.NSRoutes[0].Code
-			}
-
-			records = append(records, &dnstypes.Record{
-				Id:    types.String(record.Id),
-				Name:  record.Name,
-				Type:  record.Type,
-				Value: record.Value,
-				Route: routeCode,
-				TTL:   record.TTL,
-			})
+			records = append(records, this.convertRecord(record))
 		}
 
 		if len(nsRecords) < size {
@@ -276,23 +264,11 @@ func (this *EdgeDNSAPIProvider) QueryRecord(domain string, name string, recordTy
 	}
 
 	var record = recordResp.Data.NSRecord
-	if record.Id <= 0 {
+	if record == nil || record.Id <= 0 {
 		return nil, nil
 	}
 
-	var routeCode = this.DefaultRoute()
-	if len(record.NSRoutes) > 0 {
-		routeCode = record.NSRoutes[0].Code
-	}
-
-	return &dnstypes.Record{
-		Id:    types.String(record.Id),
-		Name:  record.Name,
-		Type:  record.Type,
-		Value: record.Value,
-		Route: routeCode,
-		TTL:   record.TTL,
-	}, nil
+	return this.convertRecord(record), nil
 }
 
 // QueryRecords 查询多个记录
@@ -326,19 +302,7 @@ func (this *EdgeDNSAPIProvider) QueryRecords(domain string, name string, recordT
 			return nil, nil
 		}
 
-		var routeCode = this.DefaultRoute()
-		if len(record.NSRoutes) > 0 {
-			routeCode = record.NSRoutes[0].Code
-		}
-
-		result = append(result, &dnstypes.Record{
-			Id:    types.String(record.Id),
-			Name:  record.Name,
-			Type:  record.Type,
-			Value: record.Value,
-			Route: routeCode,
-			TTL:   record.TTL,
-		})
+		result = append(result, this.convertRecord(record))
 	}
 	return result, nil
 }
@@ -358,7 +322,7 @@ func (this *EdgeDNSAPIProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 		return errors.New("can not find domain '" + domain + "'")
 	}
 
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -367,14 +331,16 @@ func (this *EdgeDNSAPIProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 	if len(newRecord.Route) > 0 {
 		routes = []string{newRecord.Route}
 	}
-	err = this.doAPI("/NSRecordService/CreateNSRecord", map[string]any{
+	var params = map[string]any{
 		"nsDomainId":   domainId,
 		"name":         newRecord.Name,
 		"type":         strings.ToUpper(newRecord.Type),
 		"value":        newRecord.Value,
 		"ttl":          newRecord.TTL,
 		"nsRouteCodes": routes,
-	}, createResp)
+	}
+	this.fillRecordParams(params, newRecord)
+	err = this.doAPI("/NSRecordService/CreateNSRecord", params, createResp)
 
 	if err != nil {
 		return err
@@ -387,7 +353,7 @@ func (this *EdgeDNSAPIProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 
 // UpdateRecord 修改记录
 func (this *EdgeDNSAPIProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -396,7 +362,7 @@ func (this *EdgeDNSAPIProvider) UpdateRecord(domain string, record *dnstypes.Rec
 	if len(newRecord.Route) > 0 {
 		routes = []string{newRecord.Route}
 	}
-	err := this.doAPI("/NSRecordService/UpdateNSRecord", map[string]any{
+	var params = map[string]any{
 		"nsRecordId":   types.Int64(record.Id),
 		"name":         newRecord.Name,
 		"type":         strings.ToUpper(newRecord.Type),
@@ -404,7 +370,9 @@ func (this *EdgeDNSAPIProvider) UpdateRecord(domain string, record *dnstypes.Rec
 		"ttl":          newRecord.TTL,
 		"nsRouteCodes": routes,
 		"isOn":         true, // important
-	}, createResp)
+	}
+	this.fillRecordParams(params, newRecord)
+	err := this.doAPI("/NSRecordService/UpdateNSRecord", params, createResp)
 
 	return err
 }
@@ -423,6 +391,67 @@ func (this *EdgeDNSAPIProvider) DefaultRoute() string {
 	return "default"
 }
 
+// SupportsWeight 是否支持权重
+func (this *EdgeDNSAPIProvider) SupportsWeight() bool {
+	return true
+}
+
+// 转换API返回的记录
+func (this *EdgeDNSAPIProvider) convertRecord(nsRecord *edgeapi.NSRecord) *dnstypes.Record {
+	var routeCode = this.DefaultRoute()
+	if len(nsRecord.NSRoutes) > 0 {
+		routeCode = nsRecord.NSRoutes[0].Code
+	}
+
+	var record = &dnstypes.Record{
+		Id:     types.String(nsRecord.Id),
+		Name:   nsRecord.Name,
+		Type:   nsRecord.Type,
+		Value:  nsRecord.Value,
+		Route:  routeCode,
+		TTL:    nsRecord.TTL,
+		Weight: nsRecord.Weight,
+	}
+
+	switch nsRecord.Type {
+	case dnstypes.RecordTypeMX:
+		record.Priority = nsRecord.MXPriority
+	case dnstypes.RecordTypeSRV:
+		record.Priority = nsRecord.SRVPriority
+		record.Weight = nsRecord.SRVWeight
+		record.Value = types.String(nsRecord.SRVPort) + " " + nsRecord.Value
+	case dnstypes.RecordTypeCAA:
+		record.Value = types.String(nsRecord.CAAFlag) + " " + nsRecord.CAATag + " \"" + strings.Trim(nsRecord.Value, "\"") + "\""
+	}
+
+	return record
+}
+
+// 设置优先级、权重等附加参数
+func (this *EdgeDNSAPIProvider) fillRecordParams(params map[string]any, record *dnstypes.Record) {
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		params["mxPriority"] = record.Priority
+	case dnstypes.RecordTypeSRV:
+		// Value格式为：端口 目标主机
+		var port, target, _ = strings.Cut(record.Value, " ")
+		params["srvPriority"] = record.Priority
+		params["srvWeight"] = record.Weight
+		params["srvPort"] = types.Int32(port)
+		params["value"] = strings.TrimSpace(target)
+	case dnstypes.RecordTypeCAA:
+		// Value格式为：flags tag "value"
+		var pieces = strings.SplitN(record.Value, " ", 3)
+		if len(pieces) == 3 {
+			params["caaFlag"] = types.Int32(pieces[0])
+			params["caaTag"] = pieces[1]
+			params["value"] = strings.Trim(pieces[2], "\"")
+		}
+	default:
+		params["weight"] = record.Weight
+	}
+}
+
 func (this *EdgeDNSAPIProvider) doAPI(path string, params map[string]any, respPtr edgeapi.ResponseInterface) error {
 	accessToken, err := this.getToken()
 	if err != nil {
@@ -451,7 +480,7 @@ func (this *EdgeDNSAPIProvider) doAPI(path string, params map[string]any, respPt
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("invalid response status code '" + types.String(resp.StatusCode) + "'")
+		return NewStatusError(resp.StatusCode, "invalid response status code '"+types.String(resp.StatusCode)+"'")
 	}
 
 	data, err := io.ReadAll(resp.Body)
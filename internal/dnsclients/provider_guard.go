@@ -0,0 +1,440 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断中
+var ErrCircuitOpen = errors.New("dns provider circuit breaker is open, too many failures recently")
+
+// GuardConfig 服务商接口调用保护设置
+type GuardConfig struct {
+	RateLimit        float64 `json:"rateLimit"`        // 每秒最多请求数，0表示不限制
+	Burst            int     `json:"burst"`            // 允许的突发请求数
+	MaxRetries       int     `json:"maxRetries"`       // 最多重试次数
+	MinBackoffMs     int     `json:"minBackoffMs"`     // 最小退避时间（毫秒）
+	MaxBackoffMs     int     `json:"maxBackoffMs"`     // 最大退避时间（毫秒）
+	BreakerFailures  int     `json:"breakerFailures"`  // 连续失败多少次后熔断，0表示不熔断
+	BreakerTimeoutMs int     `json:"breakerTimeoutMs"` // 熔断持续时间（毫秒）
+}
+
+// DefaultGuardConfig 默认的调用保护设置
+func DefaultGuardConfig() *GuardConfig {
+	return &GuardConfig{
+		RateLimit:        10,
+		Burst:            10,
+		MaxRetries:       3,
+		MinBackoffMs:     500,
+		MaxBackoffMs:     10_000,
+		BreakerFailures:  10,
+		BreakerTimeoutMs: 60_000,
+	}
+}
+
+// DecodeGuardConfig 从JSON中解析调用保护设置，未设置的选项使用默认值
+func DecodeGuardConfig(configJSON []byte) (*GuardConfig, error) {
+	var config = DefaultGuardConfig()
+	if len(configJSON) == 0 || string(configJSON) == "null" {
+		return config, nil
+	}
+	err := json.Unmarshal(configJSON, config)
+	if err != nil {
+		return DefaultGuardConfig(), err
+	}
+	return config, nil
+}
+
+// 计算第N次重试之前需要等待的时间，采用指数退避加随机抖动
+func (this *GuardConfig) backoff(attempt int) time.Duration {
+	var minBackoff = this.MinBackoffMs
+	if minBackoff <= 0 {
+		minBackoff = 100
+	}
+	var maxBackoff = this.MaxBackoffMs
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	var d = minBackoff
+	for i := 0; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	// 随机抖动：[d/2, d]
+	var half = d / 2
+	return time.Duration(half+rand.Intn(d-half+1)) * time.Millisecond
+}
+
+// 同一个服务商账号在所有实例之间共享的状态
+type guardState struct {
+	locker sync.Mutex
+
+	tokens     float64
+	refilledAt time.Time
+
+	failures  int
+	openUntil time.Time
+	probing   bool // 熔断时间结束后，是否已经有一个试探调用正在进行
+
+	stats map[string]*GuardMethodStat // method => stat
+}
+
+// GuardMethodStat 接口调用统计
+type GuardMethodStat struct {
+	ProviderId   int64  `json:"providerId"`
+	ProviderType string `json:"providerType"`
+	Method       string `json:"method"`
+	CountCalls   int64  `json:"countCalls"`   // 调用次数
+	CountFails   int64  `json:"countFails"`   // 最终失败次数
+	CountRetries int64  `json:"countRetries"` // 重试次数
+	CountRejects int64  `json:"countRejects"` // 因为熔断而被拒绝的次数
+	CostMs       int64  `json:"costMs"`       // 总耗时（毫秒）
+	LastError    string `json:"lastError"`    // 最后一次错误
+	LastCalledAt int64  `json:"lastCalledAt"` // 最后调用时间
+}
+
+var guardStateMap = map[int64]*guardState{} // providerId => *guardState
+var guardStateLocker = sync.Mutex{}
+
+func findGuardState(providerId int64) *guardState {
+	guardStateLocker.Lock()
+	defer guardStateLocker.Unlock()
+
+	state, ok := guardStateMap[providerId]
+	if !ok {
+		state = &guardState{
+			tokens:     -1,
+			refilledAt: time.Now(),
+			stats:      map[string]*GuardMethodStat{},
+		}
+		guardStateMap[providerId] = state
+	}
+	return state
+}
+
+// FindAllGuardStats 获取所有服务商接口调用统计
+// 如果providerId大于0，则只返回对应服务商的统计
+func FindAllGuardStats(providerId int64) []*GuardMethodStat {
+	guardStateLocker.Lock()
+	var states = []*guardState{}
+	for stateProviderId, state := range guardStateMap {
+		if providerId <= 0 || stateProviderId == providerId {
+			states = append(states, state)
+		}
+	}
+	guardStateLocker.Unlock()
+
+	var result = []*GuardMethodStat{}
+	for _, state := range states {
+		state.locker.Lock()
+		for _, stat := range state.stats {
+			var statCopy = *stat
+			result = append(result, &statCopy)
+		}
+		state.locker.Unlock()
+	}
+	return result
+}
+
+// GuardedProvider 对服务商接口调用进行限速、重试和熔断保护
+type GuardedProvider struct {
+	raw          ProviderInterface
+	providerId   int64
+	providerType ProviderType
+	config       *GuardConfig
+	state        *guardState
+}
+
+// NewGuardedProvider 获取新的保护对象
+func NewGuardedProvider(raw ProviderInterface, providerType ProviderType, providerId int64, config *GuardConfig) *GuardedProvider {
+	if config == nil {
+		config = DefaultGuardConfig()
+	}
+	return &GuardedProvider{
+		raw:          raw,
+		providerId:   providerId,
+		providerType: providerType,
+		config:       config,
+		state:        findGuardState(providerId),
+	}
+}
+
+// FindGuardedProvider 查找服务商实例，并加上调用保护
+func FindGuardedProvider(providerType ProviderType, providerId int64, guardConfigJSON []byte) ProviderInterface {
+	var raw = FindProvider(providerType, providerId)
+	if raw == nil {
+		return nil
+	}
+
+	config, err := DecodeGuardConfig(guardConfigJSON)
+	if err != nil {
+		remotelogs.Error("dnsclients.GuardedProvider", "decode guard config for provider '"+types.String(providerId)+"' failed: "+err.Error())
+	}
+	return NewGuardedProvider(raw, providerType, providerId, config)
+}
+
+// Raw 获取原始服务商实例
+func (this *GuardedProvider) Raw() ProviderInterface {
+	return this.raw
+}
+
+// Auth 认证
+func (this *GuardedProvider) Auth(params maps.Map) error {
+	// 认证通常不涉及网络请求，所以不做保护
+	return this.raw.Auth(params)
+}
+
+// MaskParams 对参数进行掩码
+func (this *GuardedProvider) MaskParams(params maps.Map) {
+	this.raw.MaskParams(params)
+}
+
+// GetDomains 获取所有域名列表
+func (this *GuardedProvider) GetDomains() (domains []string, err error) {
+	err = this.call("GetDomains", true, func() error {
+		domains, err = this.raw.GetDomains()
+		return err
+	})
+	return
+}
+
+// GetRecords 获取域名解析记录列表
+func (this *GuardedProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	err = this.call("GetRecords", true, func() error {
+		records, err = this.raw.GetRecords(domain)
+		return err
+	})
+	return
+}
+
+// GetRoutes 读取域名支持的线路数据
+func (this *GuardedProvider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	err = this.call("GetRoutes", true, func() error {
+		routes, err = this.raw.GetRoutes(domain)
+		return err
+	})
+	return
+}
+
+// QueryRecord 查询单个记录
+func (this *GuardedProvider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (record *dnstypes.Record, err error) {
+	err = this.call("QueryRecord", true, func() error {
+		record, err = this.raw.QueryRecord(domain, name, recordType)
+		return err
+	})
+	return
+}
+
+// QueryRecords 查询多个记录
+func (this *GuardedProvider) QueryRecords(domain string, name string, recordType dnstypes.RecordType) (records []*dnstypes.Record, err error) {
+	err = this.call("QueryRecords", true, func() error {
+		records, err = this.raw.QueryRecords(domain, name, recordType)
+		return err
+	})
+	return
+}
+
+// AddRecord 设置记录
+func (this *GuardedProvider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	return this.call("AddRecord", false, func() error {
+		return this.raw.AddRecord(domain, newRecord)
+	})
+}
+
+// UpdateRecord 修改记录
+func (this *GuardedProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	return this.call("UpdateRecord", false, func() error {
+		return this.raw.UpdateRecord(domain, record, newRecord)
+	})
+}
+
+// DeleteRecord 删除记录
+func (this *GuardedProvider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	return this.call("DeleteRecord", false, func() error {
+		return this.raw.DeleteRecord(domain, record)
+	})
+}
+
+// DefaultRoute 默认线路
+func (this *GuardedProvider) DefaultRoute() string {
+	return this.raw.DefaultRoute()
+}
+
+// SupportsWeight 是否支持权重
+func (this *GuardedProvider) SupportsWeight() bool {
+	return SupportsWeight(this.raw)
+}
+
+// SetMinTTL 设置最小TTL
+func (this *GuardedProvider) SetMinTTL(ttl int32) {
+	this.raw.SetMinTTL(ttl)
+}
+
+// MinTTL 最小TTL
+func (this *GuardedProvider) MinTTL() int32 {
+	return this.raw.MinTTL()
+}
+
+// 执行调用
+// isReadOnly 表示是否为只读调用，修改类的调用失败时可能已经生效，所以只在请求被拒绝（比如限流）时才重试
+func (this *GuardedProvider) call(method string, isReadOnly bool, f func() error) error {
+	var before = time.Now()
+
+	// 检查熔断
+	allowed, isProbe := this.allow()
+	if !allowed {
+		this.record(method, 0, ErrCircuitOpen, true, false, 0)
+		return ErrCircuitOpen
+	}
+
+	// 试探调用只执行一次
+	var maxRetries = this.config.MaxRetries
+	if isProbe {
+		maxRetries = 0
+	}
+
+	var checkRetryable = IsRetryableError
+	if !isReadOnly {
+		checkRetryable = IsRejectedError
+	}
+
+	var err error
+	var retries int
+	for attempt := 0; ; attempt++ {
+		this.wait()
+
+		err = f()
+		if err == nil || !checkRetryable(err) || attempt >= maxRetries {
+			break
+		}
+
+		retries++
+		time.Sleep(this.config.backoff(attempt))
+	}
+
+	this.record(method, retries, err, false, isProbe, time.Since(before))
+	return err
+}
+
+// 检查是否允许调用
+// 熔断时间结束后进入半开状态，只允许一个试探调用，试探成功后才恢复正常
+func (this *GuardedProvider) allow() (allowed bool, isProbe bool) {
+	if this.config.BreakerFailures <= 0 {
+		return true, false
+	}
+
+	this.state.locker.Lock()
+	defer this.state.locker.Unlock()
+
+	if this.state.openUntil.IsZero() {
+		return true, false
+	}
+	if time.Now().Before(this.state.openUntil) || this.state.probing {
+		return false, false
+	}
+	this.state.probing = true
+	return true, true
+}
+
+// 根据限速设置等待令牌
+func (this *GuardedProvider) wait() {
+	if this.config.RateLimit <= 0 {
+		return
+	}
+
+	var burst = float64(this.config.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	for {
+		this.state.locker.Lock()
+		var now = time.Now()
+		if this.state.tokens < 0 { // 初始化
+			this.state.tokens = burst
+		} else {
+			this.state.tokens += now.Sub(this.state.refilledAt).Seconds() * this.config.RateLimit
+			if this.state.tokens > burst {
+				this.state.tokens = burst
+			}
+		}
+		this.state.refilledAt = now
+
+		if this.state.tokens >= 1 {
+			this.state.tokens--
+			this.state.locker.Unlock()
+			return
+		}
+
+		var delay = time.Duration((1 - this.state.tokens) / this.config.RateLimit * float64(time.Second))
+		this.state.locker.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+// 记录调用结果
+func (this *GuardedProvider) record(method string, retries int, err error, rejected bool, isProbe bool, cost time.Duration) {
+	this.state.locker.Lock()
+	defer this.state.locker.Unlock()
+
+	stat, ok := this.state.stats[method]
+	if !ok {
+		stat = &GuardMethodStat{
+			ProviderId:   this.providerId,
+			ProviderType: this.providerType,
+			Method:       method,
+		}
+		this.state.stats[method] = stat
+	}
+	stat.CountCalls++
+	stat.CountRetries += int64(retries)
+	stat.CostMs += cost.Milliseconds()
+	stat.LastCalledAt = time.Now().Unix()
+
+	if rejected {
+		stat.CountRejects++
+		stat.CountFails++
+		return
+	}
+
+	if isProbe {
+		this.state.probing = false
+	}
+
+	if err != nil {
+		stat.CountFails++
+		stat.LastError = err.Error()
+
+		// 只有临时错误才计入熔断，试探调用失败时重新熔断
+		if IsRetryableError(err) {
+			this.state.failures++
+			if this.config.BreakerFailures > 0 && (isProbe || this.state.failures >= this.config.BreakerFailures) {
+				this.state.openUntil = time.Now().Add(time.Duration(this.config.BreakerTimeoutMs) * time.Millisecond)
+				this.state.failures = 0
+				remotelogs.Warn("dnsclients.GuardedProvider", "provider '"+types.String(this.providerId)+"' circuit breaker opened after too many failures, last error: "+err.Error())
+			}
+			return
+		}
+
+		// 非临时错误说明服务商可以正常响应
+		if !isProbe {
+			return
+		}
+	}
+
+	this.state.failures = 0
+	this.state.openUntil = time.Time{}
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/assert"
+	"github.com/iwind/TeaGo/maps"
+	"io"
+	"testing"
+	"time"
+)
+
+type testGuardProvider struct {
+	BaseProvider
+
+	countCalls int
+	errs       []error
+}
+
+func (this *testGuardProvider) Auth(params maps.Map) error {
+	return nil
+}
+
+func (this *testGuardProvider) MaskParams(params maps.Map) {
+}
+
+func (this *testGuardProvider) GetDomains() (domains []string, err error) {
+	this.countCalls++
+	if len(this.errs) > 0 {
+		err = this.errs[0]
+		this.errs = this.errs[1:]
+		return
+	}
+	return []string{"example.com"}, nil
+}
+
+func (this *testGuardProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	return
+}
+
+func (this *testGuardProvider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	return
+}
+
+func (this *testGuardProvider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	return nil, nil
+}
+
+func (this *testGuardProvider) QueryRecords(domain string, name string, recordType dnstypes.RecordType) ([]*dnstypes.Record, error) {
+	return nil, nil
+}
+
+func (this *testGuardProvider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	this.countCalls++
+	if len(this.errs) > 0 {
+		var err = this.errs[0]
+		this.errs = this.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (this *testGuardProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	return nil
+}
+
+func (this *testGuardProvider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	return nil
+}
+
+func (this *testGuardProvider) DefaultRoute() string {
+	return ""
+}
+
+func TestIsRetryableError(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsFalse(IsRetryableError(nil))
+	a.IsFalse(IsRetryableError(errors.New("invalid token")))
+	a.IsFalse(IsRetryableError(NewStatusError(403, "forbidden")))
+	a.IsTrue(IsRetryableError(NewStatusError(429, "too many requests")))
+	a.IsTrue(IsRetryableError(NewStatusError(502, "bad gateway")))
+	a.IsTrue(IsRetryableError(fmt.Errorf("read response failed: %w", io.ErrUnexpectedEOF)))
+	a.IsFalse(IsRetryableError(errors.New("invalid record: EOF")))
+}
+
+func TestIsRejectedError(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsFalse(IsRejectedError(nil))
+	a.IsFalse(IsRejectedError(NewStatusError(502, "bad gateway")))
+	a.IsTrue(IsRejectedError(NewStatusError(429, "too many requests")))
+}
+
+func TestGuardConfig_Backoff(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var config = &GuardConfig{
+		MinBackoffMs: 100,
+		MaxBackoffMs: 1000,
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		var d = config.backoff(attempt)
+		t.Log(attempt, d)
+		a.IsTrue(d >= 50*time.Millisecond)
+		a.IsTrue(d <= 1000*time.Millisecond)
+	}
+}
+
+func TestGuardedProvider_Retry(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var raw = &testGuardProvider{
+		errs: []error{NewStatusError(503, ""), NewStatusError(429, "")},
+	}
+	var provider = NewGuardedProvider(raw, "test", -1, &GuardConfig{
+		MaxRetries:   3,
+		MinBackoffMs: 1,
+		MaxBackoffMs: 2,
+	})
+	domains, err := provider.GetDomains()
+	a.IsNil(err)
+	a.IsTrue(len(domains) == 1)
+	a.IsTrue(raw.countCalls == 3)
+}
+
+func TestGuardedProvider_Breaker(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var raw = &testGuardProvider{
+		errs: []error{NewStatusError(500, ""), NewStatusError(500, "")},
+	}
+	var provider = NewGuardedProvider(raw, "test", -2, &GuardConfig{
+		BreakerFailures:  2,
+		BreakerTimeoutMs: 60_000,
+	})
+	_, err := provider.GetDomains()
+	a.IsNotNil(err)
+	_, err = provider.GetDomains()
+	a.IsNotNil(err)
+	_, err = provider.GetDomains()
+	a.IsTrue(err == ErrCircuitOpen)
+	a.IsTrue(raw.countCalls == 2)
+}
+
+func TestGuardedProvider_NoRetryForWrites(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var raw = &testGuardProvider{
+		errs: []error{NewStatusError(503, "")},
+	}
+	var provider = NewGuardedProvider(raw, "test", -3, &GuardConfig{
+		MaxRetries:   3,
+		MinBackoffMs: 1,
+		MaxBackoffMs: 2,
+	})
+	err := provider.AddRecord("example.com", &dnstypes.Record{})
+	a.IsNotNil(err)
+	a.IsTrue(raw.countCalls == 1)
+}
+
+func TestGuardedProvider_RetryRejectedWrites(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var raw = &testGuardProvider{
+		errs: []error{NewStatusError(429, ""), NewStatusError(429, "")},
+	}
+	var provider = NewGuardedProvider(raw, "test", -5, &GuardConfig{
+		MaxRetries:   3,
+		MinBackoffMs: 1,
+		MaxBackoffMs: 2,
+	})
+	err := provider.AddRecord("example.com", &dnstypes.Record{})
+	a.IsNil(err)
+	a.IsTrue(raw.countCalls == 3)
+}
+
+func TestGuardedProvider_HalfOpen(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var raw = &testGuardProvider{
+		errs: []error{NewStatusError(500, ""), NewStatusError(500, ""), NewStatusError(500, "")},
+	}
+	var provider = NewGuardedProvider(raw, "test", -4, &GuardConfig{
+		BreakerFailures:  2,
+		BreakerTimeoutMs: 1,
+	})
+	_, _ = provider.GetDomains()
+	_, _ = provider.GetDomains()
+	time.Sleep(5 * time.Millisecond)
+
+	// 只允许一个试探调用
+	allowed, isProbe := provider.allow()
+	a.IsTrue(allowed && isProbe)
+	allowed, _ = provider.allow()
+	a.IsFalse(allowed)
+	provider.state.probing = false
+
+	// 试探失败，重新熔断
+	_, err := provider.GetDomains()
+	a.IsNotNil(err)
+	_, err = provider.GetDomains()
+	a.IsTrue(err == ErrCircuitOpen)
+	a.IsTrue(raw.countCalls == 3)
+
+	// 试探成功，恢复正常
+	time.Sleep(5 * time.Millisecond)
+	_, err = provider.GetDomains()
+	a.IsNil(err)
+	_, err = provider.GetDomains()
+	a.IsNil(err)
+	a.IsTrue(raw.countCalls == 5)
+}
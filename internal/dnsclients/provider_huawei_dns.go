@@ -118,14 +118,16 @@ func (this *HuaweiDNSProvider) GetRecords(domain string) (records []*dnstypes.Re
 			for _, value := range recordSet.Records {
 				name := strings.TrimSuffix(recordSet.Name, "."+domain+".")
 
-				records = append(records, &dnstypes.Record{
-					Id:    recordSet.Id + "@" + value,
-					Name:  name,
-					Type:  recordSet.Type,
-					Value: value,
-					Route: recordSet.Line,
-					TTL:   types.Int32(recordSet.Ttl),
-				})
+				var record = &dnstypes.Record{
+					Id:     recordSet.Id + "@" + value,
+					Name:   name,
+					Type:   recordSet.Type,
+					Route:  recordSet.Line,
+					TTL:    types.Int32(recordSet.Ttl),
+					Weight: types.Int32(recordSet.Weight),
+				}
+				record.SetRData(value)
+				records = append(records, record)
 			}
 		}
 	}
@@ -2276,14 +2278,16 @@ func (this *HuaweiDNSProvider) QueryRecord(domain string, name string, recordTyp
 		return nil, nil
 	}
 
-	return &dnstypes.Record{
-		Id:    recordSet.Id + "@" + recordSet.Records[0],
-		Name:  name,
-		Type:  recordType,
-		Value: recordSet.Records[0],
-		Route: recordSet.Line,
-		TTL:   types.Int32(recordSet.Ttl),
-	}, nil
+	var record = &dnstypes.Record{
+		Id:     recordSet.Id + "@" + recordSet.Records[0],
+		Name:   name,
+		Type:   recordType,
+		Route:  recordSet.Line,
+		TTL:    types.Int32(recordSet.Ttl),
+		Weight: types.Int32(recordSet.Weight),
+	}
+	record.SetRData(recordSet.Records[0])
+	return record, nil
 }
 
 // QueryRecords 查询多个记录
@@ -2307,15 +2311,17 @@ func (this *HuaweiDNSProvider) QueryRecords(domain string, name string, recordTy
 			continue
 		}
 
-		for _, record := range recordSet.Records {
-			result = append(result, &dnstypes.Record{
-				Id:    recordSet.Id + "@" + record,
-				Name:  name,
-				Type:  recordType,
-				Value: record,
-				Route: recordSet.Line,
-				TTL:   types.Int32(recordSet.Ttl),
-			})
+		for _, value := range recordSet.Records {
+			var record = &dnstypes.Record{
+				Id:     recordSet.Id + "@" + value,
+				Name:   name,
+				Type:   recordType,
+				Route:  recordSet.Line,
+				TTL:    types.Int32(recordSet.Ttl),
+				Weight: types.Int32(recordSet.Weight),
+			}
+			record.SetRData(value)
+			result = append(result, record)
 		}
 	}
 	return result, nil
@@ -2339,19 +2345,23 @@ func (this *HuaweiDNSProvider) AddRecord(domain string, newRecord *dnstypes.Reco
 		newRecord.Value = "\"" + strings.Trim(newRecord.Value, "\"") + "\""
 	}
 
-	err = this.doAPI(http.MethodPost, "/v2.1/zones/"+zoneId+"/recordsets", map[string]string{}, maps.Map{
+	var body = maps.Map{
 		"name":        newRecord.Name + "." + domain + ".",
 		"description": "CDN系统自动创建",
 		"type":        newRecord.Type,
-		"records":     []string{newRecord.Value},
+		"records":     []string{newRecord.RData()},
 		"line":        newRecord.Route,
 		"ttl":         ttl,
-	}, resp)
+	}
+	if newRecord.Weight > 0 {
+		body["weight"] = newRecord.Weight
+	}
+	err = this.doAPI(http.MethodPost, "/v2.1/zones/"+zoneId+"/recordsets", map[string]string{}, body, resp)
 	if err != nil {
 		return this.WrapError(err, domain, newRecord)
 	}
 
-	newRecord.Id = resp.Id + "@" + newRecord.Value
+	newRecord.Id = resp.Id + "@" + newRecord.RData()
 
 	return nil
 }
@@ -2382,14 +2392,18 @@ func (this *HuaweiDNSProvider) UpdateRecord(domain string, record *dnstypes.Reco
 	}
 
 	var resp = new(huaweidns.ZonesUpdateRecordSetResponse)
-	err = this.doAPI(http.MethodPut, "/v2.1/zones/"+zoneId+"/recordsets/"+recordId, map[string]string{}, maps.Map{
+	var body = maps.Map{
 		"name":        newRecord.Name + "." + domain + ".",
 		"description": "CDN系统自动创建",
 		"type":        newRecord.Type,
-		"records":     []string{newRecord.Value},
+		"records":     []string{newRecord.RData()},
 		"line":        newRecord.Route, // TODO 华为云此API无法修改线路，API地址：https://support.huaweicloud.com/api-dns/dns_api_65006.html
 		"ttl":         ttl,
-	}, resp)
+	}
+	if newRecord.Weight > 0 {
+		body["weight"] = newRecord.Weight
+	}
+	err = this.doAPI(http.MethodPut, "/v2.1/zones/"+zoneId+"/recordsets/"+recordId, map[string]string{}, body, resp)
 	if err != nil {
 		return this.WrapError(err, domain, newRecord)
 	}
@@ -2426,6 +2440,11 @@ func (this *HuaweiDNSProvider) DefaultRoute() string {
 	return "default_view"
 }
 
+// SupportsWeight 是否支持权重
+func (this *HuaweiDNSProvider) SupportsWeight() bool {
+	return true
+}
+
 func (this *HuaweiDNSProvider) doAPI(method string, apiPath string, args map[string]string, bodyMap maps.Map, respPtr interface{}) error {
 	var endpoint = HuaweiDNSDefaultEndpoint
 	if len(this.endpoint) > 0 {
@@ -2530,15 +2549,15 @@ func (this *HuaweiDNSProvider) doAPI(method string, apiPath string, args map[str
 		return errors.New("invalid response status '" + strconv.Itoa(resp.StatusCode) + "', response '" + string(data) + "'")
 	}
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return NewStatusError(resp.StatusCode, string(data))
+	}
+
 	err = json.Unmarshal(data, respPtr)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return errors.New("response error: status code: " + types.String(resp.StatusCode) + ", response data: " + string(data))
-	}
-
 	return nil
 }
 
@@ -46,3 +46,15 @@ type ProviderInterface interface {
 	// MinTTL 最小TTL
 	MinTTL() int32
 }
+
+// WeightedProviderInterface 支持为同名记录设置负载均衡权重的服务商
+type WeightedProviderInterface interface {
+	// SupportsWeight 是否支持权重
+	SupportsWeight() bool
+}
+
+// SupportsWeight 判断服务商是否支持记录权重
+func SupportsWeight(provider ProviderInterface) bool {
+	weightedProvider, ok := provider.(WeightedProviderInterface)
+	return ok && weightedProvider.SupportsWeight()
+}
@@ -104,14 +104,7 @@ func (this *TencentDNSProvider) GetRecords(domain string) (records []*dnstypes.R
 			break
 		}
 		for _, recordObj := range resp.Response.RecordList {
-			records = append(records, &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			})
+			records = append(records, this.convertRecord(recordObj))
 		}
 		offset += uint64(countRecords)
 	}
@@ -201,14 +194,7 @@ func (this *TencentDNSProvider) QueryRecord(domain string, name string, recordTy
 	}
 	for _, recordObj := range resp.Response.RecordList {
 		if *recordObj.Name == name && *recordObj.Type == recordType {
-			return &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			}, nil
+			return this.convertRecord(recordObj), nil
 		}
 	}
 
@@ -247,14 +233,7 @@ func (this *TencentDNSProvider) QueryRecords(domain string, name string, recordT
 			break
 		}
 		for _, recordObj := range resp.Response.RecordList {
-			records = append(records, &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			})
+			records = append(records, this.convertRecord(recordObj))
 		}
 		offset += uint64(countRecords)
 	}
@@ -268,8 +247,8 @@ func (this *TencentDNSProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	// 在CNAME等记录后面加入点
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -286,6 +265,7 @@ func (this *TencentDNSProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 	req.RecordLine = this.stringVal(this.DefaultRouteName()) // 默认必填项，但以RecordLineId优先
 	req.RecordLineId = this.stringVal(newRecord.Route)
 	req.Value = this.stringVal(newRecord.Value)
+	this.fillRecordRequest(&req.MX, &req.Weight, &req.Value, newRecord)
 	resp, respErr := this.client.CreateRecord(req)
 	if respErr != nil {
 		return respErr
@@ -309,8 +289,8 @@ func (this *TencentDNSProvider) UpdateRecord(domain string, record *dnstypes.Rec
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
+	// 在CNAME等记录后面加入点
+	if dnstypes.HasHostValue(newRecord.Type) && !strings.HasSuffix(newRecord.Value, ".") {
 		newRecord.Value += "."
 	}
 
@@ -333,6 +313,7 @@ func (this *TencentDNSProvider) UpdateRecord(domain string, record *dnstypes.Rec
 	req.RecordLine = this.stringVal(this.DefaultRouteName()) // 默认必填项，但以RecordLineId优先
 	req.RecordLineId = this.stringVal(newRecord.Route)
 	req.Value = this.stringVal(newRecord.Value)
+	this.fillRecordRequest(&req.MX, &req.Weight, &req.Value, newRecord)
 	_, respErr := this.client.ModifyRecord(req)
 	if respErr != nil {
 		return respErr
@@ -380,14 +361,61 @@ func (this *TencentDNSProvider) DefaultRouteName() string {
 	return "默认"
 }
 
+// SupportsWeight 是否支持权重
+func (this *TencentDNSProvider) SupportsWeight() bool {
+	return true
+}
+
 func (this *TencentDNSProvider) fixCNAME(recordType string, recordValue string) string {
 	// 修正Record
-	if strings.ToUpper(recordType) == dnstypes.RecordTypeCNAME && !strings.HasSuffix(recordValue, ".") {
+	if dnstypes.HasHostValue(strings.ToUpper(recordType)) && !strings.HasSuffix(recordValue, ".") {
 		recordValue += "."
 	}
 	return recordValue
 }
 
+// 转换API返回的记录
+func (this *TencentDNSProvider) convertRecord(recordObj *dnspod.RecordListItem) *dnstypes.Record {
+	var record = &dnstypes.Record{
+		Id:    types.String(*recordObj.RecordId),
+		Name:  *recordObj.Name,
+		Type:  *recordObj.Type,
+		Route: *recordObj.LineId,
+		TTL:   types.Int32(*recordObj.TTL),
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		record.Value = this.fixCNAME(*recordObj.Type, *recordObj.Value)
+		if recordObj.MX != nil {
+			record.Priority = types.Int32(*recordObj.MX)
+		}
+	case dnstypes.RecordTypeSRV, dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		record.SetRData(*recordObj.Value)
+	default:
+		record.Value = this.fixCNAME(*recordObj.Type, *recordObj.Value)
+		if recordObj.Weight != nil {
+			record.Weight = types.Int32(*recordObj.Weight)
+		}
+	}
+	return record
+}
+
+// 设置优先级、权重等附加参数
+func (this *TencentDNSProvider) fillRecordRequest(mxPtr **uint64, weightPtr **uint64, valuePtr **string, record *dnstypes.Record) {
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		// 0 是有效的优先级（比如 RFC 7505 中的空MX记录），所以这里不设置默认值
+		*mxPtr = this.uint64Val(uint64(record.Priority))
+	case dnstypes.RecordTypeSRV, dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		*valuePtr = this.stringVal(record.RData())
+	default:
+		if record.Weight > 0 {
+			*weightPtr = this.uint64Val(uint64(record.Weight))
+		}
+	}
+}
+
 func (this *TencentDNSProvider) int64Val(v int64) *int64 {
 	return &v
 }
@@ -0,0 +1,184 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package zonefile
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"strings"
+)
+
+type ChangeAction = string
+
+const (
+	ChangeActionAdd    ChangeAction = "add"
+	ChangeActionUpdate ChangeAction = "update"
+	ChangeActionDelete ChangeAction = "delete"
+	ChangeActionSkip   ChangeAction = "skip"
+)
+
+// Change 导入时的单个变更
+type Change struct {
+	Action    ChangeAction     `json:"action"`
+	Record    *dnstypes.Record `json:"record"`    // 导入的记录
+	OldRecord *dnstypes.Record `json:"oldRecord"` // 已有的记录
+}
+
+// Diff 对比导入的记录和已有的记录
+// 只对比默认线路上的记录；记录名和类型相同但值或TTL不同时，如果overwrite为true则覆盖已有的记录，否则跳过
+func Diff(oldRecords []*dnstypes.Record, newRecords []*dnstypes.Record, defaultRoute string, overwrite bool) (changes []*Change) {
+	var oldGroups = map[string][]*dnstypes.Record{} // name|type => records
+	for _, record := range oldRecords {
+		if len(record.Route) > 0 && record.Route != defaultRoute {
+			continue
+		}
+		var key = groupKey(record)
+		oldGroups[key] = append(oldGroups[key], record)
+	}
+
+	var newGroups = map[string][]*dnstypes.Record{}
+	var newKeys = []string{}
+	for _, record := range newRecords {
+		var key = groupKey(record)
+		_, ok := newGroups[key]
+		if !ok {
+			newKeys = append(newKeys, key)
+		}
+		newGroups[key] = append(newGroups[key], record)
+	}
+
+	for _, key := range newKeys {
+		var newGroup = newGroups[key]
+		var oldGroup = oldGroups[key]
+
+		// 去除完全一样的记录
+		var unmatchedNew = []*dnstypes.Record{}
+		var matchedOld = map[*dnstypes.Record]bool{}
+		for _, newRecord := range newGroup {
+			var found = false
+			for _, oldRecord := range oldGroup {
+				if !matchedOld[oldRecord] && isSameRecord(oldRecord, newRecord) {
+					matchedOld[oldRecord] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				unmatchedNew = append(unmatchedNew, newRecord)
+			}
+		}
+		var unmatchedOld = []*dnstypes.Record{}
+		for _, oldRecord := range oldGroup {
+			if !matchedOld[oldRecord] {
+				unmatchedOld = append(unmatchedOld, oldRecord)
+			}
+		}
+
+		// 新的记录
+		if len(oldGroup) == 0 {
+			for _, newRecord := range unmatchedNew {
+				changes = append(changes, &Change{
+					Action: ChangeActionAdd,
+					Record: newRecord,
+				})
+			}
+			continue
+		}
+
+		if len(unmatchedNew) == 0 && len(unmatchedOld) == 0 {
+			continue
+		}
+
+		if !overwrite {
+			for _, newRecord := range unmatchedNew {
+				changes = append(changes, &Change{
+					Action: ChangeActionSkip,
+					Record: newRecord,
+				})
+			}
+			continue
+		}
+
+		// 覆盖
+		// 优先和值相同的记录配对，这样只有TTL不同的记录会作为修改
+		var usedOld = map[*dnstypes.Record]bool{}
+		for _, newRecord := range unmatchedNew {
+			var oldRecord *dnstypes.Record
+			for _, record := range unmatchedOld {
+				if !usedOld[record] && isSameValue(record, newRecord) {
+					oldRecord = record
+					break
+				}
+			}
+			if oldRecord == nil {
+				for _, record := range unmatchedOld {
+					if !usedOld[record] {
+						oldRecord = record
+						break
+					}
+				}
+			}
+
+			if oldRecord != nil {
+				usedOld[oldRecord] = true
+				changes = append(changes, &Change{
+					Action:    ChangeActionUpdate,
+					Record:    newRecord,
+					OldRecord: oldRecord,
+				})
+			} else {
+				changes = append(changes, &Change{
+					Action: ChangeActionAdd,
+					Record: newRecord,
+				})
+			}
+		}
+		for _, oldRecord := range unmatchedOld {
+			if !usedOld[oldRecord] {
+				changes = append(changes, &Change{
+					Action:    ChangeActionDelete,
+					OldRecord: oldRecord,
+				})
+			}
+		}
+	}
+
+	return
+}
+
+func groupKey(record *dnstypes.Record) string {
+	var name = strings.ToLower(record.Name)
+	if len(name) == 0 {
+		name = "@"
+	}
+	return name + "|" + record.Type
+}
+
+// 记录值和TTL都相同时认为是同一个记录
+// TTL为0表示没有设置，不参与对比
+func isSameRecord(record1 *dnstypes.Record, record2 *dnstypes.Record) bool {
+	return isSameValue(record1, record2) &&
+		(record1.TTL <= 0 || record2.TTL <= 0 || record1.TTL == record2.TTL)
+}
+
+func isSameValue(record1 *dnstypes.Record, record2 *dnstypes.Record) bool {
+	if record1.Priority != record2.Priority ||
+		(record1.Type == dnstypes.RecordTypeSRV && record1.Weight != record2.Weight) {
+		return false
+	}
+
+	var value1 = record1.Value
+	var value2 = record2.Value
+	switch {
+	case dnstypes.HasHostValue(record1.Type):
+		// 主机名不区分大小写
+		return strings.EqualFold(strings.TrimSuffix(value1, "."), strings.TrimSuffix(value2, "."))
+	case record1.Type == dnstypes.RecordTypeSRV:
+		// 端口 目标主机
+		port1, target1, _ := strings.Cut(value1, " ")
+		port2, target2, _ := strings.Cut(value2, " ")
+		return port1 == port2 && strings.EqualFold(strings.TrimSuffix(target1, "."), strings.TrimSuffix(target2, "."))
+	}
+
+	// TXT、CAA等记录值区分大小写
+	return value1 == value2
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package zonefile
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/miekg/dns"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultTTL = 600
+
+var parseErrorLineReg = regexp.MustCompile(`at line: (\d+):`)
+
+// ParseError 单条记录的解析错误
+type ParseError struct {
+	Line    int    `json:"line"`    // 行号，从1开始
+	Text    string `json:"text"`    // 原始内容
+	Message string `json:"message"` // 错误信息
+}
+
+// Parse 解析RFC 1035格式的区域文件
+// 遇到错误的记录时会跳过该行继续解析，所有的错误都会放在errs中返回
+func Parse(domain string, data string) (records []*dnstypes.Record, errs []*ParseError) {
+	var origin = dns.Fqdn(strings.ToLower(domain))
+	var lines = strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	var lastOwner = ""
+	var start = 0
+	for start < len(lines) {
+		// 重新开始时要保留之前的$ORIGIN和$TTL设置
+		var header = directives(lines[:start])
+		var headerLines = strings.Count(header, "\n")
+
+		var body = lines[start:]
+		if len(body) > 0 && len(lastOwner) > 0 && len(body[0]) > 0 && (body[0][0] == ' ' || body[0][0] == '\t') {
+			body = append([]string{lastOwner + body[0]}, body[1:]...)
+		}
+
+		var parser = dns.NewZoneParser(strings.NewReader(header+strings.Join(body, "\n")), origin, "")
+		parser.SetIncludeAllowed(false)
+		for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+			lastOwner = rr.Header().Name
+
+			// SOA和顶级NS由服务商管理，直接忽略
+			if shouldIgnoreRR(origin, rr) {
+				continue
+			}
+
+			record, err := convertRR(origin, rr)
+			if err != nil {
+				errs = append(errs, &ParseError{
+					Text:    rr.String(),
+					Message: err.Error(),
+				})
+				continue
+			}
+			records = append(records, record)
+		}
+
+		var err = parser.Err()
+		if err == nil {
+			break
+		}
+
+		var line = 0
+		var matches = parseErrorLineReg.FindStringSubmatch(err.Error())
+		if len(matches) > 1 {
+			line, _ = strconv.Atoi(matches[1])
+		}
+		line -= headerLines
+		if line <= 0 {
+			errs = append(errs, &ParseError{
+				Line:    start + 1,
+				Message: err.Error(),
+			})
+			break
+		}
+
+		var lineIndex = start + line - 1
+		var text = ""
+		if lineIndex < len(lines) {
+			text = strings.TrimSpace(lines[lineIndex])
+		}
+		errs = append(errs, &ParseError{
+			Line:    lineIndex + 1,
+			Text:    text,
+			Message: err.Error(),
+		})
+		start = lineIndex + 1
+	}
+
+	return
+}
+
+// Export 将记录导出为RFC 1035格式的区域文件
+// 非默认线路的记录会在行尾以注释的形式标注线路
+func Export(domain string, records []*dnstypes.Record, defaultRoute string) string {
+	var origin = dns.Fqdn(strings.ToLower(domain))
+
+	var sortedRecords = append([]*dnstypes.Record{}, records...)
+	sort.SliceStable(sortedRecords, func(i, j int) bool {
+		var r1 = sortedRecords[i]
+		var r2 = sortedRecords[j]
+		if r1.Name != r2.Name {
+			if r1.Name == "@" {
+				return true
+			}
+			if r2.Name == "@" {
+				return false
+			}
+			return r1.Name < r2.Name
+		}
+		return r1.Type < r2.Type
+	})
+
+	var builder = &strings.Builder{}
+	builder.WriteString("$ORIGIN " + origin + "\n")
+	builder.WriteString("$TTL " + strconv.Itoa(DefaultTTL) + "\n")
+	for _, record := range sortedRecords {
+		if !dnstypes.IsValidRecordType(record.Type) {
+			continue
+		}
+
+		var name = record.Name
+		if len(name) == 0 {
+			name = "@"
+		}
+
+		var ttl = record.TTL
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+
+		builder.WriteString(name + "\t" + strconv.Itoa(int(ttl)) + "\tIN\t" + record.Type + "\t" + exportRData(record))
+		if len(record.Route) > 0 && record.Route != defaultRoute {
+			builder.WriteString(" ; route: " + record.Route)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// 转换单条记录
+func convertRR(origin string, rr dns.RR) (*dnstypes.Record, error) {
+	var header = rr.Header()
+	var recordType = dns.TypeToString[header.Rrtype]
+	if !dnstypes.IsValidRecordType(recordType) {
+		return nil, errors.New("unsupported record type '" + recordType + "'")
+	}
+
+	name, ok := relativeName(origin, header.Name)
+	if !ok {
+		return nil, errors.New("record name '" + header.Name + "' is out of zone '" + origin + "'")
+	}
+
+	var record = &dnstypes.Record{
+		Name: name,
+		Type: recordType,
+		TTL:  int32(header.Ttl),
+	}
+
+	switch v := rr.(type) {
+	case *dns.TXT:
+		var pieces = []string{}
+		for _, piece := range v.Txt {
+			pieces = append(pieces, unescapeTXT(piece))
+		}
+		record.Value = strings.Join(pieces, "")
+	default:
+		record.SetRData(strings.TrimPrefix(rr.String(), header.String()))
+	}
+
+	return record, nil
+}
+
+// 判断是否为服务商管理的记录
+func shouldIgnoreRR(origin string, rr dns.RR) bool {
+	var header = rr.Header()
+	switch header.Rrtype {
+	case dns.TypeSOA:
+		return true
+	case dns.TypeNS:
+		return strings.ToLower(header.Name) == origin
+	}
+	return false
+}
+
+// 导出记录数据
+func exportRData(record *dnstypes.Record) string {
+	switch record.Type {
+	case dnstypes.RecordTypeTXT:
+		return quoteTXT(record.Value)
+	case dnstypes.RecordTypeCNAME, dnstypes.RecordTypeNS, dnstypes.RecordTypeMX:
+		var record2 = record.Clone()
+		record2.Value = dns.Fqdn(record2.Value)
+		return record2.RData()
+	case dnstypes.RecordTypeSRV:
+		// 端口 目标主机
+		port, target, ok := strings.Cut(record.Value, " ")
+		if ok {
+			var record2 = record.Clone()
+			record2.Value = port + " " + dns.Fqdn(strings.TrimSpace(target))
+			return record2.RData()
+		}
+	case dnstypes.RecordTypeHTTPS, dnstypes.RecordTypeSVCB:
+		// 目标主机 [参数...]
+		target, params, _ := strings.Cut(record.Value, " ")
+		if len(target) > 0 {
+			var record2 = record.Clone()
+			record2.Value = dns.Fqdn(target)
+			if len(params) > 0 {
+				record2.Value += " " + params
+			}
+			return record2.RData()
+		}
+	}
+	return record.RData()
+}
+
+// 将TXT记录值转换为带引号的字符串，超过255个字节时分段
+func quoteTXT(value string) string {
+	if strings.HasPrefix(value, "\"") {
+		return value
+	}
+
+	var pieces = []string{}
+	for len(value) > 255 {
+		pieces = append(pieces, value[:255])
+		value = value[255:]
+	}
+	pieces = append(pieces, value)
+
+	for index, piece := range pieces {
+		piece = strings.ReplaceAll(piece, "\\", "\\\\")
+		piece = strings.ReplaceAll(piece, "\"", "\\\"")
+		pieces[index] = "\"" + piece + "\""
+	}
+	return strings.Join(pieces, " ")
+}
+
+// 还原TXT记录中的转义字符，包括\X和\DDD
+func unescapeTXT(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var builder = &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			builder.WriteByte(s[i])
+			continue
+		}
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			var b = int(s[i+1]-'0')*100 + int(s[i+2]-'0')*10 + int(s[i+3]-'0')
+			if b <= 255 {
+				builder.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(s[i+1])
+		i++
+	}
+	return builder.String()
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// 获取相对于域名的记录名
+func relativeName(origin string, name string) (string, bool) {
+	name = strings.ToLower(name)
+	if name == origin {
+		return "@", true
+	}
+	if !strings.HasSuffix(name, "."+origin) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, "."+origin), true
+}
+
+// 从已经解析过的行中找出$ORIGIN和$TTL设置
+func directives(lines []string) string {
+	var result = ""
+	var origin = ""
+	var ttl = ""
+	for _, line := range lines {
+		var fields = strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			origin = fields[1]
+		case "$TTL":
+			ttl = fields[1]
+		}
+	}
+	if len(origin) > 0 {
+		result += "$ORIGIN " + origin + "\n"
+	}
+	if len(ttl) > 0 {
+		result += "$TTL " + ttl + "\n"
+	}
+	return result
+}
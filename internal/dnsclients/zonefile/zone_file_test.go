@@ -0,0 +1,158 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package zonefile_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/zonefile"
+	"github.com/iwind/TeaGo/assert"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	records, errs := zonefile.Parse("example.com", `$ORIGIN example.com.
+$TTL 300
+@	IN	SOA	ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600
+@	IN	NS	ns1.example.com.
+@	IN	A	192.168.1.100
+www	600	IN	CNAME	example.com.
+	IN	TXT	"hello" " world"
+mail	IN	MX	10 mx.example.com.
+_sip._tcp	IN	SRV	10 5 5060 sip.example.com.
+@	IN	CAA	0 issue "letsencrypt.org"
+bad	IN	A	not-an-ip
+other.example.net.	IN	A	192.168.1.101
+sub	IN	AAAA	::1
+`)
+	for _, record := range records {
+		t.Logf("%+v", record)
+	}
+	for _, err := range errs {
+		t.Logf("%+v", err)
+	}
+
+	a.IsTrue(len(records) == 7)
+	a.IsTrue(len(errs) == 2)
+
+	a.IsTrue(records[0].Name == "@" && records[0].Type == dnstypes.RecordTypeA && records[0].TTL == 300)
+	a.IsTrue(records[1].Name == "www" && records[1].Value == "example.com." && records[1].TTL == 600)
+	a.IsTrue(records[2].Name == "www" && records[2].Type == dnstypes.RecordTypeTXT && records[2].Value == "hello world")
+	a.IsTrue(records[3].Priority == 10 && records[3].Value == "mx.example.com.")
+	a.IsTrue(records[4].Priority == 10 && records[4].Weight == 5 && records[4].Value == "5060 sip.example.com.")
+	a.IsTrue(records[5].Value == `0 issue "letsencrypt.org"`)
+	a.IsTrue(records[6].Name == "sub" && records[6].Type == dnstypes.RecordTypeAAAA)
+	a.IsTrue(errs[0].Line == 11)
+}
+
+func TestExport(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var records = []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "example.com", TTL: 600, Route: "default"},
+		{Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.100", Route: "default"},
+		{Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.101", Route: "telecom"},
+		{Name: "@", Type: dnstypes.RecordTypeTXT, Value: `v=spf1 include:"example.net" ~all`},
+		{Name: "@", Type: dnstypes.RecordTypeMX, Value: "mx.example.com", Priority: 10},
+		{Name: "_sip._tcp", Type: dnstypes.RecordTypeSRV, Value: "5060 sip.example.com", Priority: 10, Weight: 5},
+		{Name: "@", Type: dnstypes.RecordTypeHTTPS, Value: "cdn.example.com alpn=h2", Priority: 1},
+	}
+	var data = zonefile.Export("example.com", records, "default")
+	t.Log("\n" + data)
+	a.IsTrue(strings.Contains(data, "5060 sip.example.com.\n"))
+	a.IsTrue(strings.Contains(data, "1 cdn.example.com. alpn=h2\n"))
+
+	parsedRecords, errs := zonefile.Parse("example.com", data)
+	a.IsTrue(len(errs) == 0)
+	a.IsTrue(len(parsedRecords) == len(records))
+	for _, record := range parsedRecords {
+		if record.Type == dnstypes.RecordTypeTXT {
+			a.IsTrue(record.Value == records[3].Value)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var oldRecords = []*dnstypes.Record{
+		{Id: "1", Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.100", Route: "default"},
+		{Id: "2", Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "a.example.com.", Route: "default"},
+		{Id: "3", Name: "api", Type: dnstypes.RecordTypeA, Value: "192.168.1.102", Route: "default"},
+		{Id: "4", Name: "api", Type: dnstypes.RecordTypeA, Value: "192.168.1.103", Route: "default"},
+	}
+	var newRecords = []*dnstypes.Record{
+		{Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.100"},
+		{Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "b.example.com."},
+		{Name: "api", Type: dnstypes.RecordTypeA, Value: "192.168.1.104"},
+		{Name: "mail", Type: dnstypes.RecordTypeA, Value: "192.168.1.105"},
+	}
+
+	{
+		var changes = zonefile.Diff(oldRecords, newRecords, "default", false)
+		a.IsTrue(len(changes) == 3)
+		a.IsTrue(changes[0].Action == zonefile.ChangeActionSkip)
+		a.IsTrue(changes[1].Action == zonefile.ChangeActionSkip)
+		a.IsTrue(changes[2].Action == zonefile.ChangeActionAdd)
+	}
+
+	{
+		var changes = zonefile.Diff(oldRecords, newRecords, "default", true)
+		for _, change := range changes {
+			t.Logf("%s %+v %+v", change.Action, change.Record, change.OldRecord)
+		}
+		a.IsTrue(len(changes) == 4)
+		a.IsTrue(changes[0].Action == zonefile.ChangeActionUpdate && changes[0].OldRecord.Id == "2")
+		a.IsTrue(changes[1].Action == zonefile.ChangeActionUpdate && changes[1].OldRecord.Id == "3")
+		a.IsTrue(changes[2].Action == zonefile.ChangeActionDelete && changes[2].OldRecord.Id == "4")
+		a.IsTrue(changes[3].Action == zonefile.ChangeActionAdd)
+	}
+}
+
+func TestDiff_TTL(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var oldRecords = []*dnstypes.Record{
+		{Id: "1", Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.100", TTL: 600, Route: "default"},
+		{Id: "2", Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.101", TTL: 600, Route: "default"},
+		{Id: "3", Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.102", TTL: 600, Route: "default"},
+	}
+	var newRecords = []*dnstypes.Record{
+		{Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.101", TTL: 300},
+		{Name: "@", Type: dnstypes.RecordTypeA, Value: "192.168.1.100", TTL: 600},
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.102"},
+	}
+
+	{
+		var changes = zonefile.Diff(oldRecords, newRecords, "default", false)
+		a.IsTrue(len(changes) == 1)
+		a.IsTrue(changes[0].Action == zonefile.ChangeActionSkip)
+	}
+
+	{
+		var changes = zonefile.Diff(oldRecords, newRecords, "default", true)
+		a.IsTrue(len(changes) == 1)
+		a.IsTrue(changes[0].Action == zonefile.ChangeActionUpdate && changes[0].OldRecord.Id == "2" && changes[0].Record.TTL == 300)
+	}
+}
+
+func TestDiff_Case(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var oldRecords = []*dnstypes.Record{
+		{Id: "1", Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "A.example.com.", Route: "default"},
+		{Id: "2", Name: "_sip._tcp", Type: dnstypes.RecordTypeSRV, Value: "5060 SIP.example.com.", Priority: 10, Weight: 5, Route: "default"},
+		{Id: "3", Name: "@", Type: dnstypes.RecordTypeTXT, Value: "token=ABC", Route: "default"},
+	}
+	var newRecords = []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "a.example.com"},
+		{Name: "_sip._tcp", Type: dnstypes.RecordTypeSRV, Value: "5060 sip.example.com", Priority: 10, Weight: 5},
+		{Name: "@", Type: dnstypes.RecordTypeTXT, Value: "token=abc"},
+	}
+
+	var changes = zonefile.Diff(oldRecords, newRecords, "default", true)
+	a.IsTrue(len(changes) == 1)
+	a.IsTrue(changes[0].Action == zonefile.ChangeActionUpdate && changes[0].OldRecord.Id == "3")
+}
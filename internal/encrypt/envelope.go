@@ -0,0 +1,238 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"strings"
+	"sync"
+)
+
+// 信封加密后的数据格式：EDGE_ENVELOPE:v2:主密钥版本:Base64(加密后的数据密钥):Base64(加密后的数据)
+// 每条数据使用单独生成的数据密钥加密，数据密钥再使用主密钥加密
+// v2格式的数据使用所在的表、字段和记录ID作为附加认证数据，v1格式的数据没有附加认证数据
+
+const (
+	EnvelopePrefix   = "EDGE_ENVELOPE:"
+	envelopeFormat   = "v2"
+	envelopeFormatV1 = "v1"
+	dataKeySize      = 32
+	envelopeAADBase  = "edge-envelope:"
+)
+
+var ErrMasterKeyNotConfigured = errors.New("master key not configured")
+
+var noMasterKeyWarningOnce = sync.Once{}
+
+// AAD 生成附加认证数据
+// 用来将加密后的数据和所在的表、字段、记录绑定，防止加密后的数据在不同的记录或字段之间互换
+func AAD(table string, column string, id int64) []byte {
+	return []byte(table + "." + column + "#" + types.String(id))
+}
+
+// IsEnvelope 判断数据是否已经加密
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(EnvelopePrefix))
+}
+
+// Encrypt 加密数据
+// 如果没有配置主密钥，则原样返回数据，并输出警告
+func (this *Keyring) Encrypt(data []byte, aad []byte) ([]byte, error) {
+	if len(data) == 0 || IsEnvelope(data) {
+		return data, nil
+	}
+
+	version, masterKey := this.currentKey()
+	if version <= 0 {
+		noMasterKeyWarningOnce.Do(func() {
+			logs.Println("[KEYRING]WARNING: master key not configured, secrets will be stored without encryption, please set '" + MasterKeyEnv + "' or create 'configs/" + MasterKeyFile + "'")
+		})
+		return data, nil
+	}
+
+	var dataKey = make([]byte, dataKeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := sealGCM(masterKey, dataKey, []byte(envelopeAADBase+types.String(version)))
+	if err != nil {
+		return nil, err
+	}
+	cipherData, err := sealGCM(dataKey, data, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(EnvelopePrefix + envelopeFormat + ":" + types.String(version) + ":" + base64.StdEncoding.EncodeToString(wrappedKey) + ":" + base64.StdEncoding.EncodeToString(cipherData)), nil
+}
+
+// Decrypt 解密数据
+// 未加密的数据会原样返回
+func (this *Keyring) Decrypt(data []byte, aad []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return data, nil
+	}
+
+	format, version, wrappedKey, cipherData, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if !this.IsEnabled() {
+		_ = this.Reload()
+		if !this.IsEnabled() {
+			return nil, ErrMasterKeyNotConfigured
+		}
+	}
+	masterKey, err := this.findKey(version)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := openGCM(masterKey, wrappedKey, []byte(envelopeAADBase+types.String(version)))
+	if err != nil {
+		return nil, errors.New("decrypt data key failed: " + err.Error())
+	}
+	if format == envelopeFormatV1 {
+		aad = nil
+	}
+	return openGCM(dataKey, cipherData, aad)
+}
+
+// Rewrap 使用当前版本的主密钥重新加密数据
+// 未加密的数据、使用旧版本主密钥或者旧格式加密的数据都会重新加密
+func (this *Keyring) Rewrap(data []byte, aad []byte) (result []byte, changed bool, err error) {
+	if len(data) == 0 {
+		return data, false, nil
+	}
+
+	currentVersion, _ := this.currentKey()
+	if currentVersion <= 0 {
+		return nil, false, ErrMasterKeyNotConfigured
+	}
+
+	if IsEnvelope(data) {
+		format, version, _, _, err := parseEnvelope(data)
+		if err != nil {
+			return nil, false, err
+		}
+		if format == envelopeFormat && version == currentVersion {
+			return data, false, nil
+		}
+
+		data, err = this.Decrypt(data, aad)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	result, err = this.Encrypt(data, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	return result, true, nil
+}
+
+// EncryptString 加密字符串
+func (this *Keyring) EncryptString(s string, aad []byte) (string, error) {
+	data, err := this.Encrypt([]byte(s), aad)
+	return string(data), err
+}
+
+// DecryptString 解密字符串
+func (this *Keyring) DecryptString(s string, aad []byte) (string, error) {
+	data, err := this.Decrypt([]byte(s), aad)
+	return string(data), err
+}
+
+// EnvelopeVersion 读取加密数据使用的主密钥版本
+// 未加密的数据返回0
+func EnvelopeVersion(data []byte) int {
+	if !IsEnvelope(data) {
+		return 0
+	}
+	_, version, _, _, err := parseEnvelope(data)
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+func parseEnvelope(data []byte) (format string, version int, wrappedKey []byte, cipherData []byte, err error) {
+	var pieces = strings.Split(string(data[len(EnvelopePrefix):]), ":")
+	if len(pieces) != 4 || (pieces[0] != envelopeFormat && pieces[0] != envelopeFormatV1) {
+		return "", 0, nil, nil, errors.New("invalid envelope data")
+	}
+	format = pieces[0]
+	version = types.Int(pieces[1])
+	if version <= 0 {
+		return "", 0, nil, nil, errors.New("invalid envelope key version")
+	}
+	wrappedKey, err = base64.StdEncoding.DecodeString(pieces[2])
+	if err != nil {
+		return "", 0, nil, nil, errors.New("invalid envelope data key: " + err.Error())
+	}
+	cipherData, err = base64.StdEncoding.DecodeString(pieces[3])
+	if err != nil {
+		return "", 0, nil, nil, errors.New("invalid envelope data: " + err.Error())
+	}
+	return
+}
+
+func sealGCM(key []byte, plainData []byte, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	var nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plainData, aad), nil
+}
+
+func openGCM(key []byte, data []byte, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid cipher data")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+// EncryptSecret 使用共享密钥环加密数据
+func EncryptSecret(data []byte, aad []byte) ([]byte, error) {
+	return SharedKeyring().Encrypt(data, aad)
+}
+
+// DecryptSecret 使用共享密钥环解密数据
+func DecryptSecret(data []byte, aad []byte) ([]byte, error) {
+	return SharedKeyring().Decrypt(data, aad)
+}
+
+// EncryptSecretString 使用共享密钥环加密字符串
+func EncryptSecretString(s string, aad []byte) (string, error) {
+	return SharedKeyring().EncryptString(s, aad)
+}
+
+// DecryptSecretString 使用共享密钥环解密字符串
+func DecryptSecretString(s string, aad []byte) (string, error) {
+	return SharedKeyring().DecryptString(s, aad)
+}
@@ -0,0 +1,204 @@
+package encrypt_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
+	"github.com/iwind/TeaGo/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var aad = encrypt.AAD("edgeTests", "secret", 1)
+
+func newTestKey() []byte {
+	var key = make([]byte, encrypt.MasterKeySize)
+	_, _ = rand.Read(key)
+	return key
+}
+
+func TestKeyring_Encrypt(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var keyring = encrypt.NewKeyring()
+
+	// 没有主密钥时不加密
+	{
+		data, err := keyring.Encrypt([]byte("hello"), aad)
+		a.IsNil(err)
+		a.IsTrue(string(data) == "hello")
+	}
+
+	a.IsNil(keyring.AddKey(1, newTestKey()))
+
+	data, err := keyring.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+	a.IsTrue(encrypt.IsEnvelope(data))
+	a.IsTrue(encrypt.EnvelopeVersion(data) == 1)
+	t.Log(string(data))
+
+	// 每次加密结果不同
+	data2, err := keyring.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+	a.IsFalse(bytes.Equal(data, data2))
+
+	// 重复加密不会改变数据
+	data3, err := keyring.Encrypt(data, aad)
+	a.IsNil(err)
+	a.IsTrue(bytes.Equal(data, data3))
+
+	plainData, err := keyring.Decrypt(data, aad)
+	a.IsNil(err)
+	a.IsTrue(string(plainData) == "hello")
+
+	// 未加密的数据原样返回
+	plainData, err = keyring.Decrypt([]byte("legacy"), aad)
+	a.IsNil(err)
+	a.IsTrue(string(plainData) == "legacy")
+}
+
+func TestKeyring_Decrypt_WrongAAD(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var keyring = encrypt.NewKeyring()
+	a.IsNil(keyring.AddKey(1, newTestKey()))
+	data, err := keyring.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+
+	// 不能在不同的记录或字段之间互换
+	_, err = keyring.Decrypt(data, encrypt.AAD("edgeTests", "secret", 2))
+	a.IsTrue(err != nil)
+	_, err = keyring.Decrypt(data, encrypt.AAD("edgeTests", "password", 1))
+	a.IsTrue(err != nil)
+	t.Log(err)
+}
+
+func TestKeyring_Decrypt_WrongKey(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var keyring1 = encrypt.NewKeyring()
+	a.IsNil(keyring1.AddKey(1, newTestKey()))
+	data, err := keyring1.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+
+	var keyring2 = encrypt.NewKeyring()
+	_, err = keyring2.Decrypt(data, aad)
+	a.IsTrue(err == encrypt.ErrMasterKeyNotConfigured)
+
+	a.IsNil(keyring2.AddKey(1, newTestKey()))
+	_, err = keyring2.Decrypt(data, aad)
+	a.IsTrue(err != nil)
+	t.Log(err)
+}
+
+func TestKeyring_Rewrap(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var keyring = encrypt.NewKeyring()
+	a.IsNil(keyring.AddKey(1, newTestKey()))
+
+	data, err := keyring.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+
+	// 版本相同时不需要轮换
+	{
+		_, changed, err := keyring.Rewrap(data, aad)
+		a.IsNil(err)
+		a.IsFalse(changed)
+	}
+
+	a.IsNil(keyring.AddKey(2, newTestKey()))
+	a.IsTrue(keyring.CurrentVersion() == 2)
+
+	newData, changed, err := keyring.Rewrap(data, aad)
+	a.IsNil(err)
+	a.IsTrue(changed)
+	a.IsTrue(encrypt.EnvelopeVersion(newData) == 2)
+
+	plainData, err := keyring.Decrypt(newData, aad)
+	a.IsNil(err)
+	a.IsTrue(string(plainData) == "hello")
+
+	// 未加密的数据
+	newData, changed, err = keyring.Rewrap([]byte("legacy"), aad)
+	a.IsNil(err)
+	a.IsTrue(changed)
+	a.IsTrue(encrypt.EnvelopeVersion(newData) == 2)
+}
+
+func TestKeyring_Reload(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var keys = map[int][]byte{1: newTestKey()}
+	keyring, err := encrypt.NewKeyringWithLoader(func() (map[int][]byte, error) {
+		return keys, nil
+	})
+	a.IsNil(err)
+
+	// 模拟其他节点使用新的主密钥加密
+	var otherKeyring = encrypt.NewKeyring()
+	var newKey = newTestKey()
+	a.IsNil(otherKeyring.AddKey(2, newKey))
+	data, err := otherKeyring.Encrypt([]byte("hello"), aad)
+	a.IsNil(err)
+
+	keys[2] = newKey
+	plainData, err := keyring.Decrypt(data, aad)
+	a.IsNil(err)
+	a.IsTrue(string(plainData) == "hello")
+	a.IsTrue(keyring.CurrentVersion() == 2)
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var key1 = base64.StdEncoding.EncodeToString(newTestKey())
+	var key2 = base64.StdEncoding.EncodeToString(newTestKey())
+
+	{
+		keys, err := encrypt.ParseMasterKeys(key1)
+		a.IsNil(err)
+		a.IsTrue(len(keys) == 1 && len(keys[1]) == encrypt.MasterKeySize)
+	}
+	{
+		keys, err := encrypt.ParseMasterKeys("# comment\n1:" + key1 + "\n3:" + key2 + "\n")
+		a.IsNil(err)
+		a.IsTrue(len(keys) == 2 && len(keys[3]) == encrypt.MasterKeySize)
+	}
+	{
+		keys, err := encrypt.ParseMasterKeys("1:" + key1 + ",2:" + key2)
+		a.IsNil(err)
+		a.IsTrue(len(keys) == 2)
+	}
+	{
+		_, err := encrypt.ParseMasterKeys("1:" + key1 + "\n1:" + key2)
+		a.IsTrue(err != nil)
+	}
+	{
+		_, err := encrypt.ParseMasterKeys("1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+		a.IsTrue(err != nil)
+	}
+}
+
+func TestGenerateMasterKey(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var path = filepath.Join(t.TempDir(), "master.key")
+	for i := 1; i <= 2; i++ {
+		version, err := encrypt.GenerateMasterKey(path)
+		a.IsNil(err)
+		a.IsTrue(version == i)
+	}
+
+	data, err := os.ReadFile(path)
+	a.IsNil(err)
+	keys, err := encrypt.ParseMasterKeys(string(data))
+	a.IsNil(err)
+	a.IsTrue(len(keys) == 2)
+
+	stat, err := os.Stat(path)
+	a.IsNil(err)
+	a.IsTrue(stat.Mode().Perm() == 0600)
+}
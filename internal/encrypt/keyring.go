@@ -0,0 +1,265 @@
+package encrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	MasterKeyEnv     = "EDGE_API_MASTER_KEY"      // 主密钥环境变量，格式：版本:Base64密钥，多个之间用逗号隔开
+	MasterKeyFileEnv = "EDGE_API_MASTER_KEY_FILE" // 主密钥文件路径环境变量
+	MasterKeyFile    = "master.key"               // 默认主密钥文件，位于configs/目录下
+
+	MasterKeySize = 32
+)
+
+// Keyring 主密钥环
+// 同一个集群中的所有API节点需要使用相同的主密钥
+type Keyring struct {
+	keys           map[int][]byte // version => key
+	currentVersion int
+	loader         func() (map[int][]byte, error)
+
+	locker sync.RWMutex
+}
+
+// NewKeyring 获取新的密钥环
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: map[int][]byte{},
+	}
+}
+
+// NewKeyringWithLoader 获取使用加载函数的密钥环
+// 在遇到未知版本的密钥时会重新加载，从而支持在线轮换
+func NewKeyringWithLoader(loader func() (map[int][]byte, error)) (*Keyring, error) {
+	var keyring = NewKeyring()
+	keyring.loader = loader
+	err := keyring.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+var sharedKeyring *Keyring
+var sharedKeyringOnce = sync.Once{}
+
+// SharedKeyring 获取共享的密钥环
+func SharedKeyring() *Keyring {
+	sharedKeyringOnce.Do(func() {
+		keyring, err := NewKeyringWithLoader(LoadMasterKeys)
+		if err != nil {
+			logs.Println("[KEYRING]load master keys failed: " + err.Error())
+			keyring = NewKeyring()
+			keyring.loader = LoadMasterKeys
+		}
+		sharedKeyring = keyring
+	})
+	return sharedKeyring
+}
+
+// AddKey 添加主密钥
+func (this *Keyring) AddKey(version int, key []byte) error {
+	if version <= 0 {
+		return errors.New("invalid key version '" + types.String(version) + "'")
+	}
+	if len(key) != MasterKeySize {
+		return errors.New("invalid key size for version '" + types.String(version) + "', should be " + types.String(MasterKeySize) + " bytes")
+	}
+
+	this.locker.Lock()
+	this.keys[version] = key
+	if version > this.currentVersion {
+		this.currentVersion = version
+	}
+	this.locker.Unlock()
+	return nil
+}
+
+// Reload 重新加载主密钥
+func (this *Keyring) Reload() error {
+	if this.loader == nil {
+		return nil
+	}
+	keys, err := this.loader()
+	if err != nil {
+		return err
+	}
+	for version, key := range keys {
+		err = this.AddKey(version, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsEnabled 是否已配置主密钥
+func (this *Keyring) IsEnabled() bool {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return this.currentVersion > 0
+}
+
+// CurrentVersion 当前主密钥版本
+func (this *Keyring) CurrentVersion() int {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return this.currentVersion
+}
+
+// Versions 所有主密钥版本
+func (this *Keyring) Versions() []int {
+	this.locker.RLock()
+	var versions = []int{}
+	for version := range this.keys {
+		versions = append(versions, version)
+	}
+	this.locker.RUnlock()
+	sort.Ints(versions)
+	return versions
+}
+
+// 获取当前主密钥
+func (this *Keyring) currentKey() (version int, key []byte) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return this.currentVersion, this.keys[this.currentVersion]
+}
+
+// 根据版本查找主密钥
+func (this *Keyring) findKey(version int) ([]byte, error) {
+	this.locker.RLock()
+	key, ok := this.keys[version]
+	this.locker.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	// 可能是其他节点轮换了主密钥，尝试重新加载
+	err := this.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	this.locker.RLock()
+	key, ok = this.keys[version]
+	this.locker.RUnlock()
+	if !ok {
+		return nil, errors.New("master key of version '" + types.String(version) + "' not found")
+	}
+	return key, nil
+}
+
+// ParseMasterKeys 分析主密钥定义
+// 每行（或逗号隔开的）一个密钥，格式为 版本:Base64密钥，只有一个密钥时可以省略版本，以"#"开头的为注释
+func ParseMasterKeys(data string) (map[int][]byte, error) {
+	var result = map[int][]byte{}
+	for _, line := range strings.FieldsFunc(data, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	}) {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var version = 1
+		var keyString = line
+		var index = strings.Index(line, ":")
+		if index > 0 {
+			version = types.Int(strings.TrimSpace(line[:index]))
+			keyString = strings.TrimSpace(line[index+1:])
+		}
+		if version <= 0 {
+			return nil, errors.New("invalid master key version in '" + line[:index] + "'")
+		}
+		if _, ok := result[version]; ok {
+			return nil, errors.New("duplicate master key version '" + types.String(version) + "'")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(keyString)
+		if err != nil {
+			return nil, fmt.Errorf("decode master key of version '%d' failed: %w", version, err)
+		}
+		if len(key) != MasterKeySize {
+			return nil, errors.New("invalid key size for version '" + types.String(version) + "', should be " + types.String(MasterKeySize) + " bytes")
+		}
+		result[version] = key
+	}
+	return result, nil
+}
+
+// LoadMasterKeys 从环境变量和文件中加载主密钥
+func LoadMasterKeys() (map[int][]byte, error) {
+	var envValue = os.Getenv(MasterKeyEnv)
+	if len(envValue) > 0 {
+		return ParseMasterKeys(envValue)
+	}
+
+	data, err := os.ReadFile(MasterKeyFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[int][]byte{}, nil
+		}
+		return nil, err
+	}
+	return ParseMasterKeys(string(data))
+}
+
+// MasterKeyFilePath 主密钥文件路径
+func MasterKeyFilePath() string {
+	var path = os.Getenv(MasterKeyFileEnv)
+	if len(path) > 0 {
+		return path
+	}
+	return Tea.ConfigFile(MasterKeyFile)
+}
+
+// GenerateMasterKey 生成新版本的主密钥并追加到主密钥文件中
+func GenerateMasterKey(path string) (version int, err error) {
+	var keys = map[int][]byte{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+	} else {
+		keys, err = ParseMasterKeys(string(data))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for v := range keys {
+		if v > version {
+			version = v
+		}
+	}
+	version++
+
+	var key = make([]byte, MasterKeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, []byte(types.String(version)+":"+base64.StdEncoding.EncodeToString(key)+"\n")...)
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
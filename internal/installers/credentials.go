@@ -1,12 +1,107 @@
 package installers
 
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
 type Credentials struct {
-	Host       string
-	Port       int
-	Username   string
-	Password   string
-	PrivateKey string
-	Passphrase string
-	Method     string
-	Sudo       bool
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	PrivateKey  string
+	Passphrase  string
+	Certificate string // OpenSSH用户证书，需要和私钥配合使用
+	Method      string
+	Sudo        bool
+	NodeId      int64 // 节点ID，用来记录主机公钥
+
+	JumpHosts []*Credentials // 跳板机，按照连接顺序排列
+}
+
+// AuthMethods 获取认证方式
+func (this *Credentials) AuthMethods() ([]ssh.AuthMethod, error) {
+	var methods = []ssh.AuthMethod{}
+	switch this.Method {
+	case "user":
+		methods = append(methods, ssh.Password(this.Password))
+	case "privateKey":
+		var signer ssh.Signer
+		var err error
+		if len(this.Passphrase) > 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(this.PrivateKey), []byte(this.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(this.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private key: %w", err)
+		}
+
+		// 用户证书
+		if len(this.Certificate) > 0 {
+			certSigner, err := this.certSigner(signer)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, ssh.PublicKeys(certSigner, signer))
+		} else {
+			methods = append(methods, ssh.PublicKeys(signer))
+		}
+	default:
+		return nil, errors.New("invalid method '" + this.Method + "'")
+	}
+
+	// 有些服务器只开放了keyboard-interactive认证
+	if len(this.Password) > 0 {
+		methods = append(methods, ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
+			return this.keyboardAnswers(questions, echos), nil
+		}))
+	}
+
+	return methods, nil
+}
+
+// 回答keyboard-interactive问题
+// 只回答不回显的密码问题，其他问题（比如动态口令）回答为空；
+// 如果没有问题中包含password（比如"密码："等本地化的提示），则回答第一个不回显的问题
+func (this *Credentials) keyboardAnswers(questions []string, echos []bool) []string {
+	var answers = make([]string, len(questions))
+	var firstHiddenIndex = -1
+	var hasPasswordQuestion = false
+	for index, question := range questions {
+		var echo = index < len(echos) && echos[index]
+		if echo {
+			continue
+		}
+		if firstHiddenIndex < 0 {
+			firstHiddenIndex = index
+		}
+		if strings.Contains(strings.ToLower(question), "password") {
+			answers[index] = this.Password
+			hasPasswordQuestion = true
+		}
+	}
+	if !hasPasswordQuestion && firstHiddenIndex >= 0 {
+		answers[firstHiddenIndex] = this.Password
+	}
+	return answers
+}
+
+// 使用证书签名
+func (this *Credentials) certSigner(signer ssh.Signer) (ssh.Signer, error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(this.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("parse certificate: not an OpenSSH certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("parse certificate: not a user certificate")
+	}
+	return ssh.NewCertSigner(cert, signer)
 }
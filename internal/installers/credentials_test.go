@@ -0,0 +1,28 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestCredentials_KeyboardAnswers(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	var credentials = &Credentials{Password: "123456"}
+
+	{
+		var answers = credentials.keyboardAnswers([]string{"Password: ", "Verification code: "}, []bool{false, false})
+		a.IsTrue(answers[0] == "123456")
+		a.IsTrue(answers[1] == "")
+	}
+	{
+		var answers = credentials.keyboardAnswers([]string{"Username: ", "密码："}, []bool{true, false})
+		a.IsTrue(answers[0] == "")
+		a.IsTrue(answers[1] == "123456")
+	}
+	{
+		var answers = credentials.keyboardAnswers([]string{"Name: "}, []bool{true})
+		a.IsTrue(answers[0] == "")
+	}
+}
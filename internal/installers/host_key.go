@@ -0,0 +1,52 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+)
+
+// NewHostKeyCallback 获取校验主机公钥的回调函数
+// 第一次连接时记录主机公钥（TOFU），之后公钥发生变化则拒绝连接，直到管理员确认新的公钥
+// 节点的公钥按照节点分别记录，nodeId为0时（比如跳板机）只按照主机地址和端口记录
+func NewHostKeyCallback(nodeId int64, host string, port int) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKey, err := models.SharedSSHHostKeyDAO.FindHostKey(nil, nodeId, host, port)
+		if err != nil {
+			return err
+		}
+
+		// 首次连接
+		if hostKey == nil {
+			return models.SharedSSHHostKeyDAO.PinHostKey(nil, nodeId, host, port, key, models.SSHHostKeySourceTOFU)
+		}
+
+		var fingerprint = ssh.FingerprintSHA256(key)
+		if hostKey.Fingerprint == fingerprint {
+			return nil
+		}
+
+		// 记录新的公钥，等待管理员确认
+		err = models.SharedSSHHostKeyDAO.UpdateHostKeyPending(nil, int64(hostKey.Id), key)
+		if err != nil {
+			return err
+		}
+		return newGrantError("host key of '" + net.JoinHostPort(host, strconv.Itoa(port)) + "' has changed, expected '" + hostKey.Fingerprint + "', but got '" + fingerprint + "'; please verify the new key and approve it before retrying")
+	}
+}
+
+// FindHostKeyAlgorithms 根据已记录的公钥类型获取协商时优先使用的算法
+// 避免服务器优先提供其他类型的公钥导致校验失败
+func FindHostKeyAlgorithms(nodeId int64, host string, port int) []string {
+	hostKey, err := models.SharedSSHHostKeyDAO.FindHostKey(nil, nodeId, host, port)
+	if err != nil || hostKey == nil || len(hostKey.KeyType) == 0 {
+		return nil
+	}
+	if hostKey.KeyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{hostKey.KeyType}
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/iwind/TeaGo/Tea"
 	stringutil "github.com/iwind/TeaGo/utils/string"
 	"golang.org/x/crypto/ssh"
-	"net"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -22,58 +21,63 @@ type BaseInstaller struct {
 
 // Login 登录SSH服务
 func (this *BaseInstaller) Login(credentials *Credentials) error {
-	var hostKeyCallback ssh.HostKeyCallback = nil
+	// 依次连接跳板机
+	var jumpClients = []*ssh.Client{}
+	var closeJumpClients = func() {
+		for i := len(jumpClients) - 1; i >= 0; i-- {
+			_ = jumpClients[i].Close()
+		}
+	}
+	var lastClient *ssh.Client
+	for _, jumpHost := range credentials.JumpHosts {
+		jumpClient, err := this.dial(lastClient, jumpHost)
+		if err != nil {
+			closeJumpClients()
+			return fmt.Errorf("connect to jump host '%s' failed: %w", jumpHost.Host, err)
+		}
+		jumpClients = append(jumpClients, jumpClient)
+		lastClient = jumpClient
+	}
+
+	sshClient, err := this.dial(lastClient, credentials)
+	if err != nil {
+		closeJumpClients()
+		return err
+	}
+	client, err := NewSSHClient(sshClient)
+	if err != nil {
+		closeJumpClients()
+		return err
+	}
+	client.jumpClients = jumpClients
+
+	if credentials.Sudo {
+		client.Sudo(credentials.Password)
+	}
+
+	this.client = client
+
+	return nil
+}
 
+// 连接SSH服务
+// 如果proxyClient不为空，则通过proxyClient转发连接
+func (this *BaseInstaller) dial(proxyClient *ssh.Client, credentials *Credentials) (*ssh.Client, error) {
 	// 检查参数
 	if len(credentials.Host) == 0 {
-		return errors.New("'host' should not be empty")
+		return nil, errors.New("'host' should not be empty")
 	}
 	if credentials.Port <= 0 {
-		return errors.New("'port' should be greater than 0")
+		return nil, errors.New("'port' should be greater than 0")
 	}
 	if len(credentials.Password) == 0 && len(credentials.PrivateKey) == 0 {
-		return errors.New("require user 'password' or 'privateKey'")
-	}
-
-	// 不使用known_hosts
-	if hostKeyCallback == nil {
-		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		}
+		return nil, errors.New("require user 'password' or 'privateKey'")
 	}
 
 	// 认证
-	var methods = []ssh.AuthMethod{}
-	if credentials.Method == "user" {
-		{
-			var authMethod = ssh.Password(credentials.Password)
-			methods = append(methods, authMethod)
-		}
-
-		{
-			authMethod := ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-				if len(questions) == 0 {
-					return []string{}, nil
-				}
-				return []string{credentials.Password}, nil
-			})
-			methods = append(methods, authMethod)
-		}
-	} else if credentials.Method == "privateKey" {
-		var signer ssh.Signer
-		var err error
-		if len(credentials.Passphrase) > 0 {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(credentials.PrivateKey), []byte(credentials.Passphrase))
-		} else {
-			signer, err = ssh.ParsePrivateKey([]byte(credentials.PrivateKey))
-		}
-		if err != nil {
-			return fmt.Errorf("parse private key: %w", err)
-		}
-		authMethod := ssh.PublicKeys(signer)
-		methods = append(methods, authMethod)
-	} else {
-		return errors.New("invalid method '" + credentials.Method + "'")
+	methods, err := credentials.AuthMethods()
+	if err != nil {
+		return nil, err
 	}
 
 	// SSH客户端
@@ -81,28 +85,40 @@ func (this *BaseInstaller) Login(credentials *Credentials) error {
 		credentials.Username = "root"
 	}
 	var config = &ssh.ClientConfig{
-		User:            credentials.Username,
-		Auth:            methods,
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         5 * time.Second, // TODO 后期可以设置这个超时时间
+		User:              credentials.Username,
+		Auth:              methods,
+		HostKeyCallback:   NewHostKeyCallback(credentials.NodeId, credentials.Host, credentials.Port),
+		HostKeyAlgorithms: FindHostKeyAlgorithms(credentials.NodeId, credentials.Host, credentials.Port),
+		Timeout:           5 * time.Second, // TODO 后期可以设置这个超时时间
 	}
 
-	sshClient, err := ssh.Dial("tcp", configutils.QuoteIP(credentials.Host)+":"+strconv.Itoa(credentials.Port), config)
-	if err != nil {
-		return err
+	var addr = configutils.QuoteIP(credentials.Host) + ":" + strconv.Itoa(credentials.Port)
+	if proxyClient == nil {
+		return ssh.Dial("tcp", addr, config)
 	}
-	client, err := NewSSHClient(sshClient)
+
+	conn, err := proxyClient.Dial("tcp", addr)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// 通过跳板机连接时ssh.NewClientConn不会使用config.Timeout，需要自行限制握手时间
+	// 跳板机转发的连接不支持SetDeadline()，所以在超时后直接关闭连接
+	var timer = time.AfterFunc(config.Timeout, func() {
+		_ = conn.Close()
+	})
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() {
+		if err == nil {
+			_ = clientConn.Close()
+		}
+		return nil, errors.New("ssh handshake with '" + addr + "' timeout")
 	}
-
-	if credentials.Sudo {
-		client.Sudo(credentials.Password)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
 	}
-
-	this.client = client
-
-	return nil
+	return ssh.NewClient(clientConn, chans, reqs), nil
 }
 
 // Close 关闭SSH服务
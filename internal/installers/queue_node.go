@@ -181,16 +181,24 @@ func (this *NodeQueue) InstallNode(nodeId int64, installStatus *models.NodeInsta
 		IsUpgrading: isUpgrading,
 	}
 
+	jumpHosts, err := this.findJumpHosts(loginParams)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
 	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
+		Host:        loginParams.Host,
+		Port:        loginParams.Port,
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+		Sudo:        grant.Su == 1,
+		NodeId:      nodeId,
+		JumpHosts:   jumpHosts,
 	})
 	if err != nil {
 		installStatus.ErrorCode = "SSH_LOGIN_FAILED"
@@ -290,16 +298,24 @@ func (this *NodeQueue) StartNode(nodeId int64) error {
 		return newGrantError("can not find user grant with id '" + numberutils.FormatInt64(loginParams.GrantId) + "'")
 	}
 
+	jumpHosts, err := this.findJumpHosts(loginParams)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
 	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
+		Host:        loginParams.Host,
+		Port:        loginParams.Port,
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+		Sudo:        grant.Su == 1,
+		NodeId:      nodeId,
+		JumpHosts:   jumpHosts,
 	})
 	if err != nil {
 		return err
@@ -400,16 +416,24 @@ func (this *NodeQueue) StopNode(nodeId int64) error {
 		return errors.New("can not find user grant with id '" + numberutils.FormatInt64(loginParams.GrantId) + "'")
 	}
 
+	jumpHosts, err := this.findJumpHosts(loginParams)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
 	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
+		Host:        loginParams.Host,
+		Port:        loginParams.Port,
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+		Sudo:        grant.Su == 1,
+		NodeId:      nodeId,
+		JumpHosts:   jumpHosts,
 	})
 	if err != nil {
 		return err
@@ -510,16 +534,24 @@ func (this *NodeQueue) UninstallNode(nodeId int64) error {
 		return errors.New("can not find user grant with id '" + numberutils.FormatInt64(loginParams.GrantId) + "'")
 	}
 
+	jumpHosts, err := this.findJumpHosts(loginParams)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
 	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
+		Host:        loginParams.Host,
+		Port:        loginParams.Port,
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+		Sudo:        grant.Su == 1,
+		NodeId:      nodeId,
+		JumpHosts:   jumpHosts,
 	})
 	if err != nil {
 		return err
@@ -549,6 +581,46 @@ func (this *NodeQueue) UninstallNode(nodeId int64) error {
 	return nil
 }
 
+// 查找跳板机登录信息
+func (this *NodeQueue) findJumpHosts(loginParams *models.NodeLoginSSHParams) ([]*Credentials, error) {
+	var result = []*Credentials{}
+	for _, jumpHost := range loginParams.JumpHosts {
+		if jumpHost == nil || len(jumpHost.Host) == 0 {
+			continue
+		}
+
+		var port = jumpHost.Port
+		if port <= 0 {
+			port = 22
+		}
+
+		// 默认使用和节点相同的认证
+		var grantId = jumpHost.GrantId
+		if grantId <= 0 {
+			grantId = loginParams.GrantId
+		}
+		grant, err := models.SharedNodeGrantDAO.FindEnabledNodeGrant(nil, grantId)
+		if err != nil {
+			return nil, err
+		}
+		if grant == nil {
+			return nil, newGrantError("can not find user grant with id '" + numberutils.FormatInt64(grantId) + "' for jump host '" + jumpHost.Host + "'")
+		}
+
+		result = append(result, &Credentials{
+			Host:        jumpHost.Host,
+			Port:        port,
+			Username:    grant.Username,
+			Password:    grant.Password,
+			PrivateKey:  grant.PrivateKey,
+			Passphrase:  grant.Passphrase,
+			Certificate: grant.Certificate,
+			Method:      grant.Method,
+		})
+	}
+	return result, nil
+}
+
 func (this *NodeQueue) lookupNodeExe(node *models.Node, client *SSHClient) (string, error) {
 	// 安装目录
 	var nodeDirs = []string{}
@@ -16,6 +16,8 @@ type SSHClient struct {
 
 	sudo         bool
 	sudoPassword string
+
+	jumpClients []*ssh.Client // 经过的跳板机
 }
 
 func NewSSHClient(raw *ssh.Client) (*SSHClient, error) {
@@ -149,7 +151,13 @@ func (this *SSHClient) Close() error {
 	if this.sftp != nil {
 		_ = this.sftp.Close()
 	}
-	return this.raw.Close()
+	var err = this.raw.Close()
+
+	// 从近到远关闭跳板机
+	for i := len(this.jumpClients) - 1; i >= 0; i-- {
+		_ = this.jumpClients[i].Close()
+	}
+	return err
 }
 
 func (this *SSHClient) OpenFile(path string, flags int) (*sftp.File, error) {
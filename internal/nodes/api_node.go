@@ -798,6 +798,24 @@ func (this *APINode) listenSock() error {
 						"code": teaconst.InstanceCode,
 					},
 				})
+			case "secrets.encrypt": // 加密敏感数据
+				result, err := this.encryptSecrets()
+				if err != nil {
+					_ = cmd.Reply(&gosock.Command{
+						Params: map[string]any{
+							"isOk":   false,
+							"err":    err.Error(),
+							"result": result,
+						},
+					})
+				} else {
+					_ = cmd.Reply(&gosock.Command{
+						Params: map[string]any{
+							"isOk":   true,
+							"result": result,
+						},
+					})
+				}
 			case "lookupToken":
 				var role = maps.NewMap(cmd.Params).GetString("role")
 				switch role {
@@ -852,6 +870,12 @@ func (this *APINode) listenSock() error {
 
 // 服务过滤器
 func (this *APINode) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	// 检查权限
+	ctx, err = this.checkPermission(ctx, info.FullMethod, req)
+	if err != nil {
+		return nil, err
+	}
+
 	if teaconst.Debug {
 		var before = time.Now()
 		var traceCtx = rpc.NewContext(ctx)
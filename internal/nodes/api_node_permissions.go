@@ -0,0 +1,105 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 检查管理员和子用户调用RPC方法的权限
+// 如果是子用户调用，则返回带有子用户ID的上下文
+func (this *APINode) checkPermission(ctx context.Context, fullMethod string, req any) (context.Context, error) {
+	// 先通过缓存的API令牌判断调用者角色，避免对节点的请求重复解析令牌
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	var nodeIds = md.Get("nodeid")
+	if len(nodeIds) == 0 || len(nodeIds[0]) == 0 {
+		return ctx, nil
+	}
+	apiToken, err := models.SharedApiTokenDAO.FindEnabledTokenWithNodeCacheable(nil, nodeIds[0])
+	if err != nil || apiToken == nil {
+		return ctx, nil
+	}
+
+	switch apiToken.Role {
+	case rpcutils.UserTypeAdmin:
+		return ctx, this.checkAdminPermission(ctx, fullMethod)
+	case rpcutils.UserTypeUser:
+		return this.checkUserPermission(ctx, fullMethod, req)
+	}
+	return ctx, nil
+}
+
+// 检查管理员权限
+func (this *APINode) checkAdminPermission(ctx context.Context, fullMethod string) error {
+	if permissions.IsPublicMethod(fullMethod) {
+		return nil
+	}
+
+	// 认证失败的请求交给具体的服务处理
+	_, _, adminId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin)
+	if err != nil || adminId <= 0 {
+		return nil
+	}
+
+	err = this.checkTwoFactorSetup(adminId, 0, fullMethod)
+	if err != nil {
+		return err
+	}
+
+	allowed, module, action, err := permissions.CheckAdminMethod(nil, adminId, fullMethod)
+	if err != nil {
+		remotelogs.Error("API_NODE", "check admin permission failed: "+err.Error())
+		return status.Error(codes.Internal, "check admin permission failed")
+	}
+	if !allowed {
+		return status.Error(codes.PermissionDenied, "permission denied, require '"+module+":"+action+"'")
+	}
+	return nil
+}
+
+// 检查平台用户和子用户权限
+func (this *APINode) checkUserPermission(ctx context.Context, fullMethod string, req any) (context.Context, error) {
+	userType, _, userId, subUserId, err := rpcutils.ValidateRequestWithSubUser(ctx, rpcutils.UserTypeUser)
+	if err != nil || userType != rpcutils.UserTypeUser || userId <= 0 {
+		return ctx, nil
+	}
+
+	// 子用户只支持OTP认证，不受双因素认证策略限制
+	if subUserId <= 0 {
+		return ctx, this.checkTwoFactorSetup(0, userId, fullMethod)
+	}
+
+	err = models.SharedSubUserDAO.CheckUserSubUser(nil, userId, subUserId)
+	if err != nil {
+		return ctx, status.Error(codes.PermissionDenied, "permission denied: invalid sub user")
+	}
+
+	err = permissions.CheckSubUserMethod(nil, subUserId, fullMethod, req)
+	if err != nil {
+		return ctx, status.Error(codes.PermissionDenied, "permission denied: "+err.Error())
+	}
+	return rpcutils.WithSubUserId(ctx, subUserId), nil
+}
+
+// 检查是否已经按照策略设置了双因素认证
+func (this *APINode) checkTwoFactorSetup(adminId int64, userId int64, fullMethod string) error {
+	allowed, err := permissions.CheckTwoFactorSetup(nil, adminId, userId, fullMethod)
+	if err != nil {
+		remotelogs.Error("API_NODE", "check two factor setup failed: "+err.Error())
+		return status.Error(codes.Internal, "check two factor setup failed")
+	}
+	if !allowed {
+		return status.Error(codes.PermissionDenied, "permission denied: two factor authentication should be set up first")
+	}
+	return nil
+}
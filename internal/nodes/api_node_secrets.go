@@ -0,0 +1,58 @@
+// Copyright 2022 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"sync"
+)
+
+var secretsLocker = &sync.Mutex{}
+
+// 使用当前主密钥加密或重新加密所有敏感数据
+// 可以在节点运行中执行，用于迁移旧数据和轮换主密钥
+func (this *APINode) encryptSecrets() (maps.Map, error) {
+	secretsLocker.Lock()
+	defer secretsLocker.Unlock()
+
+	var keyring = encrypt.SharedKeyring()
+	err := keyring.Reload()
+	if err != nil {
+		return nil, errors.New("load master keys failed: " + err.Error())
+	}
+	if !keyring.IsEnabled() {
+		return nil, errors.New("master key not configured, please set '" + encrypt.MasterKeyEnv + "' or create key file '" + encrypt.MasterKeyFilePath() + "'")
+	}
+
+	var result = maps.Map{
+		"keyVersion": keyring.CurrentVersion(),
+	}
+
+	var tasks = []struct {
+		name string
+		f    func() (int64, error)
+	}{
+		{"sslCerts", func() (int64, error) { return models.SharedSSLCertDAO.RewrapCertKeys(nil) }},
+		{"nodeGrants", func() (int64, error) { return models.SharedNodeGrantDAO.RewrapGrantSecrets(nil) }},
+		{"dbNodes", func() (int64, error) { return models.SharedDBNodeDAO.RewrapPasswords(nil) }},
+		{"dnsProviders", func() (int64, error) { return dns.SharedDNSProviderDAO.RewrapAPIParams(nil) }},
+		{"acmeUsers", func() (int64, error) { return acme.SharedACMEUserDAO.RewrapPrivateKeys(nil) }},
+	}
+	for _, task := range tasks {
+		count, err := task.f()
+		result[task.name] = count
+		if err != nil {
+			return result, errors.New("encrypt '" + task.name + "' failed: " + err.Error())
+		}
+	}
+
+	remotelogs.Println("API_NODE", "encrypted secrets with master key version '"+types.String(keyring.CurrentVersion())+"'")
+	return result, nil
+}
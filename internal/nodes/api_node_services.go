@@ -314,6 +314,11 @@ func (this *APINode) registerServices(server *grpc.Server) {
 		pb.RegisterUserServiceServer(server, instance)
 		this.rest(instance)
 	}
+	{
+		var instance = this.serviceInstance(&users.SubUserService{}).(*users.SubUserService)
+		pb.RegisterSubUserServiceServer(server, instance)
+		this.rest(instance)
+	}
 	{
 		var instance = this.serviceInstance(&services.UserIdentityService{}).(*services.UserIdentityService)
 		pb.RegisterUserIdentityServiceServer(server, instance)
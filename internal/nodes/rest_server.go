@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/sizes"
@@ -197,6 +198,29 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// 检查管理员权限
+	plainCtx, isPlain := ctx.(*rpcutils.PlainContext)
+	if isPlain && plainCtx.UserType == rpcutils.UserTypeAdmin {
+		allowed, module, action, err := permissions.CheckAdminMethod(nil, plainCtx.UserId, "/pb."+serviceName+"/"+methodName)
+		if err != nil {
+			this.writeJSON(writer, maps.Map{
+				"code":    500,
+				"data":    maps.Map{},
+				"message": "server error: " + err.Error(),
+			}, shouldPretty)
+			return
+		}
+		if !allowed {
+			writer.WriteHeader(http.StatusForbidden)
+			this.writeJSON(writer, maps.Map{
+				"code":    403,
+				"data":    maps.Map{},
+				"message": "permission denied, require '" + module + ":" + action + "'",
+			}, shouldPretty)
+			return
+		}
+	}
+
 	// TODO 可以设置最大可接收内容尺寸
 	body, err := io.ReadAll(io.LimitReader(req.Body, 32*sizes.M))
 	if err != nil {
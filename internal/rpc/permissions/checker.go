@@ -0,0 +1,94 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ttlcache"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+// 管理员权限缓存时间
+const adminCacheSeconds = 30
+
+var adminCache = ttlcache.NewCache(ttlcache.NewPiecesOption(4), ttlcache.NewMaxItemsOption(10_000))
+
+type adminPermissions struct {
+	isSuper      bool
+	isRestricted bool // 是否设置了角色，没有设置角色的管理员保持原有的权限，设置了角色的管理员默认拒绝
+	list         PermissionList
+}
+
+// CheckAdmin 检查管理员是否可以对某个模块执行某个操作
+func CheckAdmin(tx *dbs.Tx, adminId int64, module Module, action Action) (bool, error) {
+	if adminId <= 0 {
+		return true, nil
+	}
+
+	perms, err := findAdminPermissions(tx, adminId)
+	if err != nil {
+		return false, err
+	}
+	if perms.isSuper || !perms.isRestricted {
+		return true, nil
+	}
+	return perms.list.Allow(module, action), nil
+}
+
+// CheckAdminMethod 检查管理员是否可以调用某个RPC方法
+// 没有登记模块的方法需要所有模块的修改权限
+func CheckAdminMethod(tx *dbs.Tx, adminId int64, fullMethod string) (allowed bool, module Module, action Action, err error) {
+	if IsPublicMethod(fullMethod) {
+		return true, "", "", nil
+	}
+	module, action, ok := FindMethodPermission(fullMethod)
+	if !ok {
+		module, action = ModuleAll, ActionWrite
+	}
+	allowed, err = CheckAdmin(tx, adminId, module, action)
+	return
+}
+
+// ResetCache 清除权限缓存
+// 在修改角色、管理员角色或者双因素认证设置后调用
+func ResetCache() {
+	adminCache.Clean()
+	twoFactorCache.Clean()
+}
+
+// 查找管理员的权限
+func findAdminPermissions(tx *dbs.Tx, adminId int64) (*adminPermissions, error) {
+	var cacheKey = types.String(adminId)
+	var item = adminCache.Read(cacheKey)
+	if item != nil {
+		return item.Value.(*adminPermissions), nil
+	}
+
+	isSuper, roleIds, err := models.SharedAdminDAO.FindAdminRoleIds(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+	var perms = &adminPermissions{
+		isSuper:      isSuper,
+		isRestricted: len(roleIds) > 0,
+	}
+	if !isSuper && len(roleIds) > 0 {
+		permissionStrings, err := models.SharedAdminRoleDAO.FindRolesPermissions(tx, roleIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range permissionStrings {
+			permission, parseErr := ParsePermission(s)
+			if parseErr != nil {
+				// 忽略无法识别的权限
+				continue
+			}
+			perms.list = append(perms.list, permission)
+		}
+	}
+
+	adminCache.Write(cacheKey, perms, time.Now().Unix()+adminCacheSeconds)
+	return perms, nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions
+
+import "strings"
+
+// 服务和模块的对应关系，优先于前缀匹配
+var serviceModuleMap = map[string]Module{
+	"AdminService":              ModuleSettings,
+	"APITokenService":           ModuleSettings,
+	"SysSettingService":         ModuleSettings,
+	"SysLockerService":          ModuleSettings,
+	"LogService":                ModuleSettings,
+	"MessageService":            ModuleSettings,
+	"DBService":                 ModuleSettings,
+	"DBNodeService":             ModuleSettings,
+	"APIMethodStatService":      ModuleSettings,
+	"LoginSessionService":       ModuleSettings,
+	"PlanService":               ModuleBilling,
+	"UserPlanService":           ModuleBilling,
+	"UserService":               ModuleUsers,
+	"UserIdentityService":       ModuleUsers,
+	"UserAccessKeyService":      ModuleUsers,
+	"SubUserService":            ModuleUsers,
+	"LoginService":              ModuleUsers,
+	"LoginTicketService":        ModuleUsers,
+	"APINodeService":            ModuleClusters,
+	"AuthorityNodeService":      ModuleClusters,
+	"OriginService":             ModuleServers,
+	"ReverseProxyService":       ModuleServers,
+	"IPListService":             ModuleServers,
+	"IPItemService":             ModuleServers,
+	"FirewallService":           ModuleServers,
+	"UpdatingServerListService": ModuleServers,
+	"TrafficDailyStatService":   ModuleServers,
+	"FileService":               ModuleServers,
+	"FileChunkService":          ModuleServers,
+}
+
+// 服务名前缀和模块的对应关系
+var servicePrefixModules = []struct {
+	prefix string
+	module Module
+}{
+	{"Node", ModuleClusters},
+	{"Server", ModuleServers},
+	{"HTTP", ModuleServers},
+	{"Metric", ModuleServers},
+	{"DNS", ModuleDNS},
+	{"SSL", ModuleCertificates},
+	{"ACME", ModuleCertificates},
+	{"Region", ModuleSettings},
+	{"IPLibrary", ModuleSettings},
+	{"FormalClient", ModuleSettings},
+	{"ClientAgent", ModuleSettings},
+}
+
+// 不需要检查权限的方法，主要是管理员登录和修改个人信息
+// 其中管理员信息、登录信息和SESSION的方法会在服务中检查所操作的账号
+var publicMethods = map[string]bool{
+	"AdminService.LoginAdmin":                true,
+	"AdminService.CheckAdminExists":          true,
+	"AdminService.CheckAdminUsername":        true,
+	"AdminService.FindAdminFullname":         true,
+	"AdminService.FindEnabledAdmin":          true,
+	"AdminService.UpdateAdminInfo":           true,
+	"AdminService.UpdateAdminLogin":          true,
+	"AdminService.UpdateAdminTheme":          true,
+	"AdminService.UpdateAdminLang":           true,
+	"AdminService.FindAllAdminModules":       true,
+	"AdminService.CheckAdminOTPWithUsername": true,
+	"AdminService.ComposeAdminDashboard":     true,
+	"AdminService.CheckAdminPermission":      true,
+	"AdminService.FindAdminSSOProviders":     true,
+	"AdminService.BeginAdminOIDCLogin":       true,
+	"AdminService.FinishAdminOIDCLogin":      true,
+	"AdminService.LoginAdminLDAP":            true,
+
+	"LoginService.FindEnabledLogin":                true,
+	"LoginService.UpdateLogin":                     true,
+	"LoginService.CheckLoginOTP":                   true,
+	"LoginService.CheckLoginRecoveryCode":          true,
+	"LoginService.CountLoginRecoveryCodes":         true,
+	"LoginService.ResetLoginRecoveryCodes":         true,
+	"LoginService.BeginWebAuthnLogin":              true,
+	"LoginService.FinishWebAuthnLogin":             true,
+	"LoginService.BeginWebAuthnRegistration":       true,
+	"LoginService.FinishWebAuthnRegistration":      true,
+	"LoginService.FindAllWebAuthnCredentials":      true,
+	"LoginService.DeleteWebAuthnCredential":        true,
+	"LoginSessionService.WriteLoginSessionValue":   true,
+	"LoginSessionService.FindLoginSession":         true,
+	"LoginSessionService.DeleteLoginSession":       true,
+	"LoginSessionService.ClearOldLoginSessions":    true,
+	"LoginSessionService.FindAllLoginSessions":     true,
+	"LoginSessionService.DeleteLoginSessionWithId": true,
+	"LoginSessionService.DeleteAllLoginSessions":   true,
+	"APIAccessTokenService.GetAPIAccessToken":      true,
+	"LatestItemService.IncreaseLatestItem":         true,
+	"PingService.Ping":                             true,
+}
+
+// 只读方法的前缀
+var readMethodPrefixes = []string{"Find", "List", "Count", "Check", "Exists", "Read", "Lookup", "Compose", "Sum", "Search"}
+
+// IsPublicMethod 判断RPC方法是否不需要检查权限
+func IsPublicMethod(fullMethod string) bool {
+	service, method, found := splitFullMethod(fullMethod)
+	return found && publicMethods[service+"."+method]
+}
+
+// FindMethodPermission 查找RPC方法需要的权限
+// fullMethod 格式为 /pb.ServerService/FindEnabledServer
+// 公开的方法和没有登记模块的方法返回的 ok 为false
+func FindMethodPermission(fullMethod string) (module Module, action Action, ok bool) {
+	service, method, found := splitFullMethod(fullMethod)
+	if !found {
+		return
+	}
+	if publicMethods[service+"."+method] {
+		return
+	}
+
+	module, found = serviceModuleMap[service]
+	if !found {
+		for _, prefixModule := range servicePrefixModules {
+			if strings.HasPrefix(service, prefixModule.prefix) {
+				module = prefixModule.module
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return "", "", false
+	}
+
+	action = ActionWrite
+	for _, prefix := range readMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			action = ActionRead
+			break
+		}
+	}
+	return module, action, true
+}
+
+// 分析方法全称
+func splitFullMethod(fullMethod string) (service string, method string, ok bool) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	var index = strings.LastIndex(fullMethod, "/")
+	if index <= 0 {
+		return
+	}
+	service = fullMethod[:index]
+	method = fullMethod[index+1:]
+
+	// 去除包名
+	var dotIndex = strings.LastIndex(service, ".")
+	if dotIndex >= 0 {
+		service = service[dotIndex+1:]
+	}
+	return service, method, len(service) > 0 && len(method) > 0
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestFindMethodPermission(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	for _, c := range []struct {
+		method string
+		module permissions.Module
+		action permissions.Action
+		ok     bool
+	}{
+		{"/pb.ServerService/FindEnabledServer", permissions.ModuleServers, permissions.ActionRead, true},
+		{"/pb.ServerService/UpdateServerWeb", permissions.ModuleServers, permissions.ActionWrite, true},
+		{"/pb.NodeClusterService/ListEnabledNodeClusters", permissions.ModuleClusters, permissions.ActionRead, true},
+		{"/pb.DNSDomainService/CreateDNSDomain", permissions.ModuleDNS, permissions.ActionWrite, true},
+		{"/pb.SSLCertService/DeleteSSLCert", permissions.ModuleCertificates, permissions.ActionWrite, true},
+		{"/pb.UserPlanService/BuyUserPlan", permissions.ModuleBilling, permissions.ActionWrite, true},
+		{"/pb.UserService/CountAllEnabledUsers", permissions.ModuleUsers, permissions.ActionRead, true},
+		{"/pb.AdminService/CreateAdmin", permissions.ModuleSettings, permissions.ActionWrite, true},
+		{"/pb.SubUserService/UpdateSubUser", permissions.ModuleUsers, permissions.ActionWrite, true},
+		{"/pb.LoginService/UnlockLogin", permissions.ModuleUsers, permissions.ActionWrite, true},
+		{"/pb.LoginService/UpdateLogin", "", "", false},
+		{"/pb.LoginSessionService/FindAllLoginSessions", "", "", false},
+		{"/pb.UnknownService/DoSomething", "", "", false},
+		{"/pb.AdminService/LoginAdmin", "", "", false},
+		{"/pb.AdminService/FinishAdminOIDCLogin", "", "", false},
+		{"/pb.AdminService/LoginAdminLDAP", "", "", false},
+		{"/pb.PingService/Ping", "", "", false},
+		{"invalid", "", "", false},
+	} {
+		module, action, ok := permissions.FindMethodPermission(c.method)
+		a.IsTrue(module == c.module)
+		a.IsTrue(action == c.action)
+		a.IsTrue(ok == c.ok)
+	}
+}
+
+func TestIsPublicMethod(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	a.IsTrue(permissions.IsPublicMethod("/pb.AdminService/LoginAdmin"))
+	a.IsTrue(permissions.IsPublicMethod("/pb.LoginSessionService/FindLoginSession"))
+	a.IsTrue(permissions.IsPublicMethod("/pb.PingService/Ping"))
+	a.IsFalse(permissions.IsPublicMethod("/pb.AdminService/CreateAdmin"))
+	a.IsFalse(permissions.IsPublicMethod("/pb.UnknownService/DoSomething"))
+	a.IsFalse(permissions.IsPublicMethod("invalid"))
+}
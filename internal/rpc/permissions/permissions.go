@@ -0,0 +1,117 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions
+
+import (
+	"errors"
+	"strings"
+)
+
+type Module = string
+
+const (
+	ModuleAll          Module = "*"            // 所有模块
+	ModuleClusters     Module = "clusters"     // 集群和节点
+	ModuleServers      Module = "servers"      // 网站服务
+	ModuleDNS          Module = "dns"          // 域名解析
+	ModuleCertificates Module = "certificates" // 证书
+	ModuleUsers        Module = "users"        // 平台用户
+	ModuleBilling      Module = "billing"      // 财务
+	ModuleSettings     Module = "settings"     // 系统设置
+)
+
+type Action = string
+
+const (
+	ActionRead  Action = "read"  // 读取
+	ActionWrite Action = "write" // 修改，包含读取
+)
+
+// AllModules 所有可以授权的模块
+func AllModules() []Module {
+	return []Module{
+		ModuleClusters,
+		ModuleServers,
+		ModuleDNS,
+		ModuleCertificates,
+		ModuleUsers,
+		ModuleBilling,
+		ModuleSettings,
+	}
+}
+
+// Permission 权限
+// 字符串格式为 模块:操作，比如 servers:read
+type Permission struct {
+	Module Module
+	Action Action
+}
+
+// ParsePermission 分析权限字符串
+func ParsePermission(s string) (*Permission, error) {
+	var index = strings.Index(s, ":")
+	if index <= 0 {
+		return nil, errors.New("invalid permission '" + s + "'")
+	}
+	var module = strings.TrimSpace(s[:index])
+	var action = strings.TrimSpace(s[index+1:])
+
+	if module != ModuleAll && !isValidModule(module) {
+		return nil, errors.New("invalid permission module '" + module + "'")
+	}
+	if action != ActionRead && action != ActionWrite {
+		return nil, errors.New("invalid permission action '" + action + "'")
+	}
+	return &Permission{
+		Module: module,
+		Action: action,
+	}, nil
+}
+
+// String 转换为字符串
+func (this *Permission) String() string {
+	return this.Module + ":" + this.Action
+}
+
+// Allow 是否允许对某个模块执行某个操作
+func (this *Permission) Allow(module Module, action Action) bool {
+	if this.Module != ModuleAll && this.Module != module {
+		return false
+	}
+	return this.Action == ActionWrite || this.Action == action
+}
+
+// PermissionList 权限列表
+type PermissionList []*Permission
+
+// ParsePermissionList 分析一组权限字符串
+func ParsePermissionList(permissionStrings []string) (PermissionList, error) {
+	var result = PermissionList{}
+	for _, s := range permissionStrings {
+		permission, err := ParsePermission(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, permission)
+	}
+	return result, nil
+}
+
+// Allow 是否允许对某个模块执行某个操作
+func (this PermissionList) Allow(module Module, action Action) bool {
+	for _, permission := range this {
+		if permission.Allow(module, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidModule(module Module) bool {
+	for _, m := range AllModules() {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
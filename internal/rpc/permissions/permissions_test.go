@@ -0,0 +1,48 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestParsePermission(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		permission, err := permissions.ParsePermission("servers:read")
+		a.IsNil(err)
+		a.IsTrue(permission.Allow(permissions.ModuleServers, permissions.ActionRead))
+		a.IsFalse(permission.Allow(permissions.ModuleServers, permissions.ActionWrite))
+		a.IsFalse(permission.Allow(permissions.ModuleDNS, permissions.ActionRead))
+		a.IsTrue(permission.String() == "servers:read")
+	}
+
+	{
+		permission, err := permissions.ParsePermission("*:write")
+		a.IsNil(err)
+		a.IsTrue(permission.Allow(permissions.ModuleBilling, permissions.ActionRead))
+		a.IsTrue(permission.Allow(permissions.ModuleSettings, permissions.ActionWrite))
+	}
+
+	for _, s := range []string{"", "servers", "servers:delete", "unknown:read", ":read"} {
+		_, err := permissions.ParsePermission(s)
+		a.IsTrue(err != nil)
+	}
+}
+
+func TestPermissionList_Allow(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	list, err := permissions.ParsePermissionList([]string{"servers:write", "dns:read"})
+	a.IsNil(err)
+	a.IsTrue(list.Allow(permissions.ModuleServers, permissions.ActionWrite))
+	a.IsTrue(list.Allow(permissions.ModuleDNS, permissions.ActionRead))
+	a.IsFalse(list.Allow(permissions.ModuleDNS, permissions.ActionWrite))
+	a.IsFalse(list.Allow(permissions.ModuleUsers, permissions.ActionRead))
+
+	_, err = permissions.ParsePermissionList([]string{"servers:write", "bad"})
+	a.IsTrue(err != nil)
+}
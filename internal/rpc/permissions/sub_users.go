@@ -0,0 +1,251 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/iwind/TeaGo/dbs"
+	"strings"
+)
+
+// 子用户不能调用的服务，主要是账号相关的功能
+var subUserDeniedServices = map[string]bool{
+	"SubUserService":       true,
+	"UserAccessKeyService": true,
+	"UserIdentityService":  true,
+}
+
+// 子用户只能读取的服务
+var subUserReadOnlyServices = map[string]bool{
+	"UserService":     true,
+	"UserPlanService": true,
+	"PlanService":     true,
+}
+
+// 子用户可以直接调用的服务前缀，不需要检查功能
+var subUserPublicPrefixes = []string{"LoginSession", "Region"}
+
+// 需要检查网站范围的服务前缀
+var subUserServerPrefixes = []string{"Server", "HTTP", "Origin", "ReverseProxy", "IPList", "IPItem"}
+
+// 只需要检查功能的服务前缀，这些数据属于主用户，不属于某个网站
+// 创建缓存任务时会在服务中检查每个Key所属的网站
+var subUserAccountPrefixes = []string{"SSL", "ACME", "HTTPCacheTask"}
+
+// 子用户调用时会在服务中按照网站范围过滤结果的方法
+var subUserScopedListMethods = map[string]bool{
+	"ServerService.ListEnabledServersMatch":             true,
+	"ServerService.CountAllEnabledServersMatch":         true,
+	"ServerService.FindAllUserServers":                  true,
+	"ServerService.CountAllUserServers":                 true,
+	"ServerService.FindAllEnabledServerNamesWithUserId": true,
+}
+
+// FindSubUserMethodFeature 查找子用户调用RPC方法需要的功能
+// denied 表示子用户不能调用此方法；feature 为空表示不需要检查
+// 没有登记的服务子用户都不能调用
+func FindSubUserMethodFeature(fullMethod string) (feature string, denied bool) {
+	service, method, ok := splitFullMethod(fullMethod)
+	if !ok {
+		return "", true
+	}
+
+	var isRead = false
+	for _, prefix := range readMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			isRead = true
+			break
+		}
+	}
+
+	if subUserDeniedServices[service] {
+		return "", true
+	}
+	if subUserReadOnlyServices[service] {
+		return "", !isRead
+	}
+	for _, prefix := range subUserPublicPrefixes {
+		if strings.HasPrefix(service, prefix) {
+			return "", false
+		}
+	}
+
+	// 缓存
+	if strings.HasPrefix(service, "HTTPCacheTask") {
+		return models.SubUserFeaturePurge, false
+	}
+
+	// 统计
+	if strings.Contains(service, "Stat") || strings.HasPrefix(service, "Metric") || service == "HTTPAccessLogService" {
+		if !isRead {
+			return "", true
+		}
+		return models.SubUserFeatureStat, false
+	}
+
+	for _, prefix := range append(subUserServerPrefixes, subUserAccountPrefixes...) {
+		if strings.HasPrefix(service, prefix) {
+			if isRead {
+				return models.SubUserFeatureView, false
+			}
+			return models.SubUserFeatureConfig, false
+		}
+	}
+
+	return "", true
+}
+
+// CheckSubUserMethod 检查子用户是否可以调用某个RPC方法
+// 网站相关的请求需要能找到所属的网站，并检查网站是否在子用户的管理范围内；找不到所属网站的请求不允许调用
+func CheckSubUserMethod(tx *dbs.Tx, subUserId int64, fullMethod string, req any) error {
+	if subUserId <= 0 {
+		return nil
+	}
+
+	feature, denied := FindSubUserMethodFeature(fullMethod)
+	if denied {
+		return errors.New("sub user is not allowed to call this method")
+	}
+	if len(feature) == 0 {
+		return nil
+	}
+
+	service, method, _ := splitFullMethod(fullMethod)
+	if !isSubUserServerService(service) {
+		return models.SharedSubUserDAO.CheckSubUserFeature(tx, subUserId, feature)
+	}
+
+	// 列表由服务按照子用户的网站范围过滤
+	if subUserScopedListMethods[service+"."+method] {
+		return models.SharedSubUserDAO.CheckSubUserFeature(tx, subUserId, feature)
+	}
+
+	scope, err := findRequestServerScope(tx, req)
+	if err != nil {
+		return err
+	}
+	if scope.isEmpty() {
+		return errors.New("sub user is not allowed to call this method without a server")
+	}
+	for _, serverId := range scope.serverIds {
+		if serverId <= 0 {
+			return models.ErrNotFound
+		}
+		err = models.SharedSubUserDAO.CheckSubUserServer(tx, subUserId, serverId, feature)
+		if err != nil {
+			return err
+		}
+	}
+	for _, groupId := range scope.groupIds {
+		err = models.SharedSubUserDAO.CheckSubUserServerGroup(tx, subUserId, groupId, feature)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isSubUserServerService(service string) bool {
+	for _, prefix := range subUserAccountPrefixes {
+		if strings.HasPrefix(service, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range subUserServerPrefixes {
+		if strings.HasPrefix(service, prefix) {
+			return true
+		}
+	}
+
+	// 统计
+	return strings.Contains(service, "Stat") || strings.HasPrefix(service, "Metric")
+}
+
+// 请求涉及的网站范围
+type serverScope struct {
+	serverIds []int64 // 为0的ID表示没有找到所属网站
+	groupIds  []int64
+}
+
+func (this *serverScope) isEmpty() bool {
+	return len(this.serverIds) == 0 && len(this.groupIds) == 0
+}
+
+// 从请求中查找涉及的网站和网站分组
+func findRequestServerScope(tx *dbs.Tx, req any) (*serverScope, error) {
+	var scope = &serverScope{}
+
+	if r, ok := req.(interface{ GetServerId() int64 }); ok && r.GetServerId() > 0 {
+		scope.serverIds = append(scope.serverIds, r.GetServerId())
+	}
+	if r, ok := req.(interface{ GetServerIds() []int64 }); ok {
+		scope.serverIds = append(scope.serverIds, r.GetServerIds()...)
+	}
+	if r, ok := req.(interface{ GetServerGroupId() int64 }); ok && r.GetServerGroupId() > 0 {
+		scope.groupIds = append(scope.groupIds, r.GetServerGroupId())
+	}
+
+	var resolvers = []struct {
+		getId   func() (int64, bool)
+		resolve func(tx *dbs.Tx, id int64) (int64, error)
+	}{
+		{
+			getId: func() (int64, bool) {
+				r, ok := req.(interface{ GetHttpWebId() int64 })
+				return getRequestId(r, ok, func() int64 { return r.GetHttpWebId() })
+			},
+			resolve: models.SharedHTTPWebDAO.FindWebServerId,
+		},
+		{
+			getId: func() (int64, bool) {
+				r, ok := req.(interface{ GetReverseProxyId() int64 })
+				return getRequestId(r, ok, func() int64 { return r.GetReverseProxyId() })
+			},
+			resolve: models.SharedServerDAO.FindEnabledServerIdWithReverseProxyId,
+		},
+		{
+			getId: func() (int64, bool) {
+				r, ok := req.(interface{ GetOriginId() int64 })
+				return getRequestId(r, ok, func() int64 { return r.GetOriginId() })
+			},
+			resolve: models.SharedOriginDAO.FindOriginServerId,
+		},
+		{
+			getId: func() (int64, bool) {
+				r, ok := req.(interface{ GetIpListId() int64 })
+				return getRequestId(r, ok, func() int64 { return r.GetIpListId() })
+			},
+			resolve: models.SharedIPListDAO.FindServerIdWithListId,
+		},
+		{
+			getId: func() (int64, bool) {
+				r, ok := req.(interface{ GetHttpFirewallPolicyId() int64 })
+				return getRequestId(r, ok, func() int64 { return r.GetHttpFirewallPolicyId() })
+			},
+			resolve: models.SharedHTTPFirewallPolicyDAO.FindServerIdWithFirewallPolicyId,
+		},
+	}
+	for _, resolver := range resolvers {
+		id, ok := resolver.getId()
+		if !ok {
+			continue
+		}
+		serverId, err := resolver.resolve(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		scope.serverIds = append(scope.serverIds, serverId)
+	}
+
+	return scope, nil
+}
+
+// 读取请求中大于0的ID
+func getRequestId(r any, ok bool, getter func() int64) (int64, bool) {
+	if !ok || r == nil {
+		return 0, false
+	}
+	var id = getter()
+	return id, id > 0
+}
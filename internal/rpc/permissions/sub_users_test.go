@@ -0,0 +1,39 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestFindSubUserMethodFeature(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	for _, c := range []struct {
+		method  string
+		feature string
+		denied  bool
+	}{
+		{"/pb.ServerService/FindEnabledServerConfig", models.SubUserFeatureView, false},
+		{"/pb.ServerService/UpdateServerWeb", models.SubUserFeatureConfig, false},
+		{"/pb.HTTPWebService/UpdateHTTPWebCache", models.SubUserFeatureConfig, false},
+		{"/pb.HTTPCacheTaskService/CreateHTTPCacheTask", models.SubUserFeaturePurge, false},
+		{"/pb.ServerDailyStatService/FindLatestServerHourlyStats", models.SubUserFeatureStat, false},
+		{"/pb.UserService/FindEnabledUser", "", false},
+		{"/pb.UserService/UpdateUserInfo", "", true},
+		{"/pb.UserAccessKeyService/CreateUserAccessKey", "", true},
+		{"/pb.SubUserService/CreateSubUser", "", true},
+		{"/pb.RegionCountryService/FindAllRegionCountries", "", false},
+		{"/pb.LoginSessionService/FindLoginSession", "", false},
+		{"/pb.LoginService/UpdateLogin", "", true},
+		{"/pb.NodeClusterService/FindAllEnabledNodeClusters", "", true},
+		{"/pb.UnknownService/FindUnknown", "", true},
+	} {
+		feature, denied := permissions.FindSubUserMethodFeature(c.method)
+		a.IsTrue(feature == c.feature)
+		a.IsTrue(denied == c.denied)
+	}
+}
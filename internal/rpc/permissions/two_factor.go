@@ -0,0 +1,64 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package permissions
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ttlcache"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+var twoFactorCache = ttlcache.NewCache(ttlcache.NewPiecesOption(4), ttlcache.NewMaxItemsOption(10_000))
+
+// 策略要求启用双因素认证但还没有设置时，仍然可以调用的服务，用来完成设置
+var twoFactorSetupServices = map[string]bool{
+	"LoginService":        true,
+	"LoginSessionService": true,
+}
+
+// 策略要求启用双因素认证但还没有设置时，平台用户仍然可以调用的方法
+var twoFactorSetupUserMethods = map[string]bool{
+	"UserService.FindEnabledUser": true,
+}
+
+// CheckTwoFactorSetup 检查管理员或平台用户是否可以调用某个RPC方法
+// 策略要求启用双因素认证但还没有设置时，只能调用登录和设置认证方式相关的方法
+func CheckTwoFactorSetup(tx *dbs.Tx, adminId int64, userId int64, fullMethod string) (bool, error) {
+	if adminId <= 0 && userId <= 0 {
+		return true, nil
+	}
+
+	service, method, ok := splitFullMethod(fullMethod)
+	if !ok {
+		return true, nil
+	}
+	if twoFactorSetupServices[service] {
+		return true, nil
+	}
+	if adminId > 0 && publicMethods[service+"."+method] {
+		return true, nil
+	}
+	if adminId <= 0 && twoFactorSetupUserMethods[service+"."+method] {
+		return true, nil
+	}
+
+	var cacheKey string
+	if adminId > 0 {
+		cacheKey = "admin:" + types.String(adminId)
+	} else {
+		cacheKey = "user:" + types.String(userId)
+	}
+	var item = twoFactorCache.Read(cacheKey)
+	if item != nil {
+		return !item.Value.(bool), nil
+	}
+
+	_, requireSetup, err := models.SharedLoginDAO.FindTwoFactorStatus(tx, adminId, userId)
+	if err != nil {
+		return false, err
+	}
+	twoFactorCache.Write(cacheKey, requireSetup, time.Now().Unix()+adminCacheSeconds)
+	return !requireSetup, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
@@ -33,3 +34,33 @@ func (this *ACMEAuthenticationService) FindACMEAuthenticationKeyWithToken(ctx co
 	}
 	return &pb.FindACMEAuthenticationKeyWithTokenResponse{Key: auth.Key}, nil
 }
+
+// FindAllACMETLSALPNChallenges 查找所有有效的TLS-ALPN-01认证证书
+func (this *ACMEAuthenticationService) FindAllACMETLSALPNChallenges(ctx context.Context, req *pb.FindAllACMETLSALPNChallengesRequest) (*pb.FindAllACMETLSALPNChallengesResponse, error) {
+	nodeId, err := this.ValidateNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 只下发节点所在集群部署的域名
+	clusterIds, err := models.SharedNodeDAO.FindEnabledAndOnNodeClusterIds(tx, nodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	challenges, err := acme.SharedACMETLSALPNChallengeDAO.FindAllAvailableClusterChallenges(tx, clusterIds)
+	if err != nil {
+		return nil, err
+	}
+	var pbChallenges = []*pb.ACMETLSALPNChallenge{}
+	for _, challenge := range challenges {
+		pbChallenges = append(pbChallenges, &pb.ACMETLSALPNChallenge{
+			Domain:   challenge.Domain,
+			CertData: challenge.CertData,
+			KeyData:  challenge.KeyData,
+		})
+	}
+	return &pb.FindAllACMETLSALPNChallengesResponse{AcmeTLSALPNChallenges: pbChallenges}, nil
+}
@@ -5,7 +5,10 @@ package services
 import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/acme"
+	acmemodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net/url"
 )
 
 // ACMEProviderService ACME服务商
@@ -20,8 +23,13 @@ func (this *ACMEProviderService) FindAllACMEProviders(ctx context.Context, req *
 		return nil, err
 	}
 
+	providers, err := acme.FindAllAvailableProviders()
+	if err != nil {
+		return nil, err
+	}
+
 	var pbProviders = []*pb.ACMEProvider{}
-	for _, provider := range acme.FindAllProviders() {
+	for _, provider := range providers {
 		pbProviders = append(pbProviders, &pb.ACMEProvider{
 			Name:           provider.Name,
 			Code:           provider.Code,
@@ -29,6 +37,7 @@ func (this *ACMEProviderService) FindAllACMEProviders(ctx context.Context, req *
 			ApiURL:         provider.APIURL,
 			RequireEAB:     provider.RequireEAB,
 			EabDescription: provider.EABDescription,
+			IsCustom:       provider.IsCustom,
 		})
 	}
 
@@ -57,6 +66,149 @@ func (this *ACMEProviderService) FindACMEProviderWithCode(ctx context.Context, r
 			ApiURL:         provider.APIURL,
 			RequireEAB:     provider.RequireEAB,
 			EabDescription: provider.EABDescription,
+			IsCustom:       provider.IsCustom,
 		},
 	}, nil
 }
+
+// CreateCustomACMEProvider 创建自定义服务商
+func (this *ACMEProviderService) CreateCustomACMEProvider(ctx context.Context, req *pb.CreateCustomACMEProviderRequest) (*pb.CreateCustomACMEProviderResponse, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.checkCustomProvider(req.Name, req.ApiURL, req.CaCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	providerId, err := acmemodels.SharedACMEProviderDAO.CreateProvider(tx, adminId, req.Name, req.Description, req.ApiURL, req.RequireEAB, req.EabDescription, req.CaCert)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateCustomACMEProviderResponse{AcmeProviderId: providerId}, nil
+}
+
+// UpdateCustomACMEProvider 修改自定义服务商
+func (this *ACMEProviderService) UpdateCustomACMEProvider(ctx context.Context, req *pb.UpdateCustomACMEProviderRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.checkCustomProvider(req.Name, req.ApiURL, req.CaCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = acmemodels.SharedACMEProviderDAO.UpdateProvider(tx, req.AcmeProviderId, req.Name, req.Description, req.ApiURL, req.RequireEAB, req.EabDescription, req.CaCert, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteCustomACMEProvider 删除自定义服务商
+func (this *ACMEProviderService) DeleteCustomACMEProvider(ctx context.Context, req *pb.DeleteCustomACMEProviderRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	provider, err := acmemodels.SharedACMEProviderDAO.FindEnabledACMEProvider(tx, req.AcmeProviderId)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return this.Success()
+	}
+
+	// 检查是否有用户正在使用
+	countUsers, err := acmemodels.SharedACMEUserDAO.CountACMEUsersWithProviderCode(tx, provider.Code)
+	if err != nil {
+		return nil, err
+	}
+	if countUsers > 0 {
+		return nil, errors.New("the provider is being used by ACME users, please delete them first")
+	}
+
+	err = acmemodels.SharedACMEProviderDAO.DisableACMEProvider(tx, req.AcmeProviderId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllCustomACMEProviders 查找所有自定义服务商
+func (this *ACMEProviderService) FindAllCustomACMEProviders(ctx context.Context, req *pb.FindAllCustomACMEProvidersRequest) (*pb.FindAllCustomACMEProvidersResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	providers, err := acmemodels.SharedACMEProviderDAO.FindAllEnabledProviders(tx)
+	if err != nil {
+		return nil, err
+	}
+	var pbProviders = []*pb.ACMEProvider{}
+	for _, provider := range providers {
+		pbProviders = append(pbProviders, this.convertCustomProviderToPB(provider))
+	}
+	return &pb.FindAllCustomACMEProvidersResponse{AcmeProviders: pbProviders}, nil
+}
+
+// FindCustomACMEProvider 查找单个自定义服务商
+func (this *ACMEProviderService) FindCustomACMEProvider(ctx context.Context, req *pb.FindCustomACMEProviderRequest) (*pb.FindCustomACMEProviderResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	provider, err := acmemodels.SharedACMEProviderDAO.FindEnabledACMEProvider(tx, req.AcmeProviderId)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return &pb.FindCustomACMEProviderResponse{AcmeProvider: nil}, nil
+	}
+	return &pb.FindCustomACMEProviderResponse{AcmeProvider: this.convertCustomProviderToPB(provider)}, nil
+}
+
+// 检查自定义服务商参数
+func (this *ACMEProviderService) checkCustomProvider(name string, apiURL string, caCert string) error {
+	if len(name) == 0 {
+		return errors.New("'name' should not be empty")
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return errors.New("invalid directory url '" + apiURL + "'")
+	}
+	if len(caCert) > 0 {
+		_, err = acme.ParseCACertPool(caCert)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *ACMEProviderService) convertCustomProviderToPB(provider *acmemodels.ACMEProvider) *pb.ACMEProvider {
+	return &pb.ACMEProvider{
+		Id:             int64(provider.Id),
+		IsOn:           provider.IsOn,
+		Name:           provider.Name,
+		Code:           provider.Code,
+		Description:    provider.Description,
+		ApiURL:         provider.ApiURL,
+		RequireEAB:     provider.RequireEAB,
+		EabDescription: provider.EabDescription,
+		IsCustom:       true,
+		CaCert:         provider.CaCert,
+	}
+}
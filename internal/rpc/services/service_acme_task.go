@@ -2,12 +2,15 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	acmemodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
 )
 
 // ACMETaskService ACME任务相关服务
@@ -215,6 +218,8 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 			LatestACMETaskLog: pbTaskLog,
 			AuthType:          task.AuthType,
 			AuthURL:           task.AuthURL,
+			KeyType:           task.KeyType,
+			DualKeyType:       task.DualKeyType,
 		})
 	}
 
@@ -231,6 +236,14 @@ func (this *ACMETaskService) CreateACMETask(ctx context.Context, req *pb.CreateA
 	if len(req.AuthType) == 0 {
 		req.AuthType = acme.AuthTypeDNS
 	}
+	if req.AuthType != acme.AuthTypeDNS && req.AuthType != acme.AuthTypeHTTP && req.AuthType != acme.AuthTypeTLSALPN {
+		return nil, errors.New("invalid auth type '" + req.AuthType + "'")
+	}
+
+	err = this.checkKeyTypes(req.KeyType, req.DualKeyType)
+	if err != nil {
+		return nil, err
+	}
 
 	if adminId > 0 {
 		if req.UserId > 0 {
@@ -239,7 +252,7 @@ func (this *ACMETaskService) CreateACMETask(ctx context.Context, req *pb.CreateA
 	}
 
 	var tx = this.NullTx()
-	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL)
+	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL, req.KeyType, req.DualKeyType)
 	if err != nil {
 		return nil, err
 	}
@@ -263,7 +276,12 @@ func (this *ACMETaskService) UpdateACMETask(ctx context.Context, req *pb.UpdateA
 		return nil, this.PermissionError()
 	}
 
-	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL)
+	err = this.checkKeyTypes(req.KeyType, req.DualKeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL, req.KeyType, req.DualKeyType)
 	if err != nil {
 		return nil, err
 	}
@@ -425,6 +443,10 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 	if task.CertId > 0 {
 		pbCert = &pb.SSLCert{Id: int64(task.CertId)}
 	}
+	var pbDualCert *pb.SSLCert
+	if task.DualCertId > 0 {
+		pbDualCert = &pb.SSLCert{Id: int64(task.DualCertId)}
+	}
 
 	return &pb.FindEnabledACMETaskResponse{AcmeTask: &pb.ACMETask{
 		Id:          int64(task.Id),
@@ -438,6 +460,13 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 		AuthType:    task.AuthType,
 		AuthURL:     task.AuthURL,
 		SslCert:     pbCert,
+		KeyType:     task.KeyType,
+		DualKeyType: task.DualKeyType,
+		DualSSLCert: pbDualCert,
+
+		DnsDelegationsJSON: task.DnsDelegations,
+		RenewBeforeDays:    int32(task.RenewBeforeDays),
+		NextRenewAt:        int64(task.NextRenewAt),
 	}}, nil
 }
 
@@ -477,3 +506,271 @@ func (this *ACMETaskService) FindACMETaskUser(ctx context.Context, req *pb.FindA
 		},
 	}, nil
 }
+
+// FindAllACMEKeyTypes 查找所有支持的证书密钥类型
+func (this *ACMETaskService) FindAllACMEKeyTypes(ctx context.Context, req *pb.FindAllACMEKeyTypesRequest) (*pb.FindAllACMEKeyTypesResponse, error) {
+	_, _, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbKeyTypes = []*pb.ACMEKeyType{}
+	for _, keyType := range acme.FindAllKeyTypes() {
+		pbKeyTypes = append(pbKeyTypes, &pb.ACMEKeyType{
+			Name: keyType.Name,
+			Code: keyType.Code,
+		})
+	}
+	return &pb.FindAllACMEKeyTypesResponse{AcmeKeyTypes: pbKeyTypes}, nil
+}
+
+// UpdateACMETaskDNSDelegations 修改任务的CNAME委托设置
+func (this *ACMETaskService) UpdateACMETaskDNSDelegations(ctx context.Context, req *pb.UpdateACMETaskDNSDelegationsRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckUserACMETask(tx, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	var delegations = map[string]string{}
+	if len(req.DnsDelegationsJSON) > 0 {
+		err = json.Unmarshal(req.DnsDelegationsJSON, &delegations)
+		if err != nil {
+			return nil, errors.New("decode delegations failed: " + err.Error())
+		}
+	}
+
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETaskDNSDelegations(tx, req.AcmeTaskId, delegations)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CheckACMETaskDNSDelegations 检查任务中域名的CNAME委托是否正确
+func (this *ACMETaskService) CheckACMETaskDNSDelegations(ctx context.Context, req *pb.CheckACMETaskDNSDelegationsRequest) (*pb.CheckACMETaskDNSDelegationsResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckUserACMETask(tx, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	task, err := acmemodels.SharedACMETaskDAO.FindEnabledACMETask(tx, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("can not find task")
+	}
+	if task.AuthType != acme.AuthTypeDNS {
+		return nil, errors.New("only dns auth type can use CNAME delegations")
+	}
+
+	// 如果传入了委托设置，则使用传入的设置检查
+	var delegations = task.DecodeDNSDelegations()
+	if len(req.DnsDelegationsJSON) > 0 {
+		delegations = map[string]string{}
+		err = json.Unmarshal(req.DnsDelegationsJSON, &delegations)
+		if err != nil {
+			return nil, errors.New("decode delegations failed: " + err.Error())
+		}
+	}
+
+	var pbResults = []*pb.CheckACMETaskDNSDelegationsResponse_Result{}
+	for _, result := range acme.CheckDNSDelegations(task.DnsDomain, task.DecodeDomains(), delegations) {
+		pbResults = append(pbResults, &pb.CheckACMETaskDNSDelegationsResponse_Result{
+			Domain:        result.Domain,
+			ChallengeName: result.ChallengeName,
+			Target:        result.Target,
+			Expected:      result.Expected,
+			Suggested:     acme.SuggestDNSDelegationTarget(result.Domain, task.DnsDomain),
+			IsDelegated:   result.IsDelegated,
+			IsOk:          result.IsOk,
+			Error:         result.Error,
+		})
+	}
+	return &pb.CheckACMETaskDNSDelegationsResponse{Results: pbResults}, nil
+}
+
+// UpdateACMETaskRenewBeforeDays 设置任务提前续期的天数
+func (this *ACMETaskService) UpdateACMETaskRenewBeforeDays(ctx context.Context, req *pb.UpdateACMETaskRenewBeforeDaysRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RenewBeforeDays < 0 || req.RenewBeforeDays > acme.MaxRenewBeforeDays {
+		return nil, errors.New("'renewBeforeDays' should be between 0 and " + types.String(acme.MaxRenewBeforeDays))
+	}
+
+	var tx = this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckUserACMETask(tx, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETaskRenewBeforeDays(tx, req.AcmeTaskId, req.RenewBeforeDays)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CountACMETaskRenewalQueue 计算续期队列中的任务数量
+func (this *ACMETaskService) CountACMETaskRenewalQueue(ctx context.Context, req *pb.CountACMETaskRenewalQueueRequest) (*pb.RPCCountResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		req.UserId = userId
+	}
+
+	count, err := acmemodels.SharedACMETaskDAO.CountRenewalQueue(tx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListACMETaskRenewalQueue 列出续期队列中的任务
+func (this *ACMETaskService) ListACMETaskRenewalQueue(ctx context.Context, req *pb.ListACMETaskRenewalQueueRequest) (*pb.ListACMETaskRenewalQueueResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		req.UserId = userId
+	}
+
+	tasks, err := acmemodels.SharedACMETaskDAO.ListRenewalQueue(tx, req.UserId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbItems = []*pb.ListACMETaskRenewalQueueResponse_Item{}
+	for _, task := range tasks {
+		var pbItem = &pb.ListACMETaskRenewalQueueResponse_Item{
+			AcmeTaskId:      int64(task.Id),
+			Domains:         task.DecodeDomains(),
+			SslCertId:       int64(task.CertId),
+			RenewBeforeDays: int32(task.RenewBeforeDays),
+			NextRenewAt:     int64(task.NextRenewAt),
+			RenewFailures:   int32(task.RenewFailures),
+		}
+
+		// 证书过期时间
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, int64(task.CertId))
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			pbItem.TimeEndAt = int64(cert.TimeEndAt)
+		}
+
+		// 续期时间段
+		var window = task.DecodeRenewWindow()
+		if window != nil {
+			pbItem.WindowStartAt = window.Start
+			pbItem.WindowEndAt = window.End
+			pbItem.WindowSource = window.Source
+			pbItem.ExplanationURL = window.ExplanationURL
+		}
+
+		pbItems = append(pbItems, pbItem)
+	}
+	return &pb.ListACMETaskRenewalQueueResponse{Items: pbItems}, nil
+}
+
+// RevokeACMETaskCert 吊销任务申请的证书
+func (this *ACMETaskService) RevokeACMETaskCert(ctx context.Context, req *pb.RevokeACMETaskCertRequest) (*pb.RevokeACMETaskCertResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Reason < 0 || !acme.IsValidRevokeReason(acme.RevokeReason(req.Reason)) {
+		return nil, errors.New("invalid revoke reason '" + types.String(req.Reason) + "'")
+	}
+
+	var tx = this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckUserACMETask(tx, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	var result = acmemodels.SharedACMETaskDAO.RevokeAndReissueACMETaskCert(tx, req.AcmeTaskId, req.SslCertId, acme.RevokeReason(req.Reason), req.UseCertKey, req.Reissue)
+	return &pb.RevokeACMETaskCertResponse{
+		IsOk:              result.IsOk,
+		Error:             result.ErrMsg,
+		ReissueIsOk:       result.ReissueIsOk,
+		ReissueError:      result.ReissueErrMsg,
+		ReissuedSSLCertId: result.ReissuedCertId,
+	}, nil
+}
+
+// FindAllACMERevokeReasons 查找所有可用的证书吊销原因
+func (this *ACMETaskService) FindAllACMERevokeReasons(ctx context.Context, req *pb.FindAllACMERevokeReasonsRequest) (*pb.FindAllACMERevokeReasonsResponse, error) {
+	_, _, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbReasons = []*pb.ACMERevokeReason{}
+	for _, reason := range acme.FindAllRevokeReasons() {
+		pbReasons = append(pbReasons, &pb.ACMERevokeReason{
+			Name: reason.Name,
+			Code: int32(reason.Code),
+		})
+	}
+	return &pb.FindAllACMERevokeReasonsResponse{AcmeRevokeReasons: pbReasons}, nil
+}
+
+// 检查密钥类型
+func (this *ACMETaskService) checkKeyTypes(keyType string, dualKeyType string) error {
+	if len(keyType) > 0 && !acme.IsValidKeyType(keyType) {
+		return errors.New("invalid key type '" + keyType + "'")
+	}
+	if len(dualKeyType) > 0 {
+		if !acme.IsValidKeyType(dualKeyType) {
+			return errors.New("invalid dual key type '" + dualKeyType + "'")
+		}
+		if len(keyType) == 0 {
+			keyType = acme.DefaultKeyType
+		}
+		if acme.IsECDSAKeyType(keyType) == acme.IsECDSAKeyType(dualKeyType) {
+			return errors.New("dual key type should be different from key type, one is RSA and the other is ECDSA")
+		}
+	}
+	return nil
+}
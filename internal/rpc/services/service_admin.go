@@ -7,12 +7,14 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/stats"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/tasks"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
+	"github.com/iwind/TeaGo/dbs"
 	timeutil "github.com/iwind/TeaGo/utils/time"
 	"time"
 )
@@ -41,6 +43,22 @@ func (this *AdminService) LoginAdmin(ctx context.Context, req *pb.LoginAdminRequ
 
 	var tx = this.NullTx()
 
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, models.LoginFailureRoleAdmin, req.Username, req.Ip, req.CaptchaVerified)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.LoginAdminResponse{
+			AdminId:        0,
+			IsOk:           false,
+			Message:        message,
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
 	adminId, err := models.SharedAdminDAO.CheckAdminPassword(tx, req.Username, req.Password)
 	if err != nil {
 		utils.PrintError(err)
@@ -48,16 +66,43 @@ func (this *AdminService) LoginAdmin(ctx context.Context, req *pb.LoginAdminRequ
 	}
 
 	if adminId <= 0 {
+		failedAdminId, err := models.SharedAdminDAO.FindAdminIdWithUsername(tx, req.Username)
+		if err != nil {
+			return nil, err
+		}
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, models.LoginFailureRoleAdmin, req.Username, req.Ip, models.LoginFailureTypePassword, failedAdminId, 0)
+		if err != nil {
+			return nil, err
+		}
 		return &pb.LoginAdminResponse{
-			AdminId: 0,
-			IsOk:    false,
-			Message: "请输入正确的用户名密码",
+			AdminId:        0,
+			IsOk:           false,
+			Message:        "请输入正确的用户名密码",
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
 		}, nil
 	}
 
+	// 需要设置双因素认证时，仍然返回管理员ID用来完成设置，但只能调用设置相关的方法，参考 permissions.CheckTwoFactorSetup()
+	twoFactorTypes, requireTwoFactorSetup, err := models.SharedLoginDAO.FindTwoFactorStatus(tx, adminId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 需要第二步认证时，等第二步认证成功后再清除失败记录
+	if len(twoFactorTypes) == 0 {
+		err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, models.LoginFailureRoleAdmin, req.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &pb.LoginAdminResponse{
-		AdminId: adminId,
-		IsOk:    true,
+		AdminId:               adminId,
+		IsOk:                  true,
+		TwoFactorTypes:        twoFactorTypes,
+		RequireTwoFactorSetup: requireTwoFactorSetup,
 	}, nil
 }
 
@@ -165,8 +210,6 @@ func (this *AdminService) FindEnabledAdmin(ctx context.Context, req *pb.FindEnab
 		return nil, err
 	}
 
-	// TODO 检查权限
-
 	var tx = this.NullTx()
 
 	// 超级管理员才能查看是否为弱密码
@@ -175,6 +218,18 @@ func (this *AdminService) FindEnabledAdmin(ctx context.Context, req *pb.FindEnab
 		return nil, err
 	}
 
+	// 查看其他管理员需要系统设置权限
+	var isSelf = req.AdminId == adminId
+	if !isSelf && !isSuperAdmin {
+		allowed, err := permissions.CheckAdmin(tx, adminId, permissions.ModuleSettings, permissions.ActionRead)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, this.PermissionError()
+		}
+	}
+
 	admin, err := models.SharedAdminDAO.FindEnabledAdmin(tx, req.AdminId)
 	if err != nil {
 		return nil, err
@@ -208,10 +263,14 @@ func (this *AdminService) FindEnabledAdmin(ctx context.Context, req *pb.FindEnab
 		}
 		if adminAuth != nil {
 			pbOtpAuth = &pb.Login{
-				Id:         int64(adminAuth.Id),
-				Type:       adminAuth.Type,
-				ParamsJSON: adminAuth.Params,
-				IsOn:       adminAuth.IsOn,
+				Id:   int64(adminAuth.Id),
+				Type: adminAuth.Type,
+				IsOn: adminAuth.IsOn,
+			}
+
+			// 只有本人和超级管理员才能读取OTP密钥
+			if isSelf || isSuperAdmin {
+				pbOtpAuth.ParamsJSON = adminAuth.Params
 			}
 		}
 	}
@@ -244,6 +303,10 @@ func (this *AdminService) CreateOrUpdateAdmin(ctx context.Context, req *pb.Creat
 	if err != nil {
 		return nil, err
 	}
+	err = this.checkPasswordPolicy(tx, adminId, req.Password)
+	if err != nil {
+		return nil, err
+	}
 	if adminId > 0 {
 		err = models.SharedAdminDAO.UpdateAdminPassword(tx, adminId, req.Password)
 		if err != nil {
@@ -261,13 +324,21 @@ func (this *AdminService) CreateOrUpdateAdmin(ctx context.Context, req *pb.Creat
 // UpdateAdminInfo 修改管理员信息
 func (this *AdminService) UpdateAdminInfo(ctx context.Context, req *pb.UpdateAdminInfoRequest) (*pb.RPCSuccess, error) {
 	// 校验请求
-	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeAPI)
+	userType, _, adminId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeAPI)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
 
+	// 修改其他管理员的信息需要超级管理员权限
+	if userType == rpcutils.UserTypeAdmin {
+		err = this.CheckAdminLoginTarget(tx, adminId, req.AdminId, 0, permissions.ActionWrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedAdminDAO.UpdateAdminInfo(tx, req.AdminId, req.Fullname)
 	if err != nil {
 		return nil, err
@@ -278,13 +349,21 @@ func (this *AdminService) UpdateAdminInfo(ctx context.Context, req *pb.UpdateAdm
 // UpdateAdminLogin 修改管理员登录信息
 func (this *AdminService) UpdateAdminLogin(ctx context.Context, req *pb.UpdateAdminLoginRequest) (*pb.RPCSuccess, error) {
 	// 校验请求
-	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeAPI)
+	userType, _, adminId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeAPI)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
 
+	// 修改其他管理员的登录信息需要超级管理员权限
+	if userType == rpcutils.UserTypeAdmin {
+		err = this.CheckAdminLoginTarget(tx, adminId, req.AdminId, 0, permissions.ActionWrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	exists, err := models.SharedAdminDAO.CheckAdminUsername(tx, req.AdminId, req.Username)
 	if err != nil {
 		return nil, err
@@ -293,6 +372,13 @@ func (this *AdminService) UpdateAdminLogin(ctx context.Context, req *pb.UpdateAd
 		return nil, errors.New("username already been token")
 	}
 
+	if len(req.Password) > 0 {
+		err = this.checkPasswordPolicy(tx, req.AdminId, req.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedAdminDAO.UpdateAdminLogin(tx, req.AdminId, req.Username, req.Password)
 	if err != nil {
 		return nil, err
@@ -350,38 +436,61 @@ func (this *AdminService) FindAllAdminModules(ctx context.Context, req *pb.FindA
 
 // CreateAdmin 创建管理员
 func (this *AdminService) CreateAdmin(ctx context.Context, req *pb.CreateAdminRequest) (*pb.CreateAdminResponse, error) {
-	_, err := this.ValidateAdmin(ctx)
+	adminId, err := this.ValidateAdmin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO 检查权限
-
 	var tx = this.NullTx()
 
-	adminId, err := models.SharedAdminDAO.CreateAdmin(tx, req.Username, req.CanLogin, req.Password, req.Fullname, req.IsSuper, req.ModulesJSON)
+	// 只有超级管理员才能创建超级管理员
+	if req.IsSuper {
+		err = this.checkSuperAdmin(tx, adminId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = this.checkPasswordPolicy(tx, 0, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	newAdminId, err := models.SharedAdminDAO.CreateAdmin(tx, req.Username, req.CanLogin, req.Password, req.Fullname, req.IsSuper, req.ModulesJSON)
 	if err != nil {
 		return nil, err
 	}
 
-	return &pb.CreateAdminResponse{AdminId: adminId}, nil
+	return &pb.CreateAdminResponse{AdminId: newAdminId}, nil
 }
 
 // UpdateAdmin 修改管理员
 func (this *AdminService) UpdateAdmin(ctx context.Context, req *pb.UpdateAdminRequest) (*pb.RPCSuccess, error) {
-	_, err := this.ValidateAdmin(ctx)
+	adminId, err := this.ValidateAdmin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO 检查权限
-
 	var tx = this.NullTx()
 
+	// 只有超级管理员才能设置超级管理员，或者修改超级管理员的信息
+	err = this.checkUpdatingSuperAdmin(tx, adminId, req.AdminId, req.IsSuper)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Password) > 0 {
+		err = this.checkPasswordPolicy(tx, req.AdminId, req.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedAdminDAO.UpdateAdmin(tx, req.AdminId, req.Username, req.CanLogin, req.Password, req.Fullname, req.IsSuper, req.ModulesJSON, req.IsOn)
 	if err != nil {
 		return nil, err
 	}
+	permissions.ResetCache()
 
 	return this.Success()
 }
@@ -476,21 +585,26 @@ func (this *AdminService) ListEnabledAdmins(ctx context.Context, req *pb.ListEna
 
 // DeleteAdmin 删除管理员
 func (this *AdminService) DeleteAdmin(ctx context.Context, req *pb.DeleteAdminRequest) (*pb.RPCSuccess, error) {
-	_, err := this.ValidateAdmin(ctx)
+	adminId, err := this.ValidateAdmin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO 检查权限
-
 	var tx = this.NullTx()
 
-	// TODO 超级管理员用户是不能删除的，或者要至少留一个超级管理员用户
+	// 只有超级管理员才能删除超级管理员
+	err = this.checkUpdatingSuperAdmin(tx, adminId, req.AdminId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO 要至少留一个超级管理员用户
 
 	err = models.SharedAdminDAO.DisableAdmin(tx, req.AdminId)
 	if err != nil {
 		return nil, err
 	}
+	permissions.ResetCache()
 
 	return this.Success()
 }
@@ -584,7 +698,7 @@ func (this *AdminService) ComposeAdminDashboard(ctx context.Context, req *pb.Com
 	result.CountServers = countServers
 
 	this.BeginTag(ctx, "SharedServerDAO.CountAllEnabledServersMatch")
-	countAuditingServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", 0, 0, configutils.BoolStateYes, nil, 0)
+	countAuditingServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", 0, 0, configutils.BoolStateYes, nil, 0, nil)
 	this.EndTag(ctx, "SharedServerDAO.CountAllEnabledServersMatch")
 	if err != nil {
 		return nil, err
@@ -785,3 +899,27 @@ func (this *AdminService) UpdateAdminLang(ctx context.Context, req *pb.UpdateAdm
 	}
 	return this.Success()
 }
+
+// 检查管理员密码是否符合密码策略
+func (this *AdminService) checkPasswordPolicy(tx *dbs.Tx, adminId int64, password string) error {
+	policy, err := models.SharedSysSettingDAO.ReadPasswordPolicy(tx)
+	if err != nil {
+		return err
+	}
+	if !policy.ApplyToAdmins {
+		return nil
+	}
+	err = policy.Check(password)
+	if err != nil {
+		return err
+	}
+
+	isReused, err := models.SharedAdminDAO.CheckAdminPasswordReused(tx, adminId, password, policy.HistorySize)
+	if err != nil {
+		return err
+	}
+	if isReused {
+		return errors.New("the password has been used recently, please choose another one")
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+)
+
+// CreateAdminRole 创建管理员角色
+func (this *AdminService) CreateAdminRole(ctx context.Context, req *pb.CreateAdminRoleRequest) (*pb.CreateAdminRoleResponse, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkSuperAdmin(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Name) == 0 {
+		return nil, errors.New("'name' should not be empty")
+	}
+	_, err = permissions.ParsePermissionList(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	roleId, err := models.SharedAdminRoleDAO.CreateAdminRole(tx, req.Name, req.Description, req.Permissions, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateAdminRoleResponse{AdminRoleId: roleId}, nil
+}
+
+// UpdateAdminRole 修改管理员角色
+func (this *AdminService) UpdateAdminRole(ctx context.Context, req *pb.UpdateAdminRoleRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkSuperAdmin(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Name) == 0 {
+		return nil, errors.New("'name' should not be empty")
+	}
+	_, err = permissions.ParsePermissionList(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedAdminRoleDAO.UpdateAdminRole(tx, req.AdminRoleId, req.Name, req.Description, req.Permissions, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	permissions.ResetCache()
+	return this.Success()
+}
+
+// DeleteAdminRole 删除管理员角色
+func (this *AdminService) DeleteAdminRole(ctx context.Context, req *pb.DeleteAdminRoleRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkSuperAdmin(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedAdminRoleDAO.DisableAdminRole(tx, req.AdminRoleId)
+	if err != nil {
+		return nil, err
+	}
+	permissions.ResetCache()
+	return this.Success()
+}
+
+// FindEnabledAdminRole 查找单个管理员角色
+func (this *AdminService) FindEnabledAdminRole(ctx context.Context, req *pb.FindEnabledAdminRoleRequest) (*pb.FindEnabledAdminRoleResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	role, err := models.SharedAdminRoleDAO.FindEnabledAdminRole(tx, req.AdminRoleId)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return &pb.FindEnabledAdminRoleResponse{AdminRole: nil}, nil
+	}
+	return &pb.FindEnabledAdminRoleResponse{AdminRole: this.convertAdminRole(role)}, nil
+}
+
+// FindAllAdminRoles 查找所有管理员角色
+func (this *AdminService) FindAllAdminRoles(ctx context.Context, req *pb.FindAllAdminRolesRequest) (*pb.FindAllAdminRolesResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	roles, err := models.SharedAdminRoleDAO.FindAllEnabledAdminRoles(tx)
+	if err != nil {
+		return nil, err
+	}
+	var pbRoles = []*pb.AdminRole{}
+	for _, role := range roles {
+		pbRoles = append(pbRoles, this.convertAdminRole(role))
+	}
+	return &pb.FindAllAdminRolesResponse{AdminRoles: pbRoles}, nil
+}
+
+// FindAdminRoleIds 查找管理员的角色
+func (this *AdminService) FindAdminRoleIds(ctx context.Context, req *pb.FindAdminRoleIdsRequest) (*pb.FindAdminRoleIdsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	_, roleIds, err := models.SharedAdminDAO.FindAdminRoleIds(tx, req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindAdminRoleIdsResponse{AdminRoleIds: roleIds}, nil
+}
+
+// UpdateAdminRoleIds 设置管理员的角色
+func (this *AdminService) UpdateAdminRoleIds(ctx context.Context, req *pb.UpdateAdminRoleIdsRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkSuperAdmin(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, roleId := range req.AdminRoleIds {
+		role, err := models.SharedAdminRoleDAO.FindEnabledAdminRole(tx, roleId)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, errors.New("can not find admin role '" + types.String(roleId) + "'")
+		}
+	}
+
+	err = models.SharedAdminDAO.UpdateAdminRoleIds(tx, req.AdminId, req.AdminRoleIds)
+	if err != nil {
+		return nil, err
+	}
+	permissions.ResetCache()
+	return this.Success()
+}
+
+// CheckAdminPermission 检查管理员是否可以调用某个RPC方法
+func (this *AdminService) CheckAdminPermission(ctx context.Context, req *pb.CheckAdminPermissionRequest) (*pb.CheckAdminPermissionResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	allowed, module, action, err := permissions.CheckAdminMethod(tx, req.AdminId, req.Method)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckAdminPermissionResponse{
+		IsAllowed: allowed,
+		Module:    module,
+		Action:    action,
+	}, nil
+}
+
+// 只有超级管理员才能管理角色
+func (this *AdminService) checkSuperAdmin(tx *dbs.Tx, adminId int64) error {
+	isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, adminId)
+	if err != nil {
+		return err
+	}
+	if !isSuper {
+		return this.PermissionError()
+	}
+	return nil
+}
+
+func (this *AdminService) convertAdminRole(role *models.AdminRole) *pb.AdminRole {
+	return &pb.AdminRole{
+		Id:          int64(role.Id),
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.DecodePermissions(),
+		IsOn:        role.IsOn,
+		CreatedAt:   int64(role.CreatedAt),
+	}
+}
+
+// 检查是否可以修改某个管理员
+// 设置超级管理员或者修改已有超级管理员时，需要当前管理员是超级管理员
+func (this *AdminService) checkUpdatingSuperAdmin(tx *dbs.Tx, adminId int64, targetAdminId int64, setSuper bool) error {
+	if !setSuper {
+		targetIsSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, targetAdminId)
+		if err != nil {
+			return err
+		}
+		if !targetIsSuper {
+			return nil
+		}
+	}
+	return this.checkSuperAdmin(tx, adminId)
+}
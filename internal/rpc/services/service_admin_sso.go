@@ -0,0 +1,258 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ssoutils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"time"
+)
+
+// OIDC登录state有效期
+const adminOIDCStateLife = 10 * time.Minute
+
+// FindAdminSSOProviders 查找可用的单点登录方式
+func (this *AdminService) FindAdminSSOProviders(ctx context.Context, req *pb.FindAdminSSOProvidersRequest) (*pb.FindAdminSSOProvidersResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	policy, err := models.SharedSysSettingDAO.ReadAdminSSOPolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oidcName = policy.OIDC.Name
+	if len(oidcName) == 0 {
+		oidcName = "OpenID Connect"
+	}
+	return &pb.FindAdminSSOProvidersResponse{
+		OidcIsOn: policy.OIDC.IsOn,
+		OidcName: oidcName,
+		LdapIsOn: policy.LDAP.IsOn,
+	}, nil
+}
+
+// BeginAdminOIDCLogin 开始使用OpenID Connect登录，返回需要跳转的认证地址
+func (this *AdminService) BeginAdminOIDCLogin(ctx context.Context, req *pb.BeginAdminOIDCLoginRequest) (*pb.BeginAdminOIDCLoginResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	policy, err := models.SharedSysSettingDAO.ReadAdminSSOPolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.OIDC.IsOn {
+		return nil, errors.New("oidc login is not enabled")
+	}
+
+	state, err := ssoutils.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := ssoutils.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := ssoutils.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := ssoutils.NewOIDCClient(policy.OIDC).AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedLoginChallengeDAO.CreateAnonymousChallenge(tx, models.LoginChallengeTypeOIDCLogin, state, maps.Map{
+		"nonce":        nonce,
+		"codeVerifier": codeVerifier,
+	}, adminOIDCStateLife)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.BeginAdminOIDCLoginResponse{
+		AuthURL: authURL,
+		State:   state,
+	}, nil
+}
+
+// FinishAdminOIDCLogin 使用身份提供方回调的授权码完成登录
+func (this *AdminService) FinishAdminOIDCLogin(ctx context.Context, req *pb.FinishAdminOIDCLoginRequest) (*pb.LoginAdminResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	policy, err := models.SharedSysSettingDAO.ReadAdminSSOPolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.OIDC.IsOn {
+		return nil, errors.New("oidc login is not enabled")
+	}
+
+	// state只能使用一次
+	params, err := models.SharedLoginChallengeDAO.ConsumeAnonymousChallenge(tx, models.LoginChallengeTypeOIDCLogin, req.State)
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "登录请求已失效，请重新登录",
+		}, nil
+	}
+
+	identity, err := ssoutils.NewOIDCClient(policy.OIDC).Exchange(req.Code, params.GetString("codeVerifier"), params.GetString("nonce"))
+	if err != nil {
+		remotelogs.Error("ADMIN_SSO", "oidc login failed: "+err.Error())
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "身份认证失败，请重新登录",
+		}, nil
+	}
+
+	return this.loginSSOAdmin(tx, policy, identity)
+}
+
+// LoginAdminLDAP 使用LDAP账号登录
+func (this *AdminService) LoginAdminLDAP(ctx context.Context, req *pb.LoginAdminLDAPRequest) (*pb.LoginAdminResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Username) == 0 || len(req.Password) == 0 {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "请输入正确的用户名密码",
+		}, nil
+	}
+
+	var tx = this.NullTx()
+	policy, err := models.SharedSysSettingDAO.ReadAdminSSOPolicy(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.LDAP.IsOn {
+		return nil, errors.New("ldap login is not enabled")
+	}
+
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, models.LoginFailureRoleAdmin, req.Username, req.Ip, req.CaptchaVerified)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.LoginAdminResponse{
+			IsOk:           false,
+			Message:        message,
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
+	identity, err := ssoutils.NewLDAPClient(policy.LDAP).Authenticate(req.Username, req.Password)
+	if err != nil {
+		if err != ssoutils.ErrInvalidCredentials {
+			remotelogs.Error("ADMIN_SSO", "ldap login failed: "+err.Error())
+			return &pb.LoginAdminResponse{
+				IsOk:    false,
+				Message: "LDAP服务暂时不可用，请稍后再试",
+			}, nil
+		}
+
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, models.LoginFailureRoleAdmin, req.Username, req.Ip, models.LoginFailureTypePassword, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.LoginAdminResponse{
+			IsOk:           false,
+			Message:        "请输入正确的用户名密码",
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
+	resp, err := this.loginSSOAdmin(tx, policy, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 有双因素认证时，需要在第二步认证成功后才清除失败记录
+	if resp.IsOk && len(resp.TwoFactorTypes) == 0 {
+		err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, models.LoginFailureRoleAdmin, req.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// 使用身份提供方认证后的身份登录，必要时自动创建管理员
+func (this *AdminService) loginSSOAdmin(tx *dbs.Tx, policy *ssoutils.Policy, identity *ssoutils.Identity) (*pb.LoginAdminResponse, error) {
+	grant, matched := policy.MapRoles(identity)
+	if !matched && policy.DenyUnmapped {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "当前账号没有登录管理系统的权限",
+		}, nil
+	}
+
+	adminId, err := models.SharedAdminDAO.FindOrCreateSSOAdmin(tx, identity, grant, policy.AutoCreate, policy.SyncRoles)
+	if err != nil {
+		var message string
+		switch err {
+		case models.ErrSSOAdminNotFound:
+			message = "当前账号还没有开通管理员权限，请联系系统管理员"
+		case models.ErrSSOAdminDisabled:
+			message = "当前账号已被禁止登录"
+		case models.ErrSSOUsernameConflicts:
+			message = "用户名'" + identity.Username + "'已被其他管理员使用，请联系系统管理员"
+		case models.ErrSSOAdminNoRoles:
+			message = "当前账号没有分配管理员角色，请联系系统管理员"
+		default:
+			return nil, err
+		}
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: message,
+		}, nil
+	}
+
+	// 角色可能已经同步修改
+	if policy.SyncRoles {
+		permissions.ResetCache()
+	}
+
+	twoFactorTypes, requireTwoFactorSetup, err := models.SharedLoginDAO.FindTwoFactorStatus(tx, adminId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LoginAdminResponse{
+		AdminId:               adminId,
+		IsOk:                  true,
+		TwoFactorTypes:        twoFactorTypes,
+		RequireTwoFactorSetup: requireTwoFactorSetup,
+	}, nil
+}
@@ -38,6 +38,9 @@ func (this *APIAccessTokenService) GetAPIAccessToken(ctx context.Context, req *p
 		if accessKey.UserId == 0 {
 			return nil, errors.New("access key not found")
 		}
+		if accessKey.SubUserId > 0 {
+			return nil, errors.New("access keys of sub users are not supported yet")
+		}
 
 		// 检查用户状态
 		user, err := models.SharedUserDAO.FindEnabledUser(tx, int64(accessKey.UserId), nil)
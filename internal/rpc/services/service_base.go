@@ -10,12 +10,15 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/lists"
 	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -226,6 +229,56 @@ func (this *BaseService) NotImplementedYet() error {
 	return status.Error(codes.Unimplemented, "not implemented yet")
 }
 
+// CheckLoginFailures 登录之前检查失败记录
+// 如果不允许登录，则返回不为空的提示信息
+func (this *BaseService) CheckLoginFailures(tx *dbs.Tx, role string, username string, ip string, captchaVerified bool) (decision *loginutils.Decision, message string, err error) {
+	decision, err = models.SharedLoginFailureDAO.CheckLogin(tx, role, username, ip)
+	if err != nil {
+		return nil, "", err
+	}
+	if decision.IsLocked {
+		return decision, "登录失败次数过多，请" + types.String(decision.RetryAfter) + "秒后再试", nil
+	}
+	if decision.RetryAfter > 0 {
+		return decision, "操作过于频繁，请" + types.String(decision.RetryAfter) + "秒后再试", nil
+	}
+	if decision.RequireCaptcha && !captchaVerified {
+		return decision, "请输入验证码", nil
+	}
+	return decision, "", nil
+}
+
+// CheckAdminLoginTarget 检查管理员是否可以管理某个账号的登录信息
+// 管理平台用户需要用户模块的权限，管理其他管理员需要超级管理员权限
+func (this *BaseService) CheckAdminLoginTarget(tx *dbs.Tx, adminId int64, targetAdminId int64, targetUserId int64, action permissions.Action) error {
+	// 管理系统在登录过程中的调用没有管理员ID，和 permissions.CheckAdmin() 保持一致
+	if adminId <= 0 {
+		return nil
+	}
+
+	if targetUserId > 0 {
+		allowed, err := permissions.CheckAdmin(tx, adminId, permissions.ModuleUsers, action)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return this.PermissionError()
+		}
+		return nil
+	}
+
+	if targetAdminId > 0 && targetAdminId != adminId {
+		isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, adminId)
+		if err != nil {
+			return err
+		}
+		if !isSuper {
+			return this.PermissionError()
+		}
+	}
+	return nil
+}
+
 // NullTx 空的数据库事务
 func (this *BaseService) NullTx() *dbs.Tx {
 	return nil
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns/dnsutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 )
@@ -51,3 +52,54 @@ func (this *DNSService) FindAllDNSIssues(ctx context.Context, req *pb.FindAllDNS
 
 	return &pb.FindAllDNSIssuesResponse{Issues: result}, nil
 }
+
+// CountAllDNSChangeLogs 计算DNS记录变更日志数量
+func (this *DNSService) CountAllDNSChangeLogs(ctx context.Context, req *pb.CountAllDNSChangeLogsRequest) (*pb.RPCCountResponse, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	count, err := dns.SharedDNSChangeLogDAO.CountLogs(tx, req.NodeClusterId, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListDNSChangeLogs 列出单页DNS记录变更日志
+func (this *DNSService) ListDNSChangeLogs(ctx context.Context, req *pb.ListDNSChangeLogsRequest) (*pb.ListDNSChangeLogsResponse, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	logs, err := dns.SharedDNSChangeLogDAO.ListLogs(tx, req.NodeClusterId, req.DnsDomainId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbLogs = []*pb.DNSChangeLog{}
+	for _, log := range logs {
+		pbLogs = append(pbLogs, &pb.DNSChangeLog{
+			Id:            int64(log.Id),
+			NodeClusterId: int64(log.ClusterId),
+			DnsDomainId:   int64(log.DomainId),
+			DnsTaskId:     int64(log.TaskId),
+			Source:        log.Source,
+			Action:        log.Action,
+			RecordName:    log.RecordName,
+			RecordType:    log.RecordType,
+			OldRecordJSON: log.OldRecord,
+			NewRecordJSON: log.NewRecord,
+			IsOk:          log.IsOk,
+			Error:         log.Error,
+			CreatedAt:     int64(log.CreatedAt),
+		})
+	}
+	return &pb.ListDNSChangeLogsResponse{DnsChangeLogs: pbLogs}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns/dnsutils"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/zonefile"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/goman"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
@@ -18,7 +19,9 @@ import (
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/lists"
 	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
 	"net"
+	"strings"
 )
 
 // DNSDomainService DNS域名相关服务
@@ -58,7 +61,7 @@ func (this *DNSDomainService) CreateDNSDomain(ctx context.Context, req *pb.Creat
 	goman.New(func() {
 		domainName := req.Name
 
-		providerInterface := dnsclients.FindProvider(provider.Type, int64(provider.Id))
+		providerInterface := dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 		if providerInterface == nil {
 			return
 		}
@@ -734,7 +737,7 @@ func (this *DNSDomainService) syncClusterDNS(req *pb.SyncDNSDomainDataRequest) (
 	}
 
 	// 开始同步
-	var manager = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	var manager = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if manager == nil {
 		return &pb.SyncDNSDomainDataResponse{IsOk: false, Error: "目前不支持'" + provider.Type + "'"}, nil
 	}
@@ -872,7 +875,7 @@ func (this *DNSDomainService) SyncDNSDomainsFromProvider(ctx context.Context, re
 		return nil, err
 	}
 
-	dnsProvider := dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	dnsProvider := dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if dnsProvider == nil {
 		return nil, errors.New("provider type '" + provider.Type + "' is not supported yet")
 	}
@@ -930,3 +933,347 @@ func (this *DNSDomainService) SyncDNSDomainsFromProvider(ctx context.Context, re
 		HasChanges: hasChanges,
 	}, nil
 }
+
+// FindAllDNSDomainRecords 查询域名下的所有解析记录
+func (this *DNSDomainService) FindAllDNSDomainRecords(ctx context.Context, req *pb.FindAllDNSDomainRecordsRequest) (*pb.FindAllDNSDomainRecordsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := manager.GetRecords(domain.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// 顺便更新缓存的记录
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	err = dns.SharedDNSDomainDAO.UpdateDomainRecords(tx, int64(domain.Id), recordsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbRecords = []*pb.DNSRecord{}
+	for _, record := range records {
+		if len(req.Type) > 0 && record.Type != strings.ToUpper(req.Type) {
+			continue
+		}
+		if len(req.Keyword) > 0 && !strings.Contains(record.Name, req.Keyword) && !strings.Contains(record.Value, req.Keyword) {
+			continue
+		}
+
+		pbRecord, err := dnsutils.ConvertRecordToPB(record)
+		if err != nil {
+			return nil, err
+		}
+		pbRecords = append(pbRecords, pbRecord)
+	}
+
+	return &pb.FindAllDNSDomainRecordsResponse{DnsRecords: pbRecords}, nil
+}
+
+// CreateDNSDomainRecord 创建解析记录
+func (this *DNSDomainService) CreateDNSDomainRecord(ctx context.Context, req *pb.CreateDNSDomainRecordRequest) (*pb.CreateDNSDomainRecordResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := this.convertPBToRecord(req.DnsRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if len(record.Route) == 0 {
+		record.Route = manager.DefaultRoute()
+	}
+
+	err = manager.AddRecord(domain.Name, record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dns.SharedDNSTaskDAO.CreateDomainTask(tx, int64(domain.Id), dns.DNSTaskTypeDomainChange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateDNSDomainRecordResponse{DnsRecordId: record.Id}, nil
+}
+
+// UpdateDNSDomainRecord 修改解析记录
+func (this *DNSDomainService) UpdateDNSDomainRecord(ctx context.Context, req *pb.UpdateDNSDomainRecordRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	newRecord, err := this.convertPBToRecord(req.DnsRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := this.findDomainRecord(manager, domain.Name, req.DnsRecordId)
+	if err != nil {
+		return nil, err
+	}
+	if len(newRecord.Route) == 0 {
+		newRecord.Route = record.Route
+	}
+
+	err = manager.UpdateRecord(domain.Name, record, newRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dns.SharedDNSTaskDAO.CreateDomainTask(tx, int64(domain.Id), dns.DNSTaskTypeDomainChange)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.Success()
+}
+
+// DeleteDNSDomainRecord 删除解析记录
+func (this *DNSDomainService) DeleteDNSDomainRecord(ctx context.Context, req *pb.DeleteDNSDomainRecordRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := this.findDomainRecord(manager, domain.Name, req.DnsRecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.DeleteRecord(domain.Name, record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dns.SharedDNSTaskDAO.CreateDomainTask(tx, int64(domain.Id), dns.DNSTaskTypeDomainChange)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.Success()
+}
+
+// ExportDNSDomainZoneFile 导出域名解析记录为区域文件
+func (this *DNSDomainService) ExportDNSDomainZoneFile(ctx context.Context, req *pb.ExportDNSDomainZoneFileRequest) (*pb.ExportDNSDomainZoneFileResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := manager.GetRecords(domain.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ExportDNSDomainZoneFileResponse{
+		ZoneFile: []byte(zonefile.Export(domain.Name, records, manager.DefaultRoute())),
+	}, nil
+}
+
+// ImportDNSDomainZoneFile 从区域文件中导入解析记录
+func (this *DNSDomainService) ImportDNSDomainZoneFile(ctx context.Context, req *pb.ImportDNSDomainZoneFileRequest) (*pb.ImportDNSDomainZoneFileResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.ZoneFile) == 0 {
+		return nil, errors.New("'zoneFile' should not be empty")
+	}
+
+	var tx = this.NullTx()
+	domain, manager, err := this.findDomainManager(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbErrors = []*pb.ImportDNSDomainZoneFileResponse_Error{}
+	newRecords, parseErrors := zonefile.Parse(domain.Name, string(req.ZoneFile))
+	for _, parseErr := range parseErrors {
+		pbErrors = append(pbErrors, &pb.ImportDNSDomainZoneFileResponse_Error{
+			Line:    int32(parseErr.Line),
+			Text:    parseErr.Text,
+			Message: parseErr.Message,
+		})
+	}
+
+	oldRecords, err := manager.GetRecords(domain.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultRoute = manager.DefaultRoute()
+	var changes = zonefile.Diff(oldRecords, newRecords, defaultRoute, req.Overwrite)
+
+	var isChanged = false
+	var pbChanges = []*pb.ImportDNSDomainZoneFileResponse_Change{}
+	for _, change := range changes {
+		var pbChange = &pb.ImportDNSDomainZoneFileResponse_Change{
+			Action: change.Action,
+		}
+		if change.Record != nil {
+			pbChange.Record, err = dnsutils.ConvertRecordToPB(change.Record)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if change.OldRecord != nil {
+			pbChange.OldRecord, err = dnsutils.ConvertRecordToPB(change.OldRecord)
+			if err != nil {
+				return nil, err
+			}
+		}
+		pbChanges = append(pbChanges, pbChange)
+
+		// 预览时不执行
+		if req.PreviewOnly || change.Action == zonefile.ChangeActionSkip {
+			continue
+		}
+
+		var changeErr error
+		switch change.Action {
+		case zonefile.ChangeActionAdd:
+			change.Record.Route = defaultRoute
+			changeErr = manager.AddRecord(domain.Name, change.Record)
+		case zonefile.ChangeActionUpdate:
+			change.Record.Route = change.OldRecord.Route
+			changeErr = manager.UpdateRecord(domain.Name, change.OldRecord, change.Record)
+		case zonefile.ChangeActionDelete:
+			changeErr = manager.DeleteRecord(domain.Name, change.OldRecord)
+		}
+
+		err = dns.SharedDNSChangeLogDAO.CreateLog(tx, dns.DNSChangeSourceManual, 0, int64(domain.Id), 0, change.Action, change.OldRecord, change.Record, changeErr)
+		if err != nil {
+			return nil, err
+		}
+
+		if changeErr != nil {
+			pbChange.Error = changeErr.Error()
+			continue
+		}
+		isChanged = true
+	}
+
+	if isChanged {
+		err = dns.SharedDNSTaskDAO.CreateDomainTask(tx, int64(domain.Id), dns.DNSTaskTypeDomainChange)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.ImportDNSDomainZoneFileResponse{
+		Changes: pbChanges,
+		Errors:  pbErrors,
+	}, nil
+}
+
+// 查找域名和对应的服务商实例
+func (this *DNSDomainService) findDomainManager(tx *dbs.Tx, domainId int64) (*dns.DNSDomain, dnsclients.ProviderInterface, error) {
+	domain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, domainId, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if domain == nil {
+		return nil, nil, errors.New("can not find domain '" + types.String(domainId) + "'")
+	}
+
+	manager, err := dnsutils.FindDomainProvider(tx, domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	return domain, manager, nil
+}
+
+// 根据ID查找解析记录
+func (this *DNSDomainService) findDomainRecord(manager dnsclients.ProviderInterface, domainName string, recordId string) (*dnstypes.Record, error) {
+	if len(recordId) == 0 {
+		return nil, errors.New("invalid 'dnsRecordId'")
+	}
+
+	records, err := manager.GetRecords(domainName)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Id == recordId {
+			return record, nil
+		}
+	}
+	return nil, errors.New("can not find record '" + recordId + "'")
+}
+
+// 从请求中解析解析记录
+func (this *DNSDomainService) convertPBToRecord(pbRecord *pb.DNSRecord) (*dnstypes.Record, error) {
+	if pbRecord == nil {
+		return nil, errors.New("'dnsRecord' should not be nil")
+	}
+
+	var recordType = strings.ToUpper(pbRecord.Type)
+	if !dnstypes.IsValidRecordType(recordType) {
+		return nil, errors.New("unsupported record type '" + pbRecord.Type + "'")
+	}
+	if len(pbRecord.Name) == 0 {
+		return nil, errors.New("'name' should not be empty")
+	}
+	if len(pbRecord.Value) == 0 {
+		return nil, errors.New("'value' should not be empty")
+	}
+	if pbRecord.Priority < 0 || pbRecord.Weight < 0 {
+		return nil, errors.New("'priority' and 'weight' should not be negative")
+	}
+
+	var record = &dnstypes.Record{
+		Name:     pbRecord.Name,
+		Type:     recordType,
+		Value:    pbRecord.Value,
+		Route:    pbRecord.Route,
+		TTL:      pbRecord.Ttl,
+		Priority: pbRecord.Priority,
+		Weight:   pbRecord.Weight,
+	}
+	if len(pbRecord.FlagsJSON) > 0 {
+		err := json.Unmarshal(pbRecord.FlagsJSON, &record.Flags)
+		if err != nil {
+			return nil, errors.New("decode 'flagsJSON' failed: " + err.Error())
+		}
+	}
+	return record, nil
+}
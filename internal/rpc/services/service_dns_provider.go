@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
@@ -214,13 +215,14 @@ func (this *DNSProviderService) FindEnabledDNSProvider(ctx context.Context, req
 
 	return &pb.FindEnabledDNSProviderResponse{
 		DnsProvider: &pb.DNSProvider{
-			Id:            int64(provider.Id),
-			Name:          provider.Name,
-			Type:          provider.Type,
-			TypeName:      dnsclients.FindProviderTypeName(provider.Type),
-			ApiParamsJSON: provider.ApiParams,
-			DataUpdatedAt: int64(provider.DataUpdatedAt),
-			MinTTL:        int32(provider.MinTTL),
+			Id:              int64(provider.Id),
+			Name:            provider.Name,
+			Type:            provider.Type,
+			TypeName:        dnsclients.FindProviderTypeName(provider.Type),
+			ApiParamsJSON:   provider.ApiParams,
+			DataUpdatedAt:   int64(provider.DataUpdatedAt),
+			MinTTL:          int32(provider.MinTTL),
+			GuardConfigJSON: provider.GuardConfig,
 		},
 	}, nil
 }
@@ -270,3 +272,53 @@ func (this *DNSProviderService) FindAllEnabledDNSProvidersWithType(ctx context.C
 	}
 	return &pb.FindAllEnabledDNSProvidersWithTypeResponse{DnsProviders: result}, nil
 }
+
+// UpdateDNSProviderGuardConfig 修改服务商接口调用保护设置
+func (this *DNSProviderService) UpdateDNSProviderGuardConfig(ctx context.Context, req *pb.UpdateDNSProviderGuardConfigRequest) (*pb.RPCSuccess, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验设置
+	if len(req.GuardConfigJSON) > 0 {
+		_, err = dnsclients.DecodeGuardConfig(req.GuardConfigJSON)
+		if err != nil {
+			return nil, errors.New("decode guard config failed: " + err.Error())
+		}
+	}
+
+	var tx = this.NullTx()
+	err = dns.SharedDNSProviderDAO.UpdateDNSProviderGuardConfig(tx, req.DnsProviderId, req.GuardConfigJSON)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllDNSProviderCallStats 查看服务商接口调用统计
+func (this *DNSProviderService) FindAllDNSProviderCallStats(ctx context.Context, req *pb.FindAllDNSProviderCallStatsRequest) (*pb.FindAllDNSProviderCallStatsResponse, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbStats = []*pb.FindAllDNSProviderCallStatsResponse_Stat{}
+	for _, stat := range dnsclients.FindAllGuardStats(req.DnsProviderId) {
+		pbStats = append(pbStats, &pb.FindAllDNSProviderCallStatsResponse_Stat{
+			DnsProviderId: stat.ProviderId,
+			ProviderType:  stat.ProviderType,
+			Method:        stat.Method,
+			CountCalls:    stat.CountCalls,
+			CountFails:    stat.CountFails,
+			CountRetries:  stat.CountRetries,
+			CountRejects:  stat.CountRejects,
+			CostMs:        stat.CostMs,
+			LastError:     stat.LastError,
+			LastCalledAt:  stat.LastCalledAt,
+		})
+	}
+	return &pb.FindAllDNSProviderCallStatsResponse{Stats: pbStats}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/userconfigs"
@@ -109,6 +110,34 @@ func (this *HTTPCacheTaskService) CreateHTTPCacheTask(ctx context.Context, req *
 		}
 	}
 
+	var domainMap = map[string]*models.Server{} // domain name => *Server
+
+	// 子用户只能操作管理范围内的网站
+	var subUserId = rpcutils.SubUserIdFromContext(ctx)
+	if subUserId > 0 {
+		for _, key := range req.Keys {
+			var domain = utils.ParseDomainFromKey(key)
+			if len(domain) == 0 {
+				continue
+			}
+			server, ok := domainMap[domain]
+			if !ok {
+				server, err = models.SharedServerDAO.FindEnabledServerWithDomain(tx, userId, domain)
+				if err != nil {
+					return nil, err
+				}
+				domainMap[domain] = server
+			}
+			if server == nil || int64(server.UserId) != userId {
+				continue
+			}
+			err = models.SharedSubUserDAO.CheckSubUserServer(tx, subUserId, int64(server.Id), models.SubUserFeaturePurge)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// 创建任务
 	taskId, err := models.SharedHTTPCacheTaskDAO.CreateTask(tx, userId, req.Type, req.KeyType, "")
 	if err != nil {
@@ -116,7 +145,6 @@ func (this *HTTPCacheTaskService) CreateHTTPCacheTask(ctx context.Context, req *
 	}
 
 	var countKeys = 0
-	var domainMap = map[string]*models.Server{} // domain name => *Server
 	for _, key := range req.Keys {
 		if len(key) == 0 {
 			continue
@@ -17,7 +17,7 @@ type LogService struct {
 // CreateLog 创建日志
 func (this *LogService) CreateLog(ctx context.Context, req *pb.CreateLogRequest) (*pb.CreateLogResponse, error) {
 	// 校验请求
-	userType, _, userId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser, rpcutils.UserTypeProvider)
+	userType, _, userId, subUserId, err := rpcutils.ValidateRequestWithSubUser(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser, rpcutils.UserTypeProvider)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +33,7 @@ func (this *LogService) CreateLog(ctx context.Context, req *pb.CreateLogRequest)
 		}
 	}
 
-	err = models.SharedLogDAO.CreateLog(tx, userType, userId, req.Level, req.Description, req.Action, req.Ip, langs.MessageCode(req.LangMessageCode), langMessageArgs)
+	err = models.SharedLogDAO.CreateLog(tx, userType, userId, subUserId, req.Level, req.Description, req.Action, req.Ip, langs.MessageCode(req.LangMessageCode), langMessageArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +79,13 @@ func (this *LogService) ListLogs(ctx context.Context, req *pb.ListLogsRequest) (
 			userName, err = models.SharedAdminDAO.FindAdminFullname(tx, int64(log.AdminId))
 		} else if log.UserId > 0 {
 			userName, err = models.SharedUserDAO.FindUserFullname(tx, int64(log.UserId))
+			if err == nil && log.SubUserId > 0 {
+				var subUserName string
+				subUserName, err = models.SharedSubUserDAO.FindSubUserName(tx, int64(log.SubUserId))
+				if len(subUserName) > 0 {
+					userName += " / " + subUserName
+				}
+			}
 		} else if log.ProviderId > 0 {
 			userName, err = models.SharedProviderDAO.FindProviderName(tx, int64(log.ProviderId))
 		}
@@ -93,6 +100,7 @@ func (this *LogService) ListLogs(ctx context.Context, req *pb.ListLogsRequest) (
 			Action:      log.Action,
 			AdminId:     int64(log.AdminId),
 			UserId:      int64(log.UserId),
+			SubUserId:   int64(log.SubUserId),
 			ProviderId:  int64(log.ProviderId),
 			CreatedAt:   int64(log.CreatedAt),
 			Type:        log.Type,
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/otputils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/maps"
+	"time"
 )
 
 // LoginService 管理员认证相关服务
@@ -16,17 +20,23 @@ type LoginService struct {
 
 // FindEnabledLogin 查找认证
 func (this *LoginService) FindEnabledLogin(ctx context.Context, req *pb.FindEnabledLoginRequest) (*pb.FindEnabledLoginResponse, error) {
-	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, false)
 	if err != nil {
 		return nil, err
 	}
 
+	var tx = this.NullTx()
+
 	if userId > 0 {
+		req.AdminId = 0
 		req.UserId = userId
+	} else {
+		err = this.CheckAdminLoginTarget(tx, adminId, req.AdminId, req.UserId, permissions.ActionRead)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var tx = this.NullTx()
-
 	login, err := models.SharedLoginDAO.FindEnabledLoginWithType(tx, req.AdminId, req.UserId, req.Type)
 	if err != nil {
 		return nil, err
@@ -46,7 +56,7 @@ func (this *LoginService) FindEnabledLogin(ctx context.Context, req *pb.FindEnab
 
 // UpdateLogin 修改认证
 func (this *LoginService) UpdateLogin(ctx context.Context, req *pb.UpdateLoginRequest) (*pb.RPCSuccess, error) {
-	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, false)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +68,13 @@ func (this *LoginService) UpdateLogin(ctx context.Context, req *pb.UpdateLoginRe
 	var tx = this.NullTx()
 
 	if userId > 0 {
+		req.Login.AdminId = 0
 		req.Login.UserId = userId
+	} else {
+		err = this.CheckAdminLoginTarget(tx, adminId, req.Login.AdminId, req.Login.UserId, permissions.ActionWrite)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if req.Login.IsOn {
@@ -79,5 +95,188 @@ func (this *LoginService) UpdateLogin(ctx context.Context, req *pb.UpdateLoginRe
 			return nil, err
 		}
 	}
+	permissions.ResetCache()
+	return this.Success()
+}
+
+// CheckLoginOTP 校验登录时输入的OTP动态密码
+func (this *LoginService) CheckLoginOTP(ctx context.Context, req *pb.CheckLoginOTPRequest) (*pb.CheckLoginOTPResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if userId > 0 && (req.AdminId > 0 || req.UserId != userId) {
+		return nil, this.PermissionError()
+	}
+
+	var tx = this.NullTx()
+	role, username, accountId, secret, err := this.findOTPAccount(tx, req.AdminId, req.UserId, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return &pb.CheckLoginOTPResponse{
+			IsOk:    false,
+			Message: "没有启用OTP动态密码",
+		}, nil
+	}
+
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, role, username, req.Ip, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.CheckLoginOTPResponse{
+			IsOk:       false,
+			Message:    message,
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	if !otputils.ValidateTOTP(secret, req.Code, time.Now()) {
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, role, username, req.Ip, models.LoginFailureTypeOTP, accountId, req.SubUserId)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.CheckLoginOTPResponse{
+			IsOk:       false,
+			Message:    "请输入正确的OTP动态密码",
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	// 第二步认证成功后整个登录过程才完成，此时再清除失败记录
+	err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, role, username)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckLoginOTPResponse{IsOk: true}, nil
+}
+
+// FindLoginLockStatus 查看账号或者IP的登录锁定状态
+func (this *LoginService) FindLoginLockStatus(ctx context.Context, req *pb.FindLoginLockStatusRequest) (*pb.FindLoginLockStatusResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	decision, err := models.SharedLoginFailureDAO.CheckLogin(tx, req.Role, req.Username, req.Ip)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindLoginLockStatusResponse{
+		IsLocked:       decision.IsLocked,
+		RetryAfter:     decision.RetryAfter,
+		RequireCaptcha: decision.RequireCaptcha,
+	}, nil
+}
+
+// UnlockLogin 解除账号或者IP的登录锁定
+func (this *LoginService) UnlockLogin(ctx context.Context, req *pb.UnlockLoginRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Username) == 0 && len(req.Ip) == 0 {
+		return nil, errors.New("'username' or 'ip' should not be empty")
+	}
+
+	var tx = this.NullTx()
+
+	if len(req.Username) > 0 {
+		switch req.Role {
+		case models.LoginFailureRoleAdmin:
+			// 只有超级管理员才能解锁其他管理员
+			isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, adminId)
+			if err != nil {
+				return nil, err
+			}
+			if !isSuper {
+				return nil, this.PermissionError()
+			}
+		case models.LoginFailureRoleUser:
+		default:
+			return nil, errors.New("invalid role '" + req.Role + "'")
+		}
+
+		err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, req.Role, req.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(req.Ip) > 0 {
+		err = models.SharedLoginFailureDAO.DeleteIPFailures(tx, req.Ip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return this.Success()
 }
+
+// 查找需要校验OTP的账号
+func (this *LoginService) findOTPAccount(tx *dbs.Tx, adminId int64, userId int64, subUserId int64) (role string, username string, accountId int64, secret string, err error) {
+	switch {
+	case adminId > 0:
+		admin, err := models.SharedAdminDAO.FindBasicAdmin(tx, adminId)
+		if err != nil {
+			return "", "", 0, "", err
+		}
+		if admin == nil {
+			return "", "", 0, "", errors.New("admin not found")
+		}
+		secret, err = this.findOTPSecret(tx, adminId, 0)
+		return models.LoginFailureRoleAdmin, admin.Username, adminId, secret, err
+	case subUserId > 0:
+		subUser, err := models.SharedSubUserDAO.FindEnabledSubUser(tx, subUserId)
+		if err != nil {
+			return "", "", 0, "", err
+		}
+		if subUser == nil || (userId > 0 && int64(subUser.UserId) != userId) {
+			return "", "", 0, "", errors.New("sub user not found")
+		}
+		if subUser.OtpIsOn && models.IsNotNull(subUser.OtpParams) {
+			var params = maps.Map{}
+			err = json.Unmarshal(subUser.OtpParams, &params)
+			if err != nil {
+				return "", "", 0, "", err
+			}
+			secret = params.GetString("secret")
+		}
+		return models.LoginFailureRoleUser, subUser.Username, int64(subUser.UserId), secret, nil
+	case userId > 0:
+		user, err := models.SharedUserDAO.FindEnabledUser(tx, userId, nil)
+		if err != nil {
+			return "", "", 0, "", err
+		}
+		if user == nil {
+			return "", "", 0, "", errors.New("user not found")
+		}
+		secret, err = this.findOTPSecret(tx, 0, userId)
+		return models.LoginFailureRoleUser, user.Username, userId, secret, err
+	}
+	return "", "", 0, "", errors.New("'adminId', 'userId' or 'subUserId' should be greater than 0")
+}
+
+// 查找管理员或者用户的OTP密钥
+func (this *LoginService) findOTPSecret(tx *dbs.Tx, adminId int64, userId int64) (string, error) {
+	login, err := models.SharedLoginDAO.FindEnabledLoginWithType(tx, adminId, userId, models.LoginTypeOTP)
+	if err != nil {
+		return "", err
+	}
+	if login == nil || !login.IsOn || models.IsNull(login.Params) {
+		return "", nil
+	}
+	var params = maps.Map{}
+	err = json.Unmarshal(login.Params, &params)
+	if err != nil {
+		return "", err
+	}
+	return params.GetString("secret"), nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+)
+
+// ResetLoginRecoveryCodes 重新生成恢复码，旧的恢复码将全部失效
+func (this *LoginService) ResetLoginRecoveryCodes(ctx context.Context, req *pb.ResetLoginRecoveryCodesRequest) (*pb.ResetLoginRecoveryCodesResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	codes, err := models.SharedLoginDAO.ResetRecoveryCodes(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ResetLoginRecoveryCodesResponse{Codes: codes}, nil
+}
+
+// CountLoginRecoveryCodes 计算剩余可用的恢复码数量
+func (this *LoginService) CountLoginRecoveryCodes(ctx context.Context, req *pb.CountLoginRecoveryCodesRequest) (*pb.RPCCountResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	count, err := models.SharedLoginDAO.CountRecoveryCodes(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(int64(count))
+}
+
+// CheckLoginRecoveryCode 校验登录时输入的恢复码，校验成功后恢复码即失效
+func (this *LoginService) CheckLoginRecoveryCode(ctx context.Context, req *pb.CheckLoginRecoveryCodeRequest) (*pb.CheckLoginRecoveryCodeResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	role, username, _, err := this.findSecondFactorAccount(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, role, username, req.Ip, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.CheckLoginRecoveryCodeResponse{
+			IsOk:       false,
+			Message:    message,
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	ok, err := models.SharedLoginDAO.ConsumeRecoveryCode(tx, adminId, userId, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, role, username, req.Ip, models.LoginFailureTypeRecoveryCode, adminId+userId, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.CheckLoginRecoveryCodeResponse{
+			IsOk:       false,
+			Message:    "请输入正确的恢复码",
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, role, username)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckLoginRecoveryCodeResponse{IsOk: true}, nil
+}
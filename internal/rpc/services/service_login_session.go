@@ -6,7 +6,10 @@ import (
 	"context"
 	"errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
 )
 
 // LoginSessionService 登录SESSION服务
@@ -71,17 +74,11 @@ func (this *LoginSessionService) FindLoginSession(ctx context.Context, req *pb.F
 		}, nil
 	}
 
+	var pbSession = this.convertLoginSession(session)
+	pbSession.Sid = session.Sid
+	pbSession.ValuesJSON = session.Values
 	return &pb.FindLoginSessionResponse{
-		LoginSession: &pb.LoginSession{
-			Id:         int64(session.Id),
-			Sid:        session.Sid,
-			AdminId:    int64(session.AdminId),
-			UserId:     int64(session.UserId),
-			Ip:         session.Ip,
-			CreatedAt:  int64(session.CreatedAt),
-			ExpiresAt:  int64(session.ExpiresAt),
-			ValuesJSON: session.Values,
-		},
+		LoginSession: pbSession,
 	}, nil
 }
 
@@ -105,10 +102,115 @@ func (this *LoginSessionService) ClearOldLoginSessions(ctx context.Context, req
 		return nil, errors.New("invalid sid")
 	}
 
-	err = models.SharedLoginSessionDAO.ClearOldSessions(tx, int64(session.AdminId), int64(session.UserId), req.Sid, req.Ip)
+	err = models.SharedLoginSessionDAO.ClearOldSessions(tx, int64(session.AdminId), int64(session.UserId), int64(session.SubUserId), req.Sid, req.Ip)
 	if err != nil {
 		return nil, err
 	}
 
 	return this.Success()
 }
+
+// FindAllLoginSessions 列出某个账号所有可用的SESSION
+func (this *LoginSessionService) FindAllLoginSessions(ctx context.Context, req *pb.FindAllLoginSessionsRequest) (*pb.FindAllLoginSessionsResponse, error) {
+	var tx = this.NullTx()
+	adminId, userId, subUserId, err := this.findSessionOwner(ctx, tx, req.AdminId, req.UserId, permissions.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := models.SharedLoginSessionDAO.FindAllAvailableSessions(tx, adminId, userId, subUserId)
+	if err != nil {
+		return nil, err
+	}
+	var pbSessions = []*pb.LoginSession{}
+	for _, session := range sessions {
+		var pbSession = this.convertLoginSession(session)
+		pbSession.IsCurrent = len(req.CurrentSid) > 0 && session.Sid == req.CurrentSid
+		pbSessions = append(pbSessions, pbSession)
+	}
+	return &pb.FindAllLoginSessionsResponse{LoginSessions: pbSessions}, nil
+}
+
+// DeleteLoginSessionWithId 根据ID删除SESSION
+func (this *LoginSessionService) DeleteLoginSessionWithId(ctx context.Context, req *pb.DeleteLoginSessionWithIdRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	session, err := models.SharedLoginSessionDAO.FindAvailableSessionWithId(tx, req.LoginSessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return this.Success()
+	}
+
+	// 检查权限
+	adminId, userId, subUserId, err := this.findSessionOwner(ctx, tx, int64(session.AdminId), int64(session.UserId), permissions.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+	if adminId != int64(session.AdminId) || userId != int64(session.UserId) || (subUserId > 0 && subUserId != int64(session.SubUserId)) {
+		return nil, this.PermissionError()
+	}
+
+	err = models.SharedLoginSessionDAO.DeleteSessionWithId(tx, req.LoginSessionId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteAllLoginSessions 删除某个账号的所有SESSION
+func (this *LoginSessionService) DeleteAllLoginSessions(ctx context.Context, req *pb.DeleteAllLoginSessionsRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	adminId, userId, subUserId, err := this.findSessionOwner(ctx, tx, req.AdminId, req.UserId, permissions.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, userId, subUserId, req.ExceptSid)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// 获取可以操作的SESSION所属账号
+// 管理员可以操作平台用户的SESSION，超级管理员可以操作其他管理员的SESSION；用户只能操作自己的SESSION
+func (this *LoginSessionService) findSessionOwner(ctx context.Context, tx *dbs.Tx, reqAdminId int64, reqUserId int64, action permissions.Action) (adminId int64, userId int64, subUserId int64, err error) {
+	adminId, userId, err = this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// 用户
+	if userId > 0 {
+		return 0, userId, rpcutils.SubUserIdFromContext(ctx), nil
+	}
+
+	// 管理员
+	err = this.CheckAdminLoginTarget(tx, adminId, reqAdminId, reqUserId, action)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if reqUserId > 0 {
+		return 0, reqUserId, 0, nil
+	}
+	if reqAdminId > 0 {
+		return reqAdminId, 0, 0, nil
+	}
+	return adminId, 0, 0, nil
+}
+
+func (this *LoginSessionService) convertLoginSession(session *models.LoginSession) *pb.LoginSession {
+	return &pb.LoginSession{
+		Id:        int64(session.Id),
+		AdminId:   int64(session.AdminId),
+		UserId:    int64(session.UserId),
+		SubUserId: int64(session.SubUserId),
+		Ip:        session.Ip,
+		UserAgent: session.UserAgent,
+		Location:  session.Location,
+		CreatedAt: int64(session.CreatedAt),
+		ActiveAt:  int64(session.ActiveAt),
+		ExpiresAt: int64(session.ExpiresAt),
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/webauthnutils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+// WebAuthn挑战值有效期
+const webAuthnChallengeLife = 5 * time.Minute
+
+// BeginWebAuthnRegistration 开始注册WebAuthn凭证
+func (this *LoginService) BeginWebAuthnRegistration(ctx context.Context, req *pb.BeginWebAuthnRegistrationRequest) (*pb.BeginWebAuthnRegistrationResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	_, webAuthnConfig, err := this.findWebAuthnConfig(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, username, fullname, err := this.findSecondFactorAccount(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := models.SharedWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var excludeCredentialIds = [][]byte{}
+	for _, credential := range credentials {
+		credentialId, err := webauthnutils.DecodeBase64URL(credential.CredentialId)
+		if err != nil {
+			continue
+		}
+		excludeCredentialIds = append(excludeCredentialIds, credentialId)
+	}
+
+	challenge, err := webauthnutils.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	err = models.SharedLoginChallengeDAO.CreateChallenge(tx, adminId, userId, models.LoginChallengeTypeWebAuthnRegister, challenge, webAuthnChallengeLife)
+	if err != nil {
+		return nil, err
+	}
+
+	optionsJSON, err := webauthnutils.CreationOptions(webAuthnConfig.RPId, webAuthnConfig.Name(), this.webAuthnUserHandle(adminId, userId), username, fullname, challenge, excludeCredentialIds)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BeginWebAuthnRegistrationResponse{OptionsJSON: optionsJSON}, nil
+}
+
+// FinishWebAuthnRegistration 完成注册WebAuthn凭证
+func (this *LoginService) FinishWebAuthnRegistration(ctx context.Context, req *pb.FinishWebAuthnRegistrationRequest) (*pb.FinishWebAuthnRegistrationResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	challenge, err := this.consumeWebAuthnChallenge(tx, adminId, userId, models.LoginChallengeTypeWebAuthnRegister, req.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, webAuthnConfig, err := this.findWebAuthnConfig(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	var rp = &webauthnutils.RelyingParty{
+		Id:      webAuthnConfig.RPId,
+		Origins: webAuthnConfig.Origins,
+	}
+	result, err := webauthnutils.VerifyRegistration(rp, challenge, req.ClientDataJSON, req.AttestationObject, policy.RequireUserVerification)
+	if err != nil {
+		return nil, errors.New("verify registration failed: " + err.Error())
+	}
+
+	var name = req.Name
+	if len(name) == 0 {
+		name = "通行密钥"
+	}
+	credentialIntId, err := models.SharedWebAuthnCredentialDAO.CreateCredential(tx, adminId, userId, name, result)
+	if err != nil {
+		return nil, err
+	}
+	permissions.ResetCache()
+	return &pb.FinishWebAuthnRegistrationResponse{WebAuthnCredentialId: credentialIntId}, nil
+}
+
+// FindAllWebAuthnCredentials 查找所有WebAuthn凭证
+func (this *LoginService) FindAllWebAuthnCredentials(ctx context.Context, req *pb.FindAllWebAuthnCredentialsRequest) (*pb.FindAllWebAuthnCredentialsResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	credentials, err := models.SharedWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var pbCredentials = []*pb.WebAuthnCredential{}
+	for _, credential := range credentials {
+		pbCredentials = append(pbCredentials, &pb.WebAuthnCredential{
+			Id:         int64(credential.Id),
+			Name:       credential.Name,
+			Aaguid:     credential.Aaguid,
+			CreatedAt:  int64(credential.CreatedAt),
+			LastUsedAt: int64(credential.LastUsedAt),
+		})
+	}
+	return &pb.FindAllWebAuthnCredentialsResponse{WebAuthnCredentials: pbCredentials}, nil
+}
+
+// DeleteWebAuthnCredential 删除WebAuthn凭证
+func (this *LoginService) DeleteWebAuthnCredential(ctx context.Context, req *pb.DeleteWebAuthnCredentialRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	credential, err := models.SharedWebAuthnCredentialDAO.FindEnabledCredential(tx, req.WebAuthnCredentialId)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return this.Success()
+	}
+
+	adminId, userId, err := this.findSecondFactorOwner(ctx, int64(credential.AdminId), int64(credential.UserId), true)
+	if err != nil {
+		return nil, err
+	}
+	if adminId != int64(credential.AdminId) || userId != int64(credential.UserId) {
+		return nil, this.PermissionError()
+	}
+
+	err = models.SharedWebAuthnCredentialDAO.DisableCredential(tx, req.WebAuthnCredentialId)
+	if err != nil {
+		return nil, err
+	}
+	permissions.ResetCache()
+	return this.Success()
+}
+
+// BeginWebAuthnLogin 开始使用WebAuthn登录
+func (this *LoginService) BeginWebAuthnLogin(ctx context.Context, req *pb.BeginWebAuthnLoginRequest) (*pb.BeginWebAuthnLoginResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	_, webAuthnConfig, err := this.findWebAuthnConfig(tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := models.SharedWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, errors.New("no WebAuthn credentials found")
+	}
+	var allowCredentialIds = [][]byte{}
+	for _, credential := range credentials {
+		credentialId, err := webauthnutils.DecodeBase64URL(credential.CredentialId)
+		if err != nil {
+			continue
+		}
+		allowCredentialIds = append(allowCredentialIds, credentialId)
+	}
+
+	challenge, err := webauthnutils.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	err = models.SharedLoginChallengeDAO.CreateChallenge(tx, adminId, userId, models.LoginChallengeTypeWebAuthnLogin, challenge, webAuthnChallengeLife)
+	if err != nil {
+		return nil, err
+	}
+
+	optionsJSON, err := webauthnutils.RequestOptions(webAuthnConfig.RPId, challenge, allowCredentialIds)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BeginWebAuthnLoginResponse{OptionsJSON: optionsJSON}, nil
+}
+
+// FinishWebAuthnLogin 完成WebAuthn登录校验
+func (this *LoginService) FinishWebAuthnLogin(ctx context.Context, req *pb.FinishWebAuthnLoginRequest) (*pb.FinishWebAuthnLoginResponse, error) {
+	adminId, userId, err := this.findSecondFactorOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	role, username, _, err := this.findSecondFactorAccount(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, role, username, req.Ip, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.FinishWebAuthnLoginResponse{
+			IsOk:       false,
+			Message:    message,
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	verifyErr := this.verifyWebAuthnLogin(tx, adminId, userId, req)
+	if verifyErr != nil {
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, role, username, req.Ip, models.LoginFailureTypeWebAuthn, adminId+userId, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.FinishWebAuthnLoginResponse{
+			IsOk:       false,
+			Message:    "安全密钥校验失败：" + verifyErr.Error(),
+			IsLocked:   decision.IsLocked,
+			RetryAfter: decision.RetryAfter,
+		}, nil
+	}
+
+	// 第二步认证成功后整个登录过程才完成，此时再清除失败记录
+	err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, role, username)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FinishWebAuthnLoginResponse{IsOk: true}, nil
+}
+
+// 校验WebAuthn登录数据
+func (this *LoginService) verifyWebAuthnLogin(tx *dbs.Tx, adminId int64, userId int64, req *pb.FinishWebAuthnLoginRequest) error {
+	challenge, err := this.consumeWebAuthnChallenge(tx, adminId, userId, models.LoginChallengeTypeWebAuthnLogin, req.ClientDataJSON)
+	if err != nil {
+		return err
+	}
+
+	credential, err := models.SharedWebAuthnCredentialDAO.FindEnabledCredentialWithCredentialId(tx, adminId, userId, req.CredentialId)
+	if err != nil {
+		return err
+	}
+	if credential == nil {
+		return errors.New("credential not found")
+	}
+
+	policy, webAuthnConfig, err := this.findWebAuthnConfig(tx, adminId)
+	if err != nil {
+		return err
+	}
+
+	var rp = &webauthnutils.RelyingParty{
+		Id:      webAuthnConfig.RPId,
+		Origins: webAuthnConfig.Origins,
+	}
+	signCount, err := webauthnutils.VerifyAssertion(rp, challenge, req.ClientDataJSON, req.AuthenticatorData, req.Signature, credential.PublicKey, credential.SignCount, policy.RequireUserVerification)
+	if err != nil {
+		return err
+	}
+	return models.SharedWebAuthnCredentialDAO.UpdateCredentialUsed(tx, int64(credential.Id), signCount)
+}
+
+// 读取系统设置中的WebAuthn依赖方
+func (this *LoginService) findWebAuthnConfig(tx *dbs.Tx, adminId int64) (*loginutils.TwoFactorPolicy, *loginutils.WebAuthnConfig, error) {
+	policy, err := models.SharedSysSettingDAO.ReadTwoFactorPolicy(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var config = policy.WebAuthn(adminId > 0)
+	if !config.IsConfigured() {
+		return nil, nil, errors.New("WebAuthn is not configured")
+	}
+	return policy, config, nil
+}
+
+// 读取并使用挑战值
+func (this *LoginService) consumeWebAuthnChallenge(tx *dbs.Tx, adminId int64, userId int64, challengeType string, clientDataJSON []byte) (string, error) {
+	challenge, err := webauthnutils.ParseClientDataChallenge(clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	ok, err := models.SharedLoginChallengeDAO.ConsumeChallenge(tx, adminId, userId, challengeType, challenge)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("invalid or expired challenge")
+	}
+	return challenge, nil
+}
+
+// 生成WebAuthn用户标识，不能包含用户名等个人信息
+func (this *LoginService) webAuthnUserHandle(adminId int64, userId int64) []byte {
+	var key string
+	if adminId > 0 {
+		key = "admin:" + types.String(adminId)
+	} else {
+		key = "user:" + types.String(userId)
+	}
+	var sum = sha256.Sum256([]byte(key))
+	return sum[:16]
+}
+
+// 获取第二步认证所属的管理员或用户
+// requireLogin 表示是否需要已经登录，管理认证方式时需要已经登录，登录校验时则不需要
+func (this *LoginService) findSecondFactorOwner(ctx context.Context, reqAdminId int64, reqUserId int64, requireLogin bool) (adminId int64, userId int64, err error) {
+	reqUserType, _, reqId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 子用户不能管理主用户的认证方式
+	if rpcutils.SubUserIdFromContext(ctx) > 0 {
+		return 0, 0, this.PermissionError()
+	}
+
+	switch reqUserType {
+	case rpcutils.UserTypeUser:
+		if reqAdminId > 0 {
+			return 0, 0, this.PermissionError()
+		}
+		if reqId > 0 {
+			if reqUserId > 0 && reqUserId != reqId {
+				return 0, 0, this.PermissionError()
+			}
+			return 0, reqId, nil
+		}
+		if requireLogin || reqUserId <= 0 {
+			return 0, 0, this.PermissionError()
+		}
+		return 0, reqUserId, nil
+	case rpcutils.UserTypeAdmin:
+		if reqUserId <= 0 && reqAdminId <= 0 {
+			reqAdminId = reqId
+		}
+		if (reqUserId <= 0 && reqAdminId <= 0) || (requireLogin && reqId <= 0) {
+			return 0, 0, this.PermissionError()
+		}
+
+		// 管理平台用户的认证方式需要用户模块权限，管理其他管理员的认证方式需要超级管理员权限
+		if reqId > 0 {
+			err = this.CheckAdminLoginTarget(nil, reqId, reqAdminId, reqUserId, permissions.ActionWrite)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		if reqUserId > 0 {
+			return 0, reqUserId, nil
+		}
+		return reqAdminId, 0, nil
+	}
+	return 0, 0, this.PermissionError()
+}
+
+// 查找第二步认证所属账号的信息
+func (this *LoginService) findSecondFactorAccount(tx *dbs.Tx, adminId int64, userId int64) (role string, username string, fullname string, err error) {
+	if adminId > 0 {
+		admin, err := models.SharedAdminDAO.FindBasicAdmin(tx, adminId)
+		if err != nil {
+			return "", "", "", err
+		}
+		if admin == nil {
+			return "", "", "", errors.New("admin not found")
+		}
+		return models.LoginFailureRoleAdmin, admin.Username, admin.Fullname, nil
+	}
+
+	user, err := models.SharedUserDAO.FindEnabledUser(tx, userId, nil)
+	if err != nil {
+		return "", "", "", err
+	}
+	if user == nil {
+		return "", "", "", errors.New("user not found")
+	}
+	return models.LoginFailureRoleUser, user.Username, user.Fullname, nil
+}
@@ -1534,10 +1534,30 @@ func (this *NodeService) FindEnabledNodeDNS(ctx context.Context, req *pb.FindEna
 			IsBackupForCluster: node.IsBackupForCluster,
 			IsBackupForGroup:   node.IsBackupForGroup,
 			IsOffline:          node.CheckIsOffline(),
+			DnsWeight:          int32(node.DnsWeight),
 		},
 	}, nil
 }
 
+// UpdateNodeDNSWeight 修改节点的DNS权重
+func (this *NodeService) UpdateNodeDNSWeight(ctx context.Context, req *pb.UpdateNodeDNSWeightRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DnsWeight < 0 || req.DnsWeight > models.MaxNodeDNSWeight {
+		return nil, errors.New("invalid weight '" + types.String(req.DnsWeight) + "', should be between 0 and " + types.String(models.MaxNodeDNSWeight))
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedNodeDAO.UpdateNodeDNSWeight(tx, req.NodeId, req.DnsWeight)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
 // UpdateNodeDNS 修改节点的DNS解析信息
 func (this *NodeService) UpdateNodeDNS(ctx context.Context, req *pb.UpdateNodeDNSRequest) (*pb.RPCSuccess, error) {
 	// 校验请求
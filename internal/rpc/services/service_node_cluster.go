@@ -568,7 +568,7 @@ func (this *NodeClusterService) FindEnabledNodeClusterDNS(ctx context.Context, r
 			TypeName: dnsclients.FindProviderTypeName(provider.Type),
 		}
 
-		var manager = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+		var manager = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 		if manager != nil {
 			apiParams, err := provider.DecodeAPIParams()
 			if err != nil {
@@ -593,6 +593,7 @@ func (this *NodeClusterService) FindEnabledNodeClusterDNS(ctx context.Context, r
 		CnameAsDomain:    dnsConfig.CNAMEAsDomain,
 		IncludingLnNodes: dnsConfig.IncludingLnNodes,
 		DefaultRoute:     defaultRoute,
+		AutoFixDrift:     dnsInfo.DnsAutoFixDrift,
 	}, nil
 }
 
@@ -754,6 +755,90 @@ func (this *NodeClusterService) CheckNodeClusterDNSChanges(ctx context.Context,
 	return &pb.CheckNodeClusterDNSChangesResponse{IsChanged: len(changes) > 0}, nil
 }
 
+// PlanNodeClusterDNS 预览集群DNS同步时将要执行的变更，但不实际执行
+func (this *NodeClusterService) PlanNodeClusterDNS(ctx context.Context, req *pb.PlanNodeClusterDNSRequest) (*pb.PlanNodeClusterDNSResponse, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	plan, _, err := dnsutils.PlanClusterDNS(tx, req.NodeClusterId, req.NodesOnly)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return &pb.PlanNodeClusterDNSResponse{
+			Changes: []*pb.PlanNodeClusterDNSResponse_Change{},
+			Drifts:  []*pb.PlanNodeClusterDNSResponse_Change{},
+		}, nil
+	}
+
+	pbChanges, err := this.convertDNSChangesToPB(plan.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在系统之外被修改、但没有开启自动修正的记录
+	pbDrifts, err := this.convertDNSChangesToPB(plan.Drifts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PlanNodeClusterDNSResponse{
+		DnsDomainId: plan.DomainId,
+		Domain:      plan.Domain,
+		DnsName:     plan.ClusterDNSName,
+		Changes:     pbChanges,
+		Drifts:      pbDrifts,
+	}, nil
+}
+
+// 转换DNS变更为PB对象
+func (this *NodeClusterService) convertDNSChangesToPB(changes []*dnsutils.DNSChange) ([]*pb.PlanNodeClusterDNSResponse_Change, error) {
+	var pbChanges = []*pb.PlanNodeClusterDNSResponse_Change{}
+	for _, change := range changes {
+		var pbChange = &pb.PlanNodeClusterDNSResponse_Change{
+			Action:   change.Action,
+			Target:   change.Target,
+			TargetId: change.TargetId,
+		}
+		var err error
+		if change.Record != nil {
+			pbChange.Record, err = dnsutils.ConvertRecordToPB(change.Record)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if change.OldRecord != nil {
+			pbChange.OldRecord, err = dnsutils.ConvertRecordToPB(change.OldRecord)
+			if err != nil {
+				return nil, err
+			}
+		}
+		pbChanges = append(pbChanges, pbChange)
+	}
+	return pbChanges, nil
+}
+
+// UpdateNodeClusterDNSAutoFixDrift 设置是否自动修正集群DNS记录偏差
+func (this *NodeClusterService) UpdateNodeClusterDNSAutoFixDrift(ctx context.Context, req *pb.UpdateNodeClusterDNSAutoFixDriftRequest) (*pb.RPCSuccess, error) {
+	// 校验请求
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedNodeClusterDAO.UpdateClusterDNSAutoFixDrift(tx, req.NodeClusterId, req.AutoFixDrift)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
 // FindEnabledNodeClusterTOA 查找集群的TOA配置
 func (this *NodeClusterService) FindEnabledNodeClusterTOA(ctx context.Context, req *pb.FindEnabledNodeClusterTOARequest) (*pb.FindEnabledNodeClusterTOAResponse, error) {
 	_, err := this.ValidateAdmin(ctx)
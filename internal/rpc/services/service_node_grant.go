@@ -5,11 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/installers"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"golang.org/x/crypto/ssh"
-	"net"
 	"regexp"
 	"strings"
 	"time"
@@ -28,7 +28,7 @@ func (this *NodeGrantService) CreateNodeGrant(ctx context.Context, req *pb.Creat
 
 	var tx = this.NullTx()
 
-	grantId, err := models.SharedNodeGrantDAO.CreateGrant(tx, adminId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Description, req.NodeId, req.Su)
+	grantId, err := models.SharedNodeGrantDAO.CreateGrant(tx, adminId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Certificate, req.Description, req.NodeId, req.Su)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +69,7 @@ func (this *NodeGrantService) UpdateNodeGrant(ctx context.Context, req *pb.Updat
 		req.PrivateKey = grant.PrivateKey
 	}
 
-	err = models.SharedNodeGrantDAO.UpdateGrant(tx, req.NodeGrantId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Description, req.NodeId, req.Su)
+	err = models.SharedNodeGrantDAO.UpdateGrant(tx, req.NodeGrantId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Certificate, req.Description, req.NodeId, req.Su)
 	return this.Success()
 }
 
@@ -184,6 +184,7 @@ func (this *NodeGrantService) FindEnabledNodeGrant(ctx context.Context, req *pb.
 		Su:          grant.Su == 1,
 		PrivateKey:  grant.PrivateKey,
 		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
 		Description: grant.Description,
 		NodeId:      int64(grant.NodeId),
 	}}, nil
@@ -196,8 +197,6 @@ func (this *NodeGrantService) TestNodeGrant(ctx context.Context, req *pb.TestNod
 		return nil, err
 	}
 
-	var hostKeyCallback ssh.HostKeyCallback = nil
-
 	resp := &pb.TestNodeGrantResponse{
 		IsOk:  false,
 		Error: "",
@@ -228,45 +227,22 @@ func (this *NodeGrantService) TestNodeGrant(ctx context.Context, req *pb.TestNod
 		return resp, nil
 	}
 
-	// 不使用known_hosts
-	if hostKeyCallback == nil {
-		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		}
-	}
+	// 校验主机公钥
+	var hostKeyCallback = installers.NewHostKeyCallback(0, req.Host, int(req.Port))
 
 	// 认证
-	methods := []ssh.AuthMethod{}
-	if grant.Method == "user" {
-		{
-			authMethod := ssh.Password(grant.Password)
-			methods = append(methods, authMethod)
-		}
-
-		{
-			authMethod := ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-				if len(questions) == 0 {
-					return []string{}, nil
-				}
-				return []string{grant.Password}, nil
-			})
-			methods = append(methods, authMethod)
-		}
-	} else if grant.Method == "privateKey" {
-		var signer ssh.Signer
-		if len(grant.Passphrase) != 0 {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(grant.PrivateKey), []byte(grant.Passphrase))
-		} else {
-			signer, err = ssh.ParsePrivateKey([]byte(grant.PrivateKey))
-		}
-		if err != nil {
-			resp.Error = "parse private key: " + err.Error()
-			return resp, nil
-		}
-		authMethod := ssh.PublicKeys(signer)
-		methods = append(methods, authMethod)
-	} else {
-		return nil, errors.New("invalid method '" + grant.Method + "'")
+	var credentials = &installers.Credentials{
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+	}
+	methods, err := credentials.AuthMethods()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
 	}
 
 	// SSH客户端
@@ -274,10 +250,11 @@ func (this *NodeGrantService) TestNodeGrant(ctx context.Context, req *pb.TestNod
 		grant.Username = "root"
 	}
 	config := &ssh.ClientConfig{
-		User:            grant.Username,
-		Auth:            methods,
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         5 * time.Second, // TODO 后期可以设置这个超时时间
+		User:              grant.Username,
+		Auth:              methods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: installers.FindHostKeyAlgorithms(0, req.Host, int(req.Port)),
+		Timeout:           5 * time.Second, // TODO 后期可以设置这个超时时间
 	}
 
 	sshClient, err := ssh.Dial("tcp", configutils.QuoteIP(req.Host)+":"+fmt.Sprintf("%d", req.Port), config)
@@ -324,3 +301,89 @@ func (this *NodeGrantService) FindSuggestNodeGrants(ctx context.Context, req *pb
 	}
 	return &pb.FindSuggestNodeGrantsResponse{NodeGrants: pbGrants}, nil
 }
+
+// FindNodeSSHHostKeys 查找节点记录的SSH主机公钥
+func (this *NodeGrantService) FindNodeSSHHostKeys(ctx context.Context, req *pb.FindNodeSSHHostKeysRequest) (*pb.FindNodeSSHHostKeysResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	hostKeys, err := models.SharedSSHHostKeyDAO.FindAllHostKeysWithNodeId(tx, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	var pbHostKeys = []*pb.SSHHostKey{}
+	for _, hostKey := range hostKeys {
+		pbHostKeys = append(pbHostKeys, &pb.SSHHostKey{
+			Id:                 int64(hostKey.Id),
+			NodeId:             int64(hostKey.NodeId),
+			Host:               hostKey.Host,
+			Port:               int32(hostKey.Port),
+			KeyType:            hostKey.KeyType,
+			Fingerprint:        hostKey.Fingerprint,
+			PublicKey:          hostKey.PublicKey,
+			Source:             hostKey.Source,
+			PendingFingerprint: hostKey.PendingFingerprint,
+			PendingPublicKey:   hostKey.PendingPublicKey,
+			PendingAt:          int64(hostKey.PendingAt),
+			CreatedAt:          int64(hostKey.CreatedAt),
+			UpdatedAt:          int64(hostKey.UpdatedAt),
+		})
+	}
+	return &pb.FindNodeSSHHostKeysResponse{SshHostKeys: pbHostKeys}, nil
+}
+
+// ApproveNodeSSHHostKey 确认节点新的SSH主机公钥
+func (this *NodeGrantService) ApproveNodeSSHHostKey(ctx context.Context, req *pb.ApproveNodeSSHHostKeyRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	exists, err := models.SharedSSHHostKeyDAO.CheckHostKeyWithNodeId(tx, req.SshHostKeyId, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("can not find host key with id '" + numberutils.FormatInt64(req.SshHostKeyId) + "'")
+	}
+
+	err = models.SharedSSHHostKeyDAO.ApprovePendingHostKey(tx, req.SshHostKeyId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// ResetNodeSSHHostKeys 重置节点记录的SSH主机公钥
+func (this *NodeGrantService) ResetNodeSSHHostKeys(ctx context.Context, req *pb.ResetNodeSSHHostKeysRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedSSHHostKeyDAO.ResetHostKeysWithNodeId(tx, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// ImportNodeSSHKnownHosts 导入known_hosts格式的SSH主机公钥
+func (this *NodeGrantService) ImportNodeSSHKnownHosts(ctx context.Context, req *pb.ImportNodeSSHKnownHostsRequest) (*pb.ImportNodeSSHKnownHostsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	count, err := models.SharedSSHHostKeyDAO.ImportKnownHosts(tx, req.NodeId, req.KnownHostsData)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportNodeSSHKnownHostsResponse{Count: int32(count)}, nil
+}
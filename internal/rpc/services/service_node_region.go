@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 )
 
@@ -167,3 +168,51 @@ func (this *NodeRegionService) UpdateNodeRegionPrice(ctx context.Context, req *p
 	}
 	return this.Success()
 }
+
+// FindNodeRegionDNSRoutes 查找区域在某个域名下对应的DNS线路
+func (this *NodeRegionService) FindNodeRegionDNSRoutes(ctx context.Context, req *pb.FindNodeRegionDNSRoutesRequest) (*pb.FindNodeRegionDNSRoutesResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	region, err := models.SharedNodeRegionDAO.FindEnabledNodeRegion(tx, req.NodeRegionId)
+	if err != nil {
+		return nil, err
+	}
+	if region == nil {
+		return &pb.FindNodeRegionDNSRoutesResponse{DnsRoutes: nil}, nil
+	}
+
+	var pbRoutes = []*pb.DNSRoute{}
+	for _, routeCode := range region.DNSRouteCodesForDomainId(req.DnsDomainId) {
+		routeName, err := dns.SharedDNSDomainDAO.FindDomainRouteName(tx, req.DnsDomainId, routeCode)
+		if err != nil {
+			return nil, err
+		}
+		pbRoutes = append(pbRoutes, &pb.DNSRoute{
+			Name: routeName,
+			Code: routeCode,
+		})
+	}
+	return &pb.FindNodeRegionDNSRoutesResponse{DnsRoutes: pbRoutes}, nil
+}
+
+// UpdateNodeRegionDNSRoutes 修改区域在某个域名下对应的DNS线路
+// 区域中的节点如果没有单独设置线路，则使用区域的线路
+func (this *NodeRegionService) UpdateNodeRegionDNSRoutes(ctx context.Context, req *pb.UpdateNodeRegionDNSRoutesRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	err = models.SharedNodeRegionDAO.UpdateRegionDNSRoutes(tx, req.NodeRegionId, req.DnsDomainId, req.DnsRouteCodes)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
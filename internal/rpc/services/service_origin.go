@@ -10,6 +10,7 @@ import (
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/shared"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
 	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
 )
 
 // OriginService 源站相关管理
@@ -174,6 +175,52 @@ func (this *OriginService) UpdateOrigin(ctx context.Context, req *pb.UpdateOrigi
 	return this.Success()
 }
 
+// UpdateOriginCACerts 修改校验源站证书使用的CA证书
+func (this *OriginService) UpdateOriginCACerts(ctx context.Context, req *pb.UpdateOriginCACertsRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	if userId > 0 {
+		err = models.SharedOriginDAO.CheckUserOrigin(tx, userId, req.OriginId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var caCertRefs = []*sslconfigs.SSLCertRef{}
+	if len(req.CaCertRefsJSON) > 0 {
+		err = json.Unmarshal(req.CaCertRefsJSON, &caCertRefs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range caCertRefs {
+		if userId > 0 {
+			err = models.SharedSSLCertDAO.CheckUserCert(tx, ref.CertId, userId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, ref.CertId)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || !cert.IsCA {
+			return nil, errors.New("cert '" + types.String(ref.CertId) + "' is not a CA cert")
+		}
+	}
+
+	err = models.SharedOriginDAO.UpdateOriginCACerts(tx, req.OriginId, caCertRefs)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
 // FindEnabledOrigin 查找单个源站信息
 func (this *OriginService) FindEnabledOrigin(ctx context.Context, req *pb.FindEnabledOriginRequest) (*pb.FindEnabledOriginResponse, error) {
 	_, userId, err := this.ValidateAdminAndUser(ctx, true)
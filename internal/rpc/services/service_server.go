@@ -8,6 +8,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/clients"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/domainutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
@@ -1493,7 +1494,15 @@ func (this *ServerService) CountAllEnabledServersMatch(ctx context.Context, req
 
 	var tx = this.NullTx()
 
-	count, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, req.ServerGroupId, req.Keyword, req.UserId, req.NodeClusterId, types.Int8(req.AuditingFlag), utils.SplitStrings(req.ProtocolFamily, ","), req.UserPlanId)
+	subUserServerIds, isSubUser, err := this.findSubUserServerIds(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if isSubUser && len(subUserServerIds) == 0 {
+		return this.SuccessCount(0)
+	}
+
+	count, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, req.ServerGroupId, req.Keyword, req.UserId, req.NodeClusterId, types.Int8(req.AuditingFlag), utils.SplitStrings(req.ProtocolFamily, ","), req.UserPlanId, subUserServerIds)
 	if err != nil {
 		return nil, err
 	}
@@ -1532,7 +1541,15 @@ func (this *ServerService) ListEnabledServersMatch(ctx context.Context, req *pb.
 		order = "attackRequestsDesc"
 	}
 
-	servers, err := models.SharedServerDAO.ListEnabledServersMatch(tx, req.Offset, req.Size, req.ServerGroupId, req.Keyword, req.UserId, req.NodeClusterId, req.AuditingFlag, utils.SplitStrings(req.ProtocolFamily, ","), order)
+	subUserServerIds, isSubUser, err := this.findSubUserServerIds(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if isSubUser && len(subUserServerIds) == 0 {
+		return &pb.ListEnabledServersMatchResponse{Servers: []*pb.Server{}}, nil
+	}
+
+	servers, err := models.SharedServerDAO.ListEnabledServersMatch(tx, req.Offset, req.Size, req.ServerGroupId, req.Keyword, req.UserId, req.NodeClusterId, req.AuditingFlag, utils.SplitStrings(req.ProtocolFamily, ","), order, subUserServerIds)
 	if err != nil {
 		return nil, err
 	}
@@ -2243,6 +2260,10 @@ func (this *ServerService) FindAllEnabledServerNamesWithUserId(ctx context.Conte
 	if err != nil {
 		return nil, err
 	}
+	servers, err = this.filterSubUserServers(ctx, tx, servers)
+	if err != nil {
+		return nil, err
+	}
 	serverNames := []string{}
 	for _, server := range servers {
 		if models.IsNotNull(server.ServerNames) {
@@ -2325,6 +2346,10 @@ func (this *ServerService) FindAllUserServers(ctx context.Context, req *pb.FindA
 	if err != nil {
 		return nil, err
 	}
+	servers, err = this.filterSubUserServers(ctx, tx, servers)
+	if err != nil {
+		return nil, err
+	}
 
 	var pbServers = []*pb.Server{}
 	for _, server := range servers {
@@ -2353,7 +2378,15 @@ func (this *ServerService) CountAllUserServers(ctx context.Context, req *pb.Coun
 	}
 
 	var tx = this.NullTx()
-	countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", req.UserId, 0, configutils.BoolStateAll, nil, req.UserPlanId)
+	subUserServerIds, isSubUser, err := this.findSubUserServerIds(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if isSubUser && len(subUserServerIds) == 0 {
+		return this.SuccessCount(0)
+	}
+
+	countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", req.UserId, 0, configutils.BoolStateAll, nil, req.UserPlanId, subUserServerIds)
 	if err != nil {
 		return nil, err
 	}
@@ -3027,7 +3060,7 @@ func (this *ServerService) UpdateServerUserPlan(ctx context.Context, req *pb.Upd
 		}
 
 		if plan.TotalServers > 0 {
-			countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", userId, 0, configutils.BoolStateAll, nil, req.UserPlanId)
+			countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", userId, 0, configutils.BoolStateAll, nil, req.UserPlanId, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -3364,3 +3397,34 @@ func (this *ServerService) FindServerAuditingPrompt(ctx context.Context, req *pb
 		PromptText: "",
 	}, nil
 }
+
+// 查找子用户可以管理的网站ID
+// isSubUser 为false时表示当前不是子用户调用，不需要限制网站范围
+func (this *ServerService) findSubUserServerIds(ctx context.Context, tx *dbs.Tx) (serverIds []int64, isSubUser bool, err error) {
+	var subUserId = rpcutils.SubUserIdFromContext(ctx)
+	if subUserId <= 0 {
+		return nil, false, nil
+	}
+	serverIds, err = models.SharedSubUserDAO.FindSubUserServerIds(tx, subUserId)
+	return serverIds, true, err
+}
+
+// 过滤子用户不能管理的网站
+func (this *ServerService) filterSubUserServers(ctx context.Context, tx *dbs.Tx, servers []*models.Server) ([]*models.Server, error) {
+	serverIds, isSubUser, err := this.findSubUserServerIds(ctx, tx)
+	if err != nil || !isSubUser {
+		return servers, err
+	}
+
+	var serverIdMap = map[int64]bool{}
+	for _, serverId := range serverIds {
+		serverIdMap[serverId] = true
+	}
+	var result = []*models.Server{}
+	for _, server := range servers {
+		if serverIdMap[int64(server.Id)] {
+			result = append(result, server)
+		}
+	}
+	return result, nil
+}
@@ -1,11 +1,17 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/certutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
 	"github.com/iwind/TeaGo/dbs"
@@ -39,11 +45,19 @@ func (this *SSLCertService) CreateSSLCert(ctx context.Context, req *pb.CreateSSL
 		return nil, errors.New("invalid TimeEndAt")
 	}
 
-	certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, req.CertData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
+	certData, needCompleting, err := this.prepareCertData(req.CertData, req.KeyData, req.IsCA)
 	if err != nil {
 		return nil, err
 	}
 
+	certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, certData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
+	if err != nil {
+		return nil, err
+	}
+	if needCompleting {
+		this.completeCertChainLater(certId)
+	}
+
 	return &pb.CreateSSLCertResponse{SslCertId: certId}, nil
 }
 
@@ -63,10 +77,22 @@ func (this *SSLCertService) CreateSSLCerts(ctx context.Context, req *pb.CreateSS
 		}
 	}
 
+	// 检查证书
+	var certDataList = [][]byte{}
+	var needCompletingList = []bool{}
+	for _, cert := range req.SSLCerts {
+		certData, needCompleting, err := this.prepareCertData(cert.CertData, cert.KeyData, cert.IsCA)
+		if err != nil {
+			return nil, errors.New("cert '" + cert.Name + "': " + err.Error())
+		}
+		certDataList = append(certDataList, certData)
+		needCompletingList = append(needCompletingList, needCompleting)
+	}
+
 	var certIds = []int64{}
 	err = this.RunTx(func(tx *dbs.Tx) error {
-		for _, cert := range req.SSLCerts {
-			certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, cert.CertData, cert.KeyData, cert.TimeBeginAt, cert.TimeEndAt, cert.DnsNames, cert.CommonNames)
+		for index, cert := range req.SSLCerts {
+			certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, certDataList[index], cert.KeyData, cert.TimeBeginAt, cert.TimeEndAt, cert.DnsNames, cert.CommonNames)
 			if err != nil {
 				return err
 			}
@@ -77,6 +103,11 @@ func (this *SSLCertService) CreateSSLCerts(ctx context.Context, req *pb.CreateSS
 	if err != nil {
 		return nil, err
 	}
+	for index, certId := range certIds {
+		if needCompletingList[index] {
+			this.completeCertChainLater(certId)
+		}
+	}
 	return &pb.CreateSSLCertsResponse{SslCertIds: certIds}, nil
 }
 
@@ -105,10 +136,39 @@ func (this *SSLCertService) UpdateSSLCert(ctx context.Context, req *pb.UpdateSSL
 		}
 	}
 
-	err = models.SharedSSLCertDAO.UpdateCert(tx, req.SslCertId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, req.CertData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
+	// 只有重新上传时才检查证书
+	var certData = req.CertData
+	var needCompleting = false
+	if len(req.CertData) > 0 || len(req.KeyData) > 0 {
+		var keyData = req.KeyData
+		if len(certData) == 0 || len(keyData) == 0 {
+			oldCert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+			if err != nil {
+				return nil, err
+			}
+			if oldCert == nil {
+				return nil, errors.New("can not find cert")
+			}
+			if len(certData) == 0 {
+				certData = oldCert.CertData
+			}
+			if len(keyData) == 0 {
+				keyData = oldCert.KeyData
+			}
+		}
+		certData, needCompleting, err = this.prepareCertData(certData, keyData, req.IsCA)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = models.SharedSSLCertDAO.UpdateCert(tx, req.SslCertId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, certData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
 	if err != nil {
 		return nil, err
 	}
+	if needCompleting {
+		this.completeCertChainLater(req.SslCertId)
+	}
 
 	return this.Success()
 }
@@ -175,6 +235,49 @@ func (this *SSLCertService) DeleteSSLCert(ctx context.Context, req *pb.DeleteSSL
 	return this.Success()
 }
 
+// RevokeSSLCert 吊销通过ACME申请的证书
+func (this *SSLCertService) RevokeSSLCert(ctx context.Context, req *pb.RevokeSSLCertRequest) (*pb.RevokeACMETaskCertResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Reason < 0 || !acmeutils.IsValidRevokeReason(acmeutils.RevokeReason(req.Reason)) {
+		return nil, errors.New("invalid revoke reason '" + types.String(req.Reason) + "'")
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.SslCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("can not find cert")
+	}
+	if !cert.IsACME || cert.AcmeTaskId == 0 {
+		return nil, errors.New("only certs issued by ACME tasks can be revoked")
+	}
+
+	var result = acme.SharedACMETaskDAO.RevokeAndReissueACMETaskCert(tx, int64(cert.AcmeTaskId), req.SslCertId, acmeutils.RevokeReason(req.Reason), req.UseCertKey, req.Reissue)
+	return &pb.RevokeACMETaskCertResponse{
+		IsOk:              result.IsOk,
+		Error:             result.ErrMsg,
+		ReissueIsOk:       result.ReissueIsOk,
+		ReissueError:      result.ReissueErrMsg,
+		ReissuedSSLCertId: result.ReissuedCertId,
+	}, nil
+}
+
 // CountSSLCerts 计算匹配的Cert数量
 func (this *SSLCertService) CountSSLCerts(ctx context.Context, req *pb.CountSSLCertRequest) (*pb.RPCCountResponse, error) {
 	// 校验请求
@@ -405,3 +508,438 @@ func (this *SSLCertService) FindSSLCertUser(ctx context.Context, req *pb.FindSSL
 		},
 	}, nil
 }
+
+// FindAllSSLCertsWithChainIssues 查找证书链有问题的证书
+func (this *SSLCertService) FindAllSSLCertsWithChainIssues(ctx context.Context, req *pb.FindAllSSLCertsWithChainIssuesRequest) (*pb.FindAllSSLCertsWithChainIssuesResponse, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if adminId > 0 {
+		userId = req.UserId
+	}
+
+	var tx = this.NullTx()
+	certs, err := models.SharedSSLCertDAO.FindAllCertsToCheckChain(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbCerts = []*pb.FindAllSSLCertsWithChainIssuesResponse_SSLCert{}
+	for _, cert := range certs {
+		var report = certutils.CheckChain(cert.CertData, cert.KeyData)
+		if len(report.Issues) == 0 {
+			continue
+		}
+		var pbIssues = []*pb.SSLCertChainIssue{}
+		for _, issue := range report.Issues {
+			pbIssues = append(pbIssues, &pb.SSLCertChainIssue{
+				Code:    issue.Code,
+				Level:   issue.Level,
+				Message: issue.Message,
+			})
+		}
+		pbCerts = append(pbCerts, &pb.FindAllSSLCertsWithChainIssuesResponse_SSLCert{
+			SslCertId: int64(cert.Id),
+			Name:      cert.Name,
+			UserId:    int64(cert.UserId),
+			IsACME:    cert.IsACME,
+			TimeEndAt: int64(cert.TimeEndAt),
+			HasErrors: report.HasErrors(),
+			Issues:    pbIssues,
+		})
+	}
+	return &pb.FindAllSSLCertsWithChainIssuesResponse{SslCerts: pbCerts}, nil
+}
+
+// FixSSLCertChain 整理证书链并尝试补全中间证书
+func (this *SSLCertService) FixSSLCertChain(ctx context.Context, req *pb.FixSSLCertChainRequest) (*pb.FixSSLCertChainResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.SslCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("can not find cert")
+	}
+	if cert.IsCA {
+		return nil, errors.New("can not fix chain of CA cert")
+	}
+
+	var report = certutils.CheckChain(cert.CertData, nil)
+	if report.HasIssue(certutils.IssueCodeInvalidPEM) {
+		return nil, report.FirstError()
+	}
+	chain, changed, fixErr := certutils.FixChain(report, certutils.SharedIntermediateCache, true)
+	if changed {
+		err = models.SharedSSLCertDAO.UpdateCertChain(tx, req.SslCertId, certutils.EncodePEMCerts(chain))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var resp = &pb.FixSSLCertChainResponse{
+		IsChanged:   changed,
+		ChainLength: int32(len(chain)),
+	}
+	if fixErr != nil {
+		resp.Error = fixErr.Error()
+	}
+	return resp, nil
+}
+
+// ImportSSLCertPKCS12 导入PKCS#12（PFX）格式的证书
+func (this *SSLCertService) ImportSSLCertPKCS12(ctx context.Context, req *pb.ImportSSLCertPKCS12Request) (*pb.ImportSSLCertPKCS12Response, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户ID
+	if adminId > 0 && req.UserId > 0 {
+		userId = req.UserId
+	}
+
+	if len(req.PfxData) == 0 {
+		return nil, errors.New("'pfxData' should not be empty")
+	}
+
+	certData, keyData, err := certutils.DecodePKCS12(req.PfxData, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	certData, needCompleting, err := this.prepareCertData(certData, keyData, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分析证书
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+	}
+	err = sslConfig.Init(ctx)
+	if err != nil {
+		return nil, errors.New("parse cert failed: " + err.Error())
+	}
+
+	var name = req.Name
+	if len(name) == 0 && len(sslConfig.DNSNames) > 0 {
+		name = sslConfig.DNSNames[0]
+	}
+
+	var tx = this.NullTx()
+	certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, req.IsOn, name, req.Description, "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+	if err != nil {
+		return nil, err
+	}
+	if needCompleting {
+		this.completeCertChainLater(certId)
+	}
+	return &pb.ImportSSLCertPKCS12Response{SslCertId: certId}, nil
+}
+
+// ExportSSLCert 导出证书
+func (this *SSLCertService) ExportSSLCert(ctx context.Context, req *pb.ExportSSLCertRequest) (*pb.ExportSSLCertResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.SslCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("can not find cert")
+	}
+
+	switch req.Format {
+	case "pkcs12", "pfx":
+		// CA证书的私钥只用于签发证书，不能导出
+		if cert.IsCA || len(cert.KeyData) == 0 {
+			return nil, errors.New("can not export cert without private key as pkcs12")
+		}
+		data, err := certutils.EncodePKCS12(cert.CertData, cert.KeyData, req.Password)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ExportSSLCertResponse{Data: data}, nil
+	case "", "pem":
+		var data = append([]byte{}, cert.CertData...)
+		if len(cert.KeyData) > 0 && !cert.IsCA {
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				data = append(data, '\n')
+			}
+			data = append(data, cert.KeyData...)
+		}
+		return &pb.ExportSSLCertResponse{Data: data}, nil
+	}
+	return nil, errors.New("invalid format '" + req.Format + "'")
+}
+
+// CreateSSLCertCA 创建CA证书
+func (this *SSLCertService) CreateSSLCertCA(ctx context.Context, req *pb.CreateSSLCertCARequest) (*pb.CreateSSLCertCAResponse, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户ID
+	if adminId > 0 && req.UserId > 0 {
+		userId = req.UserId
+	}
+
+	var tx = this.NullTx()
+	certId, err := models.SharedSSLCertDAO.CreateCA(tx, adminId, userId, req.Name, req.Description, &certutils.CAOptions{
+		CommonName:   req.CommonName,
+		Organization: req.Organization,
+		KeyType:      req.KeyType,
+		Days:         int(req.Days),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateSSLCertCAResponse{SslCertId: certId}, nil
+}
+
+// IssueSSLCert 使用CA证书签发证书
+func (this *SSLCertService) IssueSSLCert(ctx context.Context, req *pb.IssueSSLCertRequest) (*pb.IssueSSLCertResponse, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.CaSSLCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// 签发的证书和CA证书属于同一个用户
+		userId, err = models.SharedSSLCertDAO.FindCertUserId(tx, req.CaSSLCertId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certId, err := models.SharedSSLCertDAO.IssueCertWithCA(tx, adminId, userId, req.CaSSLCertId, req.Name, req.Description, &certutils.IssueOptions{
+		CommonName:  req.CommonName,
+		DNSNames:    req.DnsNames,
+		IPAddresses: req.IpAddresses,
+		Usage:       req.Usage,
+		KeyType:     req.KeyType,
+		Days:        int(req.Days),
+		AutoRenew:   req.AutoRenew,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.IssueSSLCertResponse{SslCertId: certId}, nil
+}
+
+// RenewIssuedSSLCert 使用CA证书重新签发证书
+func (this *SSLCertService) RenewIssuedSSLCert(ctx context.Context, req *pb.RenewIssuedSSLCertRequest) (*pb.RPCSuccess, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.SslCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = models.SharedSSLCertDAO.RenewIssuedCert(tx, req.SslCertId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CountIssuedSSLCerts 计算CA签发的证书数量
+func (this *SSLCertService) CountIssuedSSLCerts(ctx context.Context, req *pb.CountIssuedSSLCertsRequest) (*pb.RPCCountResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.CaSSLCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := models.SharedSSLCertDAO.CountIssuedCerts(tx, req.CaSSLCertId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListIssuedSSLCerts 列出单页CA签发的证书
+func (this *SSLCertService) ListIssuedSSLCerts(ctx context.Context, req *pb.ListIssuedSSLCertsRequest) (*pb.ListIssuedSSLCertsResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.CaSSLCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certs, err := models.SharedSSLCertDAO.ListIssuedCerts(tx, req.CaSSLCertId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	var pbCerts = []*pb.ListIssuedSSLCertsResponse_SSLCert{}
+	for _, cert := range certs {
+		var pbCert = &pb.ListIssuedSSLCertsResponse_SSLCert{
+			SslCertId:   int64(cert.Id),
+			Name:        cert.Name,
+			IsOn:        cert.IsOn,
+			TimeBeginAt: int64(cert.TimeBeginAt),
+			TimeEndAt:   int64(cert.TimeEndAt),
+			DnsNames:    cert.DecodeDNSNames(),
+		}
+		var options = cert.DecodeIssueOptions()
+		if options != nil {
+			pbCert.Usage = options.Usage
+			pbCert.AutoRenew = options.AutoRenew
+		}
+		pbCerts = append(pbCerts, pbCert)
+	}
+	return &pb.ListIssuedSSLCertsResponse{SslCerts: pbCerts}, nil
+}
+
+// 检查上传的证书内容，并整理证书链
+// needCompleting 为true时表示缓存中没有需要的中间证书，需要在后台下载补全
+func (this *SSLCertService) prepareCertData(certData []byte, keyData []byte, isCA bool) (resultData []byte, needCompleting bool, err error) {
+	if isCA {
+		_, err = certutils.ParsePEMCerts(certData)
+		if err != nil {
+			return nil, false, errors.New("invalid cert data: " + err.Error())
+		}
+
+		// 导入的CA私钥用于签发证书
+		if len(keyData) > 0 {
+			_, err = tls.X509KeyPair(certData, keyData)
+			if err != nil {
+				return nil, false, errors.New("the CA cert and private key do not match: " + err.Error())
+			}
+		}
+		return certData, false, nil
+	}
+
+	var report = certutils.CheckChain(certData, keyData)
+	if report.HasErrors() {
+		return nil, false, report.FirstError()
+	}
+
+	// 这里只使用缓存中的中间证书，补全失败不影响上传
+	chain, changed, fixErr := certutils.FixChain(report, certutils.SharedIntermediateCache, false)
+	needCompleting = fixErr == certutils.ErrIssuerNotCached
+	if changed {
+		return certutils.EncodePEMCerts(chain), needCompleting, nil
+	}
+	return certData, needCompleting, nil
+}
+
+// 同时在后台补全证书链的最大数量
+var sslCertChainCompletingLimiter = make(chan bool, 4)
+
+// 在后台从AIA地址下载中间证书并补全证书链
+// 同时进行的任务太多时直接忽略，可以在证书链报告中手动补全
+func (this *SSLCertService) completeCertChainLater(certId int64) {
+	select {
+	case sslCertChainCompletingLimiter <- true:
+	default:
+		return
+	}
+
+	goman.New(func() {
+		defer func() {
+			<-sslCertChainCompletingLimiter
+		}()
+
+		err := this.completeCertChain(certId)
+		if err != nil {
+			remotelogs.Warn("SSL_CERT", "complete chain of cert '"+types.String(certId)+"' failed: "+err.Error())
+		}
+	})
+}
+
+// 补全证书链
+func (this *SSLCertService) completeCertChain(certId int64) error {
+	var tx = this.NullTx()
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
+	if err != nil || cert == nil || cert.IsCA {
+		return err
+	}
+
+	var report = certutils.CheckChain(cert.CertData, nil)
+	if report.HasIssue(certutils.IssueCodeInvalidPEM) {
+		return nil
+	}
+	chain, changed, err := certutils.FixChain(report, certutils.SharedIntermediateCache, true)
+	if err != nil || !changed {
+		return err
+	}
+
+	// 下载期间证书可能已经被修改
+	latestCert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
+	if err != nil || latestCert == nil || !bytes.Equal(latestCert.CertData, cert.CertData) {
+		return err
+	}
+	return models.SharedSSLCertDAO.UpdateCertChain(tx, certId, certutils.EncodePEMCerts(chain))
+}
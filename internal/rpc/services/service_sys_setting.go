@@ -2,8 +2,17 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/permissions"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sessionutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ssoutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
+	"github.com/iwind/TeaGo/types"
 )
 
 type SysSettingService struct {
@@ -21,11 +30,106 @@ func (this *SysSettingService) UpdateSysSetting(ctx context.Context, req *pb.Upd
 
 	var tx = this.NullTx()
 
+	// 校验配置
+	switch req.Code {
+	case systemconfigs.SettingCodePasswordPolicy:
+		var policy = passwordutils.DefaultPolicy()
+		err = json.Unmarshal(req.ValueJSON, policy)
+		if err != nil {
+			return nil, errors.New("decode password policy failed: " + err.Error())
+		}
+		err = policy.Init()
+		if err != nil {
+			return nil, err
+		}
+		req.ValueJSON, err = json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+	case systemconfigs.SettingCodeLoginFailurePolicy:
+		var policy = loginutils.DefaultPolicy()
+		err = json.Unmarshal(req.ValueJSON, policy)
+		if err != nil {
+			return nil, errors.New("decode login failure policy failed: " + err.Error())
+		}
+		err = policy.Init()
+		if err != nil {
+			return nil, err
+		}
+		req.ValueJSON, err = json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+	case systemconfigs.SettingCodeTwoFactorPolicy:
+		var policy = loginutils.DefaultTwoFactorPolicy()
+		err = json.Unmarshal(req.ValueJSON, policy)
+		if err != nil {
+			return nil, errors.New("decode two factor policy failed: " + err.Error())
+		}
+		err = policy.Init()
+		if err != nil {
+			return nil, err
+		}
+		req.ValueJSON, err = json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+	case systemconfigs.SettingCodeAdminSSOPolicy:
+		var policy = ssoutils.DefaultPolicy()
+		err = json.Unmarshal(req.ValueJSON, policy)
+		if err != nil {
+			return nil, errors.New("decode admin sso policy failed: " + err.Error())
+		}
+		err = policy.Init()
+		if err != nil {
+			return nil, err
+		}
+
+		// 检查角色是否存在
+		var roleIds = append([]int64{}, policy.DefaultRoleIds...)
+		for _, mapping := range policy.RoleMappings {
+			roleIds = append(roleIds, mapping.RoleIds...)
+		}
+		for _, roleId := range roleIds {
+			role, err := models.SharedAdminRoleDAO.FindEnabledAdminRole(tx, roleId)
+			if err != nil {
+				return nil, err
+			}
+			if role == nil {
+				return nil, errors.New("admin role '" + types.String(roleId) + "' not found")
+			}
+		}
+
+		req.ValueJSON, err = json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+	case systemconfigs.SettingCodeLoginSessionPolicy:
+		var policy = sessionutils.DefaultPolicy()
+		err = json.Unmarshal(req.ValueJSON, policy)
+		if err != nil {
+			return nil, errors.New("decode login session policy failed: " + err.Error())
+		}
+		err = policy.Init()
+		if err != nil {
+			return nil, err
+		}
+		req.ValueJSON, err = json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedSysSettingDAO.UpdateSetting(tx, req.Code, req.ValueJSON)
 	if err != nil {
 		return nil, err
 	}
 
+	// 双因素认证策略会影响可以调用的方法
+	if req.Code == systemconfigs.SettingCodeTwoFactorPolicy {
+		permissions.ResetCache()
+	}
+
 	return this.Success()
 }
 
@@ -0,0 +1,330 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package users
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+)
+
+// SubUserService 子用户相关服务
+type SubUserService struct {
+	services.BaseService
+}
+
+// CreateSubUser 创建子用户
+func (this *SubUserService) CreateSubUser(ctx context.Context, req *pb.CreateSubUserRequest) (*pb.CreateSubUserResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if userId <= 0 {
+		userId = req.UserId
+	}
+	if userId <= 0 {
+		return nil, errors.New("invalid 'userId'")
+	}
+
+	var tx = this.NullTx()
+	err = this.checkSubUserFields(tx, 0, userId, req.Username, req.Password, req.ServerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	subUserId, err := models.SharedSubUserDAO.CreateSubUser(tx, userId, req.Name, req.Username, req.Password, req.Features, req.ServerIds, req.ServerGroupIds, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateSubUserResponse{SubUserId: subUserId}, nil
+}
+
+// UpdateSubUser 修改子用户
+func (this *SubUserService) UpdateSubUser(ctx context.Context, req *pb.UpdateSubUserRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	subUser, err := this.findSubUser(ctx, tx, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.checkSubUserFields(tx, req.SubUserId, int64(subUser.UserId), req.Username, req.Password, req.ServerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedSubUserDAO.UpdateSubUser(tx, req.SubUserId, req.Name, req.Username, req.Password, req.Features, req.ServerIds, req.ServerGroupIds, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteSubUser 删除子用户
+func (this *SubUserService) DeleteSubUser(ctx context.Context, req *pb.DeleteSubUserRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	_, err := this.findSubUser(ctx, tx, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedSubUserDAO.DisableSubUser(tx, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindEnabledSubUser 查找单个子用户
+func (this *SubUserService) FindEnabledSubUser(ctx context.Context, req *pb.FindEnabledSubUserRequest) (*pb.FindEnabledSubUserResponse, error) {
+	var tx = this.NullTx()
+	subUser, err := this.findSubUser(ctx, tx, req.SubUserId)
+	if err != nil {
+		if err == models.ErrNotFound {
+			return &pb.FindEnabledSubUserResponse{SubUser: nil}, nil
+		}
+		return nil, err
+	}
+	return &pb.FindEnabledSubUserResponse{SubUser: this.convertSubUser(subUser, true)}, nil
+}
+
+// CountAllEnabledSubUsers 计算子用户数量
+func (this *SubUserService) CountAllEnabledSubUsers(ctx context.Context, req *pb.CountAllEnabledSubUsersRequest) (*pb.RPCCountResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if userId <= 0 {
+		userId = req.UserId
+	}
+
+	var tx = this.NullTx()
+	count, err := models.SharedSubUserDAO.CountAllEnabledSubUsers(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListEnabledSubUsers 列出单页子用户
+func (this *SubUserService) ListEnabledSubUsers(ctx context.Context, req *pb.ListEnabledSubUsersRequest) (*pb.ListEnabledSubUsersResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if userId <= 0 {
+		userId = req.UserId
+	}
+
+	var tx = this.NullTx()
+	subUsers, err := models.SharedSubUserDAO.ListEnabledSubUsers(tx, userId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	var pbSubUsers = []*pb.SubUser{}
+	for _, subUser := range subUsers {
+		pbSubUsers = append(pbSubUsers, this.convertSubUser(subUser, false))
+	}
+	return &pb.ListEnabledSubUsersResponse{SubUsers: pbSubUsers}, nil
+}
+
+// UpdateSubUserOTP 修改子用户的OTP设置
+func (this *SubUserService) UpdateSubUserOTP(ctx context.Context, req *pb.UpdateSubUserOTPRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	_, err := this.findSubUser(ctx, tx, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedSubUserDAO.UpdateSubUserOTP(tx, req.SubUserId, req.IsOn, req.ParamsJSON)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// LoginSubUser 子用户登录
+func (this *SubUserService) LoginSubUser(ctx context.Context, req *pb.LoginSubUserRequest) (*pb.LoginSubUserResponse, error) {
+	_, err := this.ValidateUserNode(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Username) == 0 || len(req.Password) == 0 {
+		return &pb.LoginSubUserResponse{
+			IsOk:    false,
+			Message: "请输入正确的用户名密码",
+		}, nil
+	}
+
+	var tx = this.NullTx()
+
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, models.LoginFailureRoleUser, req.Username, req.Ip, req.CaptchaVerified)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.LoginSubUserResponse{
+			IsOk:           false,
+			Message:        message,
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
+	subUser, err := models.SharedSubUserDAO.CheckSubUserPassword(tx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if subUser == nil {
+		var userId int64
+		var subUserId int64
+		failedSubUser, err := models.SharedSubUserDAO.FindSubUserWithUsername(tx, req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if failedSubUser != nil {
+			userId = int64(failedSubUser.UserId)
+			subUserId = int64(failedSubUser.Id)
+		}
+		decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, models.LoginFailureRoleUser, req.Username, req.Ip, models.LoginFailureTypePassword, userId, subUserId)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.LoginSubUserResponse{
+			IsOk:           false,
+			Message:        "请输入正确的用户名密码",
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
+	// 需要OTP认证时，等OTP认证成功后再清除失败记录
+	if !subUser.OtpIsOn {
+		err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, models.LoginFailureRoleUser, req.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.LoginSubUserResponse{
+		UserId:    int64(subUser.UserId),
+		SubUserId: int64(subUser.Id),
+		IsOk:      true,
+	}, nil
+}
+
+// CheckSubUserOTPWithUsername 检查子用户是否需要输入OTP
+func (this *SubUserService) CheckSubUserOTPWithUsername(ctx context.Context, req *pb.CheckSubUserOTPWithUsernameRequest) (*pb.CheckSubUserOTPWithUsernameResponse, error) {
+	_, err := this.ValidateUserNode(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Username) == 0 {
+		return &pb.CheckSubUserOTPWithUsernameResponse{RequireOTP: false}, nil
+	}
+
+	var tx = this.NullTx()
+	subUser, err := models.SharedSubUserDAO.FindSubUserWithUsername(tx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if subUser == nil {
+		return &pb.CheckSubUserOTPWithUsernameResponse{RequireOTP: false}, nil
+	}
+	return &pb.CheckSubUserOTPWithUsernameResponse{RequireOTP: subUser.OtpIsOn}, nil
+}
+
+// CheckSubUserServer 检查子用户是否可以对某个网站使用某个功能
+func (this *SubUserService) CheckSubUserServer(ctx context.Context, req *pb.CheckSubUserServerRequest) (*pb.CheckSubUserServerResponse, error) {
+	var tx = this.NullTx()
+	_, err := this.findSubUser(ctx, tx, req.SubUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedSubUserDAO.CheckSubUserServer(tx, req.SubUserId, req.ServerId, req.Feature)
+	if err != nil {
+		return &pb.CheckSubUserServerResponse{IsAllowed: false}, nil
+	}
+	return &pb.CheckSubUserServerResponse{IsAllowed: true}, nil
+}
+
+// 查找子用户并检查权限
+func (this *SubUserService) findSubUser(ctx context.Context, tx *dbs.Tx, subUserId int64) (*models.SubUser, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	subUser, err := models.SharedSubUserDAO.FindEnabledSubUser(tx, subUserId)
+	if err != nil {
+		return nil, err
+	}
+	if subUser == nil {
+		return nil, models.ErrNotFound
+	}
+	if userId > 0 && int64(subUser.UserId) != userId {
+		return nil, models.ErrNotFound
+	}
+	return subUser, nil
+}
+
+// 检查子用户信息
+func (this *SubUserService) checkSubUserFields(tx *dbs.Tx, subUserId int64, userId int64, username string, password string, serverIds []int64) error {
+	if len(username) == 0 {
+		return errors.New("'username' should not be empty")
+	}
+	exists, err := models.SharedSubUserDAO.ExistSubUsername(tx, subUserId, username)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("the username '" + username + "' has been used")
+	}
+
+	if subUserId <= 0 || len(password) > 0 {
+		err = checkUserPasswordPolicy(tx, 0, password)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 网站需要属于主用户
+	for _, serverId := range serverIds {
+		err = models.SharedServerDAO.CheckUserServer(tx, userId, serverId)
+		if err != nil {
+			return errors.New("invalid server '" + types.String(serverId) + "'")
+		}
+	}
+	return nil
+}
+
+func (this *SubUserService) convertSubUser(subUser *models.SubUser, withOTP bool) *pb.SubUser {
+	var pbSubUser = &pb.SubUser{
+		Id:             int64(subUser.Id),
+		UserId:         int64(subUser.UserId),
+		Name:           subUser.Name,
+		Username:       subUser.Username,
+		IsOn:           subUser.IsOn,
+		Features:       subUser.DecodeFeatures(),
+		ServerIds:      subUser.DecodeServerIds(),
+		ServerGroupIds: subUser.DecodeServerGroupIds(),
+		CreatedAt:      int64(subUser.CreatedAt),
+	}
+	if withOTP && models.IsNotNull(subUser.OtpParams) {
+		pbSubUser.OtpLogin = &pb.Login{
+			Type:       models.LoginTypeOTP,
+			ParamsJSON: subUser.OtpParams,
+			IsOn:       subUser.OtpIsOn,
+		}
+	}
+	return pbSubUser
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
 	"github.com/TeaOSLab/EdgeCommon/pkg/userconfigs"
+	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/types"
 	timeutil "github.com/iwind/TeaGo/utils/time"
 	"strings"
@@ -34,6 +35,11 @@ func (this *UserService) CreateUser(ctx context.Context, req *pb.CreateUserReque
 
 	var tx = this.NullTx()
 
+	err = checkUserPasswordPolicy(tx, 0, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	userId, err := models.SharedUserDAO.CreateUser(tx, req.Username, req.Password, req.Fullname, req.Mobile, req.Tel, req.Email, req.Remark, req.Source, req.NodeClusterId, nil, "", true)
 	if err != nil {
 		return nil, err
@@ -71,6 +77,13 @@ func (this *UserService) UpdateUser(ctx context.Context, req *pb.UpdateUserReque
 		return nil, err
 	}
 
+	if len(req.Password) > 0 {
+		err = checkUserPasswordPolicy(tx, req.UserId, req.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedUserDAO.UpdateUser(tx, req.UserId, req.Username, req.Password, req.Fullname, req.Mobile, req.Tel, req.Email, req.Remark, req.IsOn, req.NodeClusterId, req.BandwidthAlgo)
 	if err != nil {
 		return nil, err
@@ -319,6 +332,22 @@ func (this *UserService) LoginUser(ctx context.Context, req *pb.LoginUserRequest
 
 	var tx = this.NullTx()
 
+	// 检查失败记录
+	decision, message, err := this.CheckLoginFailures(tx, models.LoginFailureRoleUser, req.Username, req.Ip, req.CaptchaVerified)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > 0 {
+		return &pb.LoginUserResponse{
+			UserId:         0,
+			IsOk:           false,
+			Message:        message,
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
+		}, nil
+	}
+
 	// 邮箱登录
 	var registerConfig *userconfigs.UserRegisterConfig
 	if strings.Contains(req.Username, "@") {
@@ -335,10 +364,7 @@ func (this *UserService) LoginUser(ctx context.Context, req *pb.LoginUserRequest
 				return nil, err
 			}
 			if userId > 0 {
-				return &pb.LoginUserResponse{
-					UserId: userId,
-					IsOk:   true,
-				}, nil
+				return this.loginUserSuccess(tx, req.Username, userId)
 			}
 		}
 	}
@@ -358,10 +384,7 @@ func (this *UserService) LoginUser(ctx context.Context, req *pb.LoginUserRequest
 				return nil, err
 			}
 			if userId > 0 {
-				return &pb.LoginUserResponse{
-					UserId: userId,
-					IsOk:   true,
-				}, nil
+				return this.loginUserSuccess(tx, req.Username, userId)
 			}
 		}
 	}
@@ -374,16 +397,51 @@ func (this *UserService) LoginUser(ctx context.Context, req *pb.LoginUserRequest
 	}
 
 	if userId <= 0 {
+		// 子用户使用同一个登录入口，由子用户登录接口记录失败
+		subUser, err := models.SharedSubUserDAO.FindSubUserWithUsername(tx, req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if subUser == nil {
+			decision, err = models.SharedLoginFailureDAO.RecordFailure(tx, models.LoginFailureRoleUser, req.Username, req.Ip, models.LoginFailureTypePassword, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		return &pb.LoginUserResponse{
-			UserId:  0,
-			IsOk:    false,
-			Message: "请输入正确的用户名密码",
+			UserId:         0,
+			IsOk:           false,
+			Message:        "请输入正确的用户名密码",
+			IsLocked:       decision.IsLocked,
+			RetryAfter:     decision.RetryAfter,
+			RequireCaptcha: decision.RequireCaptcha,
 		}, nil
 	}
 
+	return this.loginUserSuccess(tx, req.Username, userId)
+}
+
+// 用户登录成功后清除失败记录
+// 需要第二步认证时，等第二步认证成功后再清除失败记录
+func (this *UserService) loginUserSuccess(tx *dbs.Tx, username string, userId int64) (*pb.LoginUserResponse, error) {
+	twoFactorTypes, requireTwoFactorSetup, err := models.SharedLoginDAO.FindTwoFactorStatus(tx, 0, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(twoFactorTypes) == 0 {
+		err = models.SharedLoginFailureDAO.DeleteAccountFailures(tx, models.LoginFailureRoleUser, username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &pb.LoginUserResponse{
-		UserId: userId,
-		IsOk:   true,
+		UserId:                userId,
+		IsOk:                  true,
+		TwoFactorTypes:        twoFactorTypes,
+		RequireTwoFactorSetup: requireTwoFactorSetup,
 	}, nil
 }
 
@@ -420,6 +478,13 @@ func (this *UserService) UpdateUserLogin(ctx context.Context, req *pb.UpdateUser
 
 	var tx = this.NullTx()
 
+	if len(req.Password) > 0 {
+		err = checkUserPasswordPolicy(tx, req.UserId, req.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = models.SharedUserDAO.UpdateUserLogin(tx, req.UserId, req.Username, req.Password)
 	if err != nil {
 		return nil, err
@@ -446,7 +511,7 @@ func (this *UserService) ComposeUserDashboard(ctx context.Context, req *pb.Compo
 	}
 
 	// 网站数量
-	countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", req.UserId, 0, configutils.BoolStateAll, []string{}, 0)
+	countServers, err := models.SharedServerDAO.CountAllEnabledServersMatch(tx, 0, "", req.UserId, 0, configutils.BoolStateAll, []string{}, 0, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -898,3 +963,27 @@ func (this *UserService) FindUserVerifiedEmailWithUsername(ctx context.Context,
 		Email: email,
 	}, nil
 }
+
+// 检查用户密码是否符合密码策略
+func checkUserPasswordPolicy(tx *dbs.Tx, userId int64, password string) error {
+	policy, err := models.SharedSysSettingDAO.ReadPasswordPolicy(tx)
+	if err != nil {
+		return err
+	}
+	if !policy.ApplyToUsers {
+		return nil
+	}
+	err = policy.Check(password)
+	if err != nil {
+		return err
+	}
+
+	isReused, err := models.SharedUserDAO.CheckUserPasswordReused(tx, userId, password, policy.HistorySize)
+	if err != nil {
+		return err
+	}
+	if isReused {
+		return errors.New("the password has been used recently, please choose another one")
+	}
+	return nil
+}
@@ -59,6 +59,12 @@ func (this *UserService) RegisterUser(ctx context.Context, req *pb.RegisterUserR
 		return nil, errors.New("the registration has been disabled")
 	}
 
+	// 检查密码
+	err = checkUserPasswordPolicy(tx, 0, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	var requireEmailVerification = false
 	var createdUserId int64
 	err = this.RunTx(func(tx *dbs.Tx) error {
@@ -0,0 +1,27 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package rpcutils
+
+import "context"
+
+type subUserContextKey struct{}
+
+// WithSubUserId 在上下文中记录子用户ID
+func WithSubUserId(ctx context.Context, subUserId int64) context.Context {
+	if subUserId <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, subUserContextKey{}, subUserId)
+}
+
+// SubUserIdFromContext 从上下文中读取子用户ID
+func SubUserIdFromContext(ctx context.Context) int64 {
+	if ctx == nil {
+		return 0
+	}
+	subUserId, ok := ctx.Value(subUserContextKey{}).(int64)
+	if !ok {
+		return 0
+	}
+	return subUserId
+}
@@ -18,6 +18,12 @@ import (
 
 // ValidateRequest 校验请求
 func ValidateRequest(ctx context.Context, userTypes ...UserType) (userType UserType, resultNodeId int64, userId int64, err error) {
+	userType, resultNodeId, userId, _, err = ValidateRequestWithSubUser(ctx, userTypes...)
+	return
+}
+
+// ValidateRequestWithSubUser 校验请求，并返回子用户ID
+func ValidateRequestWithSubUser(ctx context.Context, userTypes ...UserType) (userType UserType, resultNodeId int64, userId int64, subUserId int64, err error) {
 	if ctx == nil {
 		err = errors.New("context should not be nil")
 		return
@@ -45,24 +51,24 @@ func ValidateRequest(ctx context.Context, userTypes ...UserType) (userType UserT
 	{
 		mockCtx, isMock := ctx.(*MockNodeContext)
 		if isMock {
-			return UserTypeNode, 0, mockCtx.NodeId, nil
+			return UserTypeNode, 0, mockCtx.NodeId, 0, nil
 		}
 	}
 
 	{
 		mockCtx, isMock := ctx.(*MockAdminNodeContext)
 		if isMock {
-			return UserTypeAdmin, 0, mockCtx.AdminId, nil
+			return UserTypeAdmin, 0, mockCtx.AdminId, 0, nil
 		}
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return UserTypeNone, 0, 0, errors.New("context: need 'nodeId'")
+		return UserTypeNone, 0, 0, 0, errors.New("context: need 'nodeId'")
 	}
 	nodeIds := md.Get("nodeid")
 	if len(nodeIds) == 0 || len(nodeIds[0]) == 0 {
-		return UserTypeNone, 0, 0, errors.New("context: need 'nodeId'")
+		return UserTypeNone, 0, 0, 0, errors.New("context: need 'nodeId'")
 	}
 	nodeId := nodeIds[0]
 
@@ -70,83 +76,83 @@ func ValidateRequest(ctx context.Context, userTypes ...UserType) (userType UserT
 	apiToken, err := models.SharedApiTokenDAO.FindEnabledTokenWithNodeCacheable(nil, nodeId)
 	if err != nil {
 		utils.PrintError(err)
-		return UserTypeNone, 0, 0, err
+		return UserTypeNone, 0, 0, 0, err
 	}
 	nodeUserId := int64(0)
 	if apiToken == nil {
-		return UserTypeNode, 0, 0, errors.New("context: can not find api token for node '" + nodeId + "'")
+		return UserTypeNode, 0, 0, 0, errors.New("context: can not find api token for node '" + nodeId + "'")
 	}
 
 	tokens := md.Get("token")
 	if len(tokens) == 0 || len(tokens[0]) == 0 {
-		return UserTypeNone, 0, 0, errors.New("context: need 'token'")
+		return UserTypeNone, 0, 0, 0, errors.New("context: need 'token'")
 	}
 	token := tokens[0]
 
 	data, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		return UserTypeNone, 0, 0, err
+		return UserTypeNone, 0, 0, 0, err
 	}
 
 	method, err := encrypt.NewMethodInstance(teaconst.EncryptMethod, apiToken.Secret, nodeId)
 	if err != nil {
 		utils.PrintError(err)
-		return UserTypeNone, 0, 0, err
+		return UserTypeNone, 0, 0, 0, err
 	}
 	data, err = method.Decrypt(data)
 	if err != nil {
-		return UserTypeNone, 0, 0, err
+		return UserTypeNone, 0, 0, 0, err
 	}
 	if len(data) == 0 {
-		return UserTypeNone, 0, 0, errors.New("invalid token")
+		return UserTypeNone, 0, 0, 0, errors.New("invalid token")
 	}
 
 	var m = maps.Map{}
 	err = json.Unmarshal(data, &m)
 	if err != nil {
-		return UserTypeNone, 0, 0, errors.New("decode token error: " + err.Error())
+		return UserTypeNone, 0, 0, 0, errors.New("decode token error: " + err.Error())
 	}
 
 	t := m.GetString("type")
 	if len(userTypes) > 0 && !lists.ContainsString(userTypes, t) {
-		return UserTypeNone, 0, 0, errors.New("not supported node type: '" + t + "'")
+		return UserTypeNone, 0, 0, 0, errors.New("not supported node type: '" + t + "'")
 	}
 
 	switch apiToken.Role {
 	case UserTypeNode:
 		nodeIntId, err := models.SharedNodeDAO.FindEnabledNodeIdWithUniqueId(nil, nodeId)
 		if err != nil {
-			return UserTypeNode, 0, 0, errors.New("context: " + err.Error())
+			return UserTypeNode, 0, 0, 0, errors.New("context: " + err.Error())
 		}
 		if nodeIntId <= 0 {
-			return UserTypeNode, 0, 0, errors.New("context: not found node with id '" + nodeId + "'")
+			return UserTypeNode, 0, 0, 0, errors.New("context: not found node with id '" + nodeId + "'")
 		}
 		nodeUserId = nodeIntId
 		resultNodeId = nodeIntId
 	case UserTypeCluster:
 		clusterId, err := models.SharedNodeClusterDAO.FindEnabledClusterIdWithUniqueId(nil, nodeId)
 		if err != nil {
-			return UserTypeCluster, 0, 0, errors.New("context: " + err.Error())
+			return UserTypeCluster, 0, 0, 0, errors.New("context: " + err.Error())
 		}
 		if clusterId <= 0 {
-			return UserTypeCluster, 0, 0, errors.New("context: not found cluster with id '" + nodeId + "'")
+			return UserTypeCluster, 0, 0, 0, errors.New("context: not found cluster with id '" + nodeId + "'")
 		}
 		nodeUserId = clusterId
 		resultNodeId = clusterId
 	case UserTypeUser:
 		nodeIntId, err := models.SharedUserNodeDAO.FindEnabledUserNodeIdWithUniqueId(nil, nodeId)
 		if err != nil {
-			return UserTypeUser, 0, 0, errors.New("context: " + err.Error())
+			return UserTypeUser, 0, 0, 0, errors.New("context: " + err.Error())
 		}
 		if nodeIntId <= 0 {
-			return UserTypeUser, 0, 0, errors.New("context: not found node with id '" + nodeId + "'")
+			return UserTypeUser, 0, 0, 0, errors.New("context: not found node with id '" + nodeId + "'")
 		}
 		resultNodeId = nodeIntId
 	}
 
 	if nodeUserId > 0 {
-		return t, resultNodeId, nodeUserId, nil
+		return t, resultNodeId, nodeUserId, 0, nil
 	} else {
-		return t, resultNodeId, m.GetInt64("userId"), nil
+		return t, resultNodeId, m.GetInt64("userId"), m.GetInt64("subUserId"), nil
 	}
 }
@@ -0,0 +1,157 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package tasks
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	dnsmodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns/dnsutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	stringutil "github.com/iwind/TeaGo/utils/string"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		goman.New(func() {
+			NewDNSDriftCheckTask(30 * time.Minute).Start()
+		})
+	})
+}
+
+// DNSDriftCheckTask 检查服务商上的DNS记录是否在系统之外被修改
+type DNSDriftCheckTask struct {
+	BaseTask
+
+	ticker *time.Ticker
+
+	driftHashMap map[int64]string // clusterId => 上次发送消息时的偏差记录，偏差没有变化时不重复发送
+}
+
+func NewDNSDriftCheckTask(duration time.Duration) *DNSDriftCheckTask {
+	return &DNSDriftCheckTask{
+		ticker:       time.NewTicker(duration),
+		driftHashMap: map[int64]string{},
+	}
+}
+
+func (this *DNSDriftCheckTask) Start() {
+	for range this.ticker.C {
+		err := this.Loop()
+		if err != nil {
+			this.logErr("DNSDriftCheckTask", err.Error())
+		}
+	}
+}
+
+func (this *DNSDriftCheckTask) Loop() error {
+	if !this.IsPrimaryNode() {
+		return nil
+	}
+
+	var tx *dbs.Tx
+
+	// 清理过期的变更日志
+	err := dnsmodels.SharedDNSChangeLogDAO.DeleteLogsBeforeDay(tx, timeutil.Format("Ymd", time.Now().AddDate(0, 0, -30)))
+	if err != nil {
+		return err
+	}
+
+	// 有正在执行的任务时，记录本来就和期望的不一致，所以跳过
+	hasDoingTasks, err := dnsmodels.SharedDNSTaskDAO.ExistDoingTasks(tx)
+	if err != nil {
+		return err
+	}
+	if hasDoingTasks {
+		return nil
+	}
+
+	clusters, err := models.SharedNodeClusterDAO.FindAllEnabledClustersHaveDNSDomain(tx)
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		if !cluster.IsOn {
+			continue
+		}
+
+		err = this.checkCluster(tx, cluster)
+		if err != nil {
+			this.logErr("DNSDriftCheckTask", "check cluster '"+types.String(cluster.Id)+"' failed: "+err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (this *DNSDriftCheckTask) checkCluster(tx *dbs.Tx, cluster *models.NodeCluster) error {
+	var clusterId = int64(cluster.Id)
+	plan, manager, err := dnsutils.PlanClusterDNS(tx, clusterId, false)
+	if err != nil {
+		return err
+	}
+	if plan == nil || (plan.IsEmpty() && len(plan.Drifts) == 0) {
+		delete(this.driftHashMap, clusterId)
+		return nil
+	}
+
+	// 自动修正
+	if cluster.DnsAutoFixDrift {
+		isChanged, applyErr := dnsutils.ApplyClusterDNSPlan(tx, manager, plan, dnsmodels.DNSChangeSourceDrift, 0)
+		if isChanged {
+			err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, plan.DomainId, dnsmodels.DNSTaskTypeDomainChange)
+			if err != nil {
+				return err
+			}
+		}
+		return applyErr
+	}
+
+	// 和上次发送消息时的偏差相同时不再重复发送
+	var changes = append(append([]*dnsutils.DNSChange{}, plan.Changes...), plan.Drifts...)
+	var driftHash = this.hashChanges(changes)
+	if this.driftHashMap[clusterId] == driftHash {
+		return nil
+	}
+
+	// 发送消息
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	var subject = "集群\"" + cluster.Name + "\"的DNS记录和期望的不一致"
+	var body = subject + "：需要添加" + types.String(plan.Count(dnsmodels.DNSChangeActionAdd)) + "条、修改" + types.String(plan.Count(dnsmodels.DNSChangeActionUpdate)) + "条、删除" + types.String(plan.Count(dnsmodels.DNSChangeActionDelete)) + "条记录，可能是在系统之外被修改，请检查后重新同步。"
+	if len(plan.Drifts) > 0 {
+		body += "另外有" + types.String(len(plan.Drifts)) + "条同名的CNAME记录指向了别处，因为没有开启自动修正，同步时不会覆盖。"
+	}
+	err = models.SharedMessageDAO.CreateClusterMessage(tx, nodeconfigs.NodeRoleNode, clusterId, models.MessageTypeClusterDNSDrift, models.MessageLevelWarning, subject, subject, body, changesJSON)
+	if err != nil {
+		return err
+	}
+	this.driftHashMap[clusterId] = driftHash
+	return nil
+}
+
+// 计算变更的特征值，和变更的顺序无关
+func (this *DNSDriftCheckTask) hashChanges(changes []*dnsutils.DNSChange) string {
+	var keys = []string{}
+	for _, change := range changes {
+		var key = change.Action + "|" + change.Target + "|" + types.String(change.TargetId)
+		for _, record := range []*dnstypes.Record{change.OldRecord, change.Record} {
+			if record != nil {
+				key += "|" + record.Name + "|" + record.Type + "|" + record.Value + "|" + record.Route + "|" + types.String(record.TTL) + "|" + types.String(record.Weight)
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return stringutil.Md5(strings.Join(keys, "\n"))
+}
@@ -4,15 +4,15 @@ import (
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	dnsmodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns/dnsutils"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnsverify"
 	"github.com/TeaOSLab/EdgeAPI/internal/goman"
 	"github.com/TeaOSLab/EdgeCommon/pkg/dnsconfigs"
 	"github.com/TeaOSLab/EdgeCommon/pkg/iputils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/iwind/TeaGo/dbs"
-	"github.com/iwind/TeaGo/lists"
-	"net"
 	"strings"
 	"time"
 )
@@ -25,6 +25,11 @@ func init() {
 	})
 }
 
+const (
+	dnsVerifyTimeout  = 10 * time.Minute // 检查记录生效的最长时间
+	dnsVerifyInterval = 20 * time.Second // 检查记录生效的间隔
+)
+
 // DNSTaskExecutor DNS任务执行器
 type DNSTaskExecutor struct {
 	BaseTask
@@ -217,6 +222,10 @@ func (this *DNSTaskExecutor) doServer(taskId int64, taskVersion int64, oldCluste
 			if err != nil {
 				return err
 			}
+
+			var expectations = dnsverify.NewExpectationSet()
+			expectations.AddAbsent(recordName+"."+domain, recordType, record.Value)
+			this.verify(taskId, taskVersion, domain, expectations.All())
 		}
 
 		isOk = true
@@ -270,6 +279,10 @@ func (this *DNSTaskExecutor) doServer(taskId int64, taskVersion int64, oldCluste
 			return err
 		}
 
+		var expectations = dnsverify.NewExpectationSet()
+		expectations.AddPresent(recordName+"."+domain, recordType, recordValue)
+		this.verify(taskId, taskVersion, domain, expectations.All())
+
 		isOk = true
 	}
 
@@ -335,231 +348,31 @@ func (this *DNSTaskExecutor) doCluster(taskId int64, taskVersion int64, clusterI
 	}()
 
 	var tx *dbs.Tx
-	manager, domainId, domain, clusterDNSName, dnsConfig, err := this.findDNSManagerWithClusterId(tx, clusterId)
+	plan, manager, err := dnsutils.PlanClusterDNS(tx, clusterId, nodesOnly)
 	if err != nil {
 		return err
 	}
-	if manager == nil {
+	if plan == nil {
 		isOk = true
 		return nil
 	}
 
-	var clusterDomain = clusterDNSName + "." + domain
+	isChanged, applyErr := dnsutils.ApplyClusterDNSPlan(tx, manager, plan, dnsmodels.DNSChangeSourceTask, taskId)
 
-	var ttl int32 = 0
-	if dnsConfig != nil {
-		ttl = dnsConfig.TTL
-	}
-
-	// 以前的节点记录
-	records, err := manager.GetRecords(domain)
-	if err != nil {
-		return err
-	}
-	var oldRecordsMap = map[string]*dnstypes.Record{}      // route@value => record
-	var oldCnameRecordsMap = map[string]*dnstypes.Record{} // cname => record
-	for _, record := range records {
-		if (record.Type == dnstypes.RecordTypeA || record.Type == dnstypes.RecordTypeAAAA) && record.Name == clusterDNSName {
-			key := record.Route + "@" + record.Value
-			oldRecordsMap[key] = record
-		}
-
-		if record.Type == dnstypes.RecordTypeCNAME {
-			oldCnameRecordsMap[record.Name] = record
-		}
-	}
-
-	// 当前的节点记录
-	var newRecordKeys = []string{}
-	nodes, err := models.SharedNodeDAO.FindAllEnabledNodesDNSWithClusterId(tx, clusterId, true, dnsConfig != nil && dnsConfig.IncludingLnNodes, true)
-	if err != nil {
-		return err
-	}
-	var isChanged = false
-	var addingNodeRecordKeysMap = map[string]bool{} // clusterDnsName_type_ip_route
-	for _, node := range nodes {
-		shouldSkip, shouldOverwrite, ipAddressesStrings, err := models.SharedNodeDAO.CheckNodeIPAddresses(tx, node)
-		if err != nil {
-			return err
-		}
-		if shouldSkip {
-			continue
-		}
-
-		routes, err := node.DNSRouteCodesForDomainId(domainId)
+	// 通知更新域名
+	if isChanged {
+		err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, plan.DomainId, dnsmodels.DNSTaskTypeDomainChange)
 		if err != nil {
 			return err
 		}
-		if len(routes) == 0 {
-			routes = []string{manager.DefaultRoute()}
-		}
-
-		// 所有的IP记录
-		if !shouldOverwrite {
-			ipAddresses, err := models.SharedNodeIPAddressDAO.FindAllEnabledAddressesWithNode(tx, int64(node.Id), nodeconfigs.NodeRoleNode)
-			if err != nil {
-				return err
-			}
-			if len(ipAddresses) == 0 {
-				continue
-			}
-			for _, ipAddress := range ipAddresses {
-				// 检查专属节点
-				if !ipAddress.IsValidInCluster(clusterId) {
-					continue
-				}
-
-				var ip = ipAddress.DNSIP()
-				if len(ip) == 0 || !ipAddress.CanAccess || !ipAddress.IsUp || !ipAddress.IsOn {
-					continue
-				}
-				if net.ParseIP(ip) == nil {
-					continue
-				}
-				ipAddressesStrings = append(ipAddressesStrings, ip)
-			}
-		}
-
-		if len(ipAddressesStrings) == 0 {
-			continue
-		}
-
-		for _, ip := range ipAddressesStrings {
-			for _, route := range routes {
-				var key = route + "@" + ip
-				_, ok := oldRecordsMap[key]
-				if ok {
-					newRecordKeys = append(newRecordKeys, key)
-					continue
-				}
-
-				var recordType = dnstypes.RecordTypeA
-				if iputils.IsIPv6(ip) {
-					recordType = dnstypes.RecordTypeAAAA
-				}
-
-				// 避免添加重复的记录
-				var fullKey = clusterDNSName + "_" + recordType + "_" + ip + "_" + route
-				if addingNodeRecordKeysMap[fullKey] {
-					continue
-				}
-				addingNodeRecordKeysMap[fullKey] = true
-
-				err = manager.AddRecord(domain, &dnstypes.Record{
-					Id:    "",
-					Name:  clusterDNSName,
-					Type:  recordType,
-					Value: ip,
-					Route: route,
-					TTL:   ttl,
-				})
-				if err != nil {
-					return err
-				}
-				isChanged = true
-				newRecordKeys = append(newRecordKeys, key)
-			}
-		}
 	}
 
-	// 删除多余的节点解析记录
-	for key, record := range oldRecordsMap {
-		if !lists.ContainsString(newRecordKeys, key) {
-			isChanged = true
-			err = manager.DeleteRecord(domain, record)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	// 服务域名
-	if !nodesOnly {
-		servers, err := models.SharedServerDAO.FindAllServersDNSWithClusterId(tx, clusterId)
-		if err != nil {
-			return err
-		}
-		serverRecords := []*dnstypes.Record{}             // 之所以用数组再存一遍，是因为dnsName可能会重复
-		serverRecordsMap := map[string]*dnstypes.Record{} // dnsName => *Record
-		for _, record := range records {
-			if record.Type == dnstypes.RecordTypeCNAME && record.Value == clusterDomain+"." {
-				serverRecords = append(serverRecords, record)
-				serverRecordsMap[record.Name] = record
-			}
-		}
-
-		// 新增的域名
-		var serverDNSNames = []string{}
-		for _, server := range servers {
-			var dnsName = server.DnsName
-			if len(dnsName) == 0 {
-				continue
-			}
-			serverDNSNames = append(serverDNSNames, dnsName)
-			_, ok := serverRecordsMap[dnsName]
-			if !ok {
-				isChanged = true
-				err = manager.AddRecord(domain, &dnstypes.Record{
-					Id:    "",
-					Name:  dnsName,
-					Type:  dnstypes.RecordTypeCNAME,
-					Value: clusterDomain + ".",
-					Route: "", // 注意这里为空，需要在执行过程中获取默认值
-					TTL:   ttl,
-				})
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		// 自动设置的CNAME
-		var cnameRecords = []string{}
-		if dnsConfig != nil {
-			cnameRecords = dnsConfig.CNAMERecords
-		}
-		for _, cnameRecord := range cnameRecords {
-			// 如果记录已存在，则跳过
-			if lists.ContainsString(serverDNSNames, cnameRecord) {
-				continue
-			}
-
-			serverDNSNames = append(serverDNSNames, cnameRecord)
-			_, ok := serverRecordsMap[cnameRecord]
-			if !ok {
-				isChanged = true
-				err = manager.AddRecord(domain, &dnstypes.Record{
-					Id:    "",
-					Name:  cnameRecord,
-					Type:  dnstypes.RecordTypeCNAME,
-					Value: clusterDomain + ".",
-					Route: "", // 注意这里为空，需要在执行过程中获取默认值
-					TTL:   ttl,
-				})
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		// 多余的域名
-		for _, record := range serverRecords {
-			if !lists.ContainsString(serverDNSNames, record.Name) {
-				isChanged = true
-				err = manager.DeleteRecord(domain, record)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	if applyErr != nil {
+		return applyErr
 	}
 
-	// 通知更新域名
 	if isChanged {
-		err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
-		if err != nil {
-			return err
-		}
+		this.verify(taskId, taskVersion, plan.Domain, dnsutils.BuildPlanExpectations(plan, manager.DefaultRoute()))
 	}
 
 	isOk = true
@@ -694,7 +507,7 @@ func (this *DNSTaskExecutor) doDomainWithTask(taskId int64, taskVersion int64, d
 		return nil
 	}
 
-	var manager = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	var manager = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if manager == nil {
 		this.logErr("DNSTaskExecutor", "unsupported dns provider type '"+provider.Type+"'")
 		isOk = true
@@ -724,6 +537,50 @@ func (this *DNSTaskExecutor) doDomainWithTask(taskId int64, taskVersion int64, d
 	return nil
 }
 
+// 在后台检查变更是否已经在权威服务器上生效
+func (this *DNSTaskExecutor) verify(taskId int64, taskVersion int64, domain string, expectations []*dnsverify.Expectation) {
+	if taskId <= 0 || len(expectations) == 0 {
+		return
+	}
+
+	err := dnsmodels.SharedDNSTaskDAO.UpdateDNSTaskVerifying(nil, taskId)
+	if err != nil {
+		this.logErr("DNSTaskExecutor", "update verify status failed: "+err.Error())
+		return
+	}
+
+	goman.New(func() {
+		var err error
+		var verifier = dnsverify.NewVerifier(domain)
+		lagging, verifyErr := verifier.Wait(expectations, dnsVerifyTimeout, dnsVerifyInterval)
+		if verifyErr != nil {
+			lagging = []*dnsverify.Result{}
+			for _, expectation := range expectations {
+				lagging = append(lagging, &dnsverify.Result{
+					Expectation: expectation,
+					Error:       verifyErr.Error(),
+				})
+			}
+		}
+
+		var status = dnsmodels.DNSTaskVerifyStatusVerified
+		var resultJSON []byte
+		if len(lagging) > 0 {
+			status = dnsmodels.DNSTaskVerifyStatusStale
+			resultJSON, err = json.Marshal(lagging)
+			if err != nil {
+				this.logErr("DNSTaskExecutor", "encode verify result failed: "+err.Error())
+				return
+			}
+		}
+
+		err = dnsmodels.SharedDNSTaskDAO.UpdateDNSTaskVerifyResult(nil, taskId, taskVersion, status, resultJSON)
+		if err != nil {
+			this.logErr("DNSTaskExecutor", "update verify result failed: "+err.Error())
+		}
+	})
+}
+
 func (this *DNSTaskExecutor) findDNSManagerWithClusterId(tx *dbs.Tx, clusterId int64) (manager dnsclients.ProviderInterface, domainId int64, domain string, clusterDNSName string, dnsConfig *dnsconfigs.ClusterDNSConfig, err error) {
 	clusterDNS, err := models.SharedNodeClusterDAO.FindClusterDNSInfo(tx, clusterId, nil)
 	if err != nil {
@@ -771,7 +628,7 @@ func (this *DNSTaskExecutor) findDNSManagerWithDomainId(tx *dbs.Tx, domainId int
 		return nil, nil, nil
 	}
 
-	var manager = dnsclients.FindProvider(provider.Type, int64(provider.Id))
+	var manager = dnsclients.FindGuardedProvider(provider.Type, int64(provider.Id), provider.GuardConfig)
 	if manager == nil {
 		this.logErr("DNSTaskExecutor", "unsupported dns provider type '"+provider.Type+"'")
 		return nil, nil, nil
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"encoding/json"
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/goman"
@@ -52,6 +53,12 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 		return nil
 	}
 
+	// 自动续期
+	err := this.renewDueTasks()
+	if err != nil {
+		return err
+	}
+
 	// 查找需要自动更新的证书
 	// 30, 14 ... 是到期的天数
 	for _, days := range []int{30, 14, 7} {
@@ -72,7 +79,11 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 				}
 				if task != nil {
 					if task.AutoRenew == 1 {
-						msg += "此证书是免费申请的证书，且已设置了自动续期，将会在到期前三天自动尝试续期。"
+						if task.NextRenewAt > 0 {
+							msg += "此证书是免费申请的证书，且已设置了自动续期，将会在" + timeutil.FormatTime("Y-m-d H:i", int64(task.NextRenewAt)) + "左右自动尝试续期。"
+						} else {
+							msg += "此证书是免费申请的证书，且已设置了自动续期，将会在到期前自动尝试续期。"
+						}
 					} else {
 						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
 					}
@@ -97,7 +108,7 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 		}
 	}
 
-	// 自动续期
+	// 即将到期
 	for _, days := range []int{3, 2, 1} {
 		certs, err := models.SharedSSLCertDAO.FindAllExpiringCerts(nil, days)
 		if err != nil {
@@ -116,46 +127,7 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 				}
 				if task != nil {
 					if task.AutoRenew == 1 {
-						isOk, errMsg, _ := acme.SharedACMETaskDAO.RunTask(nil, int64(cert.AcmeTaskId))
-						if isOk {
-							// 发送成功通知
-							subject = "系统已成功为你自动更新了证书\"" + cert.Name + "\""
-							msg = "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-							if err != nil {
-								return err
-							}
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						} else {
-							// 发送失败通知
-							subject = "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
-							msg = "系统在尝试自动更新证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）时发生错误：" + errMsg + "。请检查系统设置并修复错误。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-							if err != nil {
-								return err
-							}
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						}
-
-						// 中止不发送消息
-						continue
-
+						msg += "此证书是免费申请的证书，自动续期尚未成功，系统将会继续尝试，也可以手动执行续期任务。"
 					} else {
 						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
 					}
@@ -210,6 +182,91 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 	return nil
 }
 
+// 执行已到续期时间的ACME任务
+func (this *SSLCertExpireCheckExecutor) renewDueTasks() error {
+	// 计算续期时间
+	unscheduledTasks, err := acme.SharedACMETaskDAO.FindAllUnscheduledRenewalTasks(nil)
+	if err != nil {
+		return err
+	}
+	for _, task := range unscheduledTasks {
+		err = acme.SharedACMETaskDAO.ScheduleACMETaskRenewal(nil, int64(task.Id))
+		if err != nil {
+			this.logErr("SSLCertExpireCheckExecutor", "schedule renewal for task '"+types.String(task.Id)+"' failed: "+err.Error())
+		}
+	}
+
+	// 服务商建议的时间段可能会变化（比如证书被批量吊销），需要定期重新获取
+	scheduledTasks, err := acme.SharedACMETaskDAO.FindAllScheduledRenewalTasks(nil)
+	if err != nil {
+		return err
+	}
+	for _, task := range scheduledTasks {
+		if task.RenewFailures > 0 {
+			continue
+		}
+		var window = task.DecodeRenewWindow()
+		if window == nil || window.Source != acmeutils.RenewalSourceARI || time.Now().Unix()-window.CheckedAt < 86400 {
+			continue
+		}
+		err = acme.SharedACMETaskDAO.ScheduleACMETaskRenewal(nil, int64(task.Id))
+		if err != nil {
+			this.logErr("SSLCertExpireCheckExecutor", "schedule renewal for task '"+types.String(task.Id)+"' failed: "+err.Error())
+		}
+	}
+
+	// 执行续期
+	dueTasks, err := acme.SharedACMETaskDAO.FindAllDueRenewalTasks(nil)
+	if err != nil {
+		return err
+	}
+	for _, task := range dueTasks {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(nil, int64(task.CertId))
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			continue
+		}
+
+		isOk, errMsg, _ := acme.SharedACMETaskDAO.RunTask(nil, int64(task.Id))
+		if isOk {
+			// 发送成功通知
+			var subject = "系统已成功为你自动更新了证书\"" + cert.Name + "\""
+			var msg = "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）。"
+			err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, maps.Map{
+				"certId":     cert.Id,
+				"acmeTaskId": task.Id,
+			}.AsJSON())
+			if err != nil {
+				return err
+			}
+		} else {
+			// 推迟下次续期时间
+			failures, err := acme.SharedACMETaskDAO.UpdateACMETaskRenewFailed(nil, int64(task.Id))
+			if err != nil {
+				return err
+			}
+
+			// 只在第一次失败时发送失败通知，避免每次重试都重复通知
+			if failures > 1 {
+				continue
+			}
+			var subject = "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
+			var msg = "系统在尝试自动更新证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）时发生错误：" + errMsg + "。请检查系统设置并修复错误。"
+			err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, maps.Map{
+				"certId":     cert.Id,
+				"acmeTaskId": task.Id,
+			}.AsJSON())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // 对证书中DNS域名的描述
 func (this *SSLCertExpireCheckExecutor) summaryDNSNames(dnsNamesJSON []byte) string {
 	if len(dnsNamesJSON) == 0 {
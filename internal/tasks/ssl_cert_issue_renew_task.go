@@ -0,0 +1,84 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		goman.New(func() {
+			NewSSLCertIssueRenewTask(1 * time.Hour).Start()
+		})
+	})
+}
+
+// SSLCertIssueRenewTask 自动续期CA签发的证书
+type SSLCertIssueRenewTask struct {
+	BaseTask
+
+	ticker *time.Ticker
+}
+
+func NewSSLCertIssueRenewTask(duration time.Duration) *SSLCertIssueRenewTask {
+	return &SSLCertIssueRenewTask{
+		ticker: time.NewTicker(duration),
+	}
+}
+
+func (this *SSLCertIssueRenewTask) Start() {
+	for range this.ticker.C {
+		err := this.Loop()
+		if err != nil {
+			this.logErr("SSLCertIssueRenewTask", err.Error())
+		}
+	}
+}
+
+func (this *SSLCertIssueRenewTask) Loop() error {
+	// 检查是否为主节点
+	if !this.IsPrimaryNode() {
+		return nil
+	}
+
+	var tx *dbs.Tx
+	certs, err := models.SharedSSLCertDAO.FindAllIssuedCertsToRenew(tx)
+	if err != nil {
+		return err
+	}
+	for _, cert := range certs {
+		err = models.SharedSSLCertDAO.RenewIssuedCert(tx, int64(cert.Id))
+		if err == nil {
+			continue
+		}
+
+		this.logErr("SSLCertIssueRenewTask", "renew cert '"+types.String(cert.Id)+"' failed: "+err.Error())
+
+		// 连续失败时只在第一次失败时发送消息
+		var renewErr = err
+		failures, err := models.SharedSSLCertDAO.UpdateIssuedCertRenewFailed(tx, int64(cert.Id))
+		if err != nil {
+			return err
+		}
+		if failures > 1 {
+			continue
+		}
+
+		var subject = "系统在尝试自动续期证书\"" + cert.Name + "\"时发生错误"
+		var msg = "系统在尝试使用CA证书自动续期证书\"" + cert.Name + "\"时发生错误：" + renewErr.Error() + "。请检查CA证书是否可用，系统会稍后重试。"
+		err = models.SharedMessageDAO.CreateMessage(tx, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertRenewFailed, models.MessageLevelError, subject, msg, maps.Map{
+			"certId":       cert.Id,
+			"issuerCertId": cert.IssuerCertId,
+		}.AsJSON())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
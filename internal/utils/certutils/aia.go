@@ -0,0 +1,299 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	maxChainDepth          = 5
+	maxIssuerCertLen       = 64 << 10
+	maxIssuerRedirects     = 3
+	maxCachedIntermediates = 1024
+)
+
+// ErrIssuerNotCached 缓存中没有签发证书，并且不允许下载
+var ErrIssuerNotCached = errors.New("issuer is not cached")
+
+// SharedIntermediateCache 已知的中间证书
+var SharedIntermediateCache = NewIntermediateCache()
+
+// 下载签发证书使用的客户端
+// AIA地址来自用户上传的证书，所以只能访问公网地址，并且不使用代理
+var issuerClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:           dialPublicAddr,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxIssuerRedirects {
+			return errors.New("too many redirects")
+		}
+		return checkIssuerURL(req.URL)
+	},
+}
+
+// 从AIA地址下载签发证书
+var fetchIssuerCert = func(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	err = checkIssuerURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := issuerClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("invalid response status '" + resp.Status + "'")
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxIssuerCertLen))
+}
+
+// 检查AIA地址，只支持HTTP和HTTPS
+func checkIssuerURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("unsupported issuer url scheme '" + u.Scheme + "'")
+	}
+	if len(u.Hostname()) == 0 {
+		return errors.New("invalid issuer url '" + u.String() + "'")
+	}
+	return nil
+}
+
+// 连接地址，解析后的IP必须是公网IP
+func dialPublicAddr(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer = &net.Dialer{Timeout: 5 * time.Second}
+	var lastErr = errors.New("no public address found for '" + host + "'")
+	for _, ip := range ips {
+		if !isPublicIP(ip.IP) {
+			lastErr = errors.New("address '" + ip.String() + "' of '" + host + "' is not allowed")
+			continue
+		}
+
+		// 使用已经检查过的IP连接，防止再次解析时得到不同的结果
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// 判断是否为公网IP
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() {
+		return false
+	}
+
+	// 共享地址 100.64.0.0/10
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// IntermediateCache 中间证书缓存
+type IntermediateCache struct {
+	certs  []*x509.Certificate
+	locker sync.RWMutex
+}
+
+func NewIntermediateCache() *IntermediateCache {
+	return &IntermediateCache{}
+}
+
+// Add 添加中间证书，非CA证书会被忽略
+// 超出最大数量时删除最早添加的证书
+func (this *IntermediateCache) Add(certs ...*x509.Certificate) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	for _, cert := range certs {
+		if !cert.IsCA || isSelfSigned(cert) {
+			continue
+		}
+		var found = false
+		for _, cachedCert := range this.certs {
+			if cachedCert.Equal(cert) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(this.certs) >= maxCachedIntermediates {
+				this.certs = this.certs[1:]
+			}
+			this.certs = append(this.certs, cert)
+		}
+	}
+}
+
+// FindIssuer 查找签发某个证书的中间证书
+func (this *IntermediateCache) FindIssuer(cert *x509.Certificate) *x509.Certificate {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	for _, cachedCert := range this.certs {
+		if isIssuedBy(cert, cachedCert) {
+			return cachedCert
+		}
+	}
+	return nil
+}
+
+// Len 缓存的证书数量
+func (this *IntermediateCache) Len() int {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return len(this.certs)
+}
+
+// CompleteChain 补全证书链
+// 依次从缓存和证书的AIA地址中查找签发证书，返回的证书链不包含根证书
+// allowFetch 为false时只从缓存中查找，缓存中没有时返回 ErrIssuerNotCached
+func CompleteChain(chain []*x509.Certificate, cache *IntermediateCache, allowFetch bool) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty chain")
+	}
+
+	var result = append([]*x509.Certificate{}, chain...)
+
+	// 去掉根证书
+	if len(result) > 1 && isSelfSigned(result[len(result)-1]) {
+		result = result[:len(result)-1]
+	}
+
+	for len(result) < maxChainDepth {
+		var current = result[len(result)-1]
+		if isSelfSigned(current) || isTrustedBySystem(result) {
+			break
+		}
+
+		var issuer = cache.FindIssuer(current)
+		if issuer == nil {
+			if !allowFetch {
+				return nil, ErrIssuerNotCached
+			}
+			var err error
+			issuer, err = fetchIssuer(current)
+			if err != nil {
+				return nil, err
+			}
+			cache.Add(issuer)
+		}
+
+		// 根证书不需要放在证书链中
+		if isSelfSigned(issuer) {
+			break
+		}
+		result = append(result, issuer)
+	}
+
+	return result, nil
+}
+
+// 通过AIA地址下载签发证书
+func fetchIssuer(cert *x509.Certificate) (*x509.Certificate, error) {
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, errors.New("can not find issuer of '" + cert.Subject.String() + "': no AIA issuer url")
+	}
+
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		data, err := fetchIssuerCert(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// 通常为DER格式，也有一些为PEM格式
+		var issuers []*x509.Certificate
+		issuer, err := x509.ParseCertificate(data)
+		if err == nil {
+			issuers = []*x509.Certificate{issuer}
+		} else {
+			issuers, err = ParsePEMCerts(data)
+			if err != nil {
+				lastErr = errors.New("parse issuer from '" + url + "' failed: " + err.Error())
+				continue
+			}
+		}
+
+		for _, issuer := range issuers {
+			if isIssuedBy(cert, issuer) {
+				return issuer, nil
+			}
+		}
+		lastErr = errors.New("certificate from '" + url + "' is not the issuer")
+	}
+	return nil, lastErr
+}
+
+// FixChain 根据检查结果整理证书链
+// 按签发顺序排列，去掉重复、无关的证书和根证书，并尝试补全中间证书
+// 补全失败时返回整理后的证书链和错误信息
+func FixChain(report *ChainReport, cache *IntermediateCache, allowFetch bool) (chain []*x509.Certificate, changed bool, err error) {
+	if len(report.Chain) == 0 {
+		return nil, false, errors.New("empty chain")
+	}
+
+	chain = report.Chain
+	changed = report.HasIssue(IssueCodeWrongOrder) ||
+		report.HasIssue(IssueCodeDuplicateCert) ||
+		report.HasIssue(IssueCodeUnrelatedCert)
+
+	if report.HasIssue(IssueCodeRootIncluded) {
+		chain = chain[:len(chain)-1]
+		changed = true
+	}
+
+	if report.HasIssue(IssueCodeIncompleteChain) {
+		completedChain, completeErr := CompleteChain(chain, cache, allowFetch)
+		if completeErr != nil {
+			err = completeErr
+		} else if len(completedChain) > len(chain) {
+			chain = completedChain
+			changed = true
+		}
+	}
+
+	cache.Add(chain[1:]...)
+	return
+}
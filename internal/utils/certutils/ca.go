@@ -0,0 +1,235 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+type CertUsage = string
+
+const (
+	CertUsageServer CertUsage = "server" // 服务端证书，比如源站证书
+	CertUsageClient CertUsage = "client" // 客户端证书，用于双向认证
+	CertUsageBoth   CertUsage = "both"   // 同时可以作为服务端和客户端证书
+)
+
+const (
+	MaxCADays   = 36500 // CA证书最长有效期
+	MaxLeafDays = 3650  // 签发证书最长有效期
+)
+
+// CAOptions 创建CA选项
+type CAOptions struct {
+	CommonName   string `json:"commonName"`
+	Organization string `json:"organization"`
+	KeyType      string `json:"keyType"`
+	Days         int    `json:"days"`
+}
+
+// Validate 校验选项
+func (this *CAOptions) Validate() error {
+	if len(strings.TrimSpace(this.CommonName)) == 0 {
+		return errors.New("'commonName' should not be empty")
+	}
+	if this.Days <= 0 || this.Days > MaxCADays {
+		return errors.New("invalid days")
+	}
+	return nil
+}
+
+// IssueOptions 签发证书选项，证书续期时使用同样的选项
+type IssueOptions struct {
+	CommonName  string    `json:"commonName"`
+	DNSNames    []string  `json:"dnsNames"`
+	IPAddresses []string  `json:"ipAddresses"`
+	Usage       CertUsage `json:"usage"`
+	KeyType     string    `json:"keyType"`
+	Days        int       `json:"days"`
+	AutoRenew   bool      `json:"autoRenew"`
+}
+
+// Validate 校验选项
+func (this *IssueOptions) Validate() error {
+	if len(strings.TrimSpace(this.CommonName)) == 0 {
+		return errors.New("'commonName' should not be empty")
+	}
+	switch this.Usage {
+	case CertUsageServer, CertUsageBoth:
+		if len(this.DNSNames) == 0 && len(this.IPAddresses) == 0 {
+			return errors.New("server certificate should contain at least one domain or ip")
+		}
+	case CertUsageClient:
+	default:
+		return errors.New("invalid usage '" + this.Usage + "'")
+	}
+	for _, dnsName := range this.DNSNames {
+		if !isValidSANDomain(dnsName) {
+			return errors.New("invalid domain '" + dnsName + "'")
+		}
+	}
+	for _, ip := range this.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			return errors.New("invalid ip '" + ip + "'")
+		}
+	}
+	if this.Days <= 0 || this.Days > MaxLeafDays {
+		return errors.New("invalid days")
+	}
+	return nil
+}
+
+// CreateCA 创建自签名的CA证书
+func CreateCA(options *CAOptions) (certData []byte, keyData []byte, err error) {
+	err = options.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, keyData, err := GeneratePrivateKey(options.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var subject = pkix.Name{CommonName: options.CommonName}
+	if len(options.Organization) > 0 {
+		subject.Organization = []string{options.Organization}
+	}
+	var now = time.Now()
+	var template = &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.AddDate(0, 0, options.Days),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true, // 只能签发终端证书
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyData, nil
+}
+
+// IssueCert 使用CA签发证书
+// 证书有效期不会超过CA证书的有效期
+func IssueCert(caCertData []byte, caKeyData []byte, options *IssueOptions) (certData []byte, keyData []byte, err error) {
+	err = options.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caKeyPair, err := tls.X509KeyPair(caCertData, caKeyData)
+	if err != nil {
+		return nil, nil, errors.New("invalid CA: " + err.Error())
+	}
+	caCert, err := x509.ParseCertificate(caKeyPair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !caCert.IsCA || caCert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, nil, errors.New("the certificate can not be used to issue other certificates")
+	}
+	caKey, ok := caKeyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("unsupported CA private key")
+	}
+
+	var now = time.Now()
+	if now.After(caCert.NotAfter) {
+		return nil, nil, errors.New("the CA certificate has expired")
+	}
+	var notAfter = now.AddDate(0, 0, options.Days)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	key, keyData, err := GeneratePrivateKey(options.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var template = &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: options.CommonName},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		DNSNames:              options.DNSNames,
+	}
+	for _, ip := range options.IPAddresses {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+	}
+	if _, isRSA := key.(*rsa.PrivateKey); isRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	switch options.Usage {
+	case CertUsageServer:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	case CertUsageClient:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	case CertUsageBoth:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyData, nil
+}
+
+// GeneratePrivateKey 生成私钥，keyType 和ACME中的密钥类型一致，默认为RSA2048
+func GeneratePrivateKey(keyType string) (key crypto.Signer, keyData []byte, err error) {
+	switch keyType {
+	case "", "RSA2048":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "RSA3072":
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	case "RSA4096":
+		key, err = rsa.GenerateKey(rand.Reader, 4096)
+	case "EC256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EC384":
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, nil, errors.New("invalid key type '" + keyType + "'")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestIssueCert(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	caCertData, caKeyData, err := CreateCA(&CAOptions{
+		CommonName:   "Test Internal CA",
+		Organization: "GoEdge",
+		KeyType:      "EC256",
+		Days:         30,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certData, keyData, err := IssueCert(caCertData, caKeyData, &IssueOptions{
+		CommonName:  "origin.example.com",
+		DNSNames:    []string{"origin.example.com"},
+		IPAddresses: []string{"10.0.0.1"},
+		Usage:       CertUsageServer,
+		Days:        365,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tls.X509KeyPair(certData, keyData)
+	a.IsNil(err)
+
+	caCerts, err := ParsePEMCerts(caCertData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := ParsePEMCerts(certData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 有效期不超过CA
+	a.IsTrue(!certs[0].NotAfter.After(caCerts[0].NotAfter))
+
+	var roots = x509.NewCertPool()
+	roots.AddCert(caCerts[0])
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		DNSName:     "origin.example.com",
+		Roots:       roots,
+		CurrentTime: time.Now(),
+	})
+	a.IsNil(err)
+
+	// 客户端证书不能用于服务端
+	clientCertData, _, err := IssueCert(caCertData, caKeyData, &IssueOptions{
+		CommonName: "client-1",
+		Usage:      CertUsageClient,
+		Days:       30,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCerts, err := ParsePEMCerts(clientCertData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clientCerts[0].Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	a.IsTrue(err != nil)
+	_, err = clientCerts[0].Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	a.IsNil(err)
+
+	// 不能使用终端证书签发
+	_, _, err = IssueCert(certData, keyData, &IssueOptions{
+		CommonName: "client-2",
+		Usage:      CertUsageClient,
+		Days:       30,
+	})
+	a.IsNotNil(err)
+}
+
+func TestIssueOptions_Validate(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsNotNil((&IssueOptions{CommonName: "a", Usage: CertUsageServer, Days: 30}).Validate())
+	a.IsNotNil((&IssueOptions{CommonName: "a", Usage: "unknown", Days: 30}).Validate())
+	a.IsNotNil((&IssueOptions{CommonName: "a", Usage: CertUsageClient, Days: 0}).Validate())
+	a.IsNotNil((&IssueOptions{CommonName: "a", Usage: CertUsageServer, DNSNames: []string{"a.*.com"}, Days: 30}).Validate())
+	a.IsNil((&IssueOptions{CommonName: "a", Usage: CertUsageServer, IPAddresses: []string{"127.0.0.1"}, Days: 30}).Validate())
+}
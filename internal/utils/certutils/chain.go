@@ -0,0 +1,347 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/domainutils"
+	"net"
+	"strings"
+	"time"
+)
+
+type IssueLevel = string
+
+const (
+	IssueLevelError   IssueLevel = "error"   // 会导致握手失败
+	IssueLevelWarning IssueLevel = "warning" // 可以使用，但不是最优
+)
+
+type IssueCode = string
+
+const (
+	IssueCodeInvalidPEM        IssueCode = "invalidPEM"
+	IssueCodeKeyMismatch       IssueCode = "keyMismatch"
+	IssueCodeExpired           IssueCode = "expired"
+	IssueCodeNotYetValid       IssueCode = "notYetValid"
+	IssueCodeExpiringSoon      IssueCode = "expiringSoon"
+	IssueCodeWrongOrder        IssueCode = "wrongOrder"
+	IssueCodeIncompleteChain   IssueCode = "incompleteChain"
+	IssueCodeUnrelatedCert     IssueCode = "unrelatedCert"
+	IssueCodeDuplicateCert     IssueCode = "duplicateCert"
+	IssueCodeRootIncluded      IssueCode = "rootIncluded"
+	IssueCodeNoSAN             IssueCode = "noSAN"
+	IssueCodeInvalidSAN        IssueCode = "invalidSAN"
+	IssueCodeCommonNameMissing IssueCode = "commonNameMissing"
+)
+
+const expiringSoonDays = 7
+
+// Issue 证书链中的问题
+type Issue struct {
+	Code    IssueCode  `json:"code"`
+	Level   IssueLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// ChainReport 证书链检查结果
+type ChainReport struct {
+	Issues []*Issue `json:"issues"`
+
+	Leaf  *x509.Certificate   `json:"-"` // 站点证书
+	Chain []*x509.Certificate `json:"-"` // 按签发顺序排列后的证书链，包含站点证书
+}
+
+func (this *ChainReport) addIssue(code IssueCode, level IssueLevel, message string) {
+	this.Issues = append(this.Issues, &Issue{
+		Code:    code,
+		Level:   level,
+		Message: message,
+	})
+}
+
+// HasErrors 是否有会导致握手失败的问题
+func (this *ChainReport) HasErrors() bool {
+	for _, issue := range this.Issues {
+		if issue.Level == IssueLevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIssue 是否有某个问题
+func (this *ChainReport) HasIssue(code IssueCode) bool {
+	for _, issue := range this.Issues {
+		if issue.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstError 第一个错误
+func (this *ChainReport) FirstError() error {
+	for _, issue := range this.Issues {
+		if issue.Level == IssueLevelError {
+			return errors.New(issue.Message)
+		}
+	}
+	return nil
+}
+
+// ParsePEMCerts 解析PEM格式的证书列表，忽略非证书内容
+func ParsePEMCerts(data []byte) ([]*x509.Certificate, error) {
+	var result = []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cert)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return result, nil
+}
+
+// EncodePEMCerts 将证书列表编码为PEM格式
+func EncodePEMCerts(certs []*x509.Certificate) []byte {
+	var buf = &bytes.Buffer{}
+	for _, cert := range certs {
+		_ = pem.Encode(buf, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})
+	}
+	return buf.Bytes()
+}
+
+// CheckChain 检查证书和私钥、证书链、有效期以及域名
+// keyData 为空时不检查私钥
+func CheckChain(certData []byte, keyData []byte) *ChainReport {
+	var report = &ChainReport{}
+
+	certs, err := ParsePEMCerts(certData)
+	if err != nil {
+		report.addIssue(IssueCodeInvalidPEM, IssueLevelError, "无法解析证书内容："+err.Error())
+		return report
+	}
+
+	// 私钥
+	if len(keyData) > 0 {
+		_, err = tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			report.addIssue(IssueCodeKeyMismatch, IssueLevelError, "证书和私钥不匹配："+err.Error())
+		}
+	}
+
+	// 证书链顺序
+	var chain, unrelated = SortChain(certs)
+	report.Leaf = chain[0]
+	report.Chain = chain
+	if len(unrelated) > 0 {
+		report.addIssue(IssueCodeUnrelatedCert, IssueLevelWarning, "证书链中包含和站点证书无关的证书："+unrelated[0].Subject.String())
+	}
+	if len(certs)-len(unrelated) > len(chain) {
+		report.addIssue(IssueCodeDuplicateCert, IssueLevelWarning, "证书链中包含重复的证书")
+	}
+	if !sameOrder(certs, chain) {
+		report.addIssue(IssueCodeWrongOrder, IssueLevelWarning, "证书链顺序不正确，站点证书应该在最前面，之后依次是签发它的中间证书")
+	}
+
+	// 证书链是否完整
+	var last = chain[len(chain)-1]
+	if isSelfSigned(last) {
+		if len(chain) > 1 {
+			report.addIssue(IssueCodeRootIncluded, IssueLevelWarning, "证书链中包含了根证书，可以删除以减少握手数据")
+		}
+	} else if !isTrustedBySystem(chain) {
+		report.addIssue(IssueCodeIncompleteChain, IssueLevelWarning, "证书链不完整，缺少签发'"+last.Issuer.String()+"'的中间证书，部分客户端可能无法握手")
+	}
+
+	// 有效期
+	var now = time.Now()
+	for _, cert := range chain {
+		if now.After(cert.NotAfter) {
+			report.addIssue(IssueCodeExpired, IssueLevelError, "证书'"+cert.Subject.String()+"'已于"+cert.NotAfter.Format("2006-01-02")+"过期")
+		} else if now.Before(cert.NotBefore) {
+			report.addIssue(IssueCodeNotYetValid, IssueLevelError, "证书'"+cert.Subject.String()+"'要到"+cert.NotBefore.Format("2006-01-02")+"才生效")
+		} else if cert == report.Leaf && cert.NotAfter.Sub(now) < expiringSoonDays*24*time.Hour {
+			report.addIssue(IssueCodeExpiringSoon, IssueLevelWarning, "证书将于"+cert.NotAfter.Format("2006-01-02")+"过期")
+		}
+	}
+
+	// 域名
+	checkSAN(report, report.Leaf)
+
+	return report
+}
+
+// SortChain 将证书按签发顺序排列
+// 第一个证书为站点证书，不在证书链上的证书放在unrelated中
+func SortChain(certs []*x509.Certificate) (chain []*x509.Certificate, unrelated []*x509.Certificate) {
+	if len(certs) == 0 {
+		return nil, nil
+	}
+
+	// 去重
+	var uniqueCerts = []*x509.Certificate{}
+	for _, cert := range certs {
+		var found = false
+		for _, uniqueCert := range uniqueCerts {
+			if cert.Equal(uniqueCert) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			uniqueCerts = append(uniqueCerts, cert)
+		}
+	}
+
+	// 站点证书：没有签发其他证书的非CA证书，通常是第一个
+	var leaf = uniqueCerts[0]
+	if leaf.IsCA {
+		for _, cert := range uniqueCerts {
+			if !cert.IsCA {
+				leaf = cert
+				break
+			}
+		}
+	}
+
+	chain = []*x509.Certificate{leaf}
+	var used = map[*x509.Certificate]bool{leaf: true}
+	for {
+		var current = chain[len(chain)-1]
+		if isSelfSigned(current) {
+			break
+		}
+		var parent *x509.Certificate
+		for _, cert := range uniqueCerts {
+			if !used[cert] && isIssuedBy(current, cert) {
+				parent = cert
+				break
+			}
+		}
+		if parent == nil {
+			break
+		}
+		used[parent] = true
+		chain = append(chain, parent)
+	}
+
+	for _, cert := range uniqueCerts {
+		if !used[cert] {
+			unrelated = append(unrelated, cert)
+		}
+	}
+	return
+}
+
+// 检查站点证书中的域名
+func checkSAN(report *ChainReport, leaf *x509.Certificate) {
+	if len(leaf.DNSNames) == 0 && len(leaf.IPAddresses) == 0 {
+		report.addIssue(IssueCodeNoSAN, IssueLevelError, "证书中没有包含任何域名（SAN），现代浏览器会拒绝此证书")
+		return
+	}
+
+	for _, dnsName := range leaf.DNSNames {
+		if !isValidSANDomain(dnsName) {
+			report.addIssue(IssueCodeInvalidSAN, IssueLevelError, "证书中的域名'"+dnsName+"'格式不正确")
+		}
+	}
+
+	var commonName = leaf.Subject.CommonName
+	if len(commonName) > 0 && !containsName(leaf, commonName) {
+		report.addIssue(IssueCodeCommonNameMissing, IssueLevelWarning, "证书的通用名称'"+commonName+"'没有包含在域名列表（SAN）中")
+	}
+}
+
+// 检查SAN中的域名，通配符只能出现在最左侧
+func isValidSANDomain(dnsName string) bool {
+	if len(dnsName) == 0 || len(dnsName) > 253 {
+		return false
+	}
+	var domain = strings.TrimSuffix(dnsName, ".")
+	if strings.HasPrefix(domain, "*.") {
+		domain = domain[2:]
+	}
+	if strings.Contains(domain, "*") || !strings.Contains(domain, ".") {
+		return false
+	}
+	return domainutils.ValidateDomainFormat(domain)
+}
+
+func containsName(cert *x509.Certificate, name string) bool {
+	var ip = net.ParseIP(name)
+	if ip != nil {
+		for _, certIP := range cert.IPAddresses {
+			if certIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, dnsName := range cert.DNSNames {
+		if strings.EqualFold(dnsName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func isIssuedBy(cert *x509.Certificate, issuer *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuer.RawSubject) && cert.CheckSignatureFrom(issuer) == nil
+}
+
+// 证书链最后一个证书是否由系统信任的根证书签发
+func isTrustedBySystem(chain []*x509.Certificate) bool {
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		return false
+	}
+	var intermediates = x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// 这里只关心签发关系，忽略有效期和用途
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   chain[0].NotBefore.Add(time.Second),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+func sameOrder(certs []*x509.Certificate, chain []*x509.Certificate) bool {
+	if len(certs) < len(chain) {
+		return false
+	}
+	for index, cert := range chain {
+		if !certs[index].Equal(cert) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/iwind/TeaGo/assert"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, isCA bool, parent *testCert, dnsNames []string, aiaURL string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(90 * 24 * time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if len(aiaURL) > 0 {
+		template.IssuingCertificateURL = []string{aiaURL}
+	}
+
+	var parentCert = template
+	var parentKey = key
+	if parent != nil {
+		parentCert = parent.cert
+		parentKey = parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func (this *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(this.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func newTestChain(t *testing.T) (root *testCert, intermediate *testCert, leaf *testCert) {
+	root = newTestCert(t, "Test Root", true, nil, nil, "")
+	intermediate = newTestCert(t, "Test Intermediate", true, root, nil, "http://ca.example.com/root.der")
+	leaf = newTestCert(t, "example.com", false, intermediate, []string{"example.com", "*.example.com"}, "http://ca.example.com/intermediate.der")
+	return
+}
+
+func TestCheckChain(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	root, intermediate, leaf := newTestChain(t)
+
+	// 完整
+	{
+		var report = CheckChain(EncodePEMCerts([]*x509.Certificate{leaf.cert, intermediate.cert}), leaf.keyPEM(t))
+		a.IsFalse(report.HasErrors())
+		a.IsFalse(report.HasIssue(IssueCodeWrongOrder))
+		a.IsTrue(len(report.Chain) == 2)
+	}
+
+	// 顺序错误且包含根证书
+	{
+		var report = CheckChain(EncodePEMCerts([]*x509.Certificate{intermediate.cert, root.cert, leaf.cert}), nil)
+		a.IsFalse(report.HasErrors())
+		a.IsTrue(report.HasIssue(IssueCodeWrongOrder))
+		a.IsTrue(report.HasIssue(IssueCodeRootIncluded))
+		a.IsTrue(report.Leaf.Equal(leaf.cert))
+		a.IsTrue(len(report.Chain) == 3)
+	}
+
+	// 缺少中间证书
+	{
+		var report = CheckChain(EncodePEMCerts([]*x509.Certificate{leaf.cert}), nil)
+		a.IsTrue(report.HasIssue(IssueCodeIncompleteChain))
+	}
+
+	// 私钥不匹配
+	{
+		var report = CheckChain(EncodePEMCerts([]*x509.Certificate{leaf.cert}), intermediate.keyPEM(t))
+		a.IsTrue(report.HasIssue(IssueCodeKeyMismatch))
+		a.IsTrue(report.HasErrors())
+		a.IsNotNil(report.FirstError())
+	}
+
+	// 没有域名
+	{
+		var noSANLeaf = newTestCert(t, "example.com", false, intermediate, nil, "")
+		var report = CheckChain(EncodePEMCerts([]*x509.Certificate{noSANLeaf.cert, intermediate.cert}), nil)
+		a.IsTrue(report.HasIssue(IssueCodeNoSAN))
+	}
+
+	// 无效内容
+	{
+		var report = CheckChain([]byte("hello"), nil)
+		a.IsTrue(report.HasIssue(IssueCodeInvalidPEM))
+	}
+}
+
+func TestIsValidSANDomain(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(isValidSANDomain("example.com"))
+	a.IsTrue(isValidSANDomain("*.example.com"))
+	a.IsFalse(isValidSANDomain("a.*.example.com"))
+	a.IsFalse(isValidSANDomain("*"))
+	a.IsFalse(isValidSANDomain("exa mple.com"))
+}
+
+func TestCompleteChain(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	root, intermediate, leaf := newTestChain(t)
+
+	var fetchedURLs = []string{}
+	var oldFetch = fetchIssuerCert
+	fetchIssuerCert = func(url string) ([]byte, error) {
+		fetchedURLs = append(fetchedURLs, url)
+		switch url {
+		case "http://ca.example.com/intermediate.der":
+			return intermediate.cert.Raw, nil
+		case "http://ca.example.com/root.der":
+			return root.cert.Raw, nil
+		}
+		return nil, errors.New("not found")
+	}
+	defer func() {
+		fetchIssuerCert = oldFetch
+	}()
+
+	var cache = NewIntermediateCache()
+	chain, err := CompleteChain([]*x509.Certificate{leaf.cert}, cache, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(len(chain) == 2)
+	a.IsTrue(chain[1].Equal(intermediate.cert))
+	a.IsTrue(len(fetchedURLs) == 2)
+	a.IsTrue(cache.Len() == 1)
+
+	// 使用缓存
+	fetchedURLs = nil
+	_, err = CompleteChain([]*x509.Certificate{leaf.cert}, cache, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(len(fetchedURLs) == 1) // 只需要下载根证书
+}
+
+func TestFixChain(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	root, intermediate, leaf := newTestChain(t)
+
+	var cache = NewIntermediateCache()
+	var report = CheckChain(EncodePEMCerts([]*x509.Certificate{root.cert, intermediate.cert, leaf.cert, intermediate.cert}), nil)
+	chain, changed, err := FixChain(report, cache, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(changed)
+	a.IsTrue(len(chain) == 2)
+	a.IsTrue(chain[0].Equal(leaf.cert))
+	a.IsTrue(chain[1].Equal(intermediate.cert))
+	a.IsTrue(cache.Len() == 1)
+
+	// 已经是最优，测试CA不在系统信任列表中，需要下载根证书后才能确认
+	var oldFetch = fetchIssuerCert
+	fetchIssuerCert = func(url string) ([]byte, error) {
+		return root.cert.Raw, nil
+	}
+	defer func() {
+		fetchIssuerCert = oldFetch
+	}()
+	report = CheckChain(EncodePEMCerts(chain), nil)
+	_, changed, err = FixChain(report, cache, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsFalse(changed)
+}
+
+func TestCompleteChain_NotCached(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	_, _, leaf := newTestChain(t)
+
+	var oldFetch = fetchIssuerCert
+	fetchIssuerCert = func(url string) ([]byte, error) {
+		t.Fatal("should not fetch")
+		return nil, nil
+	}
+	defer func() {
+		fetchIssuerCert = oldFetch
+	}()
+
+	_, err := CompleteChain([]*x509.Certificate{leaf.cert}, NewIntermediateCache(), false)
+	a.IsTrue(err == ErrIssuerNotCached)
+}
+
+func TestFetchIssuerCert_Restricted(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	for _, url := range []string{
+		"file:///etc/passwd",
+		"ftp://ca.example.com/ca.der",
+		"http://127.0.0.1/ca.der",
+		"http://[::1]/ca.der",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/ca.der",
+	} {
+		_, err := fetchIssuerCert(url)
+		a.IsTrue(err != nil)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(isPublicIP(net.ParseIP("8.8.8.8")))
+	a.IsTrue(isPublicIP(net.ParseIP("2001:4860:4860::8888")))
+	a.IsFalse(isPublicIP(net.ParseIP("127.0.0.1")))
+	a.IsFalse(isPublicIP(net.ParseIP("192.168.1.1")))
+	a.IsFalse(isPublicIP(net.ParseIP("172.16.0.1")))
+	a.IsFalse(isPublicIP(net.ParseIP("169.254.169.254")))
+	a.IsFalse(isPublicIP(net.ParseIP("100.64.0.1")))
+	a.IsFalse(isPublicIP(net.ParseIP("0.0.0.0")))
+	a.IsFalse(isPublicIP(net.ParseIP("fe80::1")))
+	a.IsFalse(isPublicIP(net.ParseIP("fd00::1")))
+}
+
+func TestIntermediateCache_Max(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	root, _, _ := newTestChain(t)
+
+	var cache = NewIntermediateCache()
+	var first = newTestCert(t, "Intermediate 0", true, root, nil, "")
+	cache.Add(first.cert)
+	for i := 1; i <= maxCachedIntermediates; i++ {
+		cache.Add(newTestCert(t, "Intermediate", true, root, nil, "").cert)
+	}
+	a.IsTrue(cache.Len() == maxCachedIntermediates)
+
+	var leaf = newTestCert(t, "example.com", false, first, []string{"example.com"}, "")
+	a.IsNil(cache.FindIssuer(leaf.cert))
+}
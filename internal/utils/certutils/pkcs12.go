@@ -0,0 +1,53 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// DecodePKCS12 解析PKCS#12（PFX）文件
+// 返回PEM格式的证书链（站点证书在最前面）和私钥
+func DecodePKCS12(pfxData []byte, password string) (certData []byte, keyData []byte, err error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(pfxData, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, nil, errors.New("incorrect password")
+		}
+		return nil, nil, errors.New("decode pkcs12 failed: " + err.Error())
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 文件中的证书顺序不一定是证书链的顺序
+	chain, unrelated := SortChain(append([]*x509.Certificate{cert}, caCerts...))
+	certData = EncodePEMCerts(append(chain, unrelated...))
+	keyData = pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyDER,
+	})
+	return
+}
+
+// EncodePKCS12 将PEM格式的证书链和私钥编码为PKCS#12（PFX）格式
+// 使用AES-256-CBC（PBES2）加密和HMAC-SHA256校验
+func EncodePKCS12(certData []byte, keyData []byte, password string) ([]byte, error) {
+	keyPair, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := ParsePEMCerts(certData)
+	if err != nil {
+		return nil, err
+	}
+	chain, _ := SortChain(certs)
+
+	return pkcs12.Modern.Encode(keyPair.PrivateKey, chain[0], chain[1:], password)
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package certutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/iwind/TeaGo/assert"
+	"strings"
+	"testing"
+)
+
+func TestPKCS12(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	_, intermediate, leaf := newTestChain(t)
+
+	var certData = EncodePEMCerts([]*x509.Certificate{leaf.cert, intermediate.cert})
+	for _, password := range []string{"123456", "", "中文密码"} {
+		pfxData, err := EncodePKCS12(certData, leaf.keyPEM(t), password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decodedCertData, decodedKeyData, err := DecodePKCS12(pfxData, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs, err := ParsePEMCerts(decodedCertData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.IsTrue(len(certs) == 2)
+		a.IsTrue(certs[0].Equal(leaf.cert))
+		a.IsTrue(certs[1].Equal(intermediate.cert))
+
+		_, err = tls.X509KeyPair(decodedCertData, decodedKeyData)
+		a.IsNil(err)
+
+		_, _, err = DecodePKCS12(pfxData, password+"wrong")
+		a.IsNotNil(err)
+	}
+}
+
+func TestPKCS12_KeyMismatch(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	_, intermediate, leaf := newTestChain(t)
+
+	_, err := EncodePKCS12(EncodePEMCerts([]*x509.Certificate{leaf.cert}), intermediate.keyPEM(t), "123456")
+	a.IsNotNil(err)
+}
+
+func TestPKCS12_PBES2(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	// openssl pkcs12 -export 生成，使用AES-256-CBC和HMAC-SHA256
+	pfxData, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(testPBES2PFX, "\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certData, keyData, err := DecodePKCS12(pfxData, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tls.X509KeyPair(certData, keyData)
+	a.IsNil(err)
+
+	certs, err := ParsePEMCerts(certData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(certs[0].Subject.CommonName == "example.com")
+
+	_, _, err = DecodePKCS12(pfxData, "654321")
+	a.IsNotNil(err)
+}
+
+const testPBES2PFX = `MIIELAIBAzCCA+IGCSqGSIb3DQEHAaCCA9MEggPPMIIDyzCCAoIGCSqGSIb3DQEHBqCCAnMwggJvAgEAMIICaAYJKoZIhvcNAQcB
+MFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAjxmhmApysBUgICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEEBY
+adtvyRe++JM+bzlcmeCAggIAFnS2Pm6BKZQYarhbdmx+vSUEY3KFoLA+stGdJQTj1xwK8aRhJjxlXZl4egRibHj8P91icowRy4qX
+E+sClYBhRVb7Nq6hQSxKbGTwIBfiaFm1NvmqkzQB7RwZI159ELiVnkY1alEmBlxb/dTjdswPWpBJqNKjgrNEQpzWDkJWFcMczA7P
+K0g63khWSOi94LvvSVmglzKj7JMKj+5/UrIH4nNOvw7ABLrjnoOdQJ302kn06ql0OurdWhxpZDR+1+G6rb5Od5Hgu5PFFmxUozFN
+mbRF5lWdiQOB7i3Gz3ix89+ssz2HVDj3QF5J9YIjSImvcL3WibEgX+XpuDRxe3ptitNPuec+r73nW51AjDO29g6SXerNIGd0lChU
+rPhSyDHs1lYiolTYWHyevjUejP1w8Dhwx51LrNQ68unW2tQ4KknnauHmhb/PtXJrI0gdH/rIccWGjXZqKiy5cSuk3tP1BwBUtDZZ
+Dx4RpyvJpb9flsJSNsSdKCQjkp8X4dDeUSkbwGllnArvNofm5hUnbgbGs+S1+K5VHaM864m5NMg2iCBLKBv/JPBxlB1xhNCYJEzH
+T32rT3rnWmXgpJJAc50gzw5LRBrmCEu2hsFvp6HX7QO+avSzKf/PRLUtPEWyFAOlCWSETCdeJSolqG8EfQtsY1dkllq/HQGbzA9R
+UkLRsW0wggFBBgkqhkiG9w0BBwGgggEyBIIBLjCCASowggEmBgsqhkiG9w0BDAoBAqCB7zCB7DBXBgkqhkiG9w0BBQ0wSjApBgkq
+hkiG9w0BBQwwHAQIRfKTX3CP0xICAggAMAwGCCqGSIb3DQIJBQAwHQYJYIZIAWUDBAEqBBCcvGWCFikT0vXM4l2f6FiRBIGQ0WyA
+d2P+NgtMTPdTxDvbYeNG0cqMxBJmNFye9RNrrvgSX/ioaLwXeAGRabdeEpUijanzNdaAGzupqczCZxNZ9XeCm0o6x+rCISapFgCp
+xHpgkrA/gsl4kaynL+xAtVFXsKwOPYry40R1zMYBTUbnXFwLFPWk4XIUu77r7P+s1bpBcGmfKzPBV+kuGXPKSxOjMSUwIwYJKoZI
+hvcNAQkVMRYEFJq4yendGM5Rhyi6KRd1ALt89db0MEEwMTANBglghkgBZQMEAgEFAAQgCuuojo4rD2iTKCpmQiPeiDvN7YCfBmro
+81TlzG2YPocECCguHFfFaFa+AgIIAA==`
@@ -0,0 +1,135 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package loginutils
+
+// Policy 登录失败防护策略
+type Policy struct {
+	IsOn               bool `yaml:"isOn" json:"isOn"`                             // 是否启用
+	Window             int  `yaml:"window" json:"window"`                         // 统计失败次数的时间范围（秒）
+	MaxAccountFailures int  `yaml:"maxAccountFailures" json:"maxAccountFailures"` // 单个账号最多失败次数，超出后锁定账号
+	MaxIPFailures      int  `yaml:"maxIPFailures" json:"maxIPFailures"`           // 单个IP最多失败次数，超出后锁定IP
+	LockDuration       int  `yaml:"lockDuration" json:"lockDuration"`             // 锁定时长（秒）
+	CaptchaFailures    int  `yaml:"captchaFailures" json:"captchaFailures"`       // 失败多少次后需要输入验证码，0表示不需要
+	BaseDelay          int  `yaml:"baseDelay" json:"baseDelay"`                   // 失败后再次尝试的初始等待时间（秒），每次失败后翻倍
+	MaxDelay           int  `yaml:"maxDelay" json:"maxDelay"`                     // 最长等待时间（秒）
+}
+
+// DefaultPolicy 默认的登录失败防护策略
+func DefaultPolicy() *Policy {
+	return &Policy{
+		IsOn:               true,
+		Window:             900,
+		MaxAccountFailures: 10,
+		MaxIPFailures:      30,
+		LockDuration:       900,
+		CaptchaFailures:    3,
+		BaseDelay:          1,
+		MaxDelay:           30,
+	}
+}
+
+// Init 初始化
+func (this *Policy) Init() error {
+	if this.Window <= 0 {
+		this.Window = 900
+	}
+	if this.MaxAccountFailures < 0 {
+		this.MaxAccountFailures = 0
+	}
+	if this.MaxIPFailures < 0 {
+		this.MaxIPFailures = 0
+	}
+	if this.LockDuration <= 0 {
+		this.LockDuration = 900
+	}
+	if this.CaptchaFailures < 0 {
+		this.CaptchaFailures = 0
+	}
+	if this.BaseDelay < 0 {
+		this.BaseDelay = 0
+	}
+	if this.MaxDelay < this.BaseDelay {
+		this.MaxDelay = this.BaseDelay
+	}
+	return nil
+}
+
+// Delay 计算失败若干次后需要等待的时间（秒）
+func (this *Policy) Delay(failures int) int {
+	if failures <= 0 || this.BaseDelay <= 0 {
+		return 0
+	}
+	var delay = this.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= this.MaxDelay {
+			return this.MaxDelay
+		}
+	}
+	return delay
+}
+
+// Evaluate 根据失败记录判断是否允许登录
+func (this *Policy) Evaluate(stat *FailureStat, now int64) *Decision {
+	var decision = &Decision{}
+	if !this.IsOn || stat == nil {
+		return decision
+	}
+
+	// 锁定
+	if this.MaxAccountFailures > 0 && stat.AccountFailures >= this.MaxAccountFailures {
+		decision.lock(stat.LastAccountFailedAt+int64(this.LockDuration), now)
+	}
+	if this.MaxIPFailures > 0 && stat.IPFailures >= this.MaxIPFailures {
+		decision.lock(stat.LastIPFailedAt+int64(this.LockDuration), now)
+	}
+	if decision.IsLocked {
+		return decision
+	}
+
+	// 递增等待时间
+	var delay = this.Delay(stat.AccountFailures)
+	if delay > 0 {
+		var retryAfter = stat.LastAccountFailedAt + int64(delay) - now
+		if retryAfter > 0 {
+			decision.RetryAfter = retryAfter
+		}
+	}
+
+	// 验证码
+	if this.CaptchaFailures > 0 && (stat.AccountFailures >= this.CaptchaFailures || stat.IPFailures >= this.CaptchaFailures) {
+		decision.RequireCaptcha = true
+	}
+
+	return decision
+}
+
+// FailureStat 登录失败统计
+type FailureStat struct {
+	AccountFailures     int   // 账号失败次数
+	LastAccountFailedAt int64 // 账号最后一次失败时间
+	IPFailures          int   // IP失败次数
+	LastIPFailedAt      int64 // IP最后一次失败时间
+}
+
+// Decision 登录检查结果
+type Decision struct {
+	IsLocked       bool  // 是否已锁定
+	RetryAfter     int64 // 需要等待多少秒后才能再次尝试
+	RequireCaptcha bool  // 是否需要输入验证码
+}
+
+// IsAllowed 是否允许尝试登录
+func (this *Decision) IsAllowed() bool {
+	return !this.IsLocked && this.RetryAfter <= 0
+}
+
+func (this *Decision) lock(until int64, now int64) {
+	if until <= now {
+		return
+	}
+	this.IsLocked = true
+	if until-now > this.RetryAfter {
+		this.RetryAfter = until - now
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package loginutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = loginutils.DefaultPolicy()
+	a.IsTrue(policy.Delay(0) == 0)
+	a.IsTrue(policy.Delay(1) == 1)
+	a.IsTrue(policy.Delay(2) == 2)
+	a.IsTrue(policy.Delay(4) == 8)
+	a.IsTrue(policy.Delay(6) == 30)
+	a.IsTrue(policy.Delay(100) == 30)
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = loginutils.DefaultPolicy()
+	a.IsNil(policy.Init())
+	var now int64 = 1_700_000_000
+
+	// 没有失败记录
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{}, now)
+		a.IsTrue(decision.IsAllowed())
+		a.IsFalse(decision.RequireCaptcha)
+	}
+
+	// 递增等待
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{AccountFailures: 4, LastAccountFailedAt: now - 3}, now)
+		a.IsFalse(decision.IsAllowed())
+		a.IsFalse(decision.IsLocked)
+		a.IsTrue(decision.RetryAfter == 5)
+		a.IsTrue(decision.RequireCaptcha)
+	}
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{AccountFailures: 2, LastAccountFailedAt: now - 10}, now)
+		a.IsTrue(decision.IsAllowed())
+		a.IsFalse(decision.RequireCaptcha)
+	}
+
+	// 锁定账号
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{AccountFailures: 10, LastAccountFailedAt: now - 100}, now)
+		a.IsTrue(decision.IsLocked)
+		a.IsTrue(decision.RetryAfter == 800)
+	}
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{AccountFailures: 10, LastAccountFailedAt: now - 1000}, now)
+		a.IsFalse(decision.IsLocked)
+	}
+
+	// 锁定IP
+	{
+		var decision = policy.Evaluate(&loginutils.FailureStat{IPFailures: 30, LastIPFailedAt: now - 10}, now)
+		a.IsTrue(decision.IsLocked)
+		a.IsTrue(decision.RetryAfter == 890)
+	}
+
+	// 关闭
+	{
+		policy.IsOn = false
+		var decision = policy.Evaluate(&loginutils.FailureStat{AccountFailures: 100, LastAccountFailedAt: now}, now)
+		a.IsTrue(decision.IsAllowed())
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package loginutils
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// TwoFactorPolicy 双因素认证策略
+type TwoFactorPolicy struct {
+	RequireForAdmins        bool            `yaml:"requireForAdmins" json:"requireForAdmins"`               // 所有管理员都需要启用双因素认证
+	RequireForUsers         bool            `yaml:"requireForUsers" json:"requireForUsers"`                 // 所有平台用户都需要启用双因素认证
+	RequireUserVerification bool            `yaml:"requireUserVerification" json:"requireUserVerification"` // WebAuthn是否需要验证用户（PIN、指纹等）
+	AdminWebAuthn           *WebAuthnConfig `yaml:"adminWebAuthn" json:"adminWebAuthn"`                     // 管理系统的WebAuthn依赖方
+	UserWebAuthn            *WebAuthnConfig `yaml:"userWebAuthn" json:"userWebAuthn"`                       // 用户系统的WebAuthn依赖方
+}
+
+// WebAuthnConfig WebAuthn依赖方配置
+// 依赖方ID和来源只能由管理员设置，不能使用客户端提交的数据
+type WebAuthnConfig struct {
+	RPId    string   `yaml:"rpId" json:"rpId"`       // 依赖方ID，通常为域名，比如 example.com
+	RPName  string   `yaml:"rpName" json:"rpName"`   // 依赖方名称，为空时使用依赖方ID
+	Origins []string `yaml:"origins" json:"origins"` // 允许的来源，比如 https://admin.example.com
+}
+
+// DefaultTwoFactorPolicy 默认的双因素认证策略
+func DefaultTwoFactorPolicy() *TwoFactorPolicy {
+	return &TwoFactorPolicy{
+		AdminWebAuthn: &WebAuthnConfig{},
+		UserWebAuthn:  &WebAuthnConfig{},
+	}
+}
+
+// Init 初始化
+func (this *TwoFactorPolicy) Init() error {
+	if this.AdminWebAuthn == nil {
+		this.AdminWebAuthn = &WebAuthnConfig{}
+	}
+	if this.UserWebAuthn == nil {
+		this.UserWebAuthn = &WebAuthnConfig{}
+	}
+
+	err := this.AdminWebAuthn.Init()
+	if err != nil {
+		return errors.New("admin webAuthn: " + err.Error())
+	}
+	err = this.UserWebAuthn.Init()
+	if err != nil {
+		return errors.New("user webAuthn: " + err.Error())
+	}
+	return nil
+}
+
+// WebAuthn 获取管理员或者平台用户使用的WebAuthn依赖方
+func (this *TwoFactorPolicy) WebAuthn(isAdmin bool) *WebAuthnConfig {
+	var config *WebAuthnConfig
+	if isAdmin {
+		config = this.AdminWebAuthn
+	} else {
+		config = this.UserWebAuthn
+	}
+	if config == nil {
+		return &WebAuthnConfig{}
+	}
+	return config
+}
+
+// Init 初始化
+func (this *WebAuthnConfig) Init() error {
+	this.RPId = strings.ToLower(strings.TrimSpace(this.RPId))
+	if len(this.RPId) == 0 {
+		this.Origins = nil
+		return nil
+	}
+	if strings.ContainsAny(this.RPId, ":/") {
+		return errors.New("'rpId' should be a domain name")
+	}
+	if len(this.Origins) == 0 {
+		return errors.New("'origins' should not be empty")
+	}
+
+	var origins = []string{}
+	for _, origin := range this.Origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		u, err := url.Parse(origin)
+		if err != nil || len(u.Host) == 0 || len(u.Path) > 0 {
+			return errors.New("invalid origin '" + origin + "'")
+		}
+		var host = strings.ToLower(u.Hostname())
+		if u.Scheme != "https" && !(u.Scheme == "http" && host == "localhost") {
+			return errors.New("origin '" + origin + "' should use https")
+		}
+		if host != this.RPId && !strings.HasSuffix(host, "."+this.RPId) {
+			return errors.New("origin '" + origin + "' does not match rpId '" + this.RPId + "'")
+		}
+		origins = append(origins, u.Scheme+"://"+strings.ToLower(u.Host))
+	}
+	this.Origins = origins
+	return nil
+}
+
+// IsConfigured 是否已经设置
+func (this *WebAuthnConfig) IsConfigured() bool {
+	return len(this.RPId) > 0 && len(this.Origins) > 0
+}
+
+// Name 依赖方名称
+func (this *WebAuthnConfig) Name() string {
+	if len(this.RPName) > 0 {
+		return this.RPName
+	}
+	return this.RPId
+}
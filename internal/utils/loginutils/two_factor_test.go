@@ -0,0 +1,55 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package loginutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/loginutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestTwoFactorPolicy_Init(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		var policy = &loginutils.TwoFactorPolicy{}
+		a.IsNil(policy.Init())
+		a.IsFalse(policy.WebAuthn(true).IsConfigured())
+		a.IsFalse(policy.WebAuthn(false).IsConfigured())
+	}
+
+	{
+		var policy = loginutils.DefaultTwoFactorPolicy()
+		policy.AdminWebAuthn.RPId = " Example.com "
+		policy.AdminWebAuthn.Origins = []string{"https://Admin.example.com/", "https://example.com:8443"}
+		a.IsNil(policy.Init())
+
+		var config = policy.WebAuthn(true)
+		a.IsTrue(config.IsConfigured())
+		a.IsTrue(config.RPId == "example.com")
+		a.IsTrue(config.Name() == "example.com")
+		a.IsTrue(len(config.Origins) == 2)
+		a.IsTrue(config.Origins[0] == "https://admin.example.com")
+		a.IsFalse(policy.WebAuthn(false).IsConfigured())
+	}
+
+	for _, config := range []*loginutils.WebAuthnConfig{
+		{RPId: "example.com"},
+		{RPId: "https://example.com", Origins: []string{"https://example.com"}},
+		{RPId: "example.com", Origins: []string{"http://admin.example.com"}},
+		{RPId: "example.com", Origins: []string{"https://evil.com"}},
+		{RPId: "example.com", Origins: []string{"https://badexample.com"}},
+		{RPId: "example.com", Origins: []string{"https://admin.example.com/login"}},
+	} {
+		var policy = &loginutils.TwoFactorPolicy{UserWebAuthn: config}
+		a.IsTrue(policy.Init() != nil)
+	}
+
+	{
+		var policy = &loginutils.TwoFactorPolicy{UserWebAuthn: &loginutils.WebAuthnConfig{
+			RPId:    "localhost",
+			Origins: []string{"http://localhost:7788"},
+		}}
+		a.IsNil(policy.Init())
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package otputils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+const (
+	DefaultRecoveryCodeCount = 10
+	recoveryCodeAlphabet     = "abcdefghjkmnpqrstuvwxyz23456789" // 去掉了容易混淆的字符
+	recoveryCodeLength       = 10
+)
+
+// GenerateRecoveryCodes 生成一组一次性恢复码，格式为 xxxxx-xxxxx
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	var codes = []string{}
+	var max = big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < count; i++ {
+		var b = make([]byte, 0, recoveryCodeLength+1)
+		for j := 0; j < recoveryCodeLength; j++ {
+			if j == recoveryCodeLength/2 {
+				b = append(b, '-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, recoveryCodeAlphabet[n.Int64()])
+		}
+		codes = append(codes, string(b))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode 计算恢复码的哈希值，用来保存到数据库
+// 恢复码为随机生成，熵足够高，所以直接使用SHA256
+func HashRecoveryCode(code string) string {
+	var sum = sha256.Sum256([]byte(NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeRecoveryCode 规范化用户输入的恢复码
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return code
+}
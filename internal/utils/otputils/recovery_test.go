@@ -0,0 +1,36 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package otputils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/otputils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	codes, err := otputils.GenerateRecoveryCodes(otputils.DefaultRecoveryCodeCount)
+	a.IsNil(err)
+	a.IsTrue(len(codes) == otputils.DefaultRecoveryCodeCount)
+
+	var codeMap = map[string]bool{}
+	for _, code := range codes {
+		a.IsTrue(len(code) == 11)
+		a.IsTrue(code[5] == '-')
+		codeMap[code] = true
+	}
+	a.IsTrue(len(codeMap) == len(codes))
+	t.Log(codes)
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var hash = otputils.HashRecoveryCode("abcde-fghjk")
+	a.IsTrue(len(hash) == 64)
+	a.IsTrue(otputils.HashRecoveryCode("ABCDE FGHJK") == hash)
+	a.IsTrue(otputils.HashRecoveryCode("abcdefghjk") == hash)
+	a.IsTrue(otputils.HashRecoveryCode("abcde-fghjm") != hash)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package otputils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+// ValidateTOTP 校验基于时间的一次性密码（RFC 6238）
+// 为了兼容客户端和服务器之间的时间误差，同时校验前后各一个周期
+func ValidateTOTP(secret string, code string, t time.Time) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != DefaultDigits {
+		return false
+	}
+
+	var counter = t.Unix() / DefaultPeriod
+	for _, offset := range []int64{0, -1, 1} {
+		expected, ok := GenerateTOTP(secret, counter+offset, DefaultDigits)
+		if ok && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateTOTP 根据计数生成一次性密码
+func GenerateTOTP(secret string, counter int64, digits int) (code string, ok bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(key) == 0 {
+		return "", false
+	}
+
+	var msg = make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	var h = hmac.New(sha1.New, key)
+	h.Write(msg)
+	var sum = h.Sum(nil)
+
+	var offset = sum[len(sum)-1] & 0x0f
+	var value = int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	var mod int64 = 1
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	code = strconv.FormatInt(value%mod, 10)
+	for len(code) < digits {
+		code = "0" + code
+	}
+	return code, true
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
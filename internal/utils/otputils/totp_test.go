@@ -0,0 +1,46 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package otputils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/otputils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+// RFC 6238 中的测试数据，密钥为 "12345678901234567890"
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTP(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	for _, item := range []struct {
+		time int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+	} {
+		code, ok := otputils.GenerateTOTP(testSecret, item.time/otputils.DefaultPeriod, 8)
+		a.IsTrue(ok)
+		a.IsTrue(code == item.code)
+	}
+
+	_, ok := otputils.GenerateTOTP("not-base32!", 1, 6)
+	a.IsFalse(ok)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var now = time.Unix(59, 0)
+	a.IsTrue(otputils.ValidateTOTP(testSecret, "287082", now))
+	a.IsTrue(otputils.ValidateTOTP(testSecret, "287082", now.Add(30*time.Second)))
+	a.IsFalse(otputils.ValidateTOTP(testSecret, "287082", now.Add(90*time.Second)))
+	a.IsFalse(otputils.ValidateTOTP(testSecret, "123456", now))
+	a.IsFalse(otputils.ValidateTOTP(testSecret, "28708", now))
+	a.IsFalse(otputils.ValidateTOTP("", "287082", now))
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"strings"
+)
+
+// 登录时客户端提交的是密码的MD5值，所以这里哈希的都是MD5之后的密码
+
+type HashAlgorithm = string
+
+const (
+	HashAlgorithmArgon2id HashAlgorithm = "argon2id"
+	HashAlgorithmBcrypt   HashAlgorithm = "bcrypt"
+)
+
+const (
+	argon2Memory  uint32 = 64 * 1024 // KiB
+	argon2Time    uint32 = 3
+	argon2Threads uint8  = 4
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+
+	bcryptCost = 12
+)
+
+var legacyMD5Reg = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// IsLegacyHash 判断是否为旧的MD5密码
+func IsLegacyHash(hash string) bool {
+	return legacyMD5Reg.MatchString(hash)
+}
+
+// HashPassword 生成密码哈希
+func HashPassword(password string, algorithm HashAlgorithm) (string, error) {
+	if len(password) == 0 {
+		return "", errors.New("password should not be empty")
+	}
+
+	switch algorithm {
+	case HashAlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	case HashAlgorithmArgon2id, "":
+		var salt = make([]byte, argon2SaltLen)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+		var key = argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			argon2Memory,
+			argon2Time,
+			argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", errors.New("unsupported hash algorithm '" + algorithm + "'")
+	}
+}
+
+// VerifyPassword 校验密码
+// needsRehash 表示密码正确但哈希使用了旧的算法或者参数，需要重新生成
+func VerifyPassword(hash string, password string, algorithm HashAlgorithm) (ok bool, needsRehash bool) {
+	if len(hash) == 0 || len(password) == 0 {
+		return false, false
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		memory, iterations, threads, salt, key, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false, false
+		}
+		var otherKey = argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+			return false, false
+		}
+		needsRehash = (algorithm != HashAlgorithmArgon2id && algorithm != "") ||
+			memory != argon2Memory ||
+			iterations != argon2Time ||
+			threads != argon2Threads ||
+			uint32(len(key)) != argon2KeyLen
+		return true, needsRehash
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		needsRehash = algorithm != HashAlgorithmBcrypt || err != nil || cost < bcryptCost
+		return true, needsRehash
+	case IsLegacyHash(hash):
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(password))) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// 分析argon2id哈希
+// 格式：$argon2id$v=19$m=65536,t=3,p=4$salt$key
+func parseArgon2Hash(hash string) (memory uint32, iterations uint32, threads uint8, salt []byte, key []byte, err error) {
+	var pieces = strings.Split(hash, "$")
+	if len(pieces) != 6 {
+		err = errors.New("invalid argon2id hash")
+		return
+	}
+
+	var version int
+	_, err = fmt.Sscanf(pieces[2], "v=%d", &version)
+	if err != nil {
+		return
+	}
+	if version != argon2.Version {
+		err = errors.New("incompatible argon2 version")
+		return
+	}
+
+	_, err = fmt.Sscanf(pieces[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads)
+	if err != nil {
+		return
+	}
+	if memory == 0 || iterations == 0 || threads == 0 {
+		err = errors.New("invalid argon2id parameters")
+		return
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(pieces[4])
+	if err != nil {
+		return
+	}
+	key, err = base64.RawStdEncoding.DecodeString(pieces[5])
+	if err != nil {
+		return
+	}
+	if len(key) == 0 {
+		err = errors.New("invalid argon2id key")
+	}
+	return
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/iwind/TeaGo/assert"
+	stringutil "github.com/iwind/TeaGo/utils/string"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword_Argon2id(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var password = stringutil.Md5("123456")
+	hash, err := passwordutils.HashPassword(password, passwordutils.HashAlgorithmArgon2id)
+	a.IsNil(err)
+	a.IsTrue(strings.HasPrefix(hash, "$argon2id$v=19$"))
+	t.Log(hash)
+
+	// 每次生成的盐值不同
+	hash2, err := passwordutils.HashPassword(password, passwordutils.HashAlgorithmArgon2id)
+	a.IsNil(err)
+	a.IsTrue(hash != hash2)
+
+	ok, needsRehash := passwordutils.VerifyPassword(hash, password, passwordutils.HashAlgorithmArgon2id)
+	a.IsTrue(ok)
+	a.IsFalse(needsRehash)
+
+	ok, _ = passwordutils.VerifyPassword(hash, stringutil.Md5("1234567"), passwordutils.HashAlgorithmArgon2id)
+	a.IsFalse(ok)
+
+	// 切换算法
+	ok, needsRehash = passwordutils.VerifyPassword(hash, password, passwordutils.HashAlgorithmBcrypt)
+	a.IsTrue(ok)
+	a.IsTrue(needsRehash)
+}
+
+func TestHashPassword_Bcrypt(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var password = stringutil.Md5("123456")
+	hash, err := passwordutils.HashPassword(password, passwordutils.HashAlgorithmBcrypt)
+	a.IsNil(err)
+	a.IsTrue(strings.HasPrefix(hash, "$2a$"))
+
+	ok, needsRehash := passwordutils.VerifyPassword(hash, password, passwordutils.HashAlgorithmBcrypt)
+	a.IsTrue(ok)
+	a.IsFalse(needsRehash)
+
+	ok, _ = passwordutils.VerifyPassword(hash, stringutil.Md5("1234567"), passwordutils.HashAlgorithmBcrypt)
+	a.IsFalse(ok)
+
+	ok, needsRehash = passwordutils.VerifyPassword(hash, password, passwordutils.HashAlgorithmArgon2id)
+	a.IsTrue(ok)
+	a.IsTrue(needsRehash)
+}
+
+func TestVerifyPassword_Legacy(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var password = stringutil.Md5("123456")
+	a.IsTrue(passwordutils.IsLegacyHash(password))
+
+	ok, needsRehash := passwordutils.VerifyPassword(password, password, passwordutils.HashAlgorithmArgon2id)
+	a.IsTrue(ok)
+	a.IsTrue(needsRehash)
+
+	ok, _ = passwordutils.VerifyPassword(password, stringutil.Md5("1234567"), passwordutils.HashAlgorithmArgon2id)
+	a.IsFalse(ok)
+
+	ok, _ = passwordutils.VerifyPassword("", "", passwordutils.HashAlgorithmArgon2id)
+	a.IsFalse(ok)
+
+	ok, _ = passwordutils.VerifyPassword("$argon2id$v=19$invalid", password, passwordutils.HashAlgorithmArgon2id)
+	a.IsFalse(ok)
+}
+
+func TestHistory(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var history = []string{}
+	for _, password := range []string{"a1", "a2", "a3"} {
+		hash, err := passwordutils.HashPassword(stringutil.Md5(password), passwordutils.HashAlgorithmBcrypt)
+		a.IsNil(err)
+		history = passwordutils.AppendHistory(history, hash)
+	}
+	a.IsTrue(len(history) == 3)
+
+	a.IsTrue(passwordutils.IsInHistory(history, stringutil.Md5("a3"), 1))
+	a.IsFalse(passwordutils.IsInHistory(history, stringutil.Md5("a1"), 2))
+	a.IsTrue(passwordutils.IsInHistory(history, stringutil.Md5("a1"), 3))
+	a.IsTrue(passwordutils.IsInHistory(history, stringutil.Md5("a1"), 10))
+	a.IsFalse(passwordutils.IsInHistory(history, stringutil.Md5("a4"), 10))
+	a.IsFalse(passwordutils.IsInHistory(history, stringutil.Md5("a1"), 0))
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils
+
+// IsInHistory 检查密码是否在最近使用的N个密码中
+func IsInHistory(history []string, password string, size int) bool {
+	if size > len(history) {
+		size = len(history)
+	}
+	for _, hash := range history[:size] {
+		ok, _ := VerifyPassword(hash, password, "")
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendHistory 将新的密码哈希加入到历史记录中
+// 最新的放在最前面，最多保留 MaxHistorySize 个
+func AppendHistory(history []string, hash string) []string {
+	var result = []string{hash}
+	for _, oldHash := range history {
+		if len(result) >= MaxHistorySize {
+			break
+		}
+		if oldHash != hash {
+			result = append(result, oldHash)
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/types"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MaxHistorySize = 24
+
+// Policy 密码策略
+type Policy struct {
+	MinLength     int           `yaml:"minLength" json:"minLength"`         // 最小长度
+	RequireUpper  bool          `yaml:"requireUpper" json:"requireUpper"`   // 需要大写字母
+	RequireLower  bool          `yaml:"requireLower" json:"requireLower"`   // 需要小写字母
+	RequireDigit  bool          `yaml:"requireDigit" json:"requireDigit"`   // 需要数字
+	RequireSymbol bool          `yaml:"requireSymbol" json:"requireSymbol"` // 需要特殊字符
+	HistorySize   int           `yaml:"historySize" json:"historySize"`     // 不能和最近N次使用过的密码重复
+	HashAlgorithm HashAlgorithm `yaml:"hashAlgorithm" json:"hashAlgorithm"` // 哈希算法：argon2id、bcrypt
+	DenyWeak      bool          `yaml:"denyWeak" json:"denyWeak"`           // 禁止使用常见弱密码
+	ApplyToUsers  bool          `yaml:"applyToUsers" json:"applyToUsers"`   // 是否应用到平台用户
+	ApplyToAdmins bool          `yaml:"applyToAdmins" json:"applyToAdmins"` // 是否应用到管理员
+}
+
+// DefaultPolicy 默认的密码策略
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinLength:     8,
+		HistorySize:   0,
+		HashAlgorithm: HashAlgorithmArgon2id,
+		DenyWeak:      true,
+		ApplyToUsers:  true,
+		ApplyToAdmins: true,
+	}
+}
+
+// Init 初始化
+func (this *Policy) Init() error {
+	if this.MinLength < 0 {
+		this.MinLength = 0
+	}
+	if this.HistorySize < 0 {
+		this.HistorySize = 0
+	} else if this.HistorySize > MaxHistorySize {
+		this.HistorySize = MaxHistorySize
+	}
+	switch this.HashAlgorithm {
+	case "":
+		this.HashAlgorithm = HashAlgorithmArgon2id
+	case HashAlgorithmArgon2id, HashAlgorithmBcrypt:
+	default:
+		return errors.New("unsupported hash algorithm '" + this.HashAlgorithm + "'")
+	}
+	return nil
+}
+
+// Check 检查明文密码是否符合策略
+func (this *Policy) Check(password string) error {
+	if utf8.RuneCountInString(password) < this.MinLength {
+		return errors.New("password should contain at least " + types.String(this.MinLength) + " characters")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if this.RequireUpper && !hasUpper {
+		return errors.New("password should contain upper case letters")
+	}
+	if this.RequireLower && !hasLower {
+		return errors.New("password should contain lower case letters")
+	}
+	if this.RequireDigit && !hasDigit {
+		return errors.New("password should contain digits")
+	}
+	if this.RequireSymbol && !hasSymbol {
+		return errors.New("password should contain symbols")
+	}
+
+	if this.DenyWeak && IsWeakPassword(password) {
+		return errors.New("password is too weak")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/passwordutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = passwordutils.DefaultPolicy()
+	a.IsNil(policy.Init())
+	a.IsTrue(policy.Check("abc") != nil)
+	a.IsTrue(policy.Check("password") != nil)
+	a.IsNil(policy.Check("abcdefgh"))
+
+	policy.RequireUpper = true
+	policy.RequireDigit = true
+	policy.RequireSymbol = true
+	a.IsTrue(policy.Check("abcdefgh") != nil)
+	a.IsTrue(policy.Check("Abcdefgh") != nil)
+	a.IsTrue(policy.Check("Abcdefg1") != nil)
+	a.IsNil(policy.Check("Abcdef1!"))
+}
+
+func TestPolicy_Init(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = &passwordutils.Policy{
+		HistorySize: 100,
+	}
+	a.IsNil(policy.Init())
+	a.IsTrue(policy.HistorySize == passwordutils.MaxHistorySize)
+	a.IsTrue(policy.HashAlgorithm == passwordutils.HashAlgorithmArgon2id)
+
+	policy.HashAlgorithm = "md5"
+	a.IsTrue(policy.Init() != nil)
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package passwordutils
+
+import "strings"
+
+// WeakPasswords 常见弱密码
+var WeakPasswords = []string{
+	"123",
+	"1234",
+	"12345",
+	"123456",
+	"12345678",
+	"123456789",
+	"000000",
+	"111111",
+	"666666",
+	"888888",
+	"654321",
+	"password",
+	"qwerty",
+	"admin",
+}
+
+// IsWeakPassword 判断明文密码是否为常见弱密码
+func IsWeakPassword(password string) bool {
+	password = strings.ToLower(password)
+	for _, weakPassword := range WeakPasswords {
+		if password == weakPassword {
+			return true
+		}
+	}
+	return false
+}
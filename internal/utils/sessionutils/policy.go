@@ -0,0 +1,62 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sessionutils
+
+const MaxSessionsLimit = 100
+
+// Policy 登录SESSION策略
+type Policy struct {
+	MaxSessions       int  `yaml:"maxSessions" json:"maxSessions"`             // 每个账号最多同时存在的SESSION数量，0表示不限制
+	SingleIP          bool `yaml:"singleIP" json:"singleIP"`                   // 从新的IP登录时是否删除其他IP上的SESSION
+	NotifyNewLocation bool `yaml:"notifyNewLocation" json:"notifyNewLocation"` // 从新的地区登录时是否发送通知
+}
+
+// DefaultPolicy 默认的SESSION策略
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxSessions:       4,
+		SingleIP:          true,
+		NotifyNewLocation: true,
+	}
+}
+
+// Init 初始化
+func (this *Policy) Init() error {
+	if this.MaxSessions < 0 {
+		this.MaxSessions = 0
+	} else if this.MaxSessions > MaxSessionsLimit {
+		this.MaxSessions = MaxSessionsLimit
+	}
+	return nil
+}
+
+// CountSessionsToDelete 计算已有SESSION中需要删除的数量
+// existingCount 为除当前SESSION之外的SESSION数量
+func (this *Policy) CountSessionsToDelete(existingCount int) int {
+	if this.MaxSessions <= 0 || existingCount < this.MaxSessions {
+		return 0
+	}
+	return existingCount - this.MaxSessions + 1
+}
+
+// IsNewLocation 判断登录地区是否为新的地区
+// knownLocations 为账号其他SESSION的地区
+func IsNewLocation(location string, knownLocations []string) bool {
+	if len(location) == 0 {
+		return false
+	}
+
+	var hasKnown = false
+	for _, knownLocation := range knownLocations {
+		if len(knownLocation) == 0 {
+			continue
+		}
+		if knownLocation == location {
+			return false
+		}
+		hasKnown = true
+	}
+
+	// 没有可以参照的地区时不认为是新的地区，避免首次登录就发送通知
+	return hasKnown
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sessionutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sessionutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestPolicy_Init(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = &sessionutils.Policy{MaxSessions: -1}
+	a.IsNil(policy.Init())
+	a.IsTrue(policy.MaxSessions == 0)
+
+	policy.MaxSessions = 1000
+	a.IsNil(policy.Init())
+	a.IsTrue(policy.MaxSessions == sessionutils.MaxSessionsLimit)
+}
+
+func TestPolicy_CountSessionsToDelete(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = sessionutils.DefaultPolicy()
+	a.IsTrue(policy.CountSessionsToDelete(0) == 0)
+	a.IsTrue(policy.CountSessionsToDelete(3) == 0)
+	a.IsTrue(policy.CountSessionsToDelete(4) == 1)
+	a.IsTrue(policy.CountSessionsToDelete(10) == 7)
+
+	policy.MaxSessions = 1
+	a.IsTrue(policy.CountSessionsToDelete(1) == 1)
+
+	policy.MaxSessions = 0
+	a.IsTrue(policy.CountSessionsToDelete(10) == 0)
+}
+
+func TestIsNewLocation(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsFalse(sessionutils.IsNewLocation("", []string{"中国 北京"}))
+	a.IsFalse(sessionutils.IsNewLocation("中国 北京", nil))
+	a.IsFalse(sessionutils.IsNewLocation("中国 北京", []string{"", ""}))
+	a.IsFalse(sessionutils.IsNewLocation("中国 北京", []string{"中国 上海", "中国 北京"}))
+	a.IsTrue(sessionutils.IsNewLocation("中国 北京", []string{"中国 上海", ""}))
+}
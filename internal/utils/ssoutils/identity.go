@@ -0,0 +1,134 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Identity 从身份提供方获得的身份信息
+type Identity struct {
+	Source   string         // 来源：oidc、ldap
+	Subject  string         // 在身份提供方中的唯一标识
+	Username string         // 用户名
+	Fullname string         // 全名
+	Email    string         // 邮箱
+	Groups   []string       // 所属用户组
+	Claims   map[string]any // 原始声明或属性
+}
+
+// RoleGrant 映射后得到的角色
+type RoleGrant struct {
+	IsSuper bool
+	RoleIds []int64
+}
+
+func (this *RoleGrant) addRoleIds(roleIds []int64) {
+	for _, roleId := range roleIds {
+		if roleId <= 0 {
+			continue
+		}
+		var exists = false
+		for _, existRoleId := range this.RoleIds {
+			if existRoleId == roleId {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			this.RoleIds = append(this.RoleIds, roleId)
+		}
+	}
+}
+
+// RoleMapping 角色映射规则
+type RoleMapping struct {
+	Claim   string  `yaml:"claim" json:"claim"`     // 要匹配的声明或属性，为空表示匹配用户组
+	Value   string  `yaml:"value" json:"value"`     // 要匹配的值，不区分大小写；匹配用户组时也可以填写组DN中的CN
+	RoleIds []int64 `yaml:"roleIds" json:"roleIds"` // 匹配后授予的角色
+	IsSuper bool    `yaml:"isSuper" json:"isSuper"` // 匹配后是否成为超级管理员
+}
+
+// Match 判断身份是否匹配此规则
+func (this *RoleMapping) Match(identity *Identity) bool {
+	if identity == nil || len(this.Value) == 0 {
+		return false
+	}
+
+	if len(this.Claim) == 0 {
+		for _, group := range identity.Groups {
+			if strings.EqualFold(group, this.Value) || strings.EqualFold(groupCommonName(group), this.Value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	value, ok := identity.Claims[this.Claim]
+	if !ok {
+		// LDAP属性名不区分大小写
+		for name, claimValue := range identity.Claims {
+			if strings.EqualFold(name, this.Claim) {
+				value, ok = claimValue, true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, s := range claimStrings(value) {
+		if strings.EqualFold(s, this.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从组DN中获取CN，比如 cn=ops,ou=groups,dc=example,dc=com 中的 ops
+func groupCommonName(group string) string {
+	var first, _, _ = strings.Cut(group, ",")
+	key, value, found := strings.Cut(first, "=")
+	if !found || !strings.EqualFold(strings.TrimSpace(key), "cn") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// 将声明值转换为字符串列表
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		var result = []string{}
+		for _, item := range v {
+			result = append(result, claimStrings(item)...)
+		}
+		return result
+	case float64:
+		if v == float64(int64(v)) {
+			return []string{fmt.Sprintf("%d", int64(v))}
+		}
+		return []string{fmt.Sprintf("%v", v)}
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
+}
+
+// 读取单个字符串声明
+func claimString(claims map[string]any, name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	var values = claimStrings(claims[name])
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
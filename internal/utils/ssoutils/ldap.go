@@ -0,0 +1,158 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// LDAPClient LDAP认证客户端
+type LDAPClient struct {
+	config *LDAPConfig
+}
+
+// NewLDAPClient 获取新对象
+func NewLDAPClient(config *LDAPConfig) *LDAPClient {
+	return &LDAPClient{config: config}
+}
+
+// Authenticate 使用用户名和密码认证
+// 先使用查询账号查找用户，再使用用户的DN和密码绑定
+func (this *LDAPClient) Authenticate(username string, password string) (*Identity, error) {
+	// 空密码会被LDAP服务当作匿名绑定，必须拒绝
+	if len(username) == 0 || len(password) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := this.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if len(this.config.BindDN) > 0 {
+		err = conn.Bind(this.config.BindDN, this.config.BindPassword)
+		if err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+				return nil, errors.New("ldap: invalid bind credentials")
+			}
+			return nil, err
+		}
+	}
+
+	var attrs = []string{this.config.UsernameAttr, this.config.FullnameAttr, this.config.GroupAttr}
+	if len(this.config.EmailAttr) > 0 {
+		attrs = append(attrs, this.config.EmailAttr)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		this.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		this.config.Timeout,
+		false,
+		composeLDAPFilter(this.config.UserFilter, ldap.EscapeFilter(username)),
+		attrs,
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	var entry = result.Entries[0]
+
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	var identity = &Identity{
+		Source:   SourceLDAP,
+		Subject:  strings.ToLower(entry.DN),
+		Username: this.attrValue(entry, this.config.UsernameAttr),
+		Fullname: this.attrValue(entry, this.config.FullnameAttr),
+		Email:    this.attrValue(entry, this.config.EmailAttr),
+		Groups:   this.attrValues(entry, this.config.GroupAttr),
+		Claims:   map[string]any{},
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = username
+	}
+	for _, attr := range entry.Attributes {
+		identity.Claims[attr.Name] = attr.Values
+	}
+	return identity, nil
+}
+
+func (this *LDAPClient) dial() (*ldap.Conn, error) {
+	addrURL, err := url.Parse(this.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout = time.Duration(this.config.Timeout) * time.Second
+	var tlsConfig = &tls.Config{
+		ServerName:         addrURL.Hostname(),
+		InsecureSkipVerify: this.config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	conn, err := ldap.DialURL(this.config.Addr, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+
+	if this.config.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// 读取属性值，属性名不区分大小写
+func (this *LDAPClient) attrValues(entry *ldap.Entry, name string) []string {
+	if len(name) == 0 {
+		return nil
+	}
+	return entry.GetEqualFoldAttributeValues(name)
+}
+
+// 读取第一个属性值
+func (this *LDAPClient) attrValue(entry *ldap.Entry, name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	return entry.GetEqualFoldAttributeValue(name)
+}
+
+// 将用户名代入过滤器，用户名需要事先转义
+func composeLDAPFilter(filter string, escapedUsername string) string {
+	filter = strings.TrimSpace(filter)
+	if len(filter) > 0 && filter[0] != '(' {
+		filter = "(" + filter + ")"
+	}
+	return strings.ReplaceAll(filter, "%s", escapedUsername)
+}
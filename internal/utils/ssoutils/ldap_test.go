@@ -0,0 +1,250 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 用来测试的LDAP服务，只支持简单绑定和基于等值过滤器的查询
+type testLDAPServer struct {
+	listener  net.Listener
+	passwords map[string]string              // dn => password
+	entries   map[string]map[string][]string // dn => attributes
+
+	locker     sync.Mutex
+	lastFilter *ber.Packet
+}
+
+func newTestLDAPServer(t *testing.T) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server = &testLDAPServer{
+		listener: listener,
+		passwords: map[string]string{
+			"cn=reader,dc=example,dc=com":           "reader123",
+			"uid=alice,ou=people,dc=example,dc=com": "alice123",
+			"uid=bob,ou=people,dc=example,dc=com":   "bob123",
+		},
+		entries: map[string]map[string][]string{
+			"uid=alice,ou=people,dc=example,dc=com": {
+				"uid":      {"alice"},
+				"cn":       {"Alice Liu"},
+				"mail":     {"alice@example.com"},
+				"memberOf": {"cn=ops,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+			},
+			"uid=bob,ou=people,dc=example,dc=com": {
+				"uid": {"bob"},
+				"cn":  {"Bob"},
+			},
+		},
+	}
+	go server.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return server
+}
+
+func (this *testLDAPServer) Addr() string {
+	return "ldap://" + this.listener.Addr().String()
+}
+
+func (this *testLDAPServer) serve() {
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		go this.handle(conn)
+	}
+}
+
+func (this *testLDAPServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var boundDN = ""
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		var messageId = message.Children[0]
+		var op = message.Children[1]
+		var reply = func(op *ber.Packet) {
+			var response = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId.Value, ""))
+			response.AppendChild(op)
+			_, _ = conn.Write(response.Bytes())
+		}
+		var result = func(tag ber.Tag, code int64) *ber.Packet {
+			var packet = ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+			packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			return packet
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			var dn = op.Children[1].Data.String()
+			var password = op.Children[2].Data.String()
+			expected, ok := this.passwords[dn]
+			if ok && len(password) > 0 && expected == password {
+				boundDN = dn
+				reply(result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+			} else {
+				reply(result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials))
+			}
+		case ldap.ApplicationSearchRequest:
+			if boundDN != "cn=reader,dc=example,dc=com" {
+				reply(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+			var filter = op.Children[6]
+			this.locker.Lock()
+			this.lastFilter = filter
+			this.locker.Unlock()
+			for dn, attrs := range this.entries {
+				if !strings.HasSuffix(dn, op.Children[0].Data.String()) || !this.match(filter, attrs) {
+					continue
+				}
+				var entry = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+				var attrsPacket = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for name, values := range attrs {
+					var attrPacket = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					attrPacket.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+					var valuesPacket = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, value := range values {
+						valuesPacket.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+					}
+					attrPacket.AppendChild(valuesPacket)
+					attrsPacket.AppendChild(attrPacket)
+				}
+				entry.AppendChild(attrsPacket)
+				reply(entry)
+			}
+			reply(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (this *testLDAPServer) match(filter *ber.Packet, attrs map[string][]string) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !this.match(child, attrs) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterEqualityMatch:
+		for _, value := range attrs[filter.Children[0].Data.String()] {
+			if value == filter.Children[1].Data.String() {
+				return true
+			}
+		}
+	case ldap.FilterPresent:
+		return len(attrs[filter.Data.String()]) > 0
+	}
+	return false
+}
+
+func testLDAPConfig(addr string) *LDAPConfig {
+	var config = DefaultLDAPConfig()
+	config.IsOn = true
+	config.Addr = addr
+	config.BindDN = "cn=reader,dc=example,dc=com"
+	config.BindPassword = "reader123"
+	config.BaseDN = "ou=people,dc=example,dc=com"
+	config.UserFilter = "(&(uid=*)(uid=%s))"
+	return config
+}
+
+func TestLDAPClient_Authenticate(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var server = newTestLDAPServer(t)
+	var config = testLDAPConfig(server.Addr())
+	a.IsNil(config.Init())
+
+	identity, err := NewLDAPClient(config).Authenticate("alice", "alice123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(identity.Source == SourceLDAP)
+	a.IsTrue(identity.Subject == "uid=alice,ou=people,dc=example,dc=com")
+	a.IsTrue(identity.Username == "alice")
+	a.IsTrue(identity.Fullname == "Alice Liu")
+	a.IsTrue(identity.Email == "alice@example.com")
+	a.IsTrue(len(identity.Groups) == 2)
+}
+
+func TestLDAPClient_Authenticate_Invalid(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var server = newTestLDAPServer(t)
+	var config = testLDAPConfig(server.Addr())
+	a.IsNil(config.Init())
+
+	var client = NewLDAPClient(config)
+	{
+		_, err := client.Authenticate("alice", "wrong")
+		a.IsTrue(err == ErrInvalidCredentials)
+	}
+	{
+		_, err := client.Authenticate("alice", "")
+		a.IsTrue(err == ErrInvalidCredentials)
+	}
+	{
+		_, err := client.Authenticate("carol", "carol123")
+		a.IsTrue(err == ErrInvalidCredentials)
+	}
+	{
+		// 过滤器注入
+		_, err := client.Authenticate("*", "alice123")
+		a.IsTrue(err == ErrInvalidCredentials)
+		server.locker.Lock()
+		var uidFilter = server.lastFilter.Children[1]
+		server.locker.Unlock()
+		a.IsTrue(uidFilter.Tag == ldap.FilterEqualityMatch)
+		a.IsTrue(uidFilter.Children[1].Data.String() == "*")
+	}
+}
+
+func TestLDAPClient_Authenticate_BindFailed(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var server = newTestLDAPServer(t)
+	var config = testLDAPConfig(server.Addr())
+	config.BindPassword = "wrong"
+	a.IsNil(config.Init())
+
+	_, err := NewLDAPClient(config).Authenticate("alice", "alice123")
+	a.IsTrue(err != nil && err != ErrInvalidCredentials)
+}
+
+func TestComposeLDAPFilter(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	a.IsTrue(composeLDAPFilter("uid=%s", "alice") == "(uid=alice)")
+	a.IsTrue(composeLDAPFilter("(&(objectClass=person)(uid=%s))", "alice") == "(&(objectClass=person)(uid=alice))")
+
+	packet, err := ldap.CompileFilter(composeLDAPFilter("(uid=%s)", ldap.EscapeFilter("a*(b)\\")))
+	a.IsNil(err)
+	a.IsTrue(packet.Tag == ldap.FilterEqualityMatch)
+	a.IsTrue(packet.Children[1].Data.String() == "a*(b)\\")
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 允许的时钟误差
+const oidcClockSkew = 60 * time.Second
+
+// 支持的签名算法，不支持 none 和 HMAC 算法
+var oidcSigningAlgs = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+}
+
+// 发现文档的缓存时间
+const oidcCacheLife = 10 * time.Minute
+
+var oidcHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// OIDCProviderMetadata 身份提供方元数据
+type OIDCProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcCacheItem struct {
+	metadata  *OIDCProviderMetadata
+	keySet    *oidc.RemoteKeySet
+	expiresAt time.Time
+}
+
+var oidcCacheMap = map[string]*oidcCacheItem{} // issuer => item
+var oidcCacheLocker = &sync.Mutex{}
+
+// OIDCClient OpenID Connect客户端，使用授权码模式和PKCE
+type OIDCClient struct {
+	config *OIDCConfig
+}
+
+// NewOIDCClient 获取新对象
+func NewOIDCClient(config *OIDCConfig) *OIDCClient {
+	return &OIDCClient{config: config}
+}
+
+// AuthCodeURL 构造跳转到身份提供方的认证地址
+func (this *OIDCClient) AuthCodeURL(state string, nonce string, codeVerifier string) (string, error) {
+	metadata, _, err := this.discover()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	var query = authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", this.config.ClientId)
+	query.Set("redirect_uri", this.config.RedirectURL)
+	query.Set("scope", strings.Join(this.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange 使用授权码换取令牌，并校验ID Token
+func (this *OIDCClient) Exchange(code string, codeVerifier string, nonce string) (*Identity, error) {
+	if len(code) == 0 {
+		return nil, errors.New("oidc: 'code' should not be empty")
+	}
+
+	metadata, _, err := this.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	var form = url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", this.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", this.config.ClientId)
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(this.config.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(this.config.ClientId), url.QueryEscape(this.config.ClientSecret))
+	}
+
+	var tokenResponse = &struct {
+		IdToken          string `json:"id_token"`
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	err = this.doJSON(req, tokenResponse)
+	if err != nil {
+		if len(tokenResponse.Error) > 0 {
+			return nil, errors.New("oidc: token request failed: " + tokenResponse.Error + " " + tokenResponse.ErrorDescription)
+		}
+		return nil, err
+	}
+	if len(tokenResponse.IdToken) == 0 {
+		return nil, errors.New("oidc: no 'id_token' in token response")
+	}
+
+	claims, err := this.VerifyIDToken(tokenResponse.IdToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// 有些身份提供方只在UserInfo中返回用户组等信息
+	if len(metadata.UserInfoEndpoint) > 0 && len(tokenResponse.AccessToken) > 0 {
+		userInfo, err := this.fetchUserInfo(metadata.UserInfoEndpoint, tokenResponse.AccessToken)
+		if err == nil && claimString(userInfo, "sub") == claimString(claims, "sub") {
+			for name, value := range userInfo {
+				_, exists := claims[name]
+				if !exists {
+					claims[name] = value
+				}
+			}
+		}
+	}
+
+	return this.composeIdentity(claims), nil
+}
+
+// VerifyIDToken 校验ID Token的签名和声明
+func (this *OIDCClient) VerifyIDToken(token string, nonce string) (map[string]any, error) {
+	metadata, keySet, err := this.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	// 签名、iss、aud和exp由go-oidc校验，身份提供方轮换密钥后会自动重新读取公钥
+	var verifier = oidc.NewVerifier(metadata.Issuer, keySet, &oidc.Config{
+		ClientID:             this.config.ClientId,
+		SupportedSigningAlgs: oidcSigningAlgs,
+	})
+	idToken, err := verifier.Verify(oidc.ClientContext(context.Background(), oidcHTTPClient), token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verify id token failed: %w", err)
+	}
+	var claims = map[string]any{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(idToken.Audience) > 1 && claimString(claims, "azp") != this.config.ClientId {
+		return nil, errors.New("oidc: invalid 'azp'")
+	}
+	if idToken.IssuedAt.After(time.Now().Add(oidcClockSkew)) {
+		return nil, errors.New("oidc: token is issued in the future")
+	}
+
+	if len(nonce) == 0 || idToken.Nonce != nonce {
+		return nil, errors.New("oidc: invalid 'nonce'")
+	}
+	if len(idToken.Subject) == 0 {
+		return nil, errors.New("oidc: no 'sub' in token")
+	}
+	return claims, nil
+}
+
+// 将声明转换为身份信息
+func (this *OIDCClient) composeIdentity(claims map[string]any) *Identity {
+	var identity = &Identity{
+		Source:   SourceOIDC,
+		Subject:  claimString(claims, "iss") + "#" + claimString(claims, "sub"),
+		Username: claimString(claims, this.config.UsernameClaim),
+		Fullname: claimString(claims, this.config.FullnameClaim),
+		Email:    claimString(claims, "email"),
+		Groups:   claimStrings(claims[this.config.GroupsClaim]),
+		Claims:   claims,
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = identity.Email
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = claimString(claims, "sub")
+	}
+	return identity
+}
+
+func (this *OIDCClient) fetchUserInfo(endpoint string, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	var userInfo = map[string]any{}
+	err = this.doJSON(req, &userInfo)
+	if err != nil {
+		return nil, err
+	}
+	return userInfo, nil
+}
+
+// 读取身份提供方元数据和公钥
+func (this *OIDCClient) discover() (*OIDCProviderMetadata, *oidc.RemoteKeySet, error) {
+	var issuer = this.config.Issuer
+
+	oidcCacheLocker.Lock()
+	item, ok := oidcCacheMap[issuer]
+	oidcCacheLocker.Unlock()
+	if ok && time.Now().Before(item.expiresAt) {
+		return item.metadata, item.keySet, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var metadata = &OIDCProviderMetadata{}
+	err = this.doJSON(req, metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	if metadata.Issuer != issuer {
+		return nil, nil, errors.New("oidc: issuer does not match, expected '" + issuer + "', got '" + metadata.Issuer + "'")
+	}
+	if len(metadata.AuthorizationEndpoint) == 0 || len(metadata.TokenEndpoint) == 0 || len(metadata.JWKSURI) == 0 {
+		return nil, nil, errors.New("oidc: incomplete provider metadata")
+	}
+
+	var keySet = oidc.NewRemoteKeySet(oidc.ClientContext(context.Background(), oidcHTTPClient), metadata.JWKSURI)
+
+	oidcCacheLocker.Lock()
+	oidcCacheMap[issuer] = &oidcCacheItem{
+		metadata:  metadata,
+		keySet:    keySet,
+		expiresAt: time.Now().Add(oidcCacheLife),
+	}
+	oidcCacheLocker.Unlock()
+
+	return metadata, keySet, nil
+}
+
+func (this *OIDCClient) doJSON(req *http.Request, result any) error {
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 尽量解析错误信息
+		_ = json.Unmarshal(data, result)
+		return errors.New("oidc: unexpected status " + resp.Status + " from '" + req.URL.Host + req.URL.Path + "'")
+	}
+	return json.Unmarshal(data, result)
+}
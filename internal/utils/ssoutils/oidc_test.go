@@ -0,0 +1,284 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/iwind/TeaGo/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 用来测试的身份提供方
+type testOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	locker sync.Mutex
+	codes  map[string]url.Values // code => authorize query
+
+	// 用来修改签发的声明
+	modifyClaims func(claims map[string]any)
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var provider = &testOIDCProvider{
+		key:   key,
+		codes: map[string]url.Values{},
+	}
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, req *http.Request) {
+		var issuer = provider.server.URL
+		provider.writeJSON(writer, map[string]any{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+			"jwks_uri":               issuer + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(writer http.ResponseWriter, req *http.Request) {
+		provider.writeJSON(writer, map[string]any{
+			"keys": []map[string]any{
+				{
+					"kid": "test",
+					"kty": "RSA",
+					"alg": "RS256",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(writer http.ResponseWriter, req *http.Request) {
+		clientId, clientSecret, _ := req.BasicAuth()
+		if clientId != "edge-admin" || clientSecret != "secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			provider.writeJSON(writer, map[string]any{"error": "invalid_client"})
+			return
+		}
+
+		provider.locker.Lock()
+		var query = provider.codes[req.PostFormValue("code")]
+		delete(provider.codes, req.PostFormValue("code"))
+		provider.locker.Unlock()
+		if query == nil ||
+			req.PostFormValue("redirect_uri") != query.Get("redirect_uri") ||
+			CodeChallenge(req.PostFormValue("code_verifier")) != query.Get("code_challenge") {
+			writer.WriteHeader(http.StatusBadRequest)
+			provider.writeJSON(writer, map[string]any{"error": "invalid_grant"})
+			return
+		}
+
+		var claims = map[string]any{
+			"iss":                provider.server.URL,
+			"sub":                "1001",
+			"aud":                "edge-admin",
+			"exp":                time.Now().Add(5 * time.Minute).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              query.Get("nonce"),
+			"preferred_username": "alice",
+			"name":               "Alice Liu",
+		}
+		if provider.modifyClaims != nil {
+			provider.modifyClaims(claims)
+		}
+		provider.writeJSON(writer, map[string]any{
+			"access_token": "access-1001",
+			"token_type":   "Bearer",
+			"id_token":     provider.sign(t, claims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(writer http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer access-1001" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		provider.writeJSON(writer, map[string]any{
+			"sub":    "1001",
+			"email":  "alice@example.com",
+			"groups": []string{"ops", "staff"},
+		})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+// 模拟用户在身份提供方完成认证，返回授权码
+func (this *testOIDCProvider) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query = u.Query()
+	if query.Get("code_challenge_method") != "S256" || len(query.Get("code_challenge")) == 0 {
+		t.Fatal("pkce is required")
+	}
+	code, err := RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	this.locker.Lock()
+	this.codes[code] = query
+	this.locker.Unlock()
+	return code
+}
+
+func (this *testOIDCProvider) sign(t *testing.T, claims map[string]any) string {
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	claimsJSON, _ := json.Marshal(claims)
+	var signed = base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	var digest = sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, this.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (this *testOIDCProvider) writeJSON(writer http.ResponseWriter, v any) {
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(v)
+}
+
+func (this *testOIDCProvider) config() *OIDCConfig {
+	var config = DefaultOIDCConfig()
+	config.IsOn = true
+	config.Issuer = this.server.URL
+	config.ClientId = "edge-admin"
+	config.ClientSecret = "secret"
+	config.RedirectURL = "https://admin.example.com/sso/oidc/callback"
+	return config
+}
+
+func TestOIDCClient_Exchange(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var provider = newTestOIDCProvider(t)
+	var config = provider.config()
+	a.IsNil(config.Init())
+	var client = NewOIDCClient(config)
+
+	verifier, _ := RandomString()
+	authURL, err := client.AuthCodeURL("state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code = provider.authorize(t, authURL)
+
+	identity, err := client.Exchange(code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(identity.Source == SourceOIDC)
+	a.IsTrue(identity.Subject == provider.server.URL+"#1001")
+	a.IsTrue(identity.Username == "alice")
+	a.IsTrue(identity.Fullname == "Alice Liu")
+	a.IsTrue(identity.Email == "alice@example.com")
+	a.IsTrue(len(identity.Groups) == 2)
+
+	// 授权码只能使用一次
+	_, err = client.Exchange(code, verifier, "nonce-1")
+	a.IsTrue(err != nil)
+}
+
+func TestOIDCClient_Exchange_Invalid(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var provider = newTestOIDCProvider(t)
+	var config = provider.config()
+	a.IsNil(config.Init())
+	var client = NewOIDCClient(config)
+
+	var exchange = func(nonce string) error {
+		verifier, _ := RandomString()
+		authURL, err := client.AuthCodeURL("state", "nonce", verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Exchange(provider.authorize(t, authURL), verifier, nonce)
+		return err
+	}
+
+	// PKCE校验码不正确
+	{
+		verifier, _ := RandomString()
+		authURL, err := client.AuthCodeURL("state", "nonce", verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Exchange(provider.authorize(t, authURL), verifier+"x", "nonce")
+		a.IsTrue(err != nil)
+	}
+
+	// nonce不正确
+	a.IsTrue(exchange("other") != nil)
+
+	for _, modify := range []func(claims map[string]any){
+		func(claims map[string]any) { claims["aud"] = "other" },
+		func(claims map[string]any) { claims["aud"] = []string{"edge-admin", "other"} },
+		func(claims map[string]any) { claims["iss"] = "https://evil.example.com" },
+		func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		func(claims map[string]any) { delete(claims, "sub") },
+	} {
+		provider.modifyClaims = modify
+		a.IsTrue(exchange("nonce") != nil)
+	}
+
+	// 正确的多个aud
+	provider.modifyClaims = func(claims map[string]any) {
+		claims["aud"] = []string{"edge-admin", "other"}
+		claims["azp"] = "edge-admin"
+	}
+	a.IsNil(exchange("nonce"))
+}
+
+func TestOIDCClient_VerifyIDToken_Signature(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var provider = newTestOIDCProvider(t)
+	var config = provider.config()
+	a.IsNil(config.Init())
+	var client = NewOIDCClient(config)
+
+	var claims = map[string]any{
+		"iss":   provider.server.URL,
+		"sub":   "1001",
+		"aud":   "edge-admin",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "nonce",
+	}
+	var token = provider.sign(t, claims)
+	_, err := client.VerifyIDToken(token, "nonce")
+	a.IsNil(err)
+
+	// 篡改声明
+	var parts = strings.Split(token, ".")
+	claims["sub"] = "1002"
+	claimsJSON, _ := json.Marshal(claims)
+	_, err = client.VerifyIDToken(parts[0]+"."+base64.RawURLEncoding.EncodeToString(claimsJSON)+"."+parts[2], "nonce")
+	a.IsTrue(err != nil)
+
+	// 不允许none算法
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "none"})
+	_, err = client.VerifyIDToken(base64.RawURLEncoding.EncodeToString(headerJSON)+"."+base64.RawURLEncoding.EncodeToString(claimsJSON)+".", "nonce")
+	a.IsTrue(err != nil)
+}
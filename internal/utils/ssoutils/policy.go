@@ -0,0 +1,217 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils
+
+import (
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"net/url"
+	"strings"
+)
+
+const (
+	SourceOIDC = "oidc"
+	SourceLDAP = "ldap"
+)
+
+// Policy 管理员单点登录策略
+type Policy struct {
+	OIDC *OIDCConfig `yaml:"oidc" json:"oidc"` // OpenID Connect
+	LDAP *LDAPConfig `yaml:"ldap" json:"ldap"` // LDAP
+
+	AutoCreate     bool           `yaml:"autoCreate" json:"autoCreate"`         // 首次登录时是否自动创建管理员
+	SyncRoles      bool           `yaml:"syncRoles" json:"syncRoles"`           // 每次登录时是否按照映射规则同步角色
+	DenyUnmapped   bool           `yaml:"denyUnmapped" json:"denyUnmapped"`     // 没有匹配任何映射规则时是否拒绝登录
+	DefaultRoleIds []int64        `yaml:"defaultRoleIds" json:"defaultRoleIds"` // 默认角色
+	RoleMappings   []*RoleMapping `yaml:"roleMappings" json:"roleMappings"`     // 角色映射规则
+}
+
+// DefaultPolicy 默认的单点登录策略
+func DefaultPolicy() *Policy {
+	return &Policy{
+		OIDC:         DefaultOIDCConfig(),
+		LDAP:         DefaultLDAPConfig(),
+		AutoCreate:   false,
+		SyncRoles:    true,
+		DenyUnmapped: true,
+	}
+}
+
+// Init 初始化
+func (this *Policy) Init() error {
+	if this.OIDC == nil {
+		this.OIDC = DefaultOIDCConfig()
+	}
+	err := this.OIDC.Init()
+	if err != nil {
+		return err
+	}
+
+	if this.LDAP == nil {
+		this.LDAP = DefaultLDAPConfig()
+	}
+	err = this.LDAP.Init()
+	if err != nil {
+		return err
+	}
+
+	for _, mapping := range this.RoleMappings {
+		if mapping == nil || len(mapping.Value) == 0 {
+			return errors.New("role mapping value should not be empty")
+		}
+	}
+	return nil
+}
+
+// MapRoles 根据映射规则计算身份对应的角色
+// 如果没有匹配任何规则，则 matched 为false
+func (this *Policy) MapRoles(identity *Identity) (grant *RoleGrant, matched bool) {
+	grant = &RoleGrant{}
+	grant.addRoleIds(this.DefaultRoleIds)
+	for _, mapping := range this.RoleMappings {
+		if mapping == nil || !mapping.Match(identity) {
+			continue
+		}
+		matched = true
+		grant.addRoleIds(mapping.RoleIds)
+		if mapping.IsSuper {
+			grant.IsSuper = true
+		}
+	}
+	return
+}
+
+// OIDCConfig OpenID Connect配置
+type OIDCConfig struct {
+	IsOn          bool     `yaml:"isOn" json:"isOn"`                   // 是否启用
+	Name          string   `yaml:"name" json:"name"`                   // 显示在登录界面上的名称
+	Issuer        string   `yaml:"issuer" json:"issuer"`               // 签发者，用来自动发现其他地址
+	ClientId      string   `yaml:"clientId" json:"clientId"`           // 客户端ID
+	ClientSecret  string   `yaml:"clientSecret" json:"clientSecret"`   // 客户端密钥，公开客户端可以为空
+	RedirectURL   string   `yaml:"redirectURL" json:"redirectURL"`     // 回调地址
+	Scopes        []string `yaml:"scopes" json:"scopes"`               // 申请的权限范围
+	UsernameClaim string   `yaml:"usernameClaim" json:"usernameClaim"` // 用户名对应的声明
+	FullnameClaim string   `yaml:"fullnameClaim" json:"fullnameClaim"` // 全名对应的声明
+	GroupsClaim   string   `yaml:"groupsClaim" json:"groupsClaim"`     // 用户组对应的声明
+}
+
+// DefaultOIDCConfig 默认的OpenID Connect配置
+func DefaultOIDCConfig() *OIDCConfig {
+	return &OIDCConfig{
+		Scopes:        []string{"openid", "profile", "email"},
+		UsernameClaim: "preferred_username",
+		FullnameClaim: "name",
+		GroupsClaim:   "groups",
+	}
+}
+
+// Init 初始化
+func (this *OIDCConfig) Init() error {
+	if len(this.UsernameClaim) == 0 {
+		this.UsernameClaim = "preferred_username"
+	}
+	if len(this.FullnameClaim) == 0 {
+		this.FullnameClaim = "name"
+	}
+	if len(this.GroupsClaim) == 0 {
+		this.GroupsClaim = "groups"
+	}
+
+	var hasOpenId = false
+	for _, scope := range this.Scopes {
+		if scope == "openid" {
+			hasOpenId = true
+			break
+		}
+	}
+	if !hasOpenId {
+		this.Scopes = append([]string{"openid"}, this.Scopes...)
+	}
+
+	if !this.IsOn {
+		return nil
+	}
+	if len(this.ClientId) == 0 {
+		return errors.New("oidc: 'clientId' should not be empty")
+	}
+	issuerURL, err := url.Parse(this.Issuer)
+	if err != nil || len(issuerURL.Host) == 0 || (issuerURL.Scheme != "https" && issuerURL.Scheme != "http") {
+		return errors.New("oidc: invalid 'issuer'")
+	}
+	redirectURL, err := url.Parse(this.RedirectURL)
+	if err != nil || len(redirectURL.Host) == 0 {
+		return errors.New("oidc: invalid 'redirectURL'")
+	}
+	this.Issuer = strings.TrimSuffix(this.Issuer, "/")
+	return nil
+}
+
+// LDAPConfig LDAP配置
+type LDAPConfig struct {
+	IsOn               bool   `yaml:"isOn" json:"isOn"`                             // 是否启用
+	Addr               string `yaml:"addr" json:"addr"`                             // 服务地址，比如 ldap://127.0.0.1:389、ldaps://ldap.example.com
+	StartTLS           bool   `yaml:"startTLS" json:"startTLS"`                     // 是否使用StartTLS
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify"` // 是否跳过证书校验
+	Timeout            int    `yaml:"timeout" json:"timeout"`                       // 超时时间（秒）
+	BindDN             string `yaml:"bindDN" json:"bindDN"`                         // 用来查询用户的账号，为空表示匿名查询
+	BindPassword       string `yaml:"bindPassword" json:"bindPassword"`             // 用来查询用户的账号密码
+	BaseDN             string `yaml:"baseDN" json:"baseDN"`                         // 查询用户的起始DN
+	UserFilter         string `yaml:"userFilter" json:"userFilter"`                 // 查询用户的过滤器，%s 会被替换为用户名
+	UsernameAttr       string `yaml:"usernameAttr" json:"usernameAttr"`             // 用户名属性
+	FullnameAttr       string `yaml:"fullnameAttr" json:"fullnameAttr"`             // 全名属性
+	EmailAttr          string `yaml:"emailAttr" json:"emailAttr"`                   // 邮箱属性
+	GroupAttr          string `yaml:"groupAttr" json:"groupAttr"`                   // 用户组属性
+}
+
+// DefaultLDAPConfig 默认的LDAP配置
+func DefaultLDAPConfig() *LDAPConfig {
+	return &LDAPConfig{
+		Timeout:      10,
+		UserFilter:   "(uid=%s)",
+		UsernameAttr: "uid",
+		FullnameAttr: "cn",
+		EmailAttr:    "mail",
+		GroupAttr:    "memberOf",
+	}
+}
+
+// Init 初始化
+func (this *LDAPConfig) Init() error {
+	if this.Timeout <= 0 {
+		this.Timeout = 10
+	}
+	if len(this.UserFilter) == 0 {
+		this.UserFilter = "(uid=%s)"
+	}
+	if len(this.UsernameAttr) == 0 {
+		this.UsernameAttr = "uid"
+	}
+	if len(this.FullnameAttr) == 0 {
+		this.FullnameAttr = "cn"
+	}
+	if len(this.GroupAttr) == 0 {
+		this.GroupAttr = "memberOf"
+	}
+
+	if !this.IsOn {
+		return nil
+	}
+	addrURL, err := url.Parse(this.Addr)
+	if err != nil || len(addrURL.Host) == 0 || (addrURL.Scheme != "ldap" && addrURL.Scheme != "ldaps") {
+		return errors.New("ldap: invalid 'addr'")
+	}
+	if addrURL.Scheme == "ldaps" && this.StartTLS {
+		return errors.New("ldap: 'startTLS' can not be used with 'ldaps'")
+	}
+	if len(this.BaseDN) == 0 {
+		return errors.New("ldap: 'baseDN' should not be empty")
+	}
+	if strings.Count(this.UserFilter, "%s") == 0 {
+		return errors.New("ldap: 'userFilter' should contain '%s'")
+	}
+	_, err = ldap.CompileFilter(composeLDAPFilter(this.UserFilter, "user"))
+	if err != nil {
+		return err
+	}
+	return nil
+}
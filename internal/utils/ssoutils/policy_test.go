@@ -0,0 +1,104 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ssoutils_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ssoutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestPolicy_Init(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		var policy = ssoutils.DefaultPolicy()
+		a.IsNil(policy.Init())
+	}
+
+	{
+		var policy = &ssoutils.Policy{}
+		a.IsNil(policy.Init())
+		a.IsTrue(policy.OIDC != nil && policy.LDAP != nil)
+		a.IsTrue(policy.OIDC.Scopes[0] == "openid")
+	}
+
+	{
+		var policy = ssoutils.DefaultPolicy()
+		policy.OIDC.IsOn = true
+		a.IsTrue(policy.Init() != nil)
+
+		policy.OIDC.Issuer = "https://idp.example.com/"
+		policy.OIDC.ClientId = "edge-admin"
+		policy.OIDC.RedirectURL = "https://admin.example.com/sso/oidc/callback"
+		a.IsNil(policy.Init())
+		a.IsTrue(policy.OIDC.Issuer == "https://idp.example.com")
+	}
+
+	{
+		var policy = ssoutils.DefaultPolicy()
+		policy.LDAP.IsOn = true
+		policy.LDAP.Addr = "ldap://127.0.0.1:389"
+		policy.LDAP.BaseDN = "dc=example,dc=com"
+		a.IsNil(policy.Init())
+
+		policy.LDAP.UserFilter = "(uid=alice)"
+		a.IsTrue(policy.Init() != nil)
+
+		policy.LDAP.UserFilter = "(&(uid=%s)"
+		a.IsTrue(policy.Init() != nil)
+
+		policy.LDAP.UserFilter = "(uid=%s)"
+		policy.LDAP.Addr = "http://127.0.0.1"
+		a.IsTrue(policy.Init() != nil)
+	}
+
+	{
+		var policy = ssoutils.DefaultPolicy()
+		policy.RoleMappings = []*ssoutils.RoleMapping{{RoleIds: []int64{1}}}
+		a.IsTrue(policy.Init() != nil)
+	}
+}
+
+func TestPolicy_MapRoles(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var policy = ssoutils.DefaultPolicy()
+	policy.DefaultRoleIds = []int64{1}
+	policy.RoleMappings = []*ssoutils.RoleMapping{
+		{Value: "ops", RoleIds: []int64{2, 3}},
+		{Value: "cn=dba,ou=groups,dc=example,dc=com", RoleIds: []int64{3, 4}},
+		{Claim: "department", Value: "Security", RoleIds: []int64{5}},
+		{Claim: "admin", Value: "true", IsSuper: true},
+	}
+	a.IsNil(policy.Init())
+
+	{
+		grant, matched := policy.MapRoles(&ssoutils.Identity{})
+		a.IsFalse(matched)
+		a.IsFalse(grant.IsSuper)
+		a.IsTrue(len(grant.RoleIds) == 1)
+	}
+
+	{
+		grant, matched := policy.MapRoles(&ssoutils.Identity{
+			Groups: []string{"CN=Ops,OU=Groups,DC=example,DC=com", "cn=dba,ou=groups,dc=example,dc=com"},
+		})
+		a.IsTrue(matched)
+		a.IsFalse(grant.IsSuper)
+		t.Log(grant.RoleIds)
+		a.IsTrue(len(grant.RoleIds) == 4)
+	}
+
+	{
+		grant, matched := policy.MapRoles(&ssoutils.Identity{
+			Claims: map[string]any{
+				"department": []any{"sales", "security"},
+				"admin":      true,
+			},
+		})
+		a.IsTrue(matched)
+		a.IsTrue(grant.IsSuper)
+		a.IsTrue(len(grant.RoleIds) == 2)
+	}
+}
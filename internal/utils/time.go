@@ -279,6 +279,23 @@ func LastDayInMonth(month string) (string, error) {
 	return month + timeutil.Format("t", time.Date(year, time.Month(monthInt), 1, 0, 0, 0, 0, time.Local)), nil
 }
 
+// RenewalRetryBackoff 证书续期失败后的重试间隔，从1小时开始随着失败次数指数增长，最长为1天
+func RenewalRetryBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	const minBackoff = 1 * time.Hour
+	const maxBackoff = 24 * time.Hour
+	var backoff = minBackoff
+	for i := 1; i < failures; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
 // FixMonthMaxDay 修正日期最大值
 func FixMonthMaxDay(day string) (string, error) {
 	if !regexputils.YYYYMMDD.MatchString(day) {
@@ -0,0 +1,72 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	flagUserPresent      byte = 0x01
+	flagUserVerified     byte = 0x04
+	flagAttestedCredData byte = 0x40
+)
+
+// AuthenticatorData 认证器数据
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// 以下仅在注册时存在
+	AAGUID       []byte
+	CredentialId []byte
+	PublicKey    []byte // COSE格式
+}
+
+// UserPresent 用户是否在场
+func (this *AuthenticatorData) UserPresent() bool {
+	return this.Flags&flagUserPresent > 0
+}
+
+// UserVerified 用户是否已验证（PIN、指纹等）
+func (this *AuthenticatorData) UserVerified() bool {
+	return this.Flags&flagUserVerified > 0
+}
+
+// ParseAuthenticatorData 分析认证器数据
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	var result = &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if result.Flags&flagAttestedCredData == 0 {
+		return result, nil
+	}
+
+	var rest = data[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data is too short")
+	}
+	result.AAGUID = rest[:16]
+	var credentialIdLength = int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if credentialIdLength == 0 || len(rest) < credentialIdLength {
+		return nil, errors.New("invalid credential id")
+	}
+	result.CredentialId = rest[:credentialIdLength]
+	rest = rest[credentialIdLength:]
+
+	// 公钥之后可能还有扩展数据，所以需要解码才能知道公钥的长度
+	_, extensions, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, errors.New("invalid credential public key: " + err.Error())
+	}
+	result.PublicKey = rest[:len(rest)-len(extensions)]
+	return result, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// WebAuthn只使用CTAP2规范的CBOR编码，所以这里只实现了其中用到的部分：
+// 整数、字节串、文本串、数组、映射、标签和简单值，不支持不定长度编码
+
+const maxCBORDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// 解码一个CBOR数据项，并返回剩余的数据
+// 整数解码为int64，字节串解码为[]byte，文本串解码为string，数组解码为[]any，映射解码为map[any]any
+func decodeCBOR(data []byte) (value any, rest []byte, err error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (value any, rest []byte, err error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("cbor: data is nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+
+	var major = data[0] >> 5
+	var info = data[0] & 0x1f
+	data = data[1:]
+
+	// 简单值和浮点数
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		case 26:
+			if len(data) < 4 {
+				return nil, nil, errCBORTruncated
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+		case 27:
+			if len(data) < 8 {
+				return nil, nil, errCBORTruncated
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+		}
+		return nil, nil, errors.New("cbor: unsupported simple value")
+	}
+
+	length, data, err := decodeCBORLength(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if length > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return int64(length), data, nil
+	case 1:
+		if length > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(length), data, nil
+	case 2, 3:
+		if uint64(len(data)) < length {
+			return nil, nil, errCBORTruncated
+		}
+		var b = data[:length]
+		if major == 3 {
+			return string(b), data[length:], nil
+		}
+		return append([]byte{}, b...), data[length:], nil
+	case 4:
+		if length > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		var items = make([]any, 0, length)
+		for i := uint64(0); i < length; i++ {
+			var item any
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if length > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		var m = map[any]any{}
+		for i := uint64(0); i < length; i++ {
+			var key, item any
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("cbor: unsupported map key type")
+			}
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = item
+		}
+		return m, data, nil
+	case 6:
+		// 忽略标签，直接返回标签的内容
+		return decodeCBORItem(data, depth+1)
+	}
+	return nil, nil, errors.New("cbor: unsupported major type")
+}
+
+func decodeCBORLength(info byte, data []byte) (length uint64, rest []byte, err error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errCBORTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	}
+	return 0, nil, errors.New("cbor: indefinite length is not supported")
+}
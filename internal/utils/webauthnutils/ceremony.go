@@ -0,0 +1,204 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	ceremonyTypeCreate = "webauthn.create"
+	ceremonyTypeGet    = "webauthn.get"
+)
+
+// NewChallenge 生成随机的挑战值，使用base64url编码
+func NewChallenge() (string, error) {
+	var b = make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64URL(b), nil
+}
+
+// EncodeBase64URL 使用不带填充的base64url编码
+func EncodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBase64URL 解码base64url，兼容带填充的格式
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(bytes.TrimRight([]byte(s), "=")))
+}
+
+// ClientData 客户端数据
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ParseClientDataChallenge 从客户端数据中读取挑战值，用来查找对应的挑战记录
+// 读取之后仍然需要调用 VerifyRegistration() 或者 VerifyAssertion() 完整校验
+func ParseClientDataChallenge(clientDataJSON []byte) (string, error) {
+	var clientData = &ClientData{}
+	err := json.Unmarshal(clientDataJSON, clientData)
+	if err != nil {
+		return "", errors.New("invalid client data: " + err.Error())
+	}
+	return clientData.Challenge, nil
+}
+
+// RelyingParty 依赖方（即当前网站）
+type RelyingParty struct {
+	Id      string   // 域名，比如 example.com
+	Origins []string // 允许的来源，比如 https://admin.example.com
+}
+
+// RegistrationResult 注册结果
+type RegistrationResult struct {
+	CredentialId []byte
+	PublicKey    []byte // COSE格式
+	Alg          int
+	SignCount    uint32
+	AAGUID       []byte
+}
+
+// VerifyRegistration 校验注册数据
+// 只校验客户端数据和认证器数据，不校验认证器的证明（相当于 attestation=none）
+func VerifyRegistration(rp *RelyingParty, challenge string, clientDataJSON []byte, attestationObject []byte, requireUserVerification bool) (*RegistrationResult, error) {
+	_, err := verifyClientData(rp, ceremonyTypeCreate, challenge, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, errors.New("invalid attestation object: " + err.Error())
+	}
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	authDataBytes, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("invalid attestation object: missing 'authData'")
+	}
+
+	authData, err := ParseAuthenticatorData(authDataBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyAuthenticatorData(rp, authData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+	if len(authData.CredentialId) == 0 || len(authData.PublicKey) == 0 {
+		return nil, errors.New("missing attested credential data")
+	}
+
+	_, alg, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrationResult{
+		CredentialId: authData.CredentialId,
+		PublicKey:    authData.PublicKey,
+		Alg:          alg,
+		SignCount:    authData.SignCount,
+		AAGUID:       authData.AAGUID,
+	}, nil
+}
+
+// VerifyAssertion 校验登录数据，返回新的签名计数
+// storedSignCount 为上次记录的签名计数，用来检测认证器是否被复制
+func VerifyAssertion(rp *RelyingParty, challenge string, clientDataJSON []byte, authenticatorData []byte, signature []byte, publicKey []byte, storedSignCount uint32, requireUserVerification bool) (signCount uint32, err error) {
+	_, err = verifyClientData(rp, ceremonyTypeGet, challenge, clientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	err = verifyAuthenticatorData(rp, authData, requireUserVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	key, alg, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var clientDataHash = sha256.Sum256(clientDataJSON)
+	var signedData = append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if !verifySignature(key, alg, signedData, signature) {
+		return 0, errors.New("invalid signature")
+	}
+
+	// 签名计数需要递增，有些认证器不支持计数，始终为0
+	if (authData.SignCount > 0 || storedSignCount > 0) && authData.SignCount <= storedSignCount {
+		return 0, errors.New("sign count is not increased, the authenticator may be cloned")
+	}
+
+	return authData.SignCount, nil
+}
+
+// 校验客户端数据
+func verifyClientData(rp *RelyingParty, ceremonyType string, challenge string, clientDataJSON []byte) (*ClientData, error) {
+	if rp == nil || len(rp.Id) == 0 {
+		return nil, errors.New("invalid relying party")
+	}
+
+	var clientData = &ClientData{}
+	err := json.Unmarshal(clientDataJSON, clientData)
+	if err != nil {
+		return nil, errors.New("invalid client data: " + err.Error())
+	}
+	if clientData.Type != ceremonyType {
+		return nil, errors.New("invalid client data type '" + clientData.Type + "'")
+	}
+	if len(challenge) == 0 || subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return nil, errors.New("challenge mismatch")
+	}
+	if clientData.CrossOrigin {
+		return nil, errors.New("cross origin request is not allowed")
+	}
+
+	var originOk = false
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			originOk = true
+			break
+		}
+	}
+	if !originOk {
+		return nil, errors.New("invalid origin '" + clientData.Origin + "'")
+	}
+	return clientData, nil
+}
+
+// 校验认证器数据
+func verifyAuthenticatorData(rp *RelyingParty, authData *AuthenticatorData, requireUserVerification bool) error {
+	var rpIdHash = sha256.Sum256([]byte(rp.Id))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rpIdHash[:]) != 1 {
+		return errors.New("relying party id mismatch")
+	}
+	if !authData.UserPresent() {
+		return errors.New("user is not present")
+	}
+	if requireUserVerification && !authData.UserVerified() {
+		return errors.New("user is not verified")
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/webauthnutils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+var testRP = &webauthnutils.RelyingParty{
+	Id:      "example.com",
+	Origins: []string{"https://admin.example.com"},
+}
+
+// 模拟的认证器
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{
+		key:          key,
+		credentialId: []byte("test-credential-id"),
+	}
+}
+
+func (this *testAuthenticator) coseKey() []byte {
+	var x = make([]byte, 32)
+	var y = make([]byte, 32)
+	this.key.X.FillBytes(x)
+	this.key.Y.FillBytes(y)
+
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	var b = []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	b = append(b, x...)
+	b = append(b, 0x22, 0x58, 0x20)
+	b = append(b, y...)
+	return b
+}
+
+func (this *testAuthenticator) authData(rpId string, flags byte, withCredential bool) []byte {
+	var rpIdHash = sha256.Sum256([]byte(rpId))
+	var b = append([]byte{}, rpIdHash[:]...)
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, this.signCount)
+	if withCredential {
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(this.credentialId)))
+		b = append(b, this.credentialId...)
+		b = append(b, this.coseKey()...)
+	}
+	return b
+}
+
+func (this *testAuthenticator) attestationObject(authData []byte) []byte {
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	var b = []byte{0xa3}
+	b = append(b, 0x63)
+	b = append(b, "fmt"...)
+	b = append(b, 0x64)
+	b = append(b, "none"...)
+	b = append(b, 0x67)
+	b = append(b, "attStmt"...)
+	b = append(b, 0xa0)
+	b = append(b, 0x68)
+	b = append(b, "authData"...)
+	b = append(b, 0x58, byte(len(authData)))
+	b = append(b, authData...)
+	return b
+}
+
+func (this *testAuthenticator) sign(t *testing.T, authData []byte, clientDataJSON []byte) []byte {
+	var clientDataHash = sha256.Sum256(clientDataJSON)
+	var sum = sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, this.key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func testClientData(ceremonyType string, challenge string, origin string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":      ceremonyType,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return data
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var authenticator = newTestAuthenticator(t)
+	challenge, err := webauthnutils.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attestationObject = authenticator.attestationObject(authenticator.authData("example.com", 0x45, true))
+
+	// 正常注册
+	{
+		result, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", challenge, "https://admin.example.com"), attestationObject, true)
+		a.IsNil(err)
+		if result != nil {
+			a.IsTrue(string(result.CredentialId) == "test-credential-id")
+			a.IsTrue(result.Alg == webauthnutils.AlgES256)
+		}
+	}
+
+	// 挑战值不一致
+	{
+		_, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", "other", "https://admin.example.com"), attestationObject, true)
+		a.IsTrue(err != nil)
+	}
+
+	// 来源不一致
+	{
+		_, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", challenge, "https://evil.example.net"), attestationObject, true)
+		a.IsTrue(err != nil)
+	}
+
+	// 类型不一致
+	{
+		_, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.get", challenge, "https://admin.example.com"), attestationObject, true)
+		a.IsTrue(err != nil)
+	}
+
+	// RP ID不一致
+	{
+		var otherObject = authenticator.attestationObject(authenticator.authData("example.net", 0x45, true))
+		_, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", challenge, "https://admin.example.com"), otherObject, true)
+		a.IsTrue(err != nil)
+	}
+
+	// 没有验证用户
+	{
+		var otherObject = authenticator.attestationObject(authenticator.authData("example.com", 0x41, true))
+		_, err := webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", challenge, "https://admin.example.com"), otherObject, true)
+		a.IsTrue(err != nil)
+
+		_, err = webauthnutils.VerifyRegistration(testRP, challenge, testClientData("webauthn.create", challenge, "https://admin.example.com"), otherObject, false)
+		a.IsNil(err)
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var authenticator = newTestAuthenticator(t)
+	var publicKey = authenticator.coseKey()
+	challenge, err := webauthnutils.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator.signCount = 5
+	var authData = authenticator.authData("example.com", 0x05, false)
+	var clientDataJSON = testClientData("webauthn.get", challenge, "https://admin.example.com")
+	var signature = authenticator.sign(t, authData, clientDataJSON)
+
+	// 正常登录
+	{
+		signCount, err := webauthnutils.VerifyAssertion(testRP, challenge, clientDataJSON, authData, signature, publicKey, 4, true)
+		a.IsNil(err)
+		a.IsTrue(signCount == 5)
+	}
+
+	// 签名计数没有增加
+	{
+		_, err := webauthnutils.VerifyAssertion(testRP, challenge, clientDataJSON, authData, signature, publicKey, 5, true)
+		a.IsTrue(err != nil)
+	}
+
+	// 签名错误
+	{
+		var badSignature = authenticator.sign(t, authData, testClientData("webauthn.get", "other", "https://admin.example.com"))
+		_, err := webauthnutils.VerifyAssertion(testRP, challenge, clientDataJSON, authData, badSignature, publicKey, 0, true)
+		a.IsTrue(err != nil)
+	}
+
+	// 其他认证器的公钥
+	{
+		var otherKey = newTestAuthenticator(t).coseKey()
+		_, err := webauthnutils.VerifyAssertion(testRP, challenge, clientDataJSON, authData, signature, otherKey, 0, true)
+		a.IsTrue(err != nil)
+	}
+}
+
+func TestParseAuthenticatorData_Short(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	_, err := webauthnutils.ParseAuthenticatorData([]byte{1, 2, 3})
+	a.IsTrue(err != nil)
+
+	var authenticator = newTestAuthenticator(t)
+	var authData = authenticator.authData("example.com", 0x45, true)
+	_, err = webauthnutils.ParseAuthenticatorData(authData[:len(authData)-10])
+	a.IsTrue(err != nil)
+}
+
+func TestOptions(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	creationJSON, err := webauthnutils.CreationOptions("example.com", "GoEdge", []byte{1, 2, 3}, "admin", "Admin", "abc", [][]byte{[]byte("id1")})
+	a.IsNil(err)
+	t.Log(string(creationJSON))
+
+	requestJSON, err := webauthnutils.RequestOptions("example.com", "abc", [][]byte{[]byte("id1")})
+	a.IsNil(err)
+	t.Log(string(requestJSON))
+}
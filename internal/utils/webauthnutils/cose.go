@@ -0,0 +1,128 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE算法
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgs 支持的公钥算法，按照优先级排列
+func SupportedAlgs() []int {
+	return []int{AlgES256, AlgEdDSA, AlgRS256}
+}
+
+// COSE密钥参数
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+	coseKeyCrv = -1 // EC2和OKP
+	coseKeyX   = -2 // EC2和OKP
+	coseKeyY   = -3 // EC2
+	coseKeyN   = -1 // RSA
+	coseKeyE   = -2 // RSA
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// ParsePublicKey 分析COSE格式的公钥
+func ParsePublicKey(coseKey []byte) (publicKey crypto.PublicKey, alg int, err error) {
+	value, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+
+	kty, _ := m[int64(coseKeyKty)].(int64)
+	algValue, _ := m[int64(coseKeyAlg)].(int64)
+	alg = int(algValue)
+
+	switch alg {
+	case AlgES256:
+		crv, _ := m[int64(coseKeyCrv)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		y, _ := m[int64(coseKeyY)].([]byte)
+		if kty != coseKtyEC2 || crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid ES256 key")
+		}
+		var key = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("invalid ES256 key: point is not on curve")
+		}
+		return key, alg, nil
+	case AlgEdDSA:
+		crv, _ := m[int64(coseKeyCrv)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		if kty != coseKtyOKP || crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid EdDSA key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case AlgRS256:
+		n, _ := m[int64(coseKeyN)].([]byte)
+		e, _ := m[int64(coseKeyE)].([]byte)
+		if kty != coseKtyRSA || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RS256 key")
+		}
+		var key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, 0, errors.New("invalid RS256 key: key size is too small")
+		}
+		return key, alg, nil
+	}
+	return nil, 0, errors.New("unsupported COSE algorithm")
+}
+
+// 校验签名
+func verifySignature(publicKey crypto.PublicKey, alg int, data []byte, signature []byte) bool {
+	switch alg {
+	case AlgES256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		var sum = sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, sum[:], signature)
+	case AlgEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(key, data, signature)
+	case AlgRS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		var sum = sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthnutils
+
+import "encoding/json"
+
+const defaultTimeout = 120_000 // 毫秒
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+// CreationOptions 生成注册时传给浏览器 navigator.credentials.create() 的参数
+// userHandle 为用户的唯一标识，不能包含用户名等个人信息
+func CreationOptions(rpId string, rpName string, userHandle []byte, username string, displayName string, challenge string, excludeCredentialIds [][]byte) ([]byte, error) {
+	var pubKeyCredParams = []map[string]any{}
+	for _, alg := range SupportedAlgs() {
+		pubKeyCredParams = append(pubKeyCredParams, map[string]any{
+			"type": "public-key",
+			"alg":  alg,
+		})
+	}
+
+	var excludeCredentials = []credentialDescriptor{}
+	for _, credentialId := range excludeCredentialIds {
+		excludeCredentials = append(excludeCredentials, credentialDescriptor{
+			Type: "public-key",
+			Id:   EncodeBase64URL(credentialId),
+		})
+	}
+
+	return json.Marshal(map[string]any{
+		"challenge": challenge,
+		"rp": map[string]any{
+			"id":   rpId,
+			"name": rpName,
+		},
+		"user": map[string]any{
+			"id":          EncodeBase64URL(userHandle),
+			"name":        username,
+			"displayName": displayName,
+		},
+		"pubKeyCredParams":   pubKeyCredParams,
+		"timeout":            defaultTimeout,
+		"excludeCredentials": excludeCredentials,
+		"authenticatorSelection": map[string]any{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+		"attestation": "none",
+	})
+}
+
+// RequestOptions 生成登录时传给浏览器 navigator.credentials.get() 的参数
+func RequestOptions(rpId string, challenge string, allowCredentialIds [][]byte) ([]byte, error) {
+	var allowCredentials = []credentialDescriptor{}
+	for _, credentialId := range allowCredentialIds {
+		allowCredentials = append(allowCredentials, credentialDescriptor{
+			Type: "public-key",
+			Id:   EncodeBase64URL(credentialId),
+		})
+	}
+
+	return json.Marshal(map[string]any{
+		"challenge":        challenge,
+		"rpId":             rpId,
+		"timeout":          defaultTimeout,
+		"allowCredentials": allowCredentials,
+		"userVerification": "preferred",
+	})
+}